@@ -21,9 +21,15 @@ type LogAgent struct {
 	Database  string
 	*zap.SugaredLogger
 
-	database operator.Database
-	pipeline *pipeline.Pipeline
-	running  bool
+	// Once will cause input operators to read all of the currently
+	// available entries a single time, and then finish
+	Once bool
+
+	database         operator.Database
+	pipeline         *pipeline.Pipeline
+	running          bool
+	finished         chan struct{}
+	deliveryFailures int64
 }
 
 // Start will start the log monitoring process.
@@ -48,6 +54,7 @@ func (a *LogAgent) Start() error {
 		PluginRegistry: registry,
 		Logger:         a.SugaredLogger,
 		Database:       a.database,
+		Once:           a.Once,
 	}
 
 	pipeline, err := a.Config.Pipeline.BuildPipeline(buildContext)
@@ -56,16 +63,55 @@ func (a *LogAgent) Start() error {
 	}
 	a.pipeline = pipeline
 
+	finishers, err := a.inputFinishers()
+	if err != nil {
+		return err
+	}
+
 	err = a.pipeline.Start()
 	if err != nil {
 		return errors.Wrap(err, "Start pipeline")
 	}
 
+	a.finished = make(chan struct{})
+	if a.Once {
+		go waitForFinishers(finishers, a.finished)
+	}
+
 	a.running = true
 	a.Info("Agent started")
 	return nil
 }
 
+// inputFinishers returns the input operators of the pipeline when running with --once.
+// An error is returned if any input operator can not finish on its own.
+func (a *LogAgent) inputFinishers() ([]operator.Finisher, error) {
+	if !a.Once {
+		return nil, nil
+	}
+
+	return operator.InputFinishers(a.pipeline.Operators(), "remove the operator from the pipeline, or run the agent without --once")
+}
+
+func waitForFinishers(finishers []operator.Finisher, finished chan struct{}) {
+	for _, finisher := range finishers {
+		<-finisher.Finished()
+	}
+	close(finished)
+}
+
+// Finished returns a channel that is closed once every input operator has finished.
+// The channel is only closed when the agent is started with Once.
+func (a *LogAgent) Finished() <-chan struct{} {
+	return a.finished
+}
+
+// DeliveryFailures returns the number of entries that could not be delivered
+// by the operators of the pipeline the last time the agent was stopped.
+func (a *LogAgent) DeliveryFailures() int64 {
+	return a.deliveryFailures
+}
+
 // Stop will stop the log monitoring process.
 func (a *LogAgent) Stop() {
 	if !a.running {
@@ -73,6 +119,12 @@ func (a *LogAgent) Stop() {
 	}
 
 	a.pipeline.Stop()
+	a.deliveryFailures = 0
+	for _, op := range a.pipeline.Operators() {
+		if reporter, ok := op.(operator.FailureReporter); ok {
+			a.deliveryFailures += reporter.DeliveryFailures()
+		}
+	}
 	a.pipeline = nil
 
 	a.database.Close()
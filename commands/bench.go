@@ -0,0 +1,347 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/observiq/carbon/agent"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/pipeline"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BenchFlags are the flags that can be supplied when running the bench command
+type BenchFlags struct {
+	*RootFlags
+	Duration time.Duration
+}
+
+// NewBenchCommand creates a command for measuring the throughput of the pipeline
+func NewBenchCommand(rootFlags *RootFlags) *cobra.Command {
+	benchFlags := &BenchFlags{RootFlags: rootFlags}
+
+	bench := &cobra.Command{
+		Use:   "bench",
+		Args:  cobra.NoArgs,
+		Short: "Run the pipeline and report its throughput and the latency of each operator",
+		Long: "Run the pipeline until all of its inputs have finished or the duration has passed, and report its throughput " +
+			"and the latency of each operator. Offsets are not read or saved, so that each run starts from the same state.",
+		Run: func(command *cobra.Command, args []string) { runBenchCommand(command, benchFlags) },
+	}
+
+	bench.Flags().DurationVar(&benchFlags.Duration, "duration", 0, "stop after this duration, even if the inputs have not finished")
+	return bench
+}
+
+func runBenchCommand(command *cobra.Command, flags *BenchFlags) {
+	var logger *zap.SugaredLogger
+	if flags.Debug {
+		logger = newDefaultLoggerAt(zapcore.DebugLevel, flags.LogFile)
+	} else {
+		logger = newDefaultLoggerAt(zapcore.InfoLevel, flags.LogFile)
+	}
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	cfg, err := agent.NewConfigFromGlobs(flags.ConfigFiles)
+	if err != nil {
+		logger.Errorw("Failed to read configs from globs", zap.Any("error", err), zap.Any("globs", flags.ConfigFiles))
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(command.Context())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	registry, err := operator.NewPluginRegistry(flags.PluginDir)
+	if err != nil {
+		logger.Errorw("Failed to load plugin registry", zap.Any("error", err))
+	}
+
+	buildContext := operator.BuildContext{
+		PluginRegistry: registry,
+		Logger:         logger,
+		Database:       operator.NewStubDatabase(),
+	}
+
+	report, err := runBench(ctx, cfg.Pipeline, buildContext, flags.Duration)
+	if err != nil {
+		logger.Errorw("Failed to run benchmark", zap.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := report.write(stdout); err != nil {
+		logger.Errorw("Failed to write benchmark report", zap.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// runBench will run a pipeline until all of its inputs have finished, the duration has passed, or
+// the context is done, and return the throughput and latencies it was measured with. Without a
+// duration, the inputs are built to run once, the same way as with --once, so that they finish.
+func runBench(ctx context.Context, cfg pipeline.Config, buildContext operator.BuildContext, duration time.Duration) (*benchReport, error) {
+	buildContext.Once = duration == 0
+	operators, err := cfg.BuildOperators(buildContext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each operator is wrapped before the operators are connected, so that the entries written
+	// to it by other operators are measured
+	instrumented := make([]*instrumentedOperator, 0, len(operators))
+	wrapped := make([]operator.Operator, 0, len(operators))
+	for _, op := range operators {
+		instrumentedOp := &instrumentedOperator{Operator: op}
+		instrumented = append(instrumented, instrumentedOp)
+		wrapped = append(wrapped, instrumentedOp)
+	}
+
+	pipeline, err := pipeline.NewPipeline(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a duration, the bench only ends once every input has finished
+	var finishers []operator.Finisher
+	if duration == 0 {
+		finishers, err = operator.InputFinishers(operators, "remove the operator from the pipeline, or run the bench with --duration")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	if err := pipeline.Start(); err != nil {
+		return nil, err
+	}
+
+	// The finished channels of the inputs are only created when they are started
+	var finished <-chan struct{}
+	if duration == 0 {
+		finished = waitForFinishers(finishers)
+	}
+
+	select {
+	case <-finished:
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	// Stopping the pipeline flushes any buffered entries, which is included in the elapsed time
+	pipeline.Stop()
+	report := &benchReport{Elapsed: time.Since(start)}
+
+	for _, op := range instrumented {
+		if reporter, ok := op.Operator.(operator.FailureReporter); ok {
+			report.DeliveryFailures += reporter.DeliveryFailures()
+		}
+		if !op.CanProcess() {
+			continue
+		}
+		if !op.CanOutput() {
+			report.Delivered += op.stats.count()
+		}
+		report.Operators = append(report.Operators, op)
+	}
+	return report, nil
+}
+
+// waitForFinishers returns a channel that is closed once every finisher has finished
+func waitForFinishers(finishers []operator.Finisher) <-chan struct{} {
+	finished := make(chan struct{})
+	go func() {
+		for _, finisher := range finishers {
+			<-finisher.Finished()
+		}
+		close(finished)
+	}()
+	return finished
+}
+
+// benchReport is the result of running a pipeline with the bench command
+type benchReport struct {
+	Elapsed          time.Duration
+	Delivered        int64
+	DeliveryFailures int64
+	Operators        []*instrumentedOperator
+}
+
+// write will write the report as a table of the operators that process entries
+func (r *benchReport) write(w io.Writer) error {
+	seconds := r.Elapsed.Seconds()
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Elapsed:\t%s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Delivered:\t%d entries (%.0f/s)\n", r.Delivered, float64(r.Delivered)/seconds)
+	fmt.Fprintf(tw, "Delivery failures:\t%d\n", r.DeliveryFailures)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATOR\tTYPE\tENTRIES\tENTRIES/S\tERRORS\tMEAN\tP50\tP99\tMAX")
+	for _, op := range r.Operators {
+		stats := &op.stats
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f\t%d\t%s\t%s\t%s\t%s\n",
+			op.ID(),
+			op.Type(),
+			stats.count(),
+			float64(stats.count())/seconds,
+			atomic.LoadInt64(&stats.errors),
+			formatLatency(stats.mean()),
+			formatLatency(stats.quantile(0.5)),
+			formatLatency(stats.quantile(0.99)),
+			formatLatency(time.Duration(atomic.LoadInt64(&stats.max))),
+		)
+	}
+	return tw.Flush()
+}
+
+// formatLatency will round latencies of a millisecond or more to microseconds, and latencies
+// of a second or more to milliseconds
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
+
+// instrumentedOperator measures the entries processed by an operator, and the time taken to
+// process them. The time includes the operators that the entries are written to synchronously.
+type instrumentedOperator struct {
+	operator.Operator
+	stats latencyStats
+}
+
+// Process will process an entry with the wrapped operator, and record the time it took
+func (o *instrumentedOperator) Process(ctx context.Context, e *entry.Entry) error {
+	start := time.Now()
+	err := o.Operator.Process(ctx, e)
+	o.stats.record(time.Since(start), err != nil)
+	return err
+}
+
+// latencyBuckets is the number of buckets of a latencyStats histogram. Each power of two is split
+// into four buckets, so quantiles are within 25% of the measured latencies.
+const latencyBuckets = 4 + 62*4
+
+// latencyStats is a histogram of latencies that can be recorded concurrently
+type latencyStats struct {
+	total   int64
+	errors  int64
+	sum     int64
+	max     int64
+	buckets [latencyBuckets]int64
+}
+
+func (s *latencyStats) record(d time.Duration, failed bool) {
+	ns := int64(d)
+	if ns < 0 {
+		ns = 0
+	}
+
+	atomic.AddInt64(&s.total, 1)
+	atomic.AddInt64(&s.sum, ns)
+	atomic.AddInt64(&s.buckets[latencyBucket(uint64(ns))], 1)
+	if failed {
+		atomic.AddInt64(&s.errors, 1)
+	}
+	for {
+		max := atomic.LoadInt64(&s.max)
+		if ns <= max || atomic.CompareAndSwapInt64(&s.max, max, ns) {
+			break
+		}
+	}
+}
+
+func (s *latencyStats) count() int64 {
+	return atomic.LoadInt64(&s.total)
+}
+
+func (s *latencyStats) mean() time.Duration {
+	count := s.count()
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.sum) / count)
+}
+
+// quantile will return the upper bound of the bucket that contains the quantile, or the
+// maximum latency if it is lower
+func (s *latencyStats) quantile(q float64) time.Duration {
+	count := s.count()
+	if count == 0 {
+		return 0
+	}
+
+	rank := int64(q*float64(count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i := range s.buckets {
+		seen += atomic.LoadInt64(&s.buckets[i])
+		if seen >= rank {
+			upper := latencyBucketUpperBound(i)
+			if max := uint64(atomic.LoadInt64(&s.max)); max < upper {
+				upper = max
+			}
+			return time.Duration(upper)
+		}
+	}
+	return time.Duration(atomic.LoadInt64(&s.max))
+}
+
+// latencyBucket returns the bucket of a latency in nanoseconds. Latencies below 4ns have a bucket
+// each, and larger latencies are bucketed by their three most significant bits.
+func latencyBucket(ns uint64) int {
+	if ns < 4 {
+		return int(ns)
+	}
+	shift := bits.Len64(ns) - 3
+	return shift*4 + int(ns>>uint(shift))
+}
+
+// latencyBucketUpperBound returns the largest latency in nanoseconds of a bucket
+func latencyBucketUpperBound(bucket int) uint64 {
+	if bucket < 4 {
+		return uint64(bucket)
+	}
+	shift := uint(bucket/4 - 1)
+	mantissa := uint64(bucket%4 + 4)
+	return (mantissa+1)<<shift - 1
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/errors"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/pipeline"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func newTestBenchPipeline(t *testing.T, config string) (pipeline.Config, operator.BuildContext) {
+	var cfg pipeline.Config
+	require.NoError(t, yaml.Unmarshal([]byte(config), &cfg))
+
+	buildContext := operator.BuildContext{
+		Logger:   zaptest.NewLogger(t).Sugar(),
+		Database: operator.NewStubDatabase(),
+	}
+	return cfg, buildContext
+}
+
+func TestRunBench(t *testing.T) {
+	config := `
+- type: generate_input
+  count: 1000
+  templated: true
+  entry:
+    record:
+      message: 'request {{ seq }} took {{ randInt 1 100 }}ms'
+- type: regex_parser
+  regex: '^request (?P<id>\d+) took (?P<latency>\d+)ms$'
+- type: drop_output
+`
+	cfg, buildContext := newTestBenchPipeline(t, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := runBench(ctx, cfg, buildContext, 0)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err(), "inputs did not finish")
+
+	require.Equal(t, int64(1000), report.Delivered)
+	require.Equal(t, int64(0), report.DeliveryFailures)
+	require.Len(t, report.Operators, 2)
+	require.Equal(t, "$.regex_parser", report.Operators[0].ID())
+	require.Equal(t, int64(1000), report.Operators[0].stats.count())
+	require.Equal(t, "$.drop_output", report.Operators[1].ID())
+	require.Equal(t, int64(1000), report.Operators[1].stats.count())
+
+	var out bytes.Buffer
+	require.NoError(t, report.write(&out))
+	require.Contains(t, out.String(), "Delivered:          1000 entries")
+	require.Contains(t, out.String(), "OPERATOR")
+	require.Contains(t, out.String(), "$.regex_parser")
+}
+
+func TestRunBenchFileInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carbon_bench")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	lines := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("log line %d", i))
+	}
+	path := filepath.Join(dir, "test.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+
+	config := fmt.Sprintf(`
+- type: file_input
+  include: [%s]
+  start_at: beginning
+- type: drop_output
+`, path)
+	cfg, buildContext := newTestBenchPipeline(t, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := runBench(ctx, cfg, buildContext, 0)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err(), "inputs did not finish")
+	require.Equal(t, int64(100), report.Delivered)
+}
+
+func TestRunBenchDuration(t *testing.T) {
+	config := `
+- type: generate_input
+  rate: 100
+  entry:
+    record: test
+- type: drop_output
+`
+	cfg, buildContext := newTestBenchPipeline(t, config)
+
+	start := time.Now()
+	report, err := runBench(context.Background(), cfg, buildContext, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, time.Since(start) < 5*time.Second)
+	require.True(t, report.Delivered > 0 && report.Delivered <= 12, "delivered %d entries", report.Delivered)
+}
+
+func TestRunBenchUnfinishedInput(t *testing.T) {
+	config := `
+- type: tcp_input
+  listen_address: 127.0.0.1:0
+- type: drop_output
+`
+	cfg, buildContext := newTestBenchPipeline(t, config)
+
+	_, err := runBench(context.Background(), cfg, buildContext, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "$.tcp_input")
+
+	agentErr, ok := err.(errors.AgentError)
+	require.True(t, ok)
+	require.Contains(t, agentErr.Suggestion, "--duration")
+}
+
+func TestLatencyStats(t *testing.T) {
+	for _, ns := range []uint64{0, 1, 3, 4, 7, 8, 9, 15, 16, 1000, 123456789, 1 << 40, 1<<63 + 12345, ^uint64(0)} {
+		bucket := latencyBucket(ns)
+		require.True(t, bucket < latencyBuckets, "bucket of %d", ns)
+		require.True(t, ns <= latencyBucketUpperBound(bucket), "upper bound of %d", ns)
+		if bucket > 0 {
+			require.True(t, ns > latencyBucketUpperBound(bucket-1), "lower bound of %d", ns)
+		}
+	}
+
+	stats := latencyStats{}
+	for i := 1; i <= 100; i++ {
+		stats.record(time.Duration(i)*time.Microsecond, i == 100)
+	}
+	require.Equal(t, int64(100), stats.count())
+	require.Equal(t, int64(1), stats.errors)
+	require.Equal(t, 50500*time.Nanosecond, stats.mean())
+	require.Equal(t, 100*time.Microsecond, time.Duration(stats.max))
+
+	// Quantiles are within 25% of the recorded latencies
+	p50 := stats.quantile(0.5)
+	require.True(t, p50 >= 50*time.Microsecond && p50 <= 63*time.Microsecond, "p50 is %s", p50)
+	p99 := stats.quantile(0.99)
+	require.True(t, p99 >= 99*time.Microsecond && p99 <= 100*time.Microsecond, "p99 is %s", p99)
+
+	require.Equal(t, time.Duration(0), (&latencyStats{}).quantile(0.5))
+}
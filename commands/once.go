@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/observiq/carbon/agent"
+	"go.uber.org/zap"
+)
+
+// Exit codes used when running with --once
+const (
+	exitSuccess          = 0
+	exitError            = 1
+	exitDeliveryFailures = 2
+)
+
+// runOnce will run the agent until all of its inputs have finished, and then stop the agent,
+// which flushes any buffered entries. It returns the exit code the process should use.
+func runOnce(ctx context.Context, agent *agent.LogAgent) int {
+	agent.Once = true
+	if err := agent.Start(); err != nil {
+		agent.Errorw("Failed to start carbon agent", zap.Any("error", err))
+		return exitError
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigChan)
+
+	code := exitSuccess
+	select {
+	case <-agent.Finished():
+		agent.Info("All inputs have finished. Stopping carbon agent")
+	case <-sigChan:
+		agent.Warn("Stopping carbon agent before all inputs have finished")
+		code = exitError
+	case <-ctx.Done():
+		agent.Warn("Stopping carbon agent before all inputs have finished")
+		code = exitError
+	}
+
+	agent.Stop()
+	if failures := agent.DeliveryFailures(); failures > 0 {
+		agent.Errorw("Failed to deliver entries", "failures", failures)
+		if code == exitSuccess {
+			code = exitDeliveryFailures
+		}
+	}
+	return code
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/agent"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestOnceAgent(t *testing.T, tempDir, pipeline string) *agent.LogAgent {
+	configPath := filepath.Join(tempDir, "config.yaml")
+	err := ioutil.WriteFile(configPath, []byte(pipeline), 0666)
+	require.NoError(t, err)
+
+	cfg, err := agent.NewConfigFromFile(configPath)
+	require.NoError(t, err)
+
+	return agent.NewLogAgent(cfg, zaptest.NewLogger(t).Sugar(), "", filepath.Join(tempDir, "logagent.db"))
+}
+
+func TestRunOnce(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	inputPath := filepath.Join(tempDir, "input.log")
+	outputPath := filepath.Join(tempDir, "output.json")
+	err = ioutil.WriteFile(inputPath, []byte("log1\nlog2\nlog3"), 0666)
+	require.NoError(t, err)
+
+	config := `
+pipeline:
+  - type: file_input
+    include: ['%s']
+    write_to: message
+    start_at: beginning
+    output: file_output
+  - type: generate_input
+    count: 2
+    entry:
+      record:
+        message: generated
+    output: file_output
+  - type: file_output
+    path: '%s'
+`
+	agent := newTestOnceAgent(t, tempDir, fmt.Sprintf(config, inputPath, outputPath))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Equal(t, exitSuccess, runOnce(ctx, agent))
+
+	actual, err := ioutil.ReadFile(outputPath)
+	require.NoError(t, err)
+	for _, message := range []string{"log1", "log2", "log3", "generated"} {
+		require.Contains(t, string(actual), fmt.Sprintf(`"message":"%s"`, message))
+	}
+}
+
+func TestRunOnceUnsupportedInput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	config := `
+pipeline:
+  - type: tcp_input
+    listen_address: '127.0.0.1:0'
+  - type: drop_output
+`
+	agent := newTestOnceAgent(t, tempDir, config)
+	require.Equal(t, exitError, runOnce(context.Background(), agent))
+}
+
+func TestRunOnceDeliveryFailures(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	// The format can not be rendered for string records, so every entry fails to be written
+	config := `
+pipeline:
+  - type: generate_input
+    count: 3
+    entry:
+      record: message
+  - type: file_output
+    path: '%s'
+    format: '{{ .Record.missing }}'
+`
+	agent := newTestOnceAgent(t, tempDir, fmt.Sprintf(config, filepath.Join(tempDir, "output.json")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.Equal(t, exitDeliveryFailures, runOnce(ctx, agent))
+	require.Equal(t, int64(3), agent.DeliveryFailures())
+}
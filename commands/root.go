@@ -33,6 +33,7 @@ type RootFlags struct {
 
 	LogFile string
 	Debug   bool
+	Once    bool
 }
 
 // NewRootCmd will return a root level command
@@ -53,6 +54,7 @@ func NewRootCmd() *cobra.Command {
 	rootFlagSet.StringVar(&rootFlags.PluginDir, "plugin_dir", defaultPluginDir(), "path to the plugin directory")
 	rootFlagSet.StringVar(&rootFlags.DatabaseFile, "database", "", "path to the carbon offset database")
 	rootFlagSet.BoolVar(&rootFlags.Debug, "debug", false, "debug logging")
+	rootFlagSet.BoolVar(&rootFlags.Once, "once", false, "read all available entries once, then exit when they have been delivered")
 
 	// Profiling flags
 	rootFlagSet.IntVar(&rootFlags.PprofPort, "pprof_port", 0, "listen port for pprof profiling")
@@ -74,6 +76,7 @@ func NewRootCmd() *cobra.Command {
 	root.AddCommand(NewGraphCommand(rootFlags))
 	root.AddCommand(NewVersionCommand())
 	root.AddCommand(NewOffsetsCmd(rootFlags))
+	root.AddCommand(NewBenchCommand(rootFlags))
 
 	return root
 }
@@ -97,6 +100,13 @@ func runRoot(command *cobra.Command, _ []string, flags *RootFlags) {
 	logger.Debugw("Parsed config", "config", cfg)
 
 	agent := agent.NewLogAgent(cfg, logger, flags.PluginDir, flags.DatabaseFile)
+	if flags.Once {
+		if code := runOnce(command.Context(), agent); code != exitSuccess {
+			os.Exit(code)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithCancel(command.Context())
 	service, err := newAgentService(ctx, agent, cancel)
 	if err != nil {
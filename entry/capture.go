@@ -0,0 +1,365 @@
+package entry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// captureTypeKey is the key of a map in a capture that wraps a record value whose type can
+// not be represented by JSON alone
+const captureTypeKey = "$capture"
+
+// captureEntry is a single line of a capture
+type captureEntry struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Severity  Severity          `json:"severity"`
+	Tags      []string          `json:"tags,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Record    interface{}       `json:"record"`
+}
+
+// CaptureEncoder writes entries in the capture format. A capture is newline delimited JSON, with
+// one entry per line. Record values that JSON can not represent losslessly, such as integers and
+// byte arrays, are wrapped in an object that records their type.
+type CaptureEncoder struct {
+	encoder *json.Encoder
+}
+
+// NewCaptureEncoder will create a capture encoder that writes to w.
+func NewCaptureEncoder(w io.Writer) *CaptureEncoder {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &CaptureEncoder{encoder: encoder}
+}
+
+// Encode will write an entry as a single line of the capture. An error is returned if the record
+// contains a value of a type that can not be decoded back to the same type.
+func (c *CaptureEncoder) Encode(entry *Entry) error {
+	record, err := encodeCaptureValue(entry.Record)
+	if err != nil {
+		return err
+	}
+
+	return c.encoder.Encode(captureEntry{
+		Timestamp: entry.Timestamp,
+		Severity:  entry.Severity,
+		Tags:      entry.Tags,
+		Labels:    entry.Labels,
+		Record:    record,
+	})
+}
+
+// CaptureDecoder reads entries written in the capture format.
+type CaptureDecoder struct {
+	reader *bufio.Reader
+	line   int
+}
+
+// NewCaptureDecoder will create a capture decoder that reads from r.
+func NewCaptureDecoder(r io.Reader) *CaptureDecoder {
+	return &CaptureDecoder{reader: bufio.NewReader(r)}
+}
+
+// Decode will read the next entry of the capture. Empty lines are skipped, and io.EOF is
+// returned once there are no more entries.
+func (c *CaptureDecoder) Decode() (*Entry, error) {
+	for {
+		line, err := c.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		c.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		entry, decodeErr := decodeCaptureLine(line)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("line %d: %s", c.line, decodeErr)
+		}
+		return entry, nil
+	}
+}
+
+// decodeCaptureLine will decode a single line of a capture
+func decodeCaptureLine(line []byte) (*Entry, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	var captured captureEntry
+	if err := decoder.Decode(&captured); err != nil {
+		return nil, err
+	}
+
+	record, err := decodeCaptureValue(captured.Record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entry{
+		Timestamp: captured.Timestamp,
+		Severity:  captured.Severity,
+		Tags:      captured.Tags,
+		Labels:    captured.Labels,
+		Record:    record,
+	}, nil
+}
+
+// wrapCaptureValue will wrap a value with its type
+func wrapCaptureValue(valueType string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		captureTypeKey: valueType,
+		"value":        value,
+	}
+}
+
+// encodeCaptureValue will convert a record value to a value that can be encoded as JSON, and
+// decoded back to the same type. Values of other types can not be captured, and return an error.
+func encodeCaptureValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case nil, string, bool:
+		return value, nil
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return wrapCaptureValue("float64", strconv.FormatFloat(value, 'g', -1, 64)), nil
+		}
+		return value, nil
+	case float32:
+		return wrapCaptureValue("float32", strconv.FormatFloat(float64(value), 'g', -1, 32)), nil
+	case int:
+		return wrapCaptureValue("int", json.Number(strconv.FormatInt(int64(value), 10))), nil
+	case int8:
+		return wrapCaptureValue("int8", json.Number(strconv.FormatInt(int64(value), 10))), nil
+	case int16:
+		return wrapCaptureValue("int16", json.Number(strconv.FormatInt(int64(value), 10))), nil
+	case int32:
+		return wrapCaptureValue("int32", json.Number(strconv.FormatInt(int64(value), 10))), nil
+	case int64:
+		return wrapCaptureValue("int64", json.Number(strconv.FormatInt(value, 10))), nil
+	case uint:
+		return wrapCaptureValue("uint", json.Number(strconv.FormatUint(uint64(value), 10))), nil
+	case uint8:
+		return wrapCaptureValue("uint8", json.Number(strconv.FormatUint(uint64(value), 10))), nil
+	case uint16:
+		return wrapCaptureValue("uint16", json.Number(strconv.FormatUint(uint64(value), 10))), nil
+	case uint32:
+		return wrapCaptureValue("uint32", json.Number(strconv.FormatUint(uint64(value), 10))), nil
+	case uint64:
+		return wrapCaptureValue("uint64", json.Number(strconv.FormatUint(value, 10))), nil
+	case []byte:
+		return wrapCaptureValue("bytes", base64.StdEncoding.EncodeToString(value)), nil
+	case []string:
+		return wrapCaptureValue("strings", value), nil
+	case []int:
+		return wrapCaptureValue("ints", value), nil
+	case map[string]string:
+		return wrapCaptureValue("string_map", value), nil
+	case map[string]interface{}:
+		encoded := make(map[string]interface{}, len(value))
+		for k, element := range value {
+			encodedElement, err := encodeCaptureValue(element)
+			if err != nil {
+				return nil, err
+			}
+			encoded[k] = encodedElement
+		}
+		// Maps that contain the type key are wrapped, so that they are not mistaken for a wrapped value
+		if _, ok := value[captureTypeKey]; ok {
+			return wrapCaptureValue("map", encoded), nil
+		}
+		return encoded, nil
+	case []interface{}:
+		encoded := make([]interface{}, 0, len(value))
+		for _, element := range value {
+			encodedElement, err := encodeCaptureValue(element)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(encoded, encodedElement)
+		}
+		return encoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported record type '%T'", value)
+	}
+}
+
+// decodeCaptureValue will convert a value decoded from a capture back to a record value
+func decodeCaptureValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case json.Number:
+		return value.Float64()
+	case map[string]interface{}:
+		if valueType, ok := value[captureTypeKey]; ok {
+			return decodeWrappedCaptureValue(valueType, value["value"])
+		}
+		decoded := make(map[string]interface{}, len(value))
+		for k, element := range value {
+			decodedElement, err := decodeCaptureValue(element)
+			if err != nil {
+				return nil, err
+			}
+			decoded[k] = decodedElement
+		}
+		return decoded, nil
+	case []interface{}:
+		decoded := make([]interface{}, 0, len(value))
+		for _, element := range value {
+			decodedElement, err := decodeCaptureValue(element)
+			if err != nil {
+				return nil, err
+			}
+			decoded = append(decoded, decodedElement)
+		}
+		return decoded, nil
+	default:
+		return value, nil
+	}
+}
+
+// decodeWrappedCaptureValue will convert a value that was wrapped with its type
+func decodeWrappedCaptureValue(valueType interface{}, value interface{}) (interface{}, error) {
+	switch valueType {
+	case "int", "int8", "int16", "int32", "int64":
+		n, err := parseCaptureInt(value, valueType.(string), captureBitSizes[valueType.(string)])
+		if err != nil {
+			return nil, err
+		}
+		switch valueType {
+		case "int":
+			return int(n), nil
+		case "int8":
+			return int8(n), nil
+		case "int16":
+			return int16(n), nil
+		case "int32":
+			return int32(n), nil
+		default:
+			return n, nil
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		n, err := parseCaptureUint(value, valueType.(string), captureBitSizes[valueType.(string)])
+		if err != nil {
+			return nil, err
+		}
+		switch valueType {
+		case "uint":
+			return uint(n), nil
+		case "uint8":
+			return uint8(n), nil
+		case "uint16":
+			return uint16(n), nil
+		case "uint32":
+			return uint32(n), nil
+		default:
+			return n, nil
+		}
+	case "float32", "float64":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s value of type '%T' must be a string", valueType, value)
+		}
+		if valueType == "float32" {
+			f, err := strconv.ParseFloat(s, 32)
+			return float32(f), err
+		}
+		return strconv.ParseFloat(s, 64)
+	case "bytes":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("bytes value of type '%T' must be a base64 string", value)
+		}
+		return base64.StdEncoding.DecodeString(s)
+	case "strings":
+		elements, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("strings value of type '%T' must be an array", value)
+		}
+		strings := make([]string, 0, len(elements))
+		for _, element := range elements {
+			s, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("strings element of type '%T' must be a string", element)
+			}
+			strings = append(strings, s)
+		}
+		return strings, nil
+	case "ints":
+		elements, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ints value of type '%T' must be an array", value)
+		}
+		ints := make([]int, 0, len(elements))
+		for _, element := range elements {
+			n, err := parseCaptureInt(element, "ints", 0)
+			if err != nil {
+				return nil, err
+			}
+			ints = append(ints, int(n))
+		}
+		return ints, nil
+	case "string_map":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("string_map value of type '%T' must be an object", value)
+		}
+		stringMap := make(map[string]string, len(m))
+		for k, element := range m {
+			s, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("string_map value of type '%T' must be a string", element)
+			}
+			stringMap[k] = s
+		}
+		return stringMap, nil
+	case "map":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("map value of type '%T' must be an object", value)
+		}
+		decoded := make(map[string]interface{}, len(m))
+		for k, element := range m {
+			decodedElement, err := decodeCaptureValue(element)
+			if err != nil {
+				return nil, err
+			}
+			decoded[k] = decodedElement
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unknown capture type '%v'", valueType)
+	}
+}
+
+// captureBitSizes are the sizes of the integer types of a capture. A size of 0 is the size of int.
+var captureBitSizes = map[string]int{
+	"int": 0, "int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	"uint": 0, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64,
+}
+
+func parseCaptureInt(value interface{}, valueType string, bitSize int) (int64, error) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%s value of type '%T' must be a number", valueType, value)
+	}
+	return strconv.ParseInt(string(n), 10, bitSize)
+}
+
+func parseCaptureUint(value interface{}, valueType string, bitSize int) (uint64, error) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%s value of type '%T' must be a number", valueType, value)
+	}
+	return strconv.ParseUint(string(n), 10, bitSize)
+}
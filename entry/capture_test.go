@@ -0,0 +1,143 @@
+package entry
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	timestamp := time.Date(2020, time.July, 21, 10, 30, 15, 123456789, time.FixedZone("", -5*60*60))
+	entries := []*Entry{
+		{
+			Timestamp: timestamp,
+			Severity:  Warning,
+			Tags:      []string{"tag1", "tag2"},
+			Labels:    map[string]string{"label": "value"},
+			Record: map[string]interface{}{
+				"string":     "value <&>",
+				"bool":       true,
+				"nil":        nil,
+				"float":      1.5,
+				"nan":        math.NaN(),
+				"float32":    float32(2.25),
+				"int":        1,
+				"int8":       int8(-8),
+				"int16":      int16(16),
+				"int32":      int32(32),
+				"int64":      int64(math.MaxInt64),
+				"uint":       uint(1),
+				"uint8":      uint8(8),
+				"uint16":     uint16(16),
+				"uint32":     uint32(32),
+				"uint64":     uint64(math.MaxUint64),
+				"bytes":      []byte("raw\x00bytes"),
+				"strings":    []string{"a", "b"},
+				"ints":       []int{1, 2},
+				"string_map": map[string]string{"key": "value"},
+				"array":      []interface{}{1, "two", map[string]interface{}{"three": 3.0}},
+				"type_key":   map[string]interface{}{captureTypeKey: "int", "value": "not an int"},
+			},
+		},
+		{
+			Timestamp: timestamp.Add(time.Second),
+			Severity:  Severity(35),
+			Record:    "string record",
+		},
+		{
+			Timestamp: timestamp.Add(2 * time.Second),
+			Record:    []byte("bytes record"),
+		},
+	}
+
+	var buf bytes.Buffer
+	encoder := NewCaptureEncoder(&buf)
+	for _, e := range entries {
+		require.NoError(t, encoder.Encode(e))
+	}
+	require.Equal(t, len(entries), strings.Count(buf.String(), "\n"))
+
+	decoder := NewCaptureDecoder(&buf)
+	for _, expected := range entries {
+		decoded, err := decoder.Decode()
+		require.NoError(t, err)
+		require.True(t, expected.Timestamp.Equal(decoded.Timestamp))
+		require.Equal(t, expected.Severity, decoded.Severity)
+		require.Equal(t, expected.Tags, decoded.Tags)
+		require.Equal(t, expected.Labels, decoded.Labels)
+
+		if record, ok := expected.Record.(map[string]interface{}); ok {
+			decodedRecord := decoded.Record.(map[string]interface{})
+			require.True(t, math.IsNaN(decodedRecord["nan"].(float64)))
+			delete(record, "nan")
+			delete(decodedRecord, "nan")
+		}
+		require.Equal(t, expected.Record, decoded.Record)
+	}
+
+	_, err := decoder.Decode()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestCaptureDecodePlainJSON(t *testing.T) {
+	capture := `{"timestamp":"2020-07-21T10:30:15Z","severity":30,"record":{"count":3,"message":"test"}}
+
+{"timestamp":"2020-07-21T10:30:16Z","severity":0,"record":"no trailing newline"}`
+
+	decoder := NewCaptureDecoder(strings.NewReader(capture))
+	e, err := decoder.Decode()
+	require.NoError(t, err)
+	require.Equal(t, Info, e.Severity)
+	require.Equal(t, map[string]interface{}{"count": 3.0, "message": "test"}, e.Record)
+
+	e, err = decoder.Decode()
+	require.NoError(t, err)
+	require.Equal(t, "no trailing newline", e.Record)
+
+	_, err = decoder.Decode()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestCaptureEncodeUnsupported(t *testing.T) {
+	cases := map[string]interface{}{
+		"IntMap":    map[string]int{"a": 1},
+		"MapSlice":  []map[string]interface{}{{"a": "b"}},
+		"Time":      time.Now(),
+		"NestedMap": map[string]interface{}{"nested": []interface{}{map[string]int{"a": 1}}},
+	}
+
+	for name, record := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewCaptureEncoder(&buf).Encode(&Entry{Record: record})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "unsupported record type")
+			require.Empty(t, buf.String())
+		})
+	}
+}
+
+func TestCaptureDecodeInvalid(t *testing.T) {
+	cases := map[string]string{
+		"NotJSON":       `not json`,
+		"UnknownType":   `{"record":{"$capture":"unknown","value":1}}`,
+		"IntOverflow":   `{"record":{"$capture":"int8","value":300}}`,
+		"IntNotNumber":  `{"record":{"$capture":"int","value":"1"}}`,
+		"InvalidBase64": `{"record":{"$capture":"bytes","value":"!"}}`,
+		"StringsNotArr": `{"record":{"$capture":"strings","value":"a"}}`,
+	}
+
+	for name, capture := range cases {
+		t.Run(name, func(t *testing.T) {
+			decoder := NewCaptureDecoder(strings.NewReader("\n" + capture + "\n"))
+			_, err := decoder.Decode()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "line 2")
+		})
+	}
+}
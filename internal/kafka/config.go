@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Shopify/sarama"
+)
+
+// SASL mechanisms supported by the kafka client
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	SASLMechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// DefaultVersion is the kafka protocol version used when none is configured
+const DefaultVersion = "2.0.0"
+
+// NewClientConfig creates a client config with default values.
+func NewClientConfig() ClientConfig {
+	return ClientConfig{
+		ClientID: "carbon",
+		Version:  DefaultVersion,
+	}
+}
+
+// ClientConfig is the configuration of the connection to kafka brokers, which is shared by
+// the kafka input and output.
+type ClientConfig struct {
+	Brokers  []string    `json:"brokers"             yaml:"brokers,flow"`
+	ClientID string      `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	Version  string      `json:"version,omitempty"   yaml:"version,omitempty"`
+	TLS      *TLSConfig  `json:"tls,omitempty"       yaml:"tls,omitempty"`
+	SASL     *SASLConfig `json:"sasl,omitempty"      yaml:"sasl,omitempty"`
+}
+
+// TLSConfig is the configuration of tls connections to kafka brokers.
+type TLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"              yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"            yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"             yaml:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty"          yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// SASLConfig is the configuration of sasl authentication with kafka brokers.
+type SASLConfig struct {
+	Mechanism string `json:"mechanism,omitempty" yaml:"mechanism,omitempty"`
+	Username  string `json:"username"            yaml:"username"`
+	Password  string `json:"password"            yaml:"password"`
+}
+
+// Build will build the sarama config of a client. Callers set the producer or consumer
+// options on the returned config.
+func (c ClientConfig) Build() (*sarama.Config, error) {
+	if len(c.Brokers) == 0 {
+		return nil, fmt.Errorf("missing required parameter 'brokers'")
+	}
+
+	cfg := sarama.NewConfig()
+	if c.ClientID != "" {
+		cfg.ClientID = c.ClientID
+	}
+
+	versionString := c.Version
+	if versionString == "" {
+		versionString = DefaultVersion
+	}
+	version, err := sarama.ParseKafkaVersion(versionString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version '%s': %s", c.Version, err)
+	}
+	cfg.Version = version
+
+	if c.TLS != nil {
+		tlsConfig, err := c.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsConfig
+	}
+
+	if c.SASL != nil {
+		if err := c.SASL.apply(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// build will build a tls client config
+func (c TLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca_file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("tls ca_file does not contain a PEM encoded certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, fmt.Errorf("tls client certificates require both cert_file and key_file")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// apply will configure sasl authentication on a sarama config
+func (c SASLConfig) apply(cfg *sarama.Config) error {
+	if c.Username == "" {
+		return fmt.Errorf("sasl requires a username")
+	}
+
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.Handshake = true
+	cfg.Net.SASL.User = c.Username
+	cfg.Net.SASL.Password = c.Password
+
+	switch c.Mechanism {
+	case SASLMechanismPlain, "":
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case SASLMechanismSCRAMSHA256:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return newSCRAMClient(sha256Generator) }
+	case SASLMechanismSCRAMSHA512:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return newSCRAMClient(sha512Generator) }
+	default:
+		return fmt.Errorf("invalid sasl mechanism '%s', expected '%s', '%s' or '%s'",
+			c.Mechanism, SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512)
+	}
+	return nil
+}
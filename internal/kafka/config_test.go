@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientConfigBuild(t *testing.T) {
+	cfg := NewClientConfig()
+	cfg.Brokers = []string{"localhost:9092"}
+
+	saramaConfig, err := cfg.Build()
+	require.NoError(t, err)
+	require.Equal(t, "carbon", saramaConfig.ClientID)
+	require.Equal(t, sarama.V2_0_0_0, saramaConfig.Version)
+	require.False(t, saramaConfig.Net.TLS.Enable)
+	require.False(t, saramaConfig.Net.SASL.Enable)
+}
+
+func TestClientConfigTLS(t *testing.T) {
+	files := testutil.NewTLSFiles(t, "carbon")
+
+	cfg := NewClientConfig()
+	cfg.Brokers = []string{"localhost:9093"}
+	cfg.TLS = &TLSConfig{
+		CAFile:     files.CAFile,
+		CertFile:   files.ClientCertFile,
+		KeyFile:    files.ClientKeyFile,
+		ServerName: "kafka.local",
+	}
+
+	saramaConfig, err := cfg.Build()
+	require.NoError(t, err)
+	require.True(t, saramaConfig.Net.TLS.Enable)
+	require.NotNil(t, saramaConfig.Net.TLS.Config.RootCAs)
+	require.Len(t, saramaConfig.Net.TLS.Config.Certificates, 1)
+	require.Equal(t, "kafka.local", saramaConfig.Net.TLS.Config.ServerName)
+
+	cfg.TLS.CAFile = files.ClientKeyFile
+	_, err = cfg.Build()
+	require.Error(t, err)
+}
+
+func TestClientConfigSASL(t *testing.T) {
+	cases := []struct {
+		mechanism string
+		expected  sarama.SASLMechanism
+	}{
+		{"", sarama.SASLTypePlaintext},
+		{SASLMechanismPlain, sarama.SASLTypePlaintext},
+		{SASLMechanismSCRAMSHA256, sarama.SASLTypeSCRAMSHA256},
+		{SASLMechanismSCRAMSHA512, sarama.SASLTypeSCRAMSHA512},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.expected), func(t *testing.T) {
+			cfg := NewClientConfig()
+			cfg.Brokers = []string{"localhost:9092"}
+			cfg.SASL = &SASLConfig{Mechanism: tc.mechanism, Username: "user", Password: "secret"}
+
+			saramaConfig, err := cfg.Build()
+			require.NoError(t, err)
+			require.NoError(t, saramaConfig.Validate())
+			require.True(t, saramaConfig.Net.SASL.Enable)
+			require.Equal(t, tc.expected, saramaConfig.Net.SASL.Mechanism)
+			require.Equal(t, "user", saramaConfig.Net.SASL.User)
+
+			if saramaConfig.Net.SASL.SCRAMClientGeneratorFunc != nil {
+				client := saramaConfig.Net.SASL.SCRAMClientGeneratorFunc()
+				require.NoError(t, client.Begin("user", "secret", ""))
+				first, err := client.Step("")
+				require.NoError(t, err)
+				require.Contains(t, first, "n=user")
+				require.False(t, client.Done())
+			}
+		})
+	}
+}
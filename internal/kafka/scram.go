@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+
+	"github.com/xdg/scram"
+)
+
+// Hash generators of the supported SCRAM mechanisms
+var (
+	sha256Generator scram.HashGeneratorFcn = sha256.New
+	sha512Generator scram.HashGeneratorFcn = sha512.New
+)
+
+// scramClient implements sarama.SCRAMClient with a single SCRAM conversation
+type scramClient struct {
+	hashGenerator scram.HashGeneratorFcn
+	conversation  *scram.ClientConversation
+}
+
+func newSCRAMClient(hashGenerator scram.HashGeneratorFcn) *scramClient {
+	return &scramClient{hashGenerator: hashGenerator}
+}
+
+// Begin will start a new conversation with the credentials
+func (c *scramClient) Begin(username, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(username, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+// Step will return the response to a challenge of the server
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+// Done returns true once the conversation is complete
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
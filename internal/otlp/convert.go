@@ -0,0 +1,338 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+)
+
+// Labels that entries are mapped to OTLP fields with. Other labels are mapped to the attributes
+// of log records.
+const (
+	// ResourceLabelPrefix is the prefix of the labels that are attributes of the resource
+	ResourceLabelPrefix = "resource."
+
+	ScopeNameLabel    = "scope.name"
+	ScopeVersionLabel = "scope.version"
+	SeverityTextLabel = "severity_text"
+	TraceIDLabel      = "trace_id"
+	SpanIDLabel       = "span_id"
+	TraceFlagsLabel   = "trace_flags"
+)
+
+// severityNumbers are the OTLP severity numbers of the carbon severities
+var severityNumbers = []struct {
+	severity entry.Severity
+	number   int32
+}{
+	{entry.Catastrophe, 24},
+	{entry.Emergency, 21},
+	{entry.Alert, 19},
+	{entry.Critical, 18},
+	{entry.Error, 17},
+	{entry.Warning, 13},
+	{entry.Notice, 10},
+	{entry.Info, 9},
+	{entry.Debug, 5},
+	{entry.Trace, 1},
+}
+
+// SeverityNumber will return the OTLP severity number of a severity. Severities between the
+// carbon severities are mapped to the number of the severity below them.
+func SeverityNumber(severity entry.Severity) int32 {
+	for _, s := range severityNumbers {
+		if severity >= s.severity {
+			return s.number
+		}
+	}
+	return 0
+}
+
+// Severity will return the carbon severity of an OTLP severity number. Numbers between the numbers
+// of carbon severities are mapped to the severity below them.
+func Severity(number int32) entry.Severity {
+	for _, s := range severityNumbers {
+		if number >= s.number {
+			return s.severity
+		}
+	}
+	return entry.Default
+}
+
+// ToEntries will convert the log records of a request to entries, which are created by newEntry
+// with the body of each log record
+func ToEntries(req *ExportLogsServiceRequest, newEntry func(record interface{}) *entry.Entry) []*entry.Entry {
+	var entries []*entry.Entry
+	for _, resourceLogs := range req.ResourceLogs {
+		resourceLabels := make(map[string]string)
+		if resourceLogs.Resource != nil {
+			for _, kv := range resourceLogs.Resource.Attributes {
+				resourceLabels[ResourceLabelPrefix+kv.Key] = labelValue(kv.Value)
+			}
+		}
+
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			for _, record := range scopeLogs.LogRecords {
+				e := newEntry(recordValue(record.Body))
+				e.Timestamp = recordTime(record)
+				e.Severity = Severity(record.SeverityNumber)
+
+				for key, value := range resourceLabels {
+					e.AddLabel(key, value)
+				}
+				if scopeLogs.Scope != nil {
+					addLabel(e, ScopeNameLabel, scopeLogs.Scope.Name)
+					addLabel(e, ScopeVersionLabel, scopeLogs.Scope.Version)
+				}
+				for _, kv := range record.Attributes {
+					e.AddLabel(kv.Key, labelValue(kv.Value))
+				}
+				addLabel(e, SeverityTextLabel, record.SeverityText)
+				addLabel(e, TraceIDLabel, hex.EncodeToString(record.TraceID))
+				addLabel(e, SpanIDLabel, hex.EncodeToString(record.SpanID))
+				if record.Flags != 0 {
+					e.AddLabel(TraceFlagsLabel, fmt.Sprintf("%02x", record.Flags&0xff))
+				}
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries
+}
+
+func addLabel(e *entry.Entry, key, value string) {
+	if value != "" {
+		e.AddLabel(key, value)
+	}
+}
+
+// recordTime will return the time of a log record, or the time it was observed if it is not set
+func recordTime(record *LogRecord) time.Time {
+	switch {
+	case record.TimeUnixNano != 0:
+		return time.Unix(0, int64(record.TimeUnixNano))
+	case record.ObservedTimeUnixNano != 0:
+		return time.Unix(0, int64(record.ObservedTimeUnixNano))
+	default:
+		return time.Now()
+	}
+}
+
+// recordValue will convert a value to a record value, where key value lists are maps
+func recordValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []*KeyValue:
+		m := make(map[string]interface{}, len(v))
+		for _, kv := range v {
+			m[kv.Key] = recordValue(kv.Value)
+		}
+		return m
+	case []interface{}:
+		array := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			array = append(array, recordValue(element))
+		}
+		return array
+	default:
+		return value
+	}
+}
+
+// labelValue will convert an attribute value to a label. Strings are kept, bytes are base64
+// encoded, and other values are encoded as JSON.
+func labelValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case nil:
+		return ""
+	default:
+		encoded, err := json.Marshal(recordValue(v))
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
+
+// FromEntries will convert entries to a request. Entries with the same resource and scope labels
+// are grouped together.
+func FromEntries(entries []*entry.Entry, observed time.Time) *ExportLogsServiceRequest {
+	req := &ExportLogsServiceRequest{}
+	resources := make(map[string]*ResourceLogs)
+	scopes := make(map[string]*ScopeLogs)
+
+	for _, e := range entries {
+		record := &LogRecord{
+			ObservedTimeUnixNano: uint64(observed.UnixNano()),
+			SeverityNumber:       SeverityNumber(e.Severity),
+			Body:                 anyValue(e.Record),
+		}
+		if !e.Timestamp.IsZero() {
+			record.TimeUnixNano = uint64(e.Timestamp.UnixNano())
+		}
+		if e.Severity != entry.Default {
+			record.SeverityText = strings.ToUpper(e.Severity.String())
+		}
+
+		var resourceAttributes []*KeyValue
+		var scope Scope
+		for _, key := range sortedKeys(e.Labels) {
+			value := e.Labels[key]
+			switch {
+			case strings.HasPrefix(key, ResourceLabelPrefix):
+				resourceAttributes = append(resourceAttributes, &KeyValue{Key: strings.TrimPrefix(key, ResourceLabelPrefix), Value: value})
+				continue
+			case key == ScopeNameLabel:
+				scope.Name = value
+				continue
+			case key == ScopeVersionLabel:
+				scope.Version = value
+				continue
+			case key == SeverityTextLabel:
+				record.SeverityText = value
+				continue
+			case key == TraceIDLabel:
+				if id, err := hex.DecodeString(value); err == nil && len(id) == 16 {
+					record.TraceID = id
+					continue
+				}
+			case key == SpanIDLabel:
+				if id, err := hex.DecodeString(value); err == nil && len(id) == 8 {
+					record.SpanID = id
+					continue
+				}
+			case key == TraceFlagsLabel:
+				if flags, err := strconv.ParseUint(value, 16, 8); err == nil {
+					record.Flags = uint32(flags)
+					continue
+				}
+			}
+
+			// Labels that can not be mapped to a field are kept as attributes
+			record.Attributes = append(record.Attributes, &KeyValue{Key: key, Value: value})
+		}
+
+		resourceKey := attributesKey(resourceAttributes)
+		resourceLogs, ok := resources[resourceKey]
+		if !ok {
+			resourceLogs = &ResourceLogs{Resource: &Resource{Attributes: resourceAttributes}}
+			resources[resourceKey] = resourceLogs
+			req.ResourceLogs = append(req.ResourceLogs, resourceLogs)
+		}
+
+		scopeKey := resourceKey + "\x00" + scope.Name + "\x00" + scope.Version
+		scopeLogs, ok := scopes[scopeKey]
+		if !ok {
+			scopeLogs = &ScopeLogs{Scope: &Scope{Name: scope.Name, Version: scope.Version}}
+			scopes[scopeKey] = scopeLogs
+			resourceLogs.ScopeLogs = append(resourceLogs.ScopeLogs, scopeLogs)
+		}
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, record)
+	}
+	return req
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attributesKey will return a key that identifies a set of sorted string attributes
+func attributesKey(attributes []*KeyValue) string {
+	var b strings.Builder
+	for _, kv := range attributes {
+		b.WriteString(strconv.Quote(kv.Key))
+		b.WriteString(strconv.Quote(kv.Value.(string)))
+	}
+	return b.String()
+}
+
+// anyValue will convert a record value to an attribute value, where maps are key value lists
+func anyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int64, float64, []byte:
+		return v
+	case map[string]interface{}:
+		list := make([]*KeyValue, 0, len(v))
+		for _, key := range sortedInterfaceKeys(v) {
+			list = append(list, &KeyValue{Key: key, Value: anyValue(v[key])})
+		}
+		return list
+	case map[string]string:
+		list := make([]*KeyValue, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			list = append(list, &KeyValue{Key: key, Value: v[key]})
+		}
+		return list
+	case []interface{}:
+		array := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			array = append(array, anyValue(element))
+		}
+		return array
+	case []string:
+		array := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			array = append(array, element)
+		}
+		return array
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return uintValue(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return uintValue(v)
+	case float32:
+		return float64(v)
+	default:
+		// Other values are encoded as JSON, such as the values of parsers
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
+
+func uintValue(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+func sortedInterfaceKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,244 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverity(t *testing.T) {
+	cases := []struct {
+		severity entry.Severity
+		number   int32
+	}{
+		{entry.Default, 0},
+		{entry.Trace, 1},
+		{entry.Debug, 5},
+		{entry.Info, 9},
+		{entry.Notice, 10},
+		{entry.Warning, 13},
+		{entry.Error, 17},
+		{entry.Critical, 18},
+		{entry.Alert, 19},
+		{entry.Emergency, 21},
+		{entry.Catastrophe, 24},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.severity.String(), func(t *testing.T) {
+			require.Equal(t, tc.number, SeverityNumber(tc.severity))
+			require.Equal(t, tc.severity, Severity(tc.number))
+		})
+	}
+
+	// Values between levels are mapped to the level below them
+	require.Equal(t, entry.Notice, Severity(12))
+	require.Equal(t, int32(13), SeverityNumber(entry.Warning+5))
+}
+
+func TestToEntries(t *testing.T) {
+	req := &ExportLogsServiceRequest{
+		ResourceLogs: []*ResourceLogs{
+			{
+				Resource: &Resource{
+					Attributes: []*KeyValue{
+						{Key: "service.name", Value: "test"},
+						{Key: "pid", Value: int64(10)},
+					},
+				},
+				ScopeLogs: []*ScopeLogs{
+					{
+						Scope: &Scope{Name: "scope", Version: "1.0"},
+						LogRecords: []*LogRecord{
+							{
+								TimeUnixNano:   1600000000000000000,
+								SeverityNumber: 17,
+								SeverityText:   "ERR",
+								Body: []*KeyValue{
+									{Key: "message", Value: "test message"},
+									{Key: "array", Value: []interface{}{[]*KeyValue{{Key: "key", Value: true}}}},
+								},
+								Attributes: []*KeyValue{
+									{Key: "string", Value: "value"},
+									{Key: "bytes", Value: []byte("value")},
+									{Key: "array", Value: []interface{}{"a", int64(1)}},
+								},
+								Flags:   1,
+								TraceID: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+								SpanID:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+							},
+							{
+								ObservedTimeUnixNano: 1600000000000000001,
+								Body:                 "test message",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	entries := ToEntries(req, func(record interface{}) *entry.Entry {
+		e := entry.New()
+		e.Record = record
+		return e
+	})
+	require.Len(t, entries, 2)
+
+	require.Equal(t, time.Unix(0, 1600000000000000000), entries[0].Timestamp)
+	require.Equal(t, entry.Error, entries[0].Severity)
+	require.Equal(t, map[string]interface{}{
+		"message": "test message",
+		"array":   []interface{}{map[string]interface{}{"key": true}},
+	}, entries[0].Record)
+	require.Equal(t, map[string]string{
+		"resource.service.name": "test",
+		"resource.pid":          "10",
+		"scope.name":            "scope",
+		"scope.version":         "1.0",
+		"string":                "value",
+		"bytes":                 "dmFsdWU=",
+		"array":                 `["a",1]`,
+		"severity_text":         "ERR",
+		"trace_id":              "0102030405060708090a0b0c0d0e0f10",
+		"span_id":               "0102030405060708",
+		"trace_flags":           "01",
+	}, entries[0].Labels)
+
+	require.Equal(t, time.Unix(0, 1600000000000000001), entries[1].Timestamp)
+	require.Equal(t, entry.Default, entries[1].Severity)
+	require.Equal(t, "test message", entries[1].Record)
+	require.Equal(t, map[string]string{
+		"resource.service.name": "test",
+		"resource.pid":          "10",
+		"scope.name":            "scope",
+		"scope.version":         "1.0",
+	}, entries[1].Labels)
+}
+
+func TestFromEntries(t *testing.T) {
+	observed := time.Unix(1600000001, 0)
+	timestamp := time.Unix(1600000000, 0)
+
+	entries := []*entry.Entry{
+		{
+			Timestamp: timestamp,
+			Severity:  entry.Warning,
+			Record: map[string]interface{}{
+				"message": "test message",
+				"count":   3,
+				"nested":  map[string]interface{}{"values": []interface{}{"a", uint64(1)}},
+			},
+			Labels: map[string]string{
+				"resource.service.name": "a",
+				"scope.name":            "scope",
+				"key":                   "value",
+				"trace_id":              "0102030405060708090a0b0c0d0e0f10",
+				"span_id":               "invalid",
+				"trace_flags":           "01",
+			},
+		},
+		{
+			Timestamp: timestamp,
+			Record:    "second",
+			Labels: map[string]string{
+				"resource.service.name": "a",
+				"scope.name":            "scope",
+				"severity_text":         "custom",
+			},
+		},
+		{
+			Record: "third",
+			Labels: map[string]string{
+				"resource.service.name": "b",
+			},
+		},
+	}
+
+	expected := &ExportLogsServiceRequest{
+		ResourceLogs: []*ResourceLogs{
+			{
+				Resource: &Resource{Attributes: []*KeyValue{{Key: "service.name", Value: "a"}}},
+				ScopeLogs: []*ScopeLogs{
+					{
+						Scope: &Scope{Name: "scope"},
+						LogRecords: []*LogRecord{
+							{
+								TimeUnixNano:         uint64(timestamp.UnixNano()),
+								ObservedTimeUnixNano: uint64(observed.UnixNano()),
+								SeverityNumber:       13,
+								SeverityText:         "WARNING",
+								Body: []*KeyValue{
+									{Key: "count", Value: int64(3)},
+									{Key: "message", Value: "test message"},
+									{Key: "nested", Value: []*KeyValue{{Key: "values", Value: []interface{}{"a", int64(1)}}}},
+								},
+								Attributes: []*KeyValue{
+									{Key: "key", Value: "value"},
+									{Key: "span_id", Value: "invalid"},
+								},
+								Flags:   1,
+								TraceID: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+							},
+							{
+								TimeUnixNano:         uint64(timestamp.UnixNano()),
+								ObservedTimeUnixNano: uint64(observed.UnixNano()),
+								SeverityText:         "custom",
+								Body:                 "second",
+							},
+						},
+					},
+				},
+			},
+			{
+				Resource: &Resource{Attributes: []*KeyValue{{Key: "service.name", Value: "b"}}},
+				ScopeLogs: []*ScopeLogs{
+					{
+						Scope: &Scope{},
+						LogRecords: []*LogRecord{
+							{
+								ObservedTimeUnixNano: uint64(observed.UnixNano()),
+								Body:                 "third",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, expected, FromEntries(entries, observed))
+}
+
+func TestRoundTrip(t *testing.T) {
+	e := entry.New()
+	e.Timestamp = time.Unix(1600000000, 0)
+	e.Severity = entry.Error
+	e.Record = map[string]interface{}{"message": "test message", "count": int64(3)}
+	e.Labels = map[string]string{
+		"resource.host": "test",
+		"key":           "value",
+		"trace_id":      "0102030405060708090a0b0c0d0e0f10",
+		"span_id":       "0102030405060708",
+	}
+
+	data, err := FromEntries([]*entry.Entry{e}, time.Now()).Marshal()
+	require.NoError(t, err)
+
+	req := &ExportLogsServiceRequest{}
+	require.NoError(t, req.Unmarshal(data))
+
+	entries := ToEntries(req, func(record interface{}) *entry.Entry {
+		e := entry.New()
+		e.Record = record
+		return e
+	})
+	require.Len(t, entries, 1)
+	require.Equal(t, e.Timestamp, entries[0].Timestamp)
+	require.Equal(t, e.Severity, entries[0].Severity)
+	require.Equal(t, e.Record, entries[0].Record)
+	e.Labels["severity_text"] = "ERROR"
+	require.Equal(t, e.Labels, entries[0].Labels)
+}
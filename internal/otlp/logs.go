@@ -0,0 +1,793 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// maxDepth is the maximum nesting of arrays and key value lists in a value
+const maxDepth = 100
+
+// ExportLogsServiceRequest is the request of the OTLP logs service
+type ExportLogsServiceRequest struct {
+	ResourceLogs []*ResourceLogs
+}
+
+// ExportLogsServiceResponse is the response of the OTLP logs service. It reports the log records
+// that were rejected, if the request was only partially successful.
+type ExportLogsServiceResponse struct {
+	RejectedLogRecords int64
+	ErrorMessage       string
+}
+
+// ResourceLogs are the logs of a resource
+type ResourceLogs struct {
+	Resource  *Resource
+	ScopeLogs []*ScopeLogs
+	SchemaURL string
+}
+
+// Resource is the entity that produced logs
+type Resource struct {
+	Attributes             []*KeyValue
+	DroppedAttributesCount uint32
+}
+
+// ScopeLogs are the logs of an instrumentation scope
+type ScopeLogs struct {
+	Scope      *Scope
+	LogRecords []*LogRecord
+	SchemaURL  string
+}
+
+// Scope is the instrumentation scope that produced logs
+type Scope struct {
+	Name                   string
+	Version                string
+	Attributes             []*KeyValue
+	DroppedAttributesCount uint32
+}
+
+// LogRecord is a single log record
+type LogRecord struct {
+	TimeUnixNano           uint64
+	ObservedTimeUnixNano   uint64
+	SeverityNumber         int32
+	SeverityText           string
+	Body                   interface{}
+	Attributes             []*KeyValue
+	DroppedAttributesCount uint32
+	Flags                  uint32
+	TraceID                []byte
+	SpanID                 []byte
+}
+
+// KeyValue is an attribute. Values are nil, string, bool, int64, float64, []byte,
+// []interface{} for arrays, or []*KeyValue for key value lists.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// Reset will reset the request, as required by the grpc codec
+func (r *ExportLogsServiceRequest) Reset() { *r = ExportLogsServiceRequest{} }
+
+// String will return a description of the request
+func (r *ExportLogsServiceRequest) String() string {
+	return fmt.Sprintf("ExportLogsServiceRequest{%d resource logs}", len(r.ResourceLogs))
+}
+
+// ProtoMessage marks the request as a protobuf message
+func (r *ExportLogsServiceRequest) ProtoMessage() {}
+
+// Marshal will encode the request
+func (r *ExportLogsServiceRequest) Marshal() ([]byte, error) {
+	var b []byte
+	for _, resourceLogs := range r.ResourceLogs {
+		message, err := resourceLogs.marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessageField(b, 1, message)
+	}
+	return b, nil
+}
+
+// Unmarshal will decode the request
+func (r *ExportLogsServiceRequest) Unmarshal(data []byte) error {
+	r.Reset()
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		if field != 1 {
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := expect(field, wireType, wireBytes); err != nil {
+			return err
+		}
+		message, err := reader.bytes()
+		if err != nil {
+			return err
+		}
+		resourceLogs := &ResourceLogs{}
+		if err := resourceLogs.unmarshal(message); err != nil {
+			return fmt.Errorf("resource logs: %s", err)
+		}
+		r.ResourceLogs = append(r.ResourceLogs, resourceLogs)
+	}
+}
+
+// Reset will reset the response, as required by the grpc codec
+func (r *ExportLogsServiceResponse) Reset() { *r = ExportLogsServiceResponse{} }
+
+// String will return a description of the response
+func (r *ExportLogsServiceResponse) String() string {
+	return fmt.Sprintf("ExportLogsServiceResponse{rejected: %d, error: %q}", r.RejectedLogRecords, r.ErrorMessage)
+}
+
+// ProtoMessage marks the response as a protobuf message
+func (r *ExportLogsServiceResponse) ProtoMessage() {}
+
+// Marshal will encode the response
+func (r *ExportLogsServiceResponse) Marshal() ([]byte, error) {
+	if r.RejectedLogRecords == 0 && r.ErrorMessage == "" {
+		return []byte{}, nil
+	}
+
+	var partialSuccess []byte
+	partialSuccess = appendVarintField(partialSuccess, 1, uint64(r.RejectedLogRecords))
+	partialSuccess = appendStringField(partialSuccess, 2, r.ErrorMessage)
+	return appendMessageField(nil, 1, partialSuccess), nil
+}
+
+// Unmarshal will decode the response
+func (r *ExportLogsServiceResponse) Unmarshal(data []byte) error {
+	r.Reset()
+	partialSuccess, err := embeddedMessage(data, 1)
+	if err != nil || partialSuccess == nil {
+		return err
+	}
+
+	reader := &fieldReader{data: partialSuccess}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if err := expect(field, wireType, wireVarint); err != nil {
+				return err
+			}
+			v, err := reader.varint()
+			if err != nil {
+				return err
+			}
+			r.RejectedLogRecords = int64(v)
+		case 2:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			v, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			r.ErrorMessage = string(v)
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// embeddedMessage will return the last value of a message field, or nil if it is not set
+func embeddedMessage(data []byte, number int) ([]byte, error) {
+	var message []byte
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return message, nil
+		}
+
+		if field != number {
+			if err := reader.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := expect(field, wireType, wireBytes); err != nil {
+			return nil, err
+		}
+		if message, err = reader.bytes(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *ResourceLogs) marshal() ([]byte, error) {
+	var b []byte
+	if r.Resource != nil {
+		resource, err := appendKeyValues(nil, 1, r.Resource.Attributes, 0)
+		if err != nil {
+			return nil, err
+		}
+		resource = appendVarintField(resource, 2, uint64(r.Resource.DroppedAttributesCount))
+		b = appendMessageField(b, 1, resource)
+	}
+
+	for _, scopeLogs := range r.ScopeLogs {
+		message, err := scopeLogs.marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessageField(b, 2, message)
+	}
+	return appendStringField(b, 3, r.SchemaURL), nil
+}
+
+func (r *ResourceLogs) unmarshal(data []byte) error {
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			r.Resource = &Resource{}
+			if err := r.Resource.unmarshal(message); err != nil {
+				return fmt.Errorf("resource: %s", err)
+			}
+		case 2, 1000:
+			// Clients of older versions of OTLP send instrumentation library logs, which are encoded like scope logs
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			scopeLogs := &ScopeLogs{}
+			if err := scopeLogs.unmarshal(message); err != nil {
+				return fmt.Errorf("scope logs: %s", err)
+			}
+			r.ScopeLogs = append(r.ScopeLogs, scopeLogs)
+		case 3:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			v, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			r.SchemaURL = string(v)
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Resource) unmarshal(data []byte) error {
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch field {
+		case 1:
+			kv, err := readKeyValue(reader, wireType, 0)
+			if err != nil {
+				return err
+			}
+			r.Attributes = append(r.Attributes, kv)
+		case 2:
+			if err := expect(field, wireType, wireVarint); err != nil {
+				return err
+			}
+			v, err := reader.varint()
+			if err != nil {
+				return err
+			}
+			r.DroppedAttributesCount = uint32(v)
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *ScopeLogs) marshal() ([]byte, error) {
+	var b []byte
+	if s.Scope != nil {
+		scope := appendStringField(nil, 1, s.Scope.Name)
+		scope = appendStringField(scope, 2, s.Scope.Version)
+		scope, err := appendKeyValues(scope, 3, s.Scope.Attributes, 0)
+		if err != nil {
+			return nil, err
+		}
+		scope = appendVarintField(scope, 4, uint64(s.Scope.DroppedAttributesCount))
+		b = appendMessageField(b, 1, scope)
+	}
+
+	for _, record := range s.LogRecords {
+		message, err := record.marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessageField(b, 2, message)
+	}
+	return appendStringField(b, 3, s.SchemaURL), nil
+}
+
+func (s *ScopeLogs) unmarshal(data []byte) error {
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			s.Scope = &Scope{}
+			if err := s.Scope.unmarshal(message); err != nil {
+				return fmt.Errorf("scope: %s", err)
+			}
+		case 2:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			record := &LogRecord{}
+			if err := record.unmarshal(message); err != nil {
+				return fmt.Errorf("log record: %s", err)
+			}
+			s.LogRecords = append(s.LogRecords, record)
+		case 3:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			v, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			s.SchemaURL = string(v)
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Scope) unmarshal(data []byte) error {
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch field {
+		case 1, 2:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			v, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			if field == 1 {
+				s.Name = string(v)
+			} else {
+				s.Version = string(v)
+			}
+		case 3:
+			kv, err := readKeyValue(reader, wireType, 0)
+			if err != nil {
+				return err
+			}
+			s.Attributes = append(s.Attributes, kv)
+		case 4:
+			if err := expect(field, wireType, wireVarint); err != nil {
+				return err
+			}
+			v, err := reader.varint()
+			if err != nil {
+				return err
+			}
+			s.DroppedAttributesCount = uint32(v)
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *LogRecord) marshal() ([]byte, error) {
+	var b []byte
+	var err error
+	b = appendFixed64Field(b, 1, l.TimeUnixNano)
+	b = appendVarintField(b, 2, uint64(l.SeverityNumber))
+	b = appendStringField(b, 3, l.SeverityText)
+	if l.Body != nil {
+		body, err := appendAnyValue(nil, l.Body, 0)
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessageField(b, 5, body)
+	}
+	if b, err = appendKeyValues(b, 6, l.Attributes, 0); err != nil {
+		return nil, err
+	}
+	b = appendVarintField(b, 7, uint64(l.DroppedAttributesCount))
+	b = appendFixed32Field(b, 8, l.Flags)
+	b = appendBytesField(b, 9, l.TraceID)
+	b = appendBytesField(b, 10, l.SpanID)
+	b = appendFixed64Field(b, 11, l.ObservedTimeUnixNano)
+	return b, nil
+}
+
+func (l *LogRecord) unmarshal(data []byte) error {
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch field {
+		case 1, 11:
+			if err := expect(field, wireType, wireFixed64); err != nil {
+				return err
+			}
+			v, err := reader.fixed64()
+			if err != nil {
+				return err
+			}
+			if field == 1 {
+				l.TimeUnixNano = v
+			} else {
+				l.ObservedTimeUnixNano = v
+			}
+		case 2, 7:
+			if err := expect(field, wireType, wireVarint); err != nil {
+				return err
+			}
+			v, err := reader.varint()
+			if err != nil {
+				return err
+			}
+			if field == 2 {
+				l.SeverityNumber = int32(v)
+			} else {
+				l.DroppedAttributesCount = uint32(v)
+			}
+		case 3, 9, 10:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			v, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			switch field {
+			case 3:
+				l.SeverityText = string(v)
+			case 9:
+				l.TraceID = v
+			default:
+				l.SpanID = v
+			}
+		case 5:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			if l.Body, err = readAnyValue(message, 0); err != nil {
+				return fmt.Errorf("body: %s", err)
+			}
+		case 6:
+			kv, err := readKeyValue(reader, wireType, 0)
+			if err != nil {
+				return err
+			}
+			l.Attributes = append(l.Attributes, kv)
+		case 8:
+			if err := expect(field, wireType, wireFixed32); err != nil {
+				return err
+			}
+			if l.Flags, err = reader.fixed32(); err != nil {
+				return err
+			}
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// appendKeyValues will append key values as repeated KeyValue messages
+func appendKeyValues(b []byte, field int, kvs []*KeyValue, depth int) ([]byte, error) {
+	for _, kv := range kvs {
+		message := appendStringField(nil, 1, kv.Key)
+		value, err := appendAnyValue(nil, kv.Value, depth)
+		if err != nil {
+			return nil, fmt.Errorf("attribute '%s': %s", kv.Key, err)
+		}
+		message = appendMessageField(message, 2, value)
+		b = appendMessageField(b, field, message)
+	}
+	return b, nil
+}
+
+// appendAnyValue will append the fields of an AnyValue message. The field of the value is
+// written even if it is the default, so that the type of the value is kept.
+func appendAnyValue(b []byte, value interface{}, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("value is nested too deeply")
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return b, nil
+	case string:
+		b = appendVarint(appendTag(b, 1, wireBytes), uint64(len(v)))
+		return append(b, v...), nil
+	case bool:
+		var i uint64
+		if v {
+			i = 1
+		}
+		return appendVarint(appendTag(b, 2, wireVarint), i), nil
+	case int64:
+		return appendVarint(appendTag(b, 3, wireVarint), uint64(v)), nil
+	case float64:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		return append(appendTag(b, 4, wireFixed64), buf[:]...), nil
+	case []interface{}:
+		var array []byte
+		for _, element := range v {
+			message, err := appendAnyValue(nil, element, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			array = appendMessageField(array, 1, message)
+		}
+		return appendMessageField(b, 5, array), nil
+	case []*KeyValue:
+		list, err := appendKeyValues(nil, 1, v, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return appendMessageField(b, 6, list), nil
+	case []byte:
+		b = appendVarint(appendTag(b, 7, wireBytes), uint64(len(v)))
+		return append(b, v...), nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", value)
+	}
+}
+
+// readKeyValue will read a KeyValue message field
+func readKeyValue(reader *fieldReader, wireType int, depth int) (*KeyValue, error) {
+	if wireType != wireBytes {
+		return nil, fmt.Errorf("invalid wire type %d of key value", wireType)
+	}
+	message, err := reader.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	kv := &KeyValue{}
+	kvReader := &fieldReader{data: message}
+	for {
+		field, wireType, ok, err := kvReader.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return kv, nil
+		}
+
+		switch field {
+		case 1:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return nil, err
+			}
+			v, err := kvReader.bytes()
+			if err != nil {
+				return nil, err
+			}
+			kv.Key = string(v)
+		case 2:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return nil, err
+			}
+			v, err := kvReader.bytes()
+			if err != nil {
+				return nil, err
+			}
+			if kv.Value, err = readAnyValue(v, depth); err != nil {
+				return nil, fmt.Errorf("attribute '%s': %s", kv.Key, err)
+			}
+		default:
+			if err := kvReader.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readAnyValue will read the value of an AnyValue message
+func readAnyValue(data []byte, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("value is nested too deeply")
+	}
+
+	var value interface{}
+	reader := &fieldReader{data: data}
+	for {
+		field, wireType, ok, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return value, nil
+		}
+
+		switch field {
+		case 1, 7:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return nil, err
+			}
+			v, err := reader.bytes()
+			if err != nil {
+				return nil, err
+			}
+			if field == 1 {
+				value = string(v)
+			} else {
+				value = v
+			}
+		case 2, 3:
+			if err := expect(field, wireType, wireVarint); err != nil {
+				return nil, err
+			}
+			v, err := reader.varint()
+			if err != nil {
+				return nil, err
+			}
+			if field == 2 {
+				value = v != 0
+			} else {
+				value = int64(v)
+			}
+		case 4:
+			if err := expect(field, wireType, wireFixed64); err != nil {
+				return nil, err
+			}
+			v, err := reader.fixed64()
+			if err != nil {
+				return nil, err
+			}
+			value = math.Float64frombits(v)
+		case 5:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return nil, err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return nil, err
+			}
+			array := []interface{}{}
+			arrayReader := &fieldReader{data: message}
+			for {
+				field, wireType, ok, err := arrayReader.next()
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				if field != 1 {
+					if err := arrayReader.skip(wireType); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if err := expect(field, wireType, wireBytes); err != nil {
+					return nil, err
+				}
+				elementMessage, err := arrayReader.bytes()
+				if err != nil {
+					return nil, err
+				}
+				element, err := readAnyValue(elementMessage, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, element)
+			}
+			value = array
+		case 6:
+			if err := expect(field, wireType, wireBytes); err != nil {
+				return nil, err
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return nil, err
+			}
+			list := []*KeyValue{}
+			listReader := &fieldReader{data: message}
+			for {
+				field, wireType, ok, err := listReader.next()
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				if field != 1 {
+					if err := listReader.skip(wireType); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				kv, err := readKeyValue(listReader, wireType, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, kv)
+			}
+			value = list
+		default:
+			if err := reader.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
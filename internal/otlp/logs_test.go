@@ -0,0 +1,196 @@
+package otlp
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportLogsServiceRequestEncoding(t *testing.T) {
+	req := &ExportLogsServiceRequest{
+		ResourceLogs: []*ResourceLogs{
+			{
+				Resource: &Resource{
+					Attributes:             []*KeyValue{{Key: "service.name", Value: "test"}},
+					DroppedAttributesCount: 1,
+				},
+				ScopeLogs: []*ScopeLogs{
+					{
+						Scope: &Scope{Name: "scope", Version: "1.0"},
+						LogRecords: []*LogRecord{
+							{
+								TimeUnixNano:         1600000000000000000,
+								ObservedTimeUnixNano: 1600000000000000001,
+								SeverityNumber:       17,
+								SeverityText:         "ERROR",
+								Body: []*KeyValue{
+									{Key: "message", Value: "test message"},
+									{Key: "bool", Value: false},
+									{Key: "int", Value: int64(-1)},
+									{Key: "float", Value: 1.5},
+									{Key: "bytes", Value: []byte{0, 1}},
+									{Key: "array", Value: []interface{}{"a", int64(0), nil}},
+									{Key: "nil", Value: nil},
+								},
+								Attributes: []*KeyValue{{Key: "key", Value: "value"}},
+								Flags:      1,
+								TraceID:    []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+								SpanID:     []byte{1, 2, 3, 4, 5, 6, 7, 8},
+							},
+						},
+						SchemaURL: "https://opentelemetry.io/schemas/1.0.0",
+					},
+				},
+			},
+		},
+	}
+
+	data, err := req.Marshal()
+	require.NoError(t, err)
+
+	decoded := &ExportLogsServiceRequest{}
+	require.NoError(t, decoded.Unmarshal(data))
+	require.Equal(t, req, decoded)
+}
+
+func TestLogRecordEncoding(t *testing.T) {
+	// The expected bytes are encoded as the generated protobuf code encodes them
+	record := &LogRecord{SeverityNumber: 9, Body: "hi", SpanID: []byte{0xff}}
+	data, err := record.marshal()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x10, 0x09, 0x2a, 0x04, 0x0a, 0x02, 'h', 'i', 0x52, 0x01, 0xff}, data)
+}
+
+func TestExportLogsServiceRequestDecoding(t *testing.T) {
+	t.Run("UnknownFields", func(t *testing.T) {
+		// resource_logs { unknown varint field 99; scope_logs { log_records { severity_number: 5 } } }
+		data := []byte{0x0a, 0x0b, 0x98, 0x06, 0x01, 0x12, 0x06, 0x12, 0x04, 0x10, 0x05, 0x7a, 0x00}
+		req := &ExportLogsServiceRequest{}
+		require.NoError(t, req.Unmarshal(data))
+		require.Len(t, req.ResourceLogs, 1)
+		require.Len(t, req.ResourceLogs[0].ScopeLogs, 1)
+		require.Equal(t, []*LogRecord{{SeverityNumber: 5}}, req.ResourceLogs[0].ScopeLogs[0].LogRecords)
+	})
+
+	t.Run("LegacyInstrumentationLibraryLogs", func(t *testing.T) {
+		// resource_logs { instrumentation_library_logs { logs { severity_number: 9 } } }
+		data := []byte{0x0a, 0x07, 0xc2, 0x3e, 0x04, 0x12, 0x02, 0x10, 0x09}
+		req := &ExportLogsServiceRequest{}
+		require.NoError(t, req.Unmarshal(data))
+		require.Len(t, req.ResourceLogs, 1)
+		require.Len(t, req.ResourceLogs[0].ScopeLogs, 1)
+		require.Equal(t, []*LogRecord{{SeverityNumber: 9}}, req.ResourceLogs[0].ScopeLogs[0].LogRecords)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := map[string][]byte{
+			"Truncated":        {0x0a, 0x05, 0x12},
+			"InvalidWireType":  {0x08, 0x01},
+			"InvalidFieldZero": {0x00},
+		}
+		for name, data := range cases {
+			t.Run(name, func(t *testing.T) {
+				require.Error(t, (&ExportLogsServiceRequest{}).Unmarshal(data))
+			})
+		}
+	})
+}
+
+func TestExportLogsServiceResponseEncoding(t *testing.T) {
+	resp := &ExportLogsServiceResponse{RejectedLogRecords: 2, ErrorMessage: "rejected"}
+	data, err := resp.Marshal()
+	require.NoError(t, err)
+
+	decoded := &ExportLogsServiceResponse{}
+	require.NoError(t, decoded.Unmarshal(data))
+	require.Equal(t, resp, decoded)
+
+	empty, err := (&ExportLogsServiceResponse{}).Marshal()
+	require.NoError(t, err)
+	require.Empty(t, empty)
+}
+
+// The golden files in testdata were encoded with the protoc generated code of the OTLP protocol
+// (go.opentelemetry.io/proto/otlp v1.3.1) from the messages matching goldenRequest and goldenResponse
+func goldenRequest() *ExportLogsServiceRequest {
+	return &ExportLogsServiceRequest{
+		ResourceLogs: []*ResourceLogs{
+			{
+				Resource: &Resource{
+					Attributes:             []*KeyValue{{Key: "service.name", Value: "carbon"}},
+					DroppedAttributesCount: 1,
+				},
+				ScopeLogs: []*ScopeLogs{
+					{
+						Scope: &Scope{
+							Name:                   "scope",
+							Version:                "1.0",
+							Attributes:             []*KeyValue{{Key: "scope.key", Value: "scope.value"}},
+							DroppedAttributesCount: 2,
+						},
+						LogRecords: []*LogRecord{
+							{
+								TimeUnixNano:         1600000000000000000,
+								ObservedTimeUnixNano: 1600000000000000001,
+								SeverityNumber:       17,
+								SeverityText:         "ERROR",
+								Body: []*KeyValue{
+									{Key: "string", Value: "test message"},
+									{Key: "bool", Value: true},
+									{Key: "false", Value: false},
+									{Key: "int", Value: int64(-1)},
+									{Key: "double", Value: 1.5},
+									{Key: "bytes", Value: []byte{0, 1, 0xff}},
+									{Key: "array", Value: []interface{}{"a", int64(0), nil}},
+									{Key: "kvlist", Value: []*KeyValue{{Key: "nested", Value: "value"}}},
+									{Key: "empty", Value: nil},
+								},
+								Attributes:             []*KeyValue{{Key: "key", Value: "value"}},
+								DroppedAttributesCount: 3,
+								Flags:                  1,
+								TraceID:                []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+								SpanID:                 []byte{1, 2, 3, 4, 5, 6, 7, 8},
+							},
+						},
+						SchemaURL: "https://opentelemetry.io/schemas/1.0.0",
+					},
+				},
+				SchemaURL: "https://opentelemetry.io/schemas/1.1.0",
+			},
+		},
+	}
+}
+
+func goldenResponse() *ExportLogsServiceResponse {
+	return &ExportLogsServiceResponse{RejectedLogRecords: 2, ErrorMessage: "rejected"}
+}
+
+func TestGoldenEncoding(t *testing.T) {
+	t.Run("Request", func(t *testing.T) {
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "export_logs_service_request.bin"))
+		require.NoError(t, err)
+
+		data, err := goldenRequest().Marshal()
+		require.NoError(t, err)
+		require.Equal(t, golden, data)
+
+		decoded := &ExportLogsServiceRequest{}
+		require.NoError(t, decoded.Unmarshal(golden))
+		require.Equal(t, goldenRequest(), decoded)
+	})
+
+	t.Run("Response", func(t *testing.T) {
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "export_logs_service_response.bin"))
+		require.NoError(t, err)
+
+		data, err := goldenResponse().Marshal()
+		require.NoError(t, err)
+		require.Equal(t, golden, data)
+
+		decoded := &ExportLogsServiceResponse{}
+		require.NoError(t, decoded.Unmarshal(golden))
+		require.Equal(t, goldenResponse(), decoded)
+	})
+}
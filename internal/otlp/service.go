@@ -0,0 +1,63 @@
+package otlp
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// LogsPath is the path of the OTLP logs service over HTTP
+const LogsPath = "/v1/logs"
+
+// exportMethod is the full name of the export method of the OTLP logs service
+const exportMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// LogsServer handles the requests of the OTLP logs service
+type LogsServer interface {
+	Export(context.Context, *ExportLogsServiceRequest) (*ExportLogsServiceResponse, error)
+}
+
+// RegisterLogsServer will register a server of the OTLP logs service with a grpc server
+func RegisterLogsServer(s *grpc.Server, srv LogsServer) {
+	s.RegisterService(&logsServiceDesc, srv)
+}
+
+var logsServiceDesc = grpc.ServiceDesc{
+	ServiceName: "opentelemetry.proto.collector.logs.v1.LogsService",
+	HandlerType: (*LogsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Export",
+			Handler:    exportHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "opentelemetry/proto/collector/logs/v1/logs_service.proto",
+}
+
+func exportHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &ExportLogsServiceRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogsServer).Export(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: exportMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogsServer).Export(ctx, req.(*ExportLogsServiceRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// Export will send a request to the OTLP logs service of a grpc connection
+func Export(ctx context.Context, conn *grpc.ClientConn, req *ExportLogsServiceRequest) (*ExportLogsServiceResponse, error) {
+	resp := &ExportLogsServiceResponse{}
+	if err := conn.Invoke(ctx, exportMethod, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
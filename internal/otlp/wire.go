@@ -0,0 +1,165 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errTruncated is returned when a message ends in the middle of a field
+var errTruncated = fmt.Errorf("truncated message")
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendVarint(appendTag(b, field, wireVarint), v)
+}
+
+func appendFixed64Field(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendFixed32Field(b []byte, field int, v uint32) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireFixed32)
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendStringField(b []byte, field int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendMessageField will append an embedded message, which is written even if it is empty
+func appendMessageField(b []byte, field int, message []byte) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(message)))
+	return append(b, message...)
+}
+
+// fieldReader reads the fields of an encoded message
+type fieldReader struct {
+	data []byte
+}
+
+// next will return the number and wire type of the next field, or false at the end of the message
+func (r *fieldReader) next() (int, int, bool, error) {
+	if len(r.data) == 0 {
+		return 0, 0, false, nil
+	}
+	tag, err := r.varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+		return 0, 0, false, fmt.Errorf("invalid field number %d", tag>>3)
+	}
+	return int(tag >> 3), int(tag & 7), true, nil
+}
+
+func (r *fieldReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *fieldReader) fixed64() (uint64, error) {
+	if len(r.data) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v, nil
+}
+
+func (r *fieldReader) fixed32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+func (r *fieldReader) bytes() ([]byte, error) {
+	length, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(r.data)) {
+		return nil, errTruncated
+	}
+	v := r.data[:length]
+	r.data = r.data[length:]
+	return v, nil
+}
+
+// skip will skip the value of a field that is not known
+func (r *fieldReader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case wireVarint:
+		_, err = r.varint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	return err
+}
+
+// expect will return an error if a known field has an unexpected wire type
+func expect(field, wireType, expected int) error {
+	if wireType != expected {
+		return fmt.Errorf("invalid wire type %d of field %d", wireType, field)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TLSFiles are the paths of the PEM encoded files of a test certificate authority,
+// and of a server and client certificate signed by it
+type TLSFiles struct {
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+// NewTLSFiles will write a new certificate authority, and a server certificate for localhost and
+// a client certificate with the given common name that are signed by it, to a temp directory
+func NewTLSFiles(t *testing.T, clientCommonName string) *TLSFiles {
+	dir := NewTempDir(t)
+	files := &TLSFiles{
+		CAFile:         filepath.Join(dir, "ca.crt"),
+		ServerCertFile: filepath.Join(dir, "server.crt"),
+		ServerKeyFile:  filepath.Join(dir, "server.key"),
+		ClientCertFile: filepath.Join(dir, "client.crt"),
+		ClientKeyFile:  filepath.Join(dir, "client.key"),
+	}
+
+	var err error
+	files.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := files.template("carbon test ca")
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &files.caKey.PublicKey, files.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.ca, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, files.CAFile, "CERTIFICATE", der)
+
+	files.WriteServerCertificate(t, "localhost")
+	files.WriteClientCertificate(t, clientCommonName)
+	return files
+}
+
+// WriteServerCertificate will replace the server certificate with a new certificate for localhost
+func (f *TLSFiles) WriteServerCertificate(t *testing.T, commonName string) {
+	template := f.template(commonName)
+	template.DNSNames = []string{"localhost"}
+	template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	f.writeCertificate(t, template, f.ServerCertFile, f.ServerKeyFile)
+}
+
+// WriteClientCertificate will replace the client certificate with a new certificate
+func (f *TLSFiles) WriteClientCertificate(t *testing.T, commonName string) {
+	template := f.template(commonName)
+	template.Subject.Organization = []string{"carbon"}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	f.writeCertificate(t, template, f.ClientCertFile, f.ClientKeyFile)
+}
+
+// ClientConfig returns a tls client config that trusts the certificate authority and uses the client certificate
+func (f *TLSFiles) ClientConfig(t *testing.T) *tls.Config {
+	certificate, err := tls.LoadX509KeyPair(f.ClientCertFile, f.ClientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := f.ClientConfigWithoutCertificate()
+	config.Certificates = []tls.Certificate{certificate}
+	return config
+}
+
+// ClientConfigWithoutCertificate returns a tls client config that trusts the certificate authority
+func (f *TLSFiles) ClientConfigWithoutCertificate() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(f.ca)
+	return &tls.Config{
+		RootCAs:    pool,
+		ServerName: "localhost",
+	}
+}
+
+func (f *TLSFiles) template(commonName string) *x509.Certificate {
+	f.serial++
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(f.serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+func (f *TLSFiles) writeCertificate(t *testing.T, template *x509.Certificate, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, f.ca, &key.PublicKey, f.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	encoded := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(path, encoded, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -17,6 +17,23 @@ type Buffer interface {
 	AddWait(context.Context, interface{}, int) error
 	SetHandler(BundleHandler)
 	Process(context.Context, *entry.Entry) error
+	DeliveryFailures() int64
+}
+
+// PartialError is returned by a bundle handler that failed to handle only some of the entries of a bundle.
+// Only the entries of the error are retried.
+type PartialError struct {
+	Entries []*entry.Entry
+	Err     error
+}
+
+// NewPartialError will create an error for the entries of a bundle that should be retried
+func NewPartialError(entries []*entry.Entry, err error) *PartialError {
+	return &PartialError{Entries: entries, Err: err}
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error()
 }
 
 func NewConfig() Config {
@@ -17,6 +17,10 @@ type MemoryBuffer struct {
 	*bundler.Bundler
 	config *Config
 	cancel context.CancelFunc
+
+	// pending is the number of entries that have been added, but not yet handled
+	pending int64
+	failed  int64
 }
 
 // NewMemoryBuffer will return a new memory buffer with the supplied configuration
@@ -36,19 +40,27 @@ func (m *MemoryBuffer) SetHandler(handler BundleHandler) {
 	currentBundleID := int64(0)
 	handleFunc := func(entries interface{}) {
 		bundleID := atomic.AddInt64(&currentBundleID, 1)
+		bundle := entries.([]*entry.Entry)
+		defer atomic.AddInt64(&m.pending, -int64(len(bundle)))
+
 		b := m.NewExponentialBackOff()
 		for {
-			err := handler.ProcessMulti(ctx, entries.([]*entry.Entry))
+			err := handler.ProcessMulti(ctx, bundle)
+			if partialErr, ok := err.(*PartialError); ok {
+				bundle = partialErr.Entries
+			}
 			if err != nil {
 				duration := b.NextBackOff()
 				if duration == backoff.Stop {
 					handler.Logger().Errorw("Failed to flush bundle. Not retrying because we are beyond max backoff", zap.Any("error", err), "bundle_id", bundleID)
+					atomic.AddInt64(&m.failed, int64(len(bundle)))
 					break
 				} else {
 					handler.Logger().Warnw("Failed to flush bundle", zap.Any("error", err), "backoff_time", duration.String(), "bundle_id", bundleID)
 					select {
 					case <-ctx.Done():
 						handler.Logger().Debugw("Flush retry cancelled by context", "bundle_id", bundleID)
+						atomic.AddInt64(&m.failed, int64(len(bundle)))
 						return
 					case <-time.After(duration):
 						continue
@@ -94,7 +106,19 @@ func (m *MemoryBuffer) Process(ctx context.Context, entry *entry.Entry) error {
 		panic("must call SetHandler before any calls to Process")
 	}
 
-	return m.AddWait(ctx, entry, 100)
+	atomic.AddInt64(&m.pending, 1)
+	if err := m.AddWait(ctx, entry, 100); err != nil {
+		atomic.AddInt64(&m.pending, -1)
+		atomic.AddInt64(&m.failed, 1)
+		return err
+	}
+	return nil
+}
+
+// DeliveryFailures returns the number of entries that failed to be handled, along with
+// the entries that have not been handled yet, which are lost if the buffer is discarded
+func (m *MemoryBuffer) DeliveryFailures() int64 {
+	return atomic.LoadInt64(&m.failed) + atomic.LoadInt64(&m.pending)
 }
 
 // NewExponentialBackOff will return a new exponential backoff for the memory buffer to use
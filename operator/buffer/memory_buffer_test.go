@@ -44,6 +44,26 @@ func newMockHandler(t *testing.T) *mockHandler {
 	}
 }
 
+// partialHandler fails to handle the last entry of the first bundle it receives
+type partialHandler struct {
+	received chan []*entry.Entry
+	failed   bool
+	logger   *zap.SugaredLogger
+}
+
+func (h *partialHandler) ProcessMulti(ctx context.Context, entries []*entry.Entry) error {
+	h.received <- entries
+	if h.failed {
+		return nil
+	}
+	h.failed = true
+	return NewPartialError(entries[len(entries)-1:], fmt.Errorf("test failure"))
+}
+
+func (h *partialHandler) Logger() *zap.SugaredLogger {
+	return h.logger
+}
+
 func TestMemoryBufferRetry(t *testing.T) {
 	t.Run("FailOnce", func(t *testing.T) {
 		cfg := NewConfig()
@@ -66,6 +86,33 @@ func TestMemoryBufferRetry(t *testing.T) {
 		<-handler.success
 	})
 
+	t.Run("PartialFailure", func(t *testing.T) {
+		cfg := NewConfig()
+		cfg.DelayThreshold = operator.Duration{Duration: 10 * time.Millisecond}
+		cfg.Retry.InitialInterval = operator.Duration{Duration: time.Millisecond}
+		buffer, err := cfg.Build()
+		require.NoError(t, err)
+
+		// The handler fails to handle the last entry of the first bundle
+		received := make(chan []*entry.Entry, 2)
+		handler := &partialHandler{received: received, logger: zaptest.NewLogger(t).Sugar()}
+		buffer.SetHandler(handler)
+
+		for _, record := range []string{"first", "second"} {
+			e := entry.New()
+			e.Record = record
+			require.NoError(t, buffer.Process(context.Background(), e))
+		}
+		require.NoError(t, buffer.Flush(context.Background()))
+
+		// Only the failed entry is retried
+		require.Len(t, <-received, 2)
+		retried := <-received
+		require.Len(t, retried, 1)
+		require.Equal(t, "second", retried[0].Record)
+		require.Equal(t, int64(0), buffer.DeliveryFailures())
+	})
+
 	t.Run("ContextCancelled", func(t *testing.T) {
 		cfg := NewConfig()
 		cfg.DelayThreshold = operator.Duration{Duration: 10 * time.Millisecond}
@@ -118,6 +165,9 @@ func TestMemoryBufferRetry(t *testing.T) {
 			require.FailNow(t, "Received unexpected success")
 		case <-time.After(200 * time.Millisecond):
 		}
+
+		// The entry is reported as failed
+		require.Equal(t, int64(1), buffer.DeliveryFailures())
 	})
 }
 
@@ -152,6 +202,11 @@ func TestMemoryBufferFlush(t *testing.T) {
 		<-handler.received
 		handler.fail <- false
 		<-handler.success
+
+		// Delivered entries are not reported as failed
+		require.Eventually(t, func() bool {
+			return buffer.DeliveryFailures() == 0
+		}, time.Second, 10*time.Millisecond)
 	})
 
 	t.Run("ContextCancelled", func(t *testing.T) {
@@ -190,6 +245,9 @@ func TestMemoryBufferFlush(t *testing.T) {
 			require.FailNow(t, "Failed to flush in reasonable amount of time")
 		}
 
+		// Entries that have not been flushed are reported as failed
+		require.Equal(t, int64(1), buffer.DeliveryFailures())
+
 		// After flushed is called, we should receive a log still, since we
 		// timed out and ignored cleanup
 		<-handler.received
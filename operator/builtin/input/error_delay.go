@@ -0,0 +1,37 @@
+package input
+
+import (
+	"context"
+	"time"
+)
+
+// The delay before retrying after consecutive errors grows from the minimum to the maximum, like the delay
+// of net/http.Server after temporary accept errors, so that a persistent error such as running out of
+// file descriptors does not spin
+const (
+	minErrorDelay = 5 * time.Millisecond
+	maxErrorDelay = time.Second
+)
+
+// nextErrorDelay will return the delay after an error, given the delay after the previous consecutive error
+func nextErrorDelay(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return minErrorDelay
+	}
+	if delay *= 2; delay > maxErrorDelay {
+		return maxErrorDelay
+	}
+	return delay
+}
+
+// waitErrorDelay will wait before retrying after an error. It returns false if the context is done first.
+func waitErrorDelay(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
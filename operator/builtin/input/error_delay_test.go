@@ -0,0 +1,30 @@
+package input
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextErrorDelay(t *testing.T) {
+	var delays []time.Duration
+	var delay time.Duration
+	for i := 0; i < 10; i++ {
+		delay = nextErrorDelay(delay)
+		delays = append(delays, delay)
+	}
+
+	require.Equal(t, minErrorDelay, delays[0])
+	require.Equal(t, 2*minErrorDelay, delays[1])
+	require.Equal(t, maxErrorDelay, delays[9])
+}
+
+func TestWaitErrorDelay(t *testing.T) {
+	require.True(t, waitErrorDelay(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, waitErrorDelay(ctx, time.Hour))
+}
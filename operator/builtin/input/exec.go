@@ -0,0 +1,375 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/file"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("exec_input", func() operator.Builder { return NewExecInputConfig("") })
+}
+
+// Modes supported by the exec input
+const (
+	execModeStream   = "stream"
+	execModeInterval = "interval"
+)
+
+func NewExecInputConfig(operatorID string) *ExecInputConfig {
+	return &ExecInputConfig{
+		InputConfig: helper.NewInputConfig(operatorID, "exec_input"),
+		Mode:        execModeStream,
+		Interval:    operator.Duration{Duration: time.Minute},
+		RestartBackoff: ExecBackoffConfig{
+			InitialInterval: operator.Duration{Duration: time.Second},
+			MaxInterval:     operator.Duration{Duration: time.Minute},
+		},
+		Framing:          framingNewline,
+		MaxLogSize:       defaultMaxMessageSize,
+		MaxEntriesPerRun: 10000,
+	}
+}
+
+// ExecInputConfig is the configuration of an exec input operator.
+type ExecInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Command          []string              `json:"command,omitempty"             yaml:"command,omitempty"`
+	WorkingDirectory string                `json:"working_directory,omitempty"   yaml:"working_directory,omitempty"`
+	Environment      map[string]string     `json:"environment,omitempty"         yaml:"environment,omitempty"`
+	Mode             string                `json:"mode,omitempty"                yaml:"mode,omitempty"`
+	Interval         operator.Duration     `json:"interval,omitempty"            yaml:"interval,omitempty"`
+	Timeout          operator.Duration     `json:"timeout,omitempty"             yaml:"timeout,omitempty"`
+	RestartBackoff   ExecBackoffConfig     `json:"restart_backoff,omitempty"     yaml:"restart_backoff,omitempty"`
+	Framing          string                `json:"framing,omitempty"             yaml:"framing,omitempty"`
+	Multiline        *file.MultilineConfig `json:"multiline,omitempty"           yaml:"multiline,omitempty"`
+	MaxLogSize       int                   `json:"max_log_size,omitempty"        yaml:"max_log_size,omitempty"`
+	MaxEntriesPerRun int                   `json:"max_entries_per_run,omitempty" yaml:"max_entries_per_run,omitempty"`
+}
+
+// ExecBackoffConfig is the configuration of the delay before a command that exited is restarted
+type ExecBackoffConfig struct {
+	InitialInterval operator.Duration `json:"initial_interval,omitempty" yaml:"initial_interval,omitempty"`
+	MaxInterval     operator.Duration `json:"max_interval,omitempty"     yaml:"max_interval,omitempty"`
+}
+
+// Build will build an exec input operator.
+func (c ExecInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return nil, fmt.Errorf("missing required parameter 'command'")
+	}
+
+	switch c.Mode {
+	case execModeStream:
+		if c.RestartBackoff.InitialInterval.Raw() <= 0 {
+			return nil, fmt.Errorf("restart_backoff initial_interval must be greater than 0")
+		}
+		if c.RestartBackoff.MaxInterval.Raw() < c.RestartBackoff.InitialInterval.Raw() {
+			return nil, fmt.Errorf("restart_backoff max_interval must not be less than initial_interval")
+		}
+		if c.Timeout.Raw() != 0 {
+			return nil, fmt.Errorf("timeout can only be configured for mode '%s'", execModeInterval)
+		}
+	case execModeInterval:
+		if c.Interval.Raw() <= 0 {
+			return nil, fmt.Errorf("interval must be greater than 0")
+		}
+		if c.MaxEntriesPerRun <= 0 {
+			return nil, fmt.Errorf("max_entries_per_run must be greater than 0")
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode '%s'", c.Mode)
+	}
+
+	if c.Timeout.Raw() < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
+	}
+
+	// The entries of a run are held until the command exits, so a run that does not exit must be killed
+	timeout := c.Timeout.Raw()
+	if c.Mode == execModeInterval && timeout == 0 {
+		timeout = c.Interval.Raw()
+	}
+
+	if c.MaxLogSize <= 0 {
+		return nil, fmt.Errorf("max_log_size must be greater than 0")
+	}
+
+	if c.Framing == framingOctetCounting || c.Framing == framingAuto {
+		return nil, fmt.Errorf("framing '%s' is not supported for command output", c.Framing)
+	}
+	splitFunc, err := newFramingSplitFunc(c.Framing, c.Multiline, c.MaxLogSize)
+	if err != nil {
+		return nil, err
+	}
+
+	env := os.Environ()
+	for key, value := range c.Environment {
+		env = append(env, key+"="+value)
+	}
+
+	execInput := &ExecInput{
+		InputOperator:  inputOperator,
+		command:        c.Command,
+		dir:            c.WorkingDirectory,
+		env:            env,
+		mode:           c.Mode,
+		interval:       c.Interval.Raw(),
+		timeout:        timeout,
+		initialBackoff: c.RestartBackoff.InitialInterval.Raw(),
+		maxBackoff:     c.RestartBackoff.MaxInterval.Raw(),
+		splitFunc:      splitFunc,
+		maxLogSize:     c.MaxLogSize,
+		maxEntries:     c.MaxEntriesPerRun,
+	}
+	return execInput, nil
+}
+
+// ExecInput is an operator that reads log entries from the output of a command.
+type ExecInput struct {
+	helper.InputOperator
+	command        []string
+	dir            string
+	env            []string
+	mode           string
+	interval       time.Duration
+	timeout        time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	splitFunc      bufio.SplitFunc
+	maxLogSize     int
+	maxEntries     int
+
+	cancel    context.CancelFunc
+	waitGroup *sync.WaitGroup
+}
+
+// Start will start running the command.
+func (e *ExecInput) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.waitGroup = &sync.WaitGroup{}
+
+	e.waitGroup.Add(1)
+	go func() {
+		defer e.waitGroup.Done()
+		if e.mode == execModeInterval {
+			e.runOnInterval(ctx)
+			return
+		}
+		e.runStream(ctx)
+	}()
+	return nil
+}
+
+// Stop will stop running the command, killing it if it is running.
+func (e *ExecInput) Stop() error {
+	e.cancel()
+	e.waitGroup.Wait()
+	return nil
+}
+
+// runStream will keep the command running, restarting it with a backoff whenever it exits
+func (e *ExecInput) runStream(ctx context.Context) {
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     e.initialBackoff,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         e.maxBackoff,
+		Stop:                backoff.Stop,
+		Clock:               backoff.SystemClock,
+	}
+	b.Reset()
+
+	for {
+		started := time.Now()
+		exitCode, err := e.run(ctx, func(message, stream string) {
+			e.write(ctx, message, map[string]string{"stream": stream})
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err != nil {
+			e.Errorw("Failed to run command", zap.Error(err))
+		} else {
+			e.Warnw("Command exited", "exit_code", exitCode)
+		}
+
+		// A command that ran for a while is restarted quickly, so that rare restarts are not delayed
+		if time.Since(started) >= e.maxBackoff {
+			b.Reset()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.NextBackOff()):
+		}
+	}
+}
+
+// runOnInterval will run the command on an interval. The entries of a run are written once the command
+// exits, so that they can be labeled with its exit code.
+func (e *ExecInput) runOnInterval(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce will run the command a single time, and write the entries of its output. The command is killed
+// if it does not exit before the timeout, or if it writes more entries than can be held until it exits.
+func (e *ExecInput) runOnce(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var mux sync.Mutex
+	var entries []*entry.Entry
+	var exceeded bool
+	exitCode, err := e.run(runCtx, func(message, stream string) {
+		mux.Lock()
+		defer mux.Unlock()
+		if len(entries) >= e.maxEntries {
+			if !exceeded {
+				exceeded = true
+				cancel()
+			}
+			return
+		}
+
+		entry := e.NewEntry(message)
+		entry.AddLabel("stream", stream)
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		e.Errorw("Failed to run command", zap.Error(err))
+		return
+	}
+
+	switch {
+	case ctx.Err() != nil:
+	case exceeded:
+		e.Errorw("Killed command that wrote more than max_entries_per_run entries. The rest of its output was discarded",
+			"max_entries_per_run", e.maxEntries)
+	case runCtx.Err() == context.DeadlineExceeded:
+		e.Warnw("Killed command that did not exit before the timeout", "timeout", e.timeout)
+	}
+
+	for _, entry := range entries {
+		entry.AddLabel("exit_code", strconv.Itoa(exitCode))
+		e.Write(ctx, entry)
+	}
+}
+
+// run will run the command until it exits, handling each message of its output, and return its exit code
+func (e *ExecInput) run(ctx context.Context, handle func(message, stream string)) (int, error) {
+	cmd := exec.Command(e.command[0], e.command[1:]...)
+	cmd.Dir = e.dir
+	cmd.Env = e.env
+	startProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	// The processes started by the command are killed as well, since they could keep its output open
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := killProcessGroup(cmd); err != nil {
+				e.Debugw("Failed to kill command", zap.Error(err))
+			}
+		case <-done:
+		}
+	}()
+
+	// The output must be read completely before waiting for the command to exit
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		e.scan(stdout, "stdout", handle)
+	}()
+	go func() {
+		defer wg.Done()
+		e.scan(stderr, "stderr", handle)
+	}()
+	wg.Wait()
+
+	err = cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// The exit code is -1 if the command was killed by a signal
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// scan will split the output of a stream into messages
+func (e *ExecInput) scan(reader io.Reader, stream string, handle func(message, stream string)) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 1024*64), e.maxLogSize)
+	scanner.Split(e.splitFunc)
+	for scanner.Scan() {
+		handle(scanner.Text(), stream)
+	}
+
+	if err := scanner.Err(); err != nil {
+		e.Warnw("Discarding command output that could not be read", "stream", stream, zap.Error(err))
+		// The rest of the output is discarded, so that the command is not blocked writing it
+		_, _ = io.Copy(ioutil.Discard, reader)
+	}
+}
+
+func (e *ExecInput) write(ctx context.Context, message string, labels map[string]string) {
+	entry := e.NewEntry(message)
+	for key, value := range labels {
+		entry.AddLabel(key, value)
+	}
+	e.Write(ctx, entry)
+}
@@ -0,0 +1,15 @@
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package input
+
+import (
+	"os/exec"
+)
+
+// startProcessGroup does nothing, since process groups are not supported on this platform
+func startProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup will kill a command. The processes it started are not killed on this platform.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
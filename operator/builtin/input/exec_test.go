@@ -0,0 +1,258 @@
+package input
+
+import (
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/file"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are run with sh")
+	}
+
+	basicExecInputConfig := func(script string) *ExecInputConfig {
+		cfg := NewExecInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		cfg.Command = []string{"sh", "-c", script}
+		cfg.RestartBackoff.InitialInterval = operator.Duration{Duration: 10 * time.Millisecond}
+		cfg.RestartBackoff.MaxInterval = operator.Duration{Duration: 10 * time.Millisecond}
+		return cfg
+	}
+
+	// startExecInput will build and start an exec input, returning a channel of the entries it writes
+	startExecInput := func(t *testing.T, cfg *ExecInputConfig) (*ExecInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		execInput := newOperator.(*ExecInput)
+		execInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 100)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, execInput.Start())
+		return execInput, entryChan
+	}
+
+	expectEntries := func(t *testing.T, entryChan chan *entry.Entry, count int) []*entry.Entry {
+		entries := make([]*entry.Entry, 0, count)
+		for i := 0; i < count; i++ {
+			select {
+			case e := <-entryChan:
+				entries = append(entries, e)
+			case <-time.After(2 * time.Second):
+				require.FailNow(t, "Timed out waiting for entry to be written")
+			}
+		}
+		return entries
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo out; echo err >&2; exec sleep 10")
+		execInput, entryChan := startExecInput(t, cfg)
+
+		entries := expectEntries(t, entryChan, 2)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Record.(string) > entries[j].Record.(string) })
+		require.Equal(t, "out", entries[0].Record)
+		require.Equal(t, map[string]string{"stream": "stdout"}, entries[0].Labels)
+		require.Equal(t, "err", entries[1].Record)
+		require.Equal(t, map[string]string{"stream": "stderr"}, entries[1].Labels)
+
+		// Stopping kills the long running command
+		stopped := make(chan struct{})
+		go func() {
+			require.NoError(t, execInput.Stop())
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for the command to be killed")
+		}
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo started")
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		for _, e := range expectEntries(t, entryChan, 3) {
+			require.Equal(t, "started", e.Record)
+		}
+	})
+
+	t.Run("KillsChildProcesses", func(t *testing.T) {
+		// The background process keeps the output open after the command is killed
+		cfg := basicExecInputConfig("sleep 10 & echo started; wait")
+		execInput, entryChan := startExecInput(t, cfg)
+		expectEntries(t, entryChan, 1)
+
+		stopped := make(chan struct{})
+		go func() {
+			require.NoError(t, execInput.Stop())
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for the command to be killed")
+		}
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo healthy; echo warning >&2; exit 3")
+		cfg.Mode = execModeInterval
+		cfg.Interval = operator.Duration{Duration: 50 * time.Millisecond}
+		cfg.Timeout = operator.Duration{Duration: 2 * time.Second}
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		// Each run writes its entries with the exit code of the command
+		entries := expectEntries(t, entryChan, 4)
+		for _, e := range entries {
+			require.Equal(t, "3", e.Labels["exit_code"])
+		}
+		sort.Slice(entries[:2], func(i, j int) bool { return entries[i].Record.(string) < entries[j].Record.(string) })
+		require.Equal(t, "healthy", entries[0].Record)
+		require.Equal(t, "stdout", entries[0].Labels["stream"])
+		require.Equal(t, "warning", entries[1].Record)
+		require.Equal(t, "stderr", entries[1].Labels["stream"])
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo before; sleep 10; echo after")
+		cfg.Mode = execModeInterval
+		cfg.Timeout = operator.Duration{Duration: 100 * time.Millisecond}
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		e := expectEntries(t, entryChan, 1)[0]
+		require.Equal(t, "before", e.Record)
+		require.Equal(t, "-1", e.Labels["exit_code"])
+	})
+
+	t.Run("DefaultTimeout", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo before; sleep 10; echo after")
+		cfg.Mode = execModeInterval
+		cfg.Interval = operator.Duration{Duration: 100 * time.Millisecond}
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		// A run that does not exit is killed after the interval
+		e := expectEntries(t, entryChan, 1)[0]
+		require.Equal(t, "before", e.Record)
+		require.Equal(t, "-1", e.Labels["exit_code"])
+	})
+
+	t.Run("MaxEntriesPerRun", func(t *testing.T) {
+		cfg := basicExecInputConfig("while true; do echo line; done")
+		cfg.Mode = execModeInterval
+		cfg.Interval = operator.Duration{Duration: time.Hour}
+		cfg.MaxEntriesPerRun = 3
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		// The command is killed once it writes more entries than are held, and the held entries are written
+		entries := expectEntries(t, entryChan, 3)
+		for _, e := range entries {
+			require.Equal(t, "line", e.Record)
+			require.Equal(t, "-1", e.Labels["exit_code"])
+		}
+		select {
+		case e := <-entryChan:
+			require.FailNow(t, "Unexpected entry", e.Record)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo $GREETING; pwd")
+		cfg.Mode = execModeInterval
+		cfg.Environment = map[string]string{"GREETING": "hello"}
+		cfg.WorkingDirectory = "/"
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		entries := expectEntries(t, entryChan, 2)
+		require.Equal(t, "hello", entries[0].Record)
+		require.Equal(t, "/", entries[1].Record)
+	})
+
+	t.Run("Multiline", func(t *testing.T) {
+		cfg := basicExecInputConfig(`printf 'start 1\n  continued\nstart 2\n'`)
+		cfg.Mode = execModeInterval
+		cfg.Framing = framingMultiline
+		cfg.Multiline = &file.MultilineConfig{LineStartPattern: "start "}
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		entries := expectEntries(t, entryChan, 2)
+		require.Equal(t, "start 1\n  continued\n", entries[0].Record)
+		require.Equal(t, "start 2\n", entries[1].Record)
+	})
+
+	t.Run("MessageTooLarge", func(t *testing.T) {
+		cfg := basicExecInputConfig("echo short; echo this message is too long; echo next")
+		cfg.Mode = execModeInterval
+		cfg.MaxLogSize = 10
+		execInput, entryChan := startExecInput(t, cfg)
+		defer execInput.Stop()
+
+		// The output after a message that is too large is discarded, without blocking the command
+		e := expectEntries(t, entryChan, 1)[0]
+		require.Equal(t, "short", e.Record)
+		require.Equal(t, "0", e.Labels["exit_code"])
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*ExecInputConfig)
+		}{
+			{"MissingCommand", func(c *ExecInputConfig) { c.Command = nil }},
+			{"EmptyCommand", func(c *ExecInputConfig) { c.Command = []string{""} }},
+			{"InvalidMode", func(c *ExecInputConfig) { c.Mode = "cron" }},
+			{"ZeroInterval", func(c *ExecInputConfig) {
+				c.Mode = execModeInterval
+				c.Interval = operator.Duration{}
+			}},
+			{"StreamTimeout", func(c *ExecInputConfig) { c.Timeout = operator.Duration{Duration: time.Second} }},
+			{"NegativeTimeout", func(c *ExecInputConfig) {
+				c.Mode = execModeInterval
+				c.Timeout = operator.Duration{Duration: -time.Second}
+			}},
+			{"ZeroBackoff", func(c *ExecInputConfig) { c.RestartBackoff.InitialInterval = operator.Duration{} }},
+			{"MaxBackoffLessThanInitial", func(c *ExecInputConfig) {
+				c.RestartBackoff.MaxInterval = operator.Duration{Duration: time.Millisecond}
+			}},
+			{"ZeroMaxLogSize", func(c *ExecInputConfig) { c.MaxLogSize = 0 }},
+			{"ZeroMaxEntriesPerRun", func(c *ExecInputConfig) {
+				c.Mode = execModeInterval
+				c.MaxEntriesPerRun = 0
+			}},
+			{"OctetCountingFraming", func(c *ExecInputConfig) { c.Framing = framingOctetCounting }},
+			{"MultilineWithoutFraming", func(c *ExecInputConfig) { c.Multiline = &file.MultilineConfig{LineStartPattern: "^start"} }},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicExecInputConfig("echo test")
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
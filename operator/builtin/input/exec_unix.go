@@ -0,0 +1,18 @@
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package input
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startProcessGroup will run a command in a new process group, so that the processes it starts can be killed with it
+func startProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup will kill a command and the processes it started
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
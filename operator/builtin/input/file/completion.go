@@ -0,0 +1,123 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+const (
+	onCompleteNone   = ""
+	onCompleteDelete = "delete"
+	onCompleteMove   = "move"
+)
+
+// validateOnComplete will return an error if the configured completion action is not supported
+func validateOnComplete(onComplete, moveTo string, startAtBeginning bool) error {
+	switch onComplete {
+	case onCompleteNone, onCompleteDelete:
+		if moveTo != "" {
+			return fmt.Errorf("move_to can only be used with on_complete '%s'", onCompleteMove)
+		}
+	case onCompleteMove:
+		if moveTo == "" {
+			return fmt.Errorf("move_to is required with on_complete '%s'", onCompleteMove)
+		}
+	default:
+		return fmt.Errorf("invalid on_complete '%s'", onComplete)
+	}
+
+	// Files that exist at startup are not read when starting at the end, so they must not be completed
+	if onComplete != onCompleteNone && !startAtBeginning {
+		return fmt.Errorf("on_complete can only be used with start_at 'beginning'")
+	}
+	return nil
+}
+
+// markCompleted will mark a file as completed if it has been read to the end,
+// and its size did not change since the previous time it was read
+func (f *InputOperator) markCompleted(path string) {
+	if f.onComplete == onCompleteNone {
+		return
+	}
+
+	knownFile, ok := f.knownFiles[path]
+	if !ok || knownFile.Path != path {
+		return
+	}
+
+	if knownFile.sizeUnchanged && knownFile.Offset >= knownFile.LastSeenFileSize {
+		knownFile.completed = true
+	}
+}
+
+// checkIncompleteFiles will check files that have been read to the end, but have not been
+// completed yet. When polling, every file is already checked on each poll, but when
+// watching for changes, files are only checked when they change.
+func (f *InputOperator) checkIncompleteFiles(ctx context.Context) {
+	if f.onComplete == onCompleteNone {
+		return
+	}
+
+	for path, knownFile := range f.knownFiles {
+		if knownFile.Path != path || knownFile.completed || knownFile.Offset < knownFile.LastSeenFileSize {
+			continue
+		}
+		f.checkFile(ctx, path, false)
+	}
+}
+
+// completeFiles will take the completion action for every completed file. It must only be
+// called after the final offsets of the completed files have been persisted, so that their
+// entries are never read again if the action fails part way through.
+func (f *InputOperator) completeFiles() {
+	for path, knownFile := range f.knownFiles {
+		if !knownFile.completed || knownFile.Path != path {
+			continue
+		}
+
+		if _, ok := f.runningFiles[path]; ok || f.queue.contains(path) {
+			continue
+		}
+
+		// Do not lose data that was written after the file was read
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				delete(f.knownFiles, path)
+			}
+			continue
+		}
+		if info.Size() != knownFile.Offset {
+			knownFile.completed = false
+			continue
+		}
+
+		if err := f.complete(path); err != nil {
+			f.Warnw("Failed to complete file", "action", f.onComplete, "path", path, zap.Error(err))
+			knownFile.completed = false
+			continue
+		}
+
+		delete(f.knownFiles, path)
+		delete(f.pendingFiles, path)
+	}
+}
+
+// complete will delete or move a file that has been completely read
+func (f *InputOperator) complete(path string) error {
+	switch f.onComplete {
+	case onCompleteDelete:
+		return os.Remove(path)
+	case onCompleteMove:
+		if err := os.MkdirAll(f.moveTo, 0755); err != nil {
+			return fmt.Errorf("create move_to directory: %s", err)
+		}
+		return os.Rename(path, filepath.Join(f.moveTo, filepath.Base(path)))
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator/helper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource_IgnoreOlder(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.IgnoreOlder = 24 * time.Hour
+
+	oldPath := filepath.Join(tempDir, "old.log")
+	require.NoError(t, ioutil.WriteFile(oldPath, []byte("testlog1\n"), 0666))
+	oldTime := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(oldPath, oldTime, oldTime))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "new.log"), []byte("testlog2\n"), 0666))
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessage(t, logReceived, "testlog2")
+	expectNoMessages(t, logReceived)
+}
+
+func TestFileSource_OnCompleteDelete(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.onComplete = onCompleteDelete
+
+	path := filepath.Join(tempDir, "spool.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("testlog1\ntestlog2\n"), 0666))
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessages(t, logReceived, []string{"testlog1", "testlog2"})
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+	expectNoMessages(t, logReceived)
+}
+
+func TestFileSource_OnCompleteMove(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*.log", tempDir)}
+	source.onComplete = onCompleteMove
+	source.moveTo = filepath.Join(tempDir, "done")
+
+	temp, err := ioutil.TempFile(tempDir, "*.log")
+	require.NoError(t, err)
+	defer temp.Close()
+
+	_, err = temp.WriteString("testlog1\n")
+	require.NoError(t, err)
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessage(t, logReceived, "testlog1")
+
+	// Files that are still being written to are not completed, so later writes are still read
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, err = temp.WriteString(fmt.Sprintf("testlog%d\n", i+2))
+		require.NoError(t, err)
+		waitForMessage(t, logReceived, fmt.Sprintf("testlog%d", i+2))
+	}
+
+	movedPath := filepath.Join(source.moveTo, filepath.Base(temp.Name()))
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(movedPath)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = os.Stat(temp.Name())
+	require.True(t, os.IsNotExist(err))
+	contents, err := ioutil.ReadFile(movedPath)
+	require.NoError(t, err)
+	require.Equal(t, "testlog1\ntestlog2\ntestlog3\ntestlog4\ntestlog5\ntestlog6\n", string(contents))
+}
+
+// failingPersister is a persister that fails to sync to its backend
+type failingPersister struct {
+	helper.Persister
+}
+
+func (p *failingPersister) Sync() error {
+	return fmt.Errorf("sync failed")
+}
+
+func TestFileSource_OnCompleteRequiresPersistedOffset(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.onComplete = onCompleteDelete
+	source.persist = &failingPersister{source.persist}
+
+	path := filepath.Join(tempDir, "spool.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("testlog1\n"), 0666))
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessage(t, logReceived, "testlog1")
+	time.Sleep(300 * time.Millisecond)
+	require.FileExists(t, path)
+}
@@ -0,0 +1,87 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressionNone = ""
+	compressionAuto = "auto"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// validateCompression will return an error if the configured compression is not supported
+func validateCompression(compression string) error {
+	switch compression {
+	case compressionNone, compressionAuto, compressionGzip, compressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("invalid compression '%s'", compression)
+	}
+}
+
+// detectCompression will determine the compression format of a file. In auto mode,
+// the file extension is checked first, and the magic bytes of the file are used as a
+// fallback. The file is left positioned at its beginning.
+func detectCompression(file *os.File, compression string) (string, error) {
+	if compression != compressionAuto {
+		return compression, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(file.Name())) {
+	case ".gz", ".gzip":
+		return compressionGzip, nil
+	case ".zst", ".zstd":
+		return compressionZstd, nil
+	}
+
+	header := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return compressionGzip, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		return compressionZstd, nil
+	default:
+		return compressionNone, nil
+	}
+}
+
+// newDecompressor will wrap a file in a streaming reader for the supplied compression format
+func newDecompressor(file io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case compressionGzip:
+		return gzip.NewReader(file)
+	case compressionZstd:
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression '%s'", compression)
+	}
+}
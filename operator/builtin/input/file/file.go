@@ -11,10 +11,12 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/observiq/carbon/entry"
 	"github.com/observiq/carbon/operator"
 	"github.com/observiq/carbon/operator/helper"
@@ -37,6 +39,9 @@ func NewInputConfig(operatorID string) *InputConfig {
 		StartAt:       "end",
 		MaxLogSize:    1024 * 1024,
 		Encoding:      "nop",
+		WatchMode:     watchModePoll,
+
+		MaxConcurrentFiles: 512,
 	}
 }
 
@@ -44,8 +49,9 @@ func NewInputConfig(operatorID string) *InputConfig {
 type InputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
-	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Include     []string `json:"include,omitempty"      yaml:"include,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"      yaml:"exclude,omitempty"`
+	ExcludeDirs []string `json:"exclude_dirs,omitempty" yaml:"exclude_dirs,omitempty"`
 
 	PollInterval  operator.Duration `json:"poll_interval,omitempty"   yaml:"poll_interval,omitempty"`
 	Multiline     *MultilineConfig  `json:"multiline,omitempty"       yaml:"multiline,omitempty"`
@@ -54,12 +60,25 @@ type InputConfig struct {
 	StartAt       string            `json:"start_at,omitempty"        yaml:"start_at,omitempty"`
 	MaxLogSize    int               `json:"max_log_size,omitempty"    yaml:"max_log_size,omitempty"`
 	Encoding      string            `json:"encoding,omitempty"        yaml:"encoding,omitempty"`
+	Compression   string            `json:"compression,omitempty"     yaml:"compression,omitempty"`
+	WatchMode     string            `json:"watch_mode,omitempty"      yaml:"watch_mode,omitempty"`
+
+	MaxConcurrentFiles int `json:"max_concurrent_files,omitempty" yaml:"max_concurrent_files,omitempty"`
+	MaxBytesPerRead    int `json:"max_bytes_per_read,omitempty"   yaml:"max_bytes_per_read,omitempty"`
+
+	IgnoreOlder operator.Duration `json:"ignore_older,omitempty" yaml:"ignore_older,omitempty"`
+	OnComplete  string            `json:"on_complete,omitempty"  yaml:"on_complete,omitempty"`
+	MoveTo      string            `json:"move_to,omitempty"      yaml:"move_to,omitempty"`
+
+	PathPattern string                 `json:"path_pattern,omitempty" yaml:"path_pattern,omitempty"`
+	PathFields  map[string]entry.Field `json:"path_fields,omitempty"  yaml:"path_fields,omitempty"`
 }
 
 // MultilineConfig is the configuration a multiline operation
 type MultilineConfig struct {
-	LineStartPattern string `json:"line_start_pattern" yaml:"line_start_pattern"`
-	LineEndPattern   string `json:"line_end_pattern"   yaml:"line_end_pattern"`
+	LineStartPattern string            `json:"line_start_pattern"           yaml:"line_start_pattern"`
+	LineEndPattern   string            `json:"line_end_pattern"             yaml:"line_end_pattern"`
+	ForceFlushPeriod operator.Duration `json:"force_flush_period,omitempty" yaml:"force_flush_period,omitempty"`
 }
 
 // Build will build a file input operator from the supplied configuration
@@ -75,7 +94,7 @@ func (c InputConfig) Build(context operator.BuildContext) (operator.Operator, er
 
 	// Ensure includes can be parsed as globs
 	for _, include := range c.Include {
-		_, err := filepath.Match(include, "matchstring")
+		err := validateGlob(include)
 		if err != nil {
 			return nil, fmt.Errorf("parse include glob: %s", err)
 		}
@@ -83,12 +102,20 @@ func (c InputConfig) Build(context operator.BuildContext) (operator.Operator, er
 
 	// Ensure excludes can be parsed as globs
 	for _, exclude := range c.Exclude {
-		_, err := filepath.Match(exclude, "matchstring")
+		err := validateGlob(exclude)
 		if err != nil {
 			return nil, fmt.Errorf("parse exclude glob: %s", err)
 		}
 	}
 
+	// Ensure excluded directories can be parsed as globs
+	for _, excludeDir := range c.ExcludeDirs {
+		err := validateGlob(excludeDir)
+		if err != nil {
+			return nil, fmt.Errorf("parse exclude_dirs glob: %s", err)
+		}
+	}
+
 	encoding, err := lookupEncoding(c.Encoding)
 	if err != nil {
 		return nil, err
@@ -99,6 +126,46 @@ func (c InputConfig) Build(context operator.BuildContext) (operator.Operator, er
 		return nil, err
 	}
 
+	if err := validateCompression(c.Compression); err != nil {
+		return nil, err
+	}
+
+	pathPattern, err := newPathPattern(c.PathPattern, c.PathFields)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.WatchMode {
+	case watchModePoll, "":
+	case watchModeInotify:
+		if runtime.GOOS != "linux" {
+			return nil, fmt.Errorf("watch_mode '%s' is only supported on linux", c.WatchMode)
+		}
+	default:
+		return nil, fmt.Errorf("invalid watch_mode '%s'", c.WatchMode)
+	}
+
+	var forceFlushPeriod time.Duration
+	if c.Multiline != nil {
+		forceFlushPeriod = c.Multiline.ForceFlushPeriod.Raw()
+		if forceFlushPeriod < 0 {
+			return nil, fmt.Errorf("force_flush_period must not be negative")
+		}
+	}
+
+	// When running once, the data at the end of each file is read as soon as the file is checked again
+	if context.Once {
+		forceFlushPeriod = 0
+	}
+
+	if c.MaxConcurrentFiles < 0 {
+		return nil, fmt.Errorf("max_concurrent_files must not be negative")
+	}
+
+	if c.MaxBytesPerRead < 0 {
+		return nil, fmt.Errorf("max_bytes_per_read must not be negative")
+	}
+
 	var startAtBeginning bool
 	switch c.StartAt {
 	case "beginning":
@@ -109,21 +176,43 @@ func (c InputConfig) Build(context operator.BuildContext) (operator.Operator, er
 		return nil, fmt.Errorf("invalid start_at location '%s'", c.StartAt)
 	}
 
+	if c.IgnoreOlder.Raw() < 0 {
+		return nil, fmt.Errorf("ignore_older must not be negative")
+	}
+
+	if err := validateOnComplete(c.OnComplete, c.MoveTo, startAtBeginning); err != nil {
+		return nil, err
+	}
+
 	operator := &InputOperator{
 		InputOperator:    inputOperator,
 		Include:          c.Include,
 		Exclude:          c.Exclude,
+		ExcludeDirs:      c.ExcludeDirs,
 		SplitFunc:        splitFunc,
 		PollInterval:     c.PollInterval.Raw(),
 		persist:          helper.NewScopedDBPersister(context.Database, c.ID()),
 		FilePathField:    c.FilePathField,
 		FileNameField:    c.FileNameField,
 		runningFiles:     make(map[string]struct{}),
+		pendingFiles:     make(map[string]struct{}),
+		forgottenDirs:    make(map[string]struct{}),
 		fileUpdateChan:   make(chan fileUpdateMessage, 10),
 		fingerprintBytes: 1000,
 		startAtBeginning: startAtBeginning,
 		encoding:         encoding,
+		compression:      c.Compression,
+		pathPattern:      pathPattern,
+		watchMode:        c.WatchMode,
 		MaxLogSize:       c.MaxLogSize,
+		ForceFlushPeriod: forceFlushPeriod,
+		IgnoreOlder:      c.IgnoreOlder.Raw(),
+		onComplete:       c.OnComplete,
+		moveTo:           c.MoveTo,
+		once:             context.Once,
+
+		MaxConcurrentFiles: c.MaxConcurrentFiles,
+		MaxBytesPerRead:    c.MaxBytesPerRead,
 	}
 
 	return operator, nil
@@ -155,35 +244,33 @@ func lookupEncoding(enc string) (encoding.Encoding, error) {
 
 // getSplitFunc will return the split function associated the configured mode.
 func (c InputConfig) getSplitFunc(encoding encoding.Encoding) (bufio.SplitFunc, error) {
-	var splitFunc bufio.SplitFunc
 	if c.Multiline == nil {
-		var err error
-		splitFunc, err = NewNewlineSplitFunc(encoding)
+		return NewNewlineSplitFunc(encoding)
+	}
+	return c.Multiline.SplitFunc()
+}
+
+// SplitFunc will return the split function matching the configured pattern
+func (c MultilineConfig) SplitFunc() (bufio.SplitFunc, error) {
+	definedLineEndPattern := c.LineEndPattern != ""
+	definedLineStartPattern := c.LineStartPattern != ""
+
+	switch {
+	case definedLineEndPattern == definedLineStartPattern:
+		return nil, fmt.Errorf("if multiline is configured, exactly one of line_start_pattern or line_end_pattern must be set")
+	case definedLineEndPattern:
+		re, err := regexp.Compile(c.LineEndPattern)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("compile line end regex: %s", err)
 		}
-	} else {
-		definedLineEndPattern := c.Multiline.LineEndPattern != ""
-		definedLineStartPattern := c.Multiline.LineStartPattern != ""
-
-		switch {
-		case definedLineEndPattern == definedLineStartPattern:
-			return nil, fmt.Errorf("if multiline is configured, exactly one of line_start_pattern or line_end_pattern must be set")
-		case definedLineEndPattern:
-			re, err := regexp.Compile(c.Multiline.LineEndPattern)
-			if err != nil {
-				return nil, fmt.Errorf("compile line end regex: %s", err)
-			}
-			splitFunc = NewLineEndSplitFunc(re)
-		case definedLineStartPattern:
-			re, err := regexp.Compile(c.Multiline.LineStartPattern)
-			if err != nil {
-				return nil, fmt.Errorf("compile line start regex: %s", err)
-			}
-			splitFunc = NewLineStartSplitFunc(re)
+		return NewLineEndSplitFunc(re), nil
+	default:
+		re, err := regexp.Compile(c.LineStartPattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile line start regex: %s", err)
 		}
+		return NewLineStartSplitFunc(re), nil
 	}
-	return splitFunc, nil
 }
 
 // InputOperator is an operator that monitors files for entries
@@ -192,22 +279,50 @@ type InputOperator struct {
 
 	Include       []string
 	Exclude       []string
+	ExcludeDirs   []string
 	FilePathField entry.Field
 	FileNameField entry.Field
 	PollInterval  time.Duration
 	SplitFunc     bufio.SplitFunc
 	MaxLogSize    int
 
+	// ForceFlushPeriod is the duration after which the unterminated data at the end of
+	// an unchanged file is read as an entry. If zero, it is read on the next check.
+	ForceFlushPeriod time.Duration
+
+	// IgnoreOlder is the duration after which files that have not been modified are no longer read
+	IgnoreOlder time.Duration
+
+	MaxConcurrentFiles int
+	MaxBytesPerRead    int
+
 	persist helper.Persister
 
 	runningFiles     map[string]struct{}
+	pendingFiles     map[string]struct{}
+	queue            *fileQueue
 	knownFiles       map[string]*knownFileInfo
 	startAtBeginning bool
 
 	fileUpdateChan   chan fileUpdateMessage
 	fingerprintBytes int64
 
-	encoding encoding.Encoding
+	encoding    encoding.Encoding
+	compression string
+	pathPattern *pathPattern
+	watchMode   string
+	onComplete  string
+	moveTo      string
+	once        bool
+	finished    chan struct{}
+	reader      *fileReader
+
+	waitingFiles      int64
+	lastWaitingReport time.Time
+
+	forgetRequests []string
+	forgottenDirs  map[string]struct{}
+	forgetMux      sync.Mutex
 
 	wg       *sync.WaitGroup
 	readerWg *sync.WaitGroup
@@ -220,6 +335,9 @@ func (f *InputOperator) Start() error {
 	f.cancel = cancel
 	f.wg = &sync.WaitGroup{}
 	f.readerWg = &sync.WaitGroup{}
+	f.queue = newFileQueue()
+	f.finished = make(chan struct{})
+	f.reader = f.newFileReader()
 
 	var err error
 	f.knownFiles, err = f.readKnownFiles()
@@ -227,6 +345,23 @@ func (f *InputOperator) Start() error {
 		return fmt.Errorf("failed to read known files from database: %s", err)
 	}
 
+	if f.once {
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			f.readOnce(ctx)
+		}()
+		return nil
+	}
+
+	var watcher *fileWatcher
+	if f.watchMode == watchModeInotify {
+		watcher, err = newFileWatcher(f.Include, f.ExcludeDirs)
+		if err != nil {
+			f.Warnw("Failed to create inotify watcher. Falling back to polling for file changes", zap.Error(err))
+		}
+	}
+
 	f.wg.Add(1)
 	go func() {
 		defer f.wg.Done()
@@ -239,25 +374,56 @@ func (f *InputOperator) Start() error {
 		// are unsafe to call from multiple goroutines. Changes to these
 		// maps should be done through the fileUpdateChan.
 		firstCheck := true
+		knownFilesChanged := false
 		for {
 			select {
 			case <-ctx.Done():
+				watcher.close()
 				f.drainMessages()
 				f.readerWg.Wait()
 				f.syncKnownFiles()
 				return
 			case <-globTicker.C:
-				matches := getMatches(f.Include, f.Exclude)
-				if firstCheck && len(matches) == 0 {
-					f.Warnw("no files match the configured include patterns", "include", f.Include)
+				// When watching for changes, files are only globbed on the first
+				// check, and the ticker is only used to persist updated offsets
+				if watcher == nil || firstCheck {
+					matches := f.pollFiles(ctx, firstCheck)
+					if watcher != nil {
+						watcher = f.refreshWatcher(watcher, matches)
+					}
+					f.forgetFiles()
+					f.syncKnownFiles()
+					firstCheck = false
+				} else {
+					f.checkFlushableFiles(ctx)
+					f.checkIncompleteFiles(ctx)
+					if f.forgetFiles() || knownFilesChanged {
+						f.syncKnownFiles()
+					}
 				}
-				for _, match := range matches {
-					f.checkFile(ctx, match, firstCheck)
+				knownFilesChanged = false
+				f.reportWaitingFiles()
+			case event := <-watcher.events():
+				watcher = f.handleWatchEvent(ctx, watcher, event)
+			case err := <-watcher.errors():
+				if err == fsnotify.ErrEventOverflow {
+					f.Debugw("Inotify event queue overflowed. Checking all files")
+				} else {
+					f.Warnw("Received error from inotify watcher. Checking all files", zap.Error(err))
 				}
-				f.syncKnownFiles()
-				firstCheck = false
+				watcher = f.rescan(ctx, watcher)
 			case message := <-f.fileUpdateChan:
 				f.updateFile(message)
+				if message.finished {
+					if message.budgetExhausted {
+						// Read the rest of the file after other waiting files have been read
+						f.checkFile(ctx, message.path, false)
+					} else {
+						f.checkPendingFile(ctx, message.path)
+					}
+					f.startQueuedFiles(ctx)
+				}
+				knownFilesChanged = true
 			}
 		}
 	}()
@@ -265,6 +431,36 @@ func (f *InputOperator) Start() error {
 	return nil
 }
 
+// pollFiles will check every file matching the include patterns, returning the matched paths
+func (f *InputOperator) pollFiles(ctx context.Context, firstCheck bool) []string {
+	matches := getMatches(f.Include, f.Exclude, f.ExcludeDirs)
+	if firstCheck && len(matches) == 0 {
+		f.Warnw("no files match the configured include patterns", "include", f.Include)
+	}
+	for _, match := range matches {
+		f.checkFile(ctx, match, firstCheck)
+	}
+	return matches
+}
+
+// checkFlushableFiles will check files that have unread data which has not changed for the
+// force flush period. When polling, every file is already checked on each poll, but when
+// watching for changes, files are only checked when they change.
+func (f *InputOperator) checkFlushableFiles(ctx context.Context) {
+	if f.ForceFlushPeriod == 0 {
+		return
+	}
+
+	for path, knownFile := range f.knownFiles {
+		if knownFile.Path != path || knownFile.Offset >= knownFile.LastSeenFileSize {
+			continue
+		}
+		if time.Since(knownFile.LastChanged) >= f.ForceFlushPeriod {
+			f.checkFile(ctx, path, false)
+		}
+	}
+}
+
 // Stop will stop the file monitoring process
 func (f *InputOperator) Stop() error {
 	f.cancel()
@@ -286,6 +482,23 @@ func (f *InputOperator) checkFile(ctx context.Context, path string, firstCheck b
 		return // file is already being read
 	}
 
+	// Check if the file is already waiting to be read
+	if f.queue.contains(path) {
+		return
+	}
+
+	// Check if the file is waiting to be deleted or moved
+	if knownFile, ok := f.knownFiles[path]; ok && knownFile.completed {
+		return
+	}
+
+	// Check if the file was last modified before the ignore_older duration
+	if f.IgnoreOlder > 0 {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > f.IgnoreOlder {
+			return
+		}
+	}
+
 	// If the path is known, start from last offset
 	knownFile, isKnown := f.knownFiles[path]
 
@@ -308,22 +521,17 @@ func (f *InputOperator) checkFile(ctx context.Context, path string, firstCheck b
 		}
 	}
 
-	f.runningFiles[path] = struct{}{}
 	f.knownFiles[path] = knownFile
-	f.readerWg.Add(1)
-	go func(ctx context.Context, path string, offset, lastSeenSize int64) {
-		defer f.readerWg.Done()
-		messenger := f.newFileUpdateMessenger(path)
-		err := ReadToEnd(ctx, path, offset, lastSeenSize, messenger, f.SplitFunc, f.FilePathField, f.FileNameField, f.InputOperator, f.MaxLogSize, f.encoding)
-		if err != nil {
-			f.Warnw("Failed to read log file", zap.Error(err))
-		}
-	}(ctx, path, knownFile.Offset, knownFile.LastSeenFileSize)
+	f.queue.add(path, knownFile.LastRead)
+	f.startQueuedFiles(ctx)
 }
 
 func (f *InputOperator) updateFile(message fileUpdateMessage) {
 	if message.finished {
 		delete(f.runningFiles, message.path)
+		if !message.budgetExhausted {
+			f.markCompleted(message.path)
+		}
 		return
 	}
 
@@ -331,10 +539,20 @@ func (f *InputOperator) updateFile(message fileUpdateMessage) {
 
 	// This is a last seen size message, so just set the size and return
 	if message.lastSeenFileSize != -1 {
+		knownFile.sizeUnchanged = knownFile.LastSeenFileSize == message.lastSeenFileSize
+		if !knownFile.sizeUnchanged {
+			knownFile.LastChanged = time.Now()
+		}
 		knownFile.LastSeenFileSize = message.lastSeenFileSize
 		return
 	}
 
+	// This is a decompressed offset message, which does not move the offset in the file
+	if message.decompressed {
+		knownFile.DecompressedOffset = message.newOffset
+		return
+	}
+
 	if message.newOffset < knownFile.Offset {
 		// The file was truncated or rotated
 
@@ -414,7 +632,13 @@ func (f *InputOperator) syncKnownFiles() {
 	}
 
 	f.persist.Set(knownFilesKey, buf.Bytes())
-	f.persist.Sync()
+	if err := f.persist.Sync(); err != nil {
+		f.Errorw("Failed to sync known files", zap.Error(err))
+		return
+	}
+
+	// Completed files can be deleted or moved once their offsets are persisted
+	f.completeFiles()
 }
 
 func (f *InputOperator) readKnownFiles() (map[string]*knownFileInfo, error) {
@@ -453,6 +677,15 @@ type knownFileInfo struct {
 	SmallFileContents []byte
 	Offset            int64
 	LastSeenFileSize  int64
+	LastRead          time.Time
+	LastChanged       time.Time
+
+	// DecompressedOffset is the position in the decompressed stream of a compressed file
+	DecompressedOffset int64
+
+	// sizeUnchanged and completed are not persisted
+	sizeUnchanged bool
+	completed     bool
 }
 
 func newKnownFileInfo(path string, fingerprintBytes int64, startAtBeginning bool) (*knownFileInfo, error) {
@@ -543,6 +776,8 @@ type fileUpdateMessage struct {
 	newOffset        int64
 	lastSeenFileSize int64
 	finished         bool
+	budgetExhausted  bool
+	decompressed     bool
 }
 
 type fileUpdateMessenger struct {
@@ -558,6 +793,16 @@ func (f *fileUpdateMessenger) SetOffset(offset int64) {
 	}
 }
 
+// SetDecompressedOffset records the position reached in the decompressed stream of a compressed file
+func (f *fileUpdateMessenger) SetDecompressedOffset(offset int64) {
+	f.c <- fileUpdateMessage{
+		path:             f.path,
+		newOffset:        offset,
+		lastSeenFileSize: -1,
+		decompressed:     true,
+	}
+}
+
 func (f *fileUpdateMessenger) SetLastSeenFileSize(size int64) {
 	f.c <- fileUpdateMessage{
 		path:             f.path,
@@ -565,31 +810,54 @@ func (f *fileUpdateMessenger) SetLastSeenFileSize(size int64) {
 	}
 }
 
-func (f *fileUpdateMessenger) FinishedReading() {
+// FinishedReading signals that a file is no longer being read. If budgetExhausted is
+// true, reading stopped before the end of the file because max_bytes_per_read was reached.
+func (f *fileUpdateMessenger) FinishedReading(budgetExhausted bool) {
 	f.c <- fileUpdateMessage{
 		path:             f.path,
 		finished:         true,
+		budgetExhausted:  budgetExhausted,
 		lastSeenFileSize: -1,
 	}
 }
 
-func getMatches(includes, excludes []string) []string {
+// matchesPatterns will return true if a path matches an include pattern and no exclude
+// patterns, and is not in an excluded directory
+func matchesPatterns(path string, includes, excludes, excludeDirs []string) bool {
+	for _, exclude := range excludes {
+		if matchGlob(exclude, path) {
+			return false
+		}
+	}
+
+	if isExcludedPath(filepath.Dir(path), excludeDirs) {
+		return false
+	}
+
+	for _, include := range includes {
+		if matchGlob(include, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func getMatches(includes, excludes, excludeDirs []string) []string {
 	all := make([]string, 0, len(includes))
+	seen := make(map[string]struct{})
 	for _, include := range includes {
-		matches, _ := filepath.Glob(include) // compile error checked in build
 	INCLUDE:
-		for _, match := range matches {
+		for _, match := range glob(include, excludeDirs) {
 			for _, exclude := range excludes {
-				if itMatches, _ := filepath.Match(exclude, match); itMatches {
-					break INCLUDE
+				if matchGlob(exclude, match) {
+					continue INCLUDE
 				}
 			}
 
-			for _, existing := range all {
-				if existing == match {
-					break INCLUDE
-				}
+			if _, ok := seen[match]; ok {
+				continue
 			}
+			seen[match] = struct{}{}
 
 			all = append(all, match)
 		}
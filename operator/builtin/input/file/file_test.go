@@ -1,17 +1,21 @@
 package file
 
 import (
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/observiq/carbon/entry"
 	"github.com/observiq/carbon/internal/testutil"
 	"github.com/observiq/carbon/operator"
@@ -88,6 +92,149 @@ func TestFileSource_Build(t *testing.T) {
 			require.Error,
 			nil,
 		},
+		{
+			"BadExcludeDirsGlob",
+			func(f *InputConfig) {
+				f.ExcludeDirs = []string{"/var/log/["}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"RecursiveIncludeGlob",
+			func(f *InputConfig) {
+				f.Include = []string{"/var/log/**/*.log"}
+				f.ExcludeDirs = []string{"/var/log/**/archive"}
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, []string{"/var/log/**/archive"}, f.ExcludeDirs)
+			},
+		},
+		{
+			"NegativeIgnoreOlder",
+			func(f *InputConfig) {
+				f.IgnoreOlder = operator.Duration{Duration: -time.Hour}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"OnCompleteDelete",
+			func(f *InputConfig) {
+				f.StartAt = "beginning"
+				f.OnComplete = "delete"
+			},
+			require.NoError,
+			func(t *testing.T, f *InputOperator) {
+				require.Equal(t, onCompleteDelete, f.onComplete)
+			},
+		},
+		{
+			"InvalidOnComplete",
+			func(f *InputConfig) {
+				f.StartAt = "beginning"
+				f.OnComplete = "archive"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"OnCompleteMoveWithoutMoveTo",
+			func(f *InputConfig) {
+				f.StartAt = "beginning"
+				f.OnComplete = "move"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MoveToWithoutOnCompleteMove",
+			func(f *InputConfig) {
+				f.StartAt = "beginning"
+				f.OnComplete = "delete"
+				f.MoveTo = "/var/log/done"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"OnCompleteStartAtEnd",
+			func(f *InputConfig) {
+				f.StartAt = "end"
+				f.OnComplete = "delete"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"InvalidCompression",
+			func(f *InputConfig) {
+				f.Compression = "lzma"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PathPatternWithoutNamedGroups",
+			func(f *InputConfig) {
+				f.PathPattern = "/var/log/(.*)"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PathFieldsUnknownGroup",
+			func(f *InputConfig) {
+				f.PathPattern = "/var/log/(?P<name>.*)"
+				f.PathFields = map[string]entry.Field{"other": entry.NewRecordField("other")}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PathFieldsWithoutPathPattern",
+			func(f *InputConfig) {
+				f.PathFields = map[string]entry.Field{"other": entry.NewRecordField("other")}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"InvalidWatchMode",
+			func(f *InputConfig) {
+				f.WatchMode = "fanotify"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MultilineNegativeForceFlushPeriod",
+			func(f *InputConfig) {
+				f.Multiline = &MultilineConfig{
+					LineStartPattern: "START.*",
+					ForceFlushPeriod: operator.Duration{Duration: -time.Second},
+				}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NegativeMaxConcurrentFiles",
+			func(f *InputConfig) {
+				f.MaxConcurrentFiles = -1
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NegativeMaxBytesPerRead",
+			func(f *InputConfig) {
+				f.MaxBytesPerRead = -1
+			},
+			require.Error,
+			nil,
+		},
 		{
 			"MultilineConfiguredStartAndEndPatterns",
 			func(f *InputConfig) {
@@ -190,6 +337,45 @@ func TestFileSource_AddFields(t *testing.T) {
 	}
 }
 
+func TestFileSource_PathPattern(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*/*/*.log", tempDir)}
+	source.WriteTo = entry.NewRecordField("message")
+
+	pattern := fmt.Sprintf(`^%s/(?P<namespace>[^_]+)_(?P<pod_name>[^_]+)_(?P<uid>[^/]+)/(?P<container>[^/]+)/`, regexp.QuoteMeta(tempDir))
+	var err error
+	source.pathPattern, err = newPathPattern(pattern, map[string]entry.Field{
+		"uid": entry.NewRecordField("pod_uid"),
+	})
+	require.NoError(t, err)
+
+	logDir := filepath.Join(tempDir, "kube-system_coredns-1234_abcd-ef", "coredns")
+	require.NoError(t, os.MkdirAll(logDir, 0755))
+	err = ioutil.WriteFile(filepath.Join(logDir, "0.log"), []byte("testlog\n"), 0666)
+	require.NoError(t, err)
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	select {
+	case e := <-logReceived:
+		require.Equal(t, map[string]string{
+			"namespace": "kube-system",
+			"pod_name":  "coredns-1234",
+			"container": "coredns",
+		}, e.Labels)
+		require.Equal(t, map[string]interface{}{
+			"message": "testlog",
+			"pod_uid": "abcd-ef",
+		}, e.Record)
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for message")
+	}
+}
+
 func TestFileSource_ReadExistingLogs(t *testing.T) {
 	t.Parallel()
 	source, logReceived := newTestFileSource(t)
@@ -332,6 +518,40 @@ func TestFileSource_MultiFileSimple(t *testing.T) {
 	waitForMessages(t, logReceived, []string{"testlog1", "testlog2"})
 }
 
+func TestFileSource_RecursiveInclude(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{filepath.Join(tempDir, "**", "*.log")}
+	source.Exclude = []string{filepath.Join(tempDir, "**", "debug.log")}
+	source.ExcludeDirs = []string{filepath.Join(tempDir, "**", "archive")}
+
+	files := map[string]string{
+		"a.log":                  "testlog1",
+		"apps/b.log":             "testlog2",
+		"apps/web/c.log":         "testlog3",
+		"apps/web/debug.log":     "excluded",
+		"apps/web/archive/d.log": "excluded",
+	}
+	for path, message := range files {
+		path = filepath.Join(tempDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(message+"\n"), 0666))
+	}
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessages(t, logReceived, []string{"testlog1", "testlog2", "testlog3"})
+	expectNoMessages(t, logReceived)
+
+	// Files in new directories are found on later polls
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "apps", "api"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "apps", "api", "e.log"), []byte("testlog4\n"), 0666))
+	waitForMessage(t, logReceived, "testlog4")
+}
+
 func TestFileSource_MoveFile(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Moving files while open is unsupported on Windows")
@@ -672,6 +892,212 @@ func TestFileSource_ManyLogsDelivered(t *testing.T) {
 	expectNoMessages(t, logReceived)
 }
 
+func TestFileSource_MultilineForceFlush(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.SplitFunc = NewLineStartSplitFunc(regexp.MustCompile("START "))
+	source.ForceFlushPeriod = 500 * time.Millisecond
+
+	temp, err := ioutil.TempFile(tempDir, "")
+	require.NoError(t, err)
+	defer temp.Close()
+
+	_, err = temp.WriteString("START log1\ncontinued\nSTART log2\ncontinued\n")
+	require.NoError(t, err)
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	// The last entry is held until the file has been unchanged for the force flush period
+	waitForMessage(t, logReceived, "START log1\ncontinued\n")
+	expectNoMessages(t, logReceived)
+	waitForMessage(t, logReceived, "START log2\ncontinued\n")
+
+	// Data written before the period expires delays the flush
+	_, err = temp.WriteString("START log3\n")
+	require.NoError(t, err)
+	time.Sleep(300 * time.Millisecond)
+	_, err = temp.WriteString("continued\n")
+	require.NoError(t, err)
+	expectNoMessages(t, logReceived)
+	waitForMessage(t, logReceived, "START log3\ncontinued\n")
+}
+
+func TestFileSource_Compressed(t *testing.T) {
+	t.Parallel()
+
+	writeGzip := func(t *testing.T, path string, contents []byte) {
+		file, err := os.Create(path)
+		require.NoError(t, err)
+		defer file.Close()
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write(contents)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+	}
+
+	writeZstd := func(t *testing.T, path string, contents []byte) {
+		file, err := os.Create(path)
+		require.NoError(t, err)
+		defer file.Close()
+		writer, err := zstd.NewWriter(file)
+		require.NoError(t, err)
+		_, err = writer.Write(contents)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+	}
+
+	cases := []struct {
+		name        string
+		fileName    string
+		compression string
+		write       func(*testing.T, string, []byte)
+	}{
+		{"GzipExtension", "test.log.1.gz", "auto", writeGzip},
+		{"GzipMagicBytes", "test.log.1", "auto", writeGzip},
+		{"GzipExplicit", "test.log.1", "gzip", writeGzip},
+		{"ZstdExtension", "test.log.1.zst", "auto", writeZstd},
+		{"ZstdMagicBytes", "test.log.1", "auto", writeZstd},
+		{"ZstdExplicit", "test.log.1", "zstd", writeZstd},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			source, logReceived := newTestFileSource(t)
+			tempDir := testutil.NewTempDir(t)
+			source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+			source.compression = tc.compression
+
+			tc.write(t, filepath.Join(tempDir, tc.fileName), []byte("testlog1\ntestlog2\ntestlog3"))
+
+			err := source.Start()
+			require.NoError(t, err)
+			defer source.Stop()
+
+			waitForMessage(t, logReceived, "testlog1")
+			waitForMessage(t, logReceived, "testlog2")
+			waitForMessage(t, logReceived, "testlog3")
+			expectNoMessages(t, logReceived)
+		})
+	}
+}
+
+func TestFileSource_CompressedOffsetsAfterRestart(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.compression = "auto"
+
+	file, err := os.Create(filepath.Join(tempDir, "test.log.gz"))
+	require.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte("testlog1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+
+	err = source.Start()
+	require.NoError(t, err)
+
+	waitForMessage(t, logReceived, "testlog1")
+
+	// Give the final offset time to be recorded
+	time.Sleep(200 * time.Millisecond)
+
+	// Restart the source, and expect the file to not be read again
+	err = source.Stop()
+	require.NoError(t, err)
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	expectNoMessages(t, logReceived)
+}
+
+func TestFileSource_CompressedTruncated(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.compression = "auto"
+
+	path := filepath.Join(tempDir, "test.log.gz")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte("testlog1\ntestlog2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+
+	// Remove the end of the gzip trailer, so the stream fails after its entries are read
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-4))
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessage(t, logReceived, "testlog1")
+	waitForMessage(t, logReceived, "testlog2")
+
+	// The file is not read again on later polls
+	for i := 0; i < 3; i++ {
+		expectNoMessages(t, logReceived)
+	}
+}
+
+func TestFileSource_CompressedResume(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.compression = "auto"
+
+	path := filepath.Join(tempDir, "test.log.gz")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte("testlog1\ntestlog2\ntestlog3\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	updates := make(chan fileUpdateMessage, 10)
+	messenger := fileUpdateMessenger{path: path, c: updates}
+
+	// Resume after the first entry
+	err = source.newFileReader().ReadToEnd(context.Background(), path, 0, int64(len("testlog1\n")), info.Size(), time.Now(), messenger)
+	require.NoError(t, err)
+
+	waitForMessage(t, logReceived, "testlog2")
+	waitForMessage(t, logReceived, "testlog3")
+	expectNoMessages(t, logReceived)
+
+	var decompressedOffsets []int64
+	var offset int64
+	close(updates)
+	for message := range updates {
+		switch {
+		case message.decompressed:
+			decompressedOffsets = append(decompressedOffsets, message.newOffset)
+		case message.lastSeenFileSize == -1 && !message.finished:
+			offset = message.newOffset
+		}
+	}
+	require.Equal(t, []int64{18, 27}, decompressedOffsets)
+	require.Equal(t, info.Size(), offset)
+}
+
 func stringWithLength(length int) string {
 	charset := "abcdefghijklmnopqrstuvwxyz"
 	b := make([]byte, length)
@@ -791,6 +1217,7 @@ func TestEncodings(t *testing.T) {
 
 			err = source.Start()
 			require.NoError(t, err)
+			defer source.Stop()
 
 			for _, expected := range tc.expected {
 				select {
@@ -0,0 +1,61 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Forget will stop tracking the offsets of the files in a directory once they no longer
+// exist, such as when the directory was removed. Files that are still being read are
+// forgotten once they have been read. It is safe to call from multiple goroutines.
+func (f *InputOperator) Forget(dir string) {
+	f.forgetMux.Lock()
+	defer f.forgetMux.Unlock()
+	f.forgetRequests = append(f.forgetRequests, filepath.Clean(dir))
+}
+
+// forgetFiles will remove the known files in forgotten directories that no longer exist,
+// returning true if any were removed. It is not safe to call from multiple goroutines.
+func (f *InputOperator) forgetFiles() bool {
+	f.forgetMux.Lock()
+	for _, dir := range f.forgetRequests {
+		f.forgottenDirs[dir] = struct{}{}
+	}
+	f.forgetRequests = nil
+	f.forgetMux.Unlock()
+
+	removed := false
+	for dir := range f.forgottenDirs {
+		remaining := false
+		for path := range f.knownFiles {
+			if !isInDir(path, dir) {
+				continue
+			}
+
+			if _, ok := f.runningFiles[path]; ok || f.queue.contains(path) {
+				remaining = true
+				continue
+			}
+
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				remaining = true
+				continue
+			}
+
+			delete(f.knownFiles, path)
+			delete(f.pendingFiles, path)
+			removed = true
+		}
+
+		if !remaining {
+			delete(f.forgottenDirs, dir)
+		}
+	}
+	return removed
+}
+
+// isInDir will return true if a path is below a directory
+func isInDir(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,55 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource_Forget(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{filepath.Join(tempDir, "*", "*.log")}
+
+	removedPath := filepath.Join(tempDir, "removed", "a.log")
+	existingPath := filepath.Join(tempDir, "existing", "b.log")
+	unforgottenPath := filepath.Join(tempDir, "unforgotten", "c.log")
+	for _, path := range []string{removedPath, existingPath, unforgottenPath} {
+		require.NoError(t, os.Mkdir(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(filepath.Base(path)+"\n"), 0666))
+	}
+
+	err := source.Start()
+	require.NoError(t, err)
+
+	waitForMessages(t, logReceived, []string{"a.log", "b.log", "c.log"})
+
+	require.NoError(t, os.RemoveAll(filepath.Dir(removedPath)))
+	require.NoError(t, os.RemoveAll(filepath.Dir(unforgottenPath)))
+	source.Forget(filepath.Dir(removedPath))
+	source.Forget(filepath.Dir(existingPath))
+
+	// Wait for the forgotten directories to be checked
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, source.Stop())
+
+	knownFiles, err := source.readKnownFiles()
+	require.NoError(t, err)
+	require.NotContains(t, knownFiles, removedPath)
+	require.Contains(t, knownFiles, existingPath)
+	require.Contains(t, knownFiles, unforgottenPath)
+}
+
+func TestIsInDir(t *testing.T) {
+	t.Parallel()
+	require.True(t, isInDir(filepath.Join("pods", "a", "0.log"), "pods"))
+	require.True(t, isInDir(filepath.Join("pods", "a", "0.log"), filepath.Join("pods", "a")))
+	require.False(t, isInDir(filepath.Join("pods", "ab", "0.log"), filepath.Join("pods", "a")))
+	require.False(t, isInDir(filepath.Join("pods", "a"), filepath.Join("pods", "a")))
+}
@@ -0,0 +1,180 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// globStar is a path component that matches zero or more directories
+const globStar = "**"
+
+// validateGlob will return an error if a pattern can not be parsed
+func validateGlob(pattern string) error {
+	for _, component := range splitPath(filepath.Clean(pattern)) {
+		if _, err := filepath.Match(component, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchGlob will return true if a path matches a pattern. In addition to the syntax
+// supported by filepath.Match, a path component of `**` matches zero or more directories.
+func matchGlob(pattern, path string) bool {
+	return matchComponents(splitPath(filepath.Clean(pattern)), splitPath(filepath.Clean(path)))
+}
+
+func matchComponents(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == globStar {
+			for i := 0; i <= len(names); i++ {
+				if matchComponents(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(patterns[0], names[0]); !ok { // syntax errors checked in build
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+func splitPath(path string) []string {
+	return strings.Split(path, string(filepath.Separator))
+}
+
+// splitPattern will split a pattern into the static directory it starts
+// from and the components that must be matched below that directory
+func splitPattern(pattern string) (string, []string) {
+	base := filepath.Clean(pattern)
+	var components []string
+	for hasMeta(base) {
+		components = append([]string{filepath.Base(base)}, components...)
+		base = filepath.Dir(base)
+	}
+	return base, components
+}
+
+// glob will return the paths matching a pattern, using the same syntax as matchGlob. Directories
+// matching any of the excludeDirs patterns are skipped without being read. Symlinked directories
+// are not descended into when matching `**`, so that links can not cause loops.
+func glob(pattern string, excludeDirs []string) []string {
+	base, components := splitPattern(pattern)
+	if isExcludedPath(base, excludeDirs) {
+		return nil
+	}
+
+	g := &globber{
+		excludeDirs: excludeDirs,
+		seen:        make(map[string]struct{}),
+	}
+
+	if len(components) == 0 {
+		if _, err := os.Lstat(base); err == nil {
+			g.add(base)
+		}
+		return g.matches
+	}
+
+	g.expand(base, components)
+	return g.matches
+}
+
+type globber struct {
+	excludeDirs []string
+	matches     []string
+	seen        map[string]struct{}
+}
+
+func (g *globber) add(path string) {
+	if _, ok := g.seen[path]; ok {
+		return
+	}
+	g.seen[path] = struct{}{}
+	g.matches = append(g.matches, path)
+}
+
+func (g *globber) expand(dir string, components []string) {
+	component, rest := components[0], components[1:]
+
+	if component == globStar {
+		if len(rest) != 0 {
+			g.expand(dir, rest)
+		}
+
+		infos, _ := ioutil.ReadDir(dir)
+		for _, info := range infos {
+			path := filepath.Join(dir, info.Name())
+			if info.IsDir() && isExcludedDir(path, g.excludeDirs) {
+				continue
+			}
+			if len(rest) == 0 {
+				g.add(path)
+			}
+			if info.IsDir() {
+				g.expand(path, components)
+			}
+		}
+		return
+	}
+
+	var paths []string
+	if hasMeta(component) {
+		infos, _ := ioutil.ReadDir(dir)
+		for _, info := range infos {
+			if ok, _ := filepath.Match(component, info.Name()); ok { // syntax errors checked in build
+				paths = append(paths, filepath.Join(dir, info.Name()))
+			}
+		}
+	} else if _, err := os.Lstat(filepath.Join(dir, component)); err == nil {
+		paths = append(paths, filepath.Join(dir, component))
+	}
+
+	for _, path := range paths {
+		if isDir(path) && isExcludedDir(path, g.excludeDirs) {
+			continue
+		}
+		if len(rest) == 0 {
+			g.add(path)
+		} else if isDir(path) {
+			g.expand(path, rest)
+		}
+	}
+}
+
+// isExcludedDir will return true if a directory matches any of the excludeDirs patterns
+func isExcludedDir(dir string, excludeDirs []string) bool {
+	for _, excludeDir := range excludeDirs {
+		if matchGlob(excludeDir, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcludedPath will return true if a path or any of its parent directories matches any of the excludeDirs patterns
+func isExcludedPath(path string, excludeDirs []string) bool {
+	if len(excludeDirs) == 0 {
+		return false
+	}
+
+	for {
+		if isExcludedDir(path, excludeDirs) {
+			return true
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return false
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,143 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"/var/log/*.log", "/var/log/a.log", true},
+		{"/var/log/*.log", "/var/log/apps/a.log", false},
+		{"/var/log/**/*.log", "/var/log/a.log", true},
+		{"/var/log/**/*.log", "/var/log/apps/a.log", true},
+		{"/var/log/**/*.log", "/var/log/apps/web/a.log", true},
+		{"/var/log/**/*.log", "/var/log/apps/web/a.txt", false},
+		{"/var/log/**/web/*.log", "/var/log/web/a.log", true},
+		{"/var/log/**/web/*.log", "/var/log/apps/web/a.log", true},
+		{"/var/log/**/web/*.log", "/var/log/apps/api/a.log", false},
+		{"/var/log/**", "/var/log", true},
+		{"/var/log/**", "/var/log/apps/web", true},
+		{"/var/log/**/**/*.log", "/var/log/a.log", true},
+		{"/var/log/apps/", "/var/log/apps", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			require.Equal(t, tc.expected, matchGlob(tc.pattern, tc.path))
+		})
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	require.NoError(t, validateGlob("/var/log/**/*.log"))
+	require.Error(t, validateGlob("/var/log/[/*.log"))
+	require.Error(t, validateGlob("/var/log/**/["))
+}
+
+func TestGlob(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+	for _, path := range []string{
+		"a.log",
+		"a.txt",
+		"apps/b.log",
+		"apps/web/c.log",
+		"apps/web/archive/d.log",
+		"apps/api/archive/e.log",
+		"other/f.log",
+	} {
+		path = filepath.Join(tempDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, nil, 0666))
+	}
+
+	// A link to a parent directory must not be followed by ** indefinitely
+	require.NoError(t, os.Symlink(tempDir, filepath.Join(tempDir, "apps", "loop")))
+
+	cases := []struct {
+		name        string
+		pattern     string
+		excludeDirs []string
+		expected    []string
+	}{
+		{
+			"Static",
+			"apps/b.log",
+			nil,
+			[]string{"apps/b.log"},
+		},
+		{
+			"Wildcard",
+			"*.log",
+			nil,
+			[]string{"a.log"},
+		},
+		{
+			"GlobStar",
+			"apps/**/*.log",
+			nil,
+			[]string{"apps/b.log", "apps/web/c.log", "apps/web/archive/d.log", "apps/api/archive/e.log"},
+		},
+		{
+			"GlobStarInMiddle",
+			"**/archive/*.log",
+			nil,
+			[]string{"apps/web/archive/d.log", "apps/api/archive/e.log"},
+		},
+		{
+			"GlobStarAtEnd",
+			"apps/web/**",
+			nil,
+			[]string{"apps/web/c.log", "apps/web/archive", "apps/web/archive/d.log"},
+		},
+		{
+			"ExcludeDirs",
+			"**/*.log",
+			[]string{"**/archive", "other"},
+			[]string{"a.log", "apps/b.log", "apps/web/c.log"},
+		},
+		{
+			"ExcludedStaticPrefix",
+			"apps/web/*.log",
+			[]string{"apps"},
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			excludeDirs := make([]string, 0, len(tc.excludeDirs))
+			for _, excludeDir := range tc.excludeDirs {
+				excludeDirs = append(excludeDirs, filepath.Join(tempDir, excludeDir))
+			}
+
+			expected := make([]string, 0, len(tc.expected))
+			for _, path := range tc.expected {
+				expected = append(expected, filepath.Join(tempDir, path))
+			}
+
+			require.ElementsMatch(t, expected, glob(filepath.Join(tempDir, tc.pattern), excludeDirs))
+		})
+	}
+}
+
+func TestGetMatches(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, name), nil, 0666))
+	}
+
+	includes := []string{filepath.Join(tempDir, "*.log"), filepath.Join(tempDir, "a.log")}
+	excludes := []string{filepath.Join(tempDir, "b.log")}
+	expected := []string{filepath.Join(tempDir, "a.log"), filepath.Join(tempDir, "c.log")}
+	require.Equal(t, expected, getMatches(includes, excludes, nil))
+}
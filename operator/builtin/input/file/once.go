@@ -0,0 +1,48 @@
+package file
+
+import (
+	"context"
+)
+
+// Finished returns a channel that is closed once every matching file has been read to
+// the end. The channel is only closed when the operator is built to run once.
+func (f *InputOperator) Finished() <-chan struct{} {
+	return f.finished
+}
+
+// readOnce will read every file matching the include patterns to the end a single time, and
+// then persist the offsets of the files. Each file is checked a second time once it has been
+// read, so that data at the end of the file without a terminator is read as an entry, and so
+// that compressed files and on_complete, which require the size of a file to be unchanged
+// between checks, are handled. It is not safe to call from multiple goroutines.
+func (f *InputOperator) readOnce(ctx context.Context) {
+	rechecked := make(map[string]struct{})
+	f.pollFiles(ctx, true)
+
+	for len(f.runningFiles) > 0 || f.queue.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			f.drainMessages()
+			f.readerWg.Wait()
+			f.syncKnownFiles()
+			return
+		case message := <-f.fileUpdateChan:
+			f.updateFile(message)
+			if !message.finished {
+				continue
+			}
+
+			if message.budgetExhausted {
+				f.checkFile(ctx, message.path, false)
+			} else if _, ok := rechecked[message.path]; !ok {
+				rechecked[message.path] = struct{}{}
+				f.checkFile(ctx, message.path, false)
+			}
+			f.startQueuedFiles(ctx)
+		}
+	}
+
+	f.syncKnownFiles()
+	f.Debugw("Finished reading files", "files", len(rechecked))
+	close(f.finished)
+}
@@ -0,0 +1,85 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource_Once(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.once = true
+	source.PollInterval = time.Hour
+	source.MaxConcurrentFiles = 1
+	source.MaxBytesPerRead = 10
+
+	// The last entry of a file is read even without a trailing newline
+	path := filepath.Join(tempDir, "a.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("testlog1\ntestlog2\ntestlog3"), 0666))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "b.log"), []byte("testlog4\n"), 0666))
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	select {
+	case <-source.Finished():
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for file input to finish")
+	}
+
+	received := make([]string, 0, 4)
+	for len(logReceived) > 0 {
+		e := <-logReceived
+		received = append(received, e.Record.(string))
+	}
+	require.ElementsMatch(t, []string{"testlog1", "testlog2", "testlog3", "testlog4"}, received)
+
+	// Files are not checked again once finished
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0666)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString("\ntestlog5\n")
+	require.NoError(t, err)
+	expectNoMessages(t, logReceived)
+}
+
+func TestFileSource_OnceOffsetsAfterRestart(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.once = true
+
+	path := filepath.Join(tempDir, "a.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("testlog1\n"), 0666))
+
+	err := source.Start()
+	require.NoError(t, err)
+	waitForMessage(t, logReceived, "testlog1")
+	<-source.Finished()
+	require.NoError(t, source.Stop())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0666)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString("testlog2\n")
+	require.NoError(t, err)
+
+	// Only new entries are read the next time the operator runs
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+	waitForMessage(t, logReceived, "testlog2")
+	<-source.Finished()
+	expectNoMessages(t, logReceived)
+}
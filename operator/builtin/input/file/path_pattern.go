@@ -0,0 +1,99 @@
+package file
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/errors"
+)
+
+// pathCapture is a value captured from the path of a file, along with the field it is written to
+type pathCapture struct {
+	field entry.Field
+	value string
+}
+
+// pathPattern extracts values from file paths using the named capture groups of a regex
+type pathPattern struct {
+	regexp *regexp.Regexp
+	fields map[string]entry.Field
+}
+
+// newPathPattern will compile a path pattern. Named capture groups are written to the field
+// mapped to their name in pathFields, or to a label of the same name if no mapping exists.
+func newPathPattern(pattern string, pathFields map[string]entry.Field) (*pathPattern, error) {
+	if pattern == "" {
+		if len(pathFields) != 0 {
+			return nil, fmt.Errorf("path_fields can only be used with path_pattern")
+		}
+		return nil, nil
+	}
+
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compile path_pattern regex: %s", err)
+	}
+
+	fields := make(map[string]entry.Field)
+	for _, groupName := range r.SubexpNames() {
+		if groupName == "" {
+			continue
+		}
+		if field, ok := pathFields[groupName]; ok {
+			fields[groupName] = field
+		} else {
+			fields[groupName] = entry.NewLabelField(groupName)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.NewError(
+			"no named capture groups in path_pattern",
+			"use named capture groups like '^/var/log/(?P<app>[^/]+)/.*$' to specify the key name for the captured value",
+		)
+	}
+
+	for groupName := range pathFields {
+		if _, ok := fields[groupName]; !ok {
+			return nil, fmt.Errorf("path_fields key '%s' is not a named capture group in path_pattern", groupName)
+		}
+	}
+
+	return &pathPattern{
+		regexp: r,
+		fields: fields,
+	}, nil
+}
+
+// capture will return the values captured from a path. If the pattern
+// does not match the path, no values are returned.
+func (p *pathPattern) capture(path string) []pathCapture {
+	if p == nil {
+		return nil
+	}
+
+	matches := p.regexp.FindStringSubmatch(path)
+	if matches == nil {
+		return nil
+	}
+
+	captures := make([]pathCapture, 0, len(p.fields))
+	for i, groupName := range p.regexp.SubexpNames() {
+		if i == 0 || groupName == "" {
+			continue
+		}
+		captures = append(captures, pathCapture{
+			field: p.fields[groupName],
+			value: matches[i],
+		})
+	}
+	return captures
+}
+
+// setPathCaptures will write captured path values to an entry
+func setPathCaptures(e *entry.Entry, captures []pathCapture) {
+	for _, capture := range captures {
+		e.Set(capture.field, capture.value)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/observiq/carbon/entry"
 	"github.com/observiq/carbon/errors"
@@ -14,21 +15,50 @@ import (
 	"golang.org/x/text/encoding"
 )
 
+// fileReader holds the settings used to read every file of an input operator
+type fileReader struct {
+	inputOperator    helper.InputOperator
+	splitFunc        bufio.SplitFunc
+	filePathField    entry.Field
+	fileNameField    entry.Field
+	pathPattern      *pathPattern
+	maxLogSize       int
+	maxBytesPerRead  int
+	forceFlushPeriod time.Duration
+	encoding         encoding.Encoding
+	compression      string
+}
+
+// newFileReader will create a reader from the current settings of the operator
+func (f *InputOperator) newFileReader() *fileReader {
+	return &fileReader{
+		inputOperator:    f.InputOperator,
+		splitFunc:        f.SplitFunc,
+		filePathField:    f.FilePathField,
+		fileNameField:    f.FileNameField,
+		pathPattern:      f.pathPattern,
+		maxLogSize:       f.MaxLogSize,
+		maxBytesPerRead:  f.MaxBytesPerRead,
+		forceFlushPeriod: f.ForceFlushPeriod,
+		encoding:         f.encoding,
+		compression:      f.compression,
+	}
+}
+
 // ReadToEnd will read entries from a file and send them to the outputs of an input operator
-func ReadToEnd(
+func (r *fileReader) ReadToEnd(
 	ctx context.Context,
 	path string,
 	startOffset int64,
+	decompressedOffset int64,
 	lastSeenFileSize int64,
+	lastChanged time.Time,
 	messenger fileUpdateMessenger,
-	splitFunc bufio.SplitFunc,
-	filePathField entry.Field,
-	fileNameField entry.Field,
-	inputOperator helper.InputOperator,
-	maxLogSize int,
-	encoding encoding.Encoding,
 ) error {
-	defer messenger.FinishedReading()
+	budgetExhausted := false
+	defer func() {
+		messenger.FinishedReading(budgetExhausted)
+	}()
 
 	select {
 	case <-ctx.Done():
@@ -48,6 +78,16 @@ func ReadToEnd(
 	}
 	messenger.SetLastSeenFileSize(stat.Size())
 
+	pathCaptures := r.pathPattern.capture(path)
+
+	fileCompression, err := detectCompression(file, r.compression)
+	if err != nil {
+		return fmt.Errorf("detect compression: %s", err)
+	}
+	if fileCompression != compressionNone {
+		return r.readCompressedToEnd(ctx, file, fileCompression, stat.Size(), startOffset, decompressedOffset, lastSeenFileSize, pathCaptures, messenger)
+	}
+
 	// Start at the beginning if the file has been truncated
 	if stat.Size() < startOffset {
 		startOffset = 0
@@ -61,25 +101,34 @@ func ReadToEnd(
 
 	scanner := bufio.NewScanner(file)
 	buf := make([]byte, 0, 16384)
-	scanner.Buffer(buf, maxLogSize)
+	scanner.Buffer(buf, r.maxLogSize)
 	pos := startOffset
 	scanFunc := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		advance, token, err = splitFunc(data, atEOF)
+		advance, token, err = r.splitFunc(data, atEOF)
 		pos += int64(advance)
 		return
 	}
 	scanner.Split(scanFunc)
 
-	decoder := encoding.NewDecoder()
+	decoder := r.encoding.NewDecoder()
 
 	// Make a large, reusable buffer for transforming
 	decodeBuffer := make([]byte, 16384)
 
-	// If we're not at the end of the file, and we haven't
-	// advanced since last cycle, read the rest of the file as an entry
+	// If we're not at the end of the file, and the file hasn't changed since the last
+	// cycle, read the rest of the file as an entry. With a force flush period, the file
+	// must also have been unchanged for that long. Otherwise, we must not have advanced.
 	defer func() {
-		if pos < stat.Size() && pos == startOffset && lastSeenFileSize == stat.Size() {
-			readRemaining(ctx, file, pos, stat.Size(), messenger, inputOperator, filePathField, fileNameField, decoder, decodeBuffer)
+		if budgetExhausted || pos >= stat.Size() || lastSeenFileSize != stat.Size() {
+			return
+		}
+
+		flush := pos == startOffset
+		if r.forceFlushPeriod > 0 {
+			flush = time.Since(lastChanged) >= r.forceFlushPeriod
+		}
+		if flush {
+			r.readRemaining(ctx, file, pos, stat.Size(), pathCaptures, messenger, decoder, decodeBuffer)
 		}
 	}()
 
@@ -104,37 +153,144 @@ func ReadToEnd(
 			return err
 		}
 
-		e := inputOperator.NewEntry(string(decodeBuffer[:nDst]))
-		e.Set(filePathField, path)
-		e.Set(fileNameField, filepath.Base(file.Name()))
-		inputOperator.Write(ctx, e)
+		r.write(ctx, file, pathCaptures, decodeBuffer[:nDst])
 		messenger.SetOffset(pos)
+
+		// Yield to other files once this file has used its budget
+		if r.maxBytesPerRead > 0 && pos-startOffset >= int64(r.maxBytesPerRead) {
+			budgetExhausted = true
+			return nil
+		}
+	}
+}
+
+// readCompressedToEnd will decompress a file and read all of its entries. Reading is deferred
+// until the size of the file is unchanged between two polls so that files which are still
+// being compressed are not read partially. A compressed stream can not be seeked, so the
+// position in the decompressed stream is recorded after each entry, and the entries before
+// it are skipped when a read is resumed. The offset is only advanced to the end of the file
+// once the stream has been read to the end, or has failed to decompress.
+func (r *fileReader) readCompressedToEnd(
+	ctx context.Context,
+	file *os.File,
+	compression string,
+	fileSize int64,
+	startOffset int64,
+	decompressedOffset int64,
+	lastSeenFileSize int64,
+	pathCaptures []pathCapture,
+	messenger fileUpdateMessenger,
+) error {
+	if startOffset >= fileSize || lastSeenFileSize != fileSize {
+		return nil
+	}
+
+	// A stream that fails to decompress will fail the same way on every poll,
+	// so the file is treated as read rather than retried
+	if err := r.readDecompressed(ctx, file, compression, decompressedOffset, pathCaptures, messenger); err != nil {
+		r.inputOperator.Errorw("Failed to decompress file. It will not be read again", zap.String("path", file.Name()), zap.Error(err))
+	} else if ctx.Err() != nil {
+		return nil
+	}
+
+	messenger.SetOffset(fileSize)
+	return nil
+}
+
+// readDecompressed will read the entries of a compressed stream that follow decompressedOffset
+func (r *fileReader) readDecompressed(
+	ctx context.Context,
+	file *os.File,
+	compression string,
+	decompressedOffset int64,
+	pathCaptures []pathCapture,
+	messenger fileUpdateMessenger,
+) error {
+	reader, err := newDecompressor(file, compression)
+	if err != nil {
+		return fmt.Errorf("open %s stream: %s", compression, err)
 	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	buf := make([]byte, 0, 16384)
+	scanner.Buffer(buf, r.maxLogSize)
+
+	// The stream will not grow, so anything left after the last
+	// complete token is emitted as the final entry
+	var pos int64
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = r.splitFunc(data, atEOF)
+		if err == nil && token == nil && atEOF && len(data) > 0 {
+			advance, token = len(data), data
+		}
+		pos += int64(advance)
+		return
+	})
+
+	decoder := r.encoding.NewDecoder()
+	decodeBuffer := make([]byte, 16384)
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		// Skip the entries that were read before the read was interrupted
+		if pos <= decompressedOffset {
+			continue
+		}
+
+		decoder.Reset()
+		nDst, _, err := decoder.Transform(decodeBuffer, scanner.Bytes(), true)
+		if err != nil {
+			return fmt.Errorf("decode entry: %s", err)
+		}
+
+		r.write(ctx, file, pathCaptures, decodeBuffer[:nDst])
+		messenger.SetDecompressedOffset(pos)
+	}
+
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return errors.NewError("log entry too large", "increase max_log_size or ensure that multiline regex patterns terminate")
+		}
+		return fmt.Errorf("read %s stream: %s", compression, err)
+	}
+	return nil
 }
 
 // readRemaining will read the remaining characters in a file as a log entry.
-func readRemaining(ctx context.Context, file *os.File, filePos int64, fileSize int64, messenger fileUpdateMessenger, inputOperator helper.InputOperator, filePathField, fileNameField entry.Field, encoder *encoding.Decoder, decodeBuffer []byte) {
+func (r *fileReader) readRemaining(ctx context.Context, file *os.File, filePos int64, fileSize int64, pathCaptures []pathCapture, messenger fileUpdateMessenger, decoder *encoding.Decoder, decodeBuffer []byte) {
 	_, err := file.Seek(filePos, 0)
 	if err != nil {
-		inputOperator.Errorf("failed to seek to read last log entry")
+		r.inputOperator.Errorf("failed to seek to read last log entry")
 		return
 	}
 
 	msgBuf := make([]byte, fileSize-filePos)
 	n, err := file.Read(msgBuf)
 	if err != nil {
-		inputOperator.Errorf("failed to read trailing log")
+		r.inputOperator.Errorf("failed to read trailing log")
 		return
 	}
-	encoder.Reset()
-	nDst, _, err := encoder.Transform(decodeBuffer, msgBuf, true)
+	decoder.Reset()
+	nDst, _, err := decoder.Transform(decodeBuffer, msgBuf, true)
 	if err != nil {
-		inputOperator.Errorw("failed to decode trailing log", zap.Error(err))
+		r.inputOperator.Errorw("failed to decode trailing log", zap.Error(err))
 	}
 
-	e := inputOperator.NewEntry(string(decodeBuffer[:nDst]))
-	e.Set(filePathField, file.Name())
-	e.Set(fileNameField, filepath.Base(file.Name()))
-	inputOperator.Write(ctx, e)
+	r.write(ctx, file, pathCaptures, decodeBuffer[:nDst])
 	messenger.SetOffset(filePos + int64(n))
 }
+
+// write will send a decoded token read from a file to the outputs of the input operator
+func (r *fileReader) write(ctx context.Context, file *os.File, pathCaptures []pathCapture, token []byte) {
+	e := r.inputOperator.NewEntry(string(token))
+	e.Set(r.filePathField, file.Name())
+	e.Set(r.fileNameField, filepath.Base(file.Name()))
+	setPathCaptures(e, pathCaptures)
+	r.inputOperator.Write(ctx, e)
+}
@@ -0,0 +1,131 @@
+package file
+
+import (
+	"container/heap"
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// waitingFilesReportInterval is the minimum duration between reports of files waiting to be read
+var waitingFilesReportInterval = time.Minute
+
+// fileQueue is a priority queue of files waiting to be read. Files that were read least
+// recently are read first, and files that were last read at the same time, such as files
+// that have never been read, are read in the order they were queued.
+type fileQueue struct {
+	files []queuedFile
+	paths map[string]struct{}
+	seq   int64
+}
+
+type queuedFile struct {
+	path     string
+	lastRead time.Time
+	seq      int64
+}
+
+func newFileQueue() *fileQueue {
+	return &fileQueue{
+		paths: make(map[string]struct{}),
+	}
+}
+
+// Len is used to implement heap.Interface, and should not be called directly
+func (q *fileQueue) Len() int { return len(q.files) }
+
+// Less is used to implement heap.Interface, and should not be called directly
+func (q *fileQueue) Less(i, j int) bool {
+	if q.files[i].lastRead.Equal(q.files[j].lastRead) {
+		return q.files[i].seq < q.files[j].seq
+	}
+	return q.files[i].lastRead.Before(q.files[j].lastRead)
+}
+
+// Swap is used to implement heap.Interface, and should not be called directly
+func (q *fileQueue) Swap(i, j int) { q.files[i], q.files[j] = q.files[j], q.files[i] }
+
+// Push is used to implement heap.Interface, and should not be called directly
+func (q *fileQueue) Push(x interface{}) { q.files = append(q.files, x.(queuedFile)) }
+
+// Pop is used to implement heap.Interface, and should not be called directly
+func (q *fileQueue) Pop() interface{} {
+	last := q.files[len(q.files)-1]
+	q.files = q.files[:len(q.files)-1]
+	return last
+}
+
+// add will queue a file if it is not already queued
+func (q *fileQueue) add(path string, lastRead time.Time) {
+	if q.contains(path) {
+		return
+	}
+	q.seq++
+	q.paths[path] = struct{}{}
+	heap.Push(q, queuedFile{path: path, lastRead: lastRead, seq: q.seq})
+}
+
+// next will remove and return the file that should be read next
+func (q *fileQueue) next() string {
+	file := heap.Pop(q).(queuedFile)
+	delete(q.paths, file.path)
+	return file.path
+}
+
+// contains will return true if a file is queued
+func (q *fileQueue) contains(path string) bool {
+	_, ok := q.paths[path]
+	return ok
+}
+
+// WaitingFiles returns the number of files that have new data, but are waiting to
+// be read because max_concurrent_files are already being read. It is safe to call
+// from multiple goroutines.
+func (f *InputOperator) WaitingFiles() int {
+	return int(atomic.LoadInt64(&f.waitingFiles))
+}
+
+// startQueuedFiles will start reading queued files until max_concurrent_files are being read.
+// It is not safe to call from multiple goroutines.
+func (f *InputOperator) startQueuedFiles(ctx context.Context) {
+	for f.queue.Len() > 0 {
+		if f.MaxConcurrentFiles > 0 && len(f.runningFiles) >= f.MaxConcurrentFiles {
+			break
+		}
+		f.startFile(ctx, f.queue.next())
+	}
+	atomic.StoreInt64(&f.waitingFiles, int64(f.queue.Len()))
+}
+
+// startFile will start reading a file from its last offset in a new goroutine
+func (f *InputOperator) startFile(ctx context.Context, path string) {
+	knownFile := f.knownFiles[path]
+	knownFile.LastRead = time.Now()
+
+	f.runningFiles[path] = struct{}{}
+	f.readerWg.Add(1)
+	go func(ctx context.Context, path string, offset, decompressedOffset, lastSeenSize int64, lastChanged time.Time) {
+		defer f.readerWg.Done()
+		messenger := f.newFileUpdateMessenger(path)
+		err := f.reader.ReadToEnd(ctx, path, offset, decompressedOffset, lastSeenSize, lastChanged, messenger)
+		if err != nil {
+			f.Warnw("Failed to read log file", zap.Error(err))
+		}
+	}(ctx, path, knownFile.Offset, knownFile.DecompressedOffset, knownFile.LastSeenFileSize, knownFile.LastChanged)
+}
+
+// reportWaitingFiles will periodically report the number of files waiting to be read
+func (f *InputOperator) reportWaitingFiles() {
+	waiting := f.queue.Len()
+	if waiting == 0 || time.Since(f.lastWaitingReport) < waitingFilesReportInterval {
+		return
+	}
+
+	f.lastWaitingReport = time.Now()
+	f.Infow("Files are waiting to be read because max_concurrent_files are already being read",
+		"waiting_files", waiting,
+		"max_concurrent_files", f.MaxConcurrentFiles,
+	)
+}
@@ -0,0 +1,105 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileQueue(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+
+	queue := newFileQueue()
+	queue.add("recent", now)
+	queue.add("never1", time.Time{})
+	queue.add("old", now.Add(-time.Minute))
+	queue.add("never2", time.Time{})
+	queue.add("never1", time.Time{})
+
+	require.True(t, queue.contains("old"))
+	require.False(t, queue.contains("missing"))
+	require.Equal(t, 4, queue.Len())
+
+	var order []string
+	for queue.Len() > 0 {
+		order = append(order, queue.next())
+	}
+	require.Equal(t, []string{"never1", "never2", "old", "recent"}, order)
+	require.False(t, queue.contains("old"))
+}
+
+func TestFileSource_MaxConcurrentFiles(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.MaxConcurrentFiles = 1
+
+	expected := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		message := fmt.Sprintf("testlog%d", i)
+		err := ioutil.WriteFile(filepath.Join(tempDir, fmt.Sprintf("%d.log", i)), []byte(message+"\n"), 0600)
+		require.NoError(t, err)
+		expected = append(expected, message)
+	}
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessages(t, logReceived, expected)
+	require.Eventually(t, func() bool {
+		return source.WaitingFiles() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFileSource_MaxBytesPerRead(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.MaxConcurrentFiles = 1
+	source.MaxBytesPerRead = 100
+
+	// The large file is read first, but must yield to the small file once it has used its budget
+	large := make([]byte, 0, 1000)
+	for i := 0; i < 100; i++ {
+		large = append(large, fmt.Sprintf("large%03d\n", i)...)
+	}
+	err := ioutil.WriteFile(filepath.Join(tempDir, "a.log"), large, 0600)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(tempDir, "b.log"), []byte("small\n"), 0600)
+	require.NoError(t, err)
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	received := make([]string, 0, 101)
+	for len(received) < 101 {
+		select {
+		case e := <-logReceived:
+			received = append(received, e.Record.(string))
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for messages", "received %d", len(received))
+		}
+	}
+
+	smallIndex := -1
+	for i, message := range received {
+		if message == "small" {
+			smallIndex = i
+		}
+	}
+	require.NotEqual(t, -1, smallIndex)
+	require.Less(t, smallIndex, 100, "small file was not read until the large file was finished")
+	require.Equal(t, "large099", received[100])
+
+	expectNoMessages(t, logReceived)
+}
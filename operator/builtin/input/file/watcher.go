@@ -0,0 +1,245 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+const (
+	watchModePoll    = "poll"
+	watchModeInotify = "inotify"
+)
+
+// fileWatcher watches the directories that may contain files matching the include
+// patterns, so that files can be read as soon as they change rather than on the next poll.
+type fileWatcher struct {
+	*fsnotify.Watcher
+	include     []string
+	excludeDirs []string
+	watched     map[string]struct{}
+
+	// targets maps the resolved path of a symlinked file to the matched paths that link to it
+	targets map[string][]string
+}
+
+func newFileWatcher(include, excludeDirs []string) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileWatcher{
+		Watcher:     watcher,
+		include:     include,
+		excludeDirs: excludeDirs,
+		watched:     make(map[string]struct{}),
+		targets:     make(map[string][]string),
+	}, nil
+}
+
+// events returns the channel of watched events, or nil if the watcher is not in use
+func (w *fileWatcher) events() <-chan fsnotify.Event {
+	if w == nil {
+		return nil
+	}
+	return w.Events
+}
+
+// errors returns the channel of watcher errors, or nil if the watcher is not in use
+func (w *fileWatcher) errors() <-chan error {
+	if w == nil {
+		return nil
+	}
+	return w.Errors
+}
+
+// close will stop watching for changes
+func (w *fileWatcher) close() {
+	if w != nil {
+		w.Close()
+	}
+}
+
+// refresh will watch every directory that may contain a file matching the include patterns,
+// along with the directories containing the targets of symlinked matches. An error is returned
+// if a directory is on a filesystem that does not reliably report changes.
+func (w *fileWatcher) refresh(matches []string) error {
+	dirs := make(map[string]struct{})
+	for _, include := range w.include {
+		for _, dir := range watchDirs(include, w.excludeDirs) {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	// Writes to the target of a symlink are only reported in the directory of the target
+	targets := make(map[string][]string)
+	for _, match := range matches {
+		target, err := filepath.EvalSymlinks(match)
+		if err != nil || target == match {
+			continue
+		}
+		targets[target] = append(targets[target], match)
+		dirs[filepath.Dir(target)] = struct{}{}
+	}
+	w.targets = targets
+
+	for dir := range w.watched {
+		if _, ok := dirs[dir]; !ok {
+			_ = w.Remove(dir) // the directory may no longer exist
+			delete(w.watched, dir)
+		}
+	}
+
+	for dir := range dirs {
+		if _, ok := w.watched[dir]; ok {
+			continue
+		}
+
+		if err := checkFilesystem(dir); err != nil {
+			return err
+		}
+
+		if err := w.Add(dir); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("watch directory %s: %s", dir, err)
+		}
+		w.watched[dir] = struct{}{}
+	}
+
+	return nil
+}
+
+// resolve will return the matched paths that may have changed as a result of an event on a path
+func (w *fileWatcher) resolve(path string) []string {
+	if links, ok := w.targets[path]; ok {
+		return links
+	}
+	return []string{path}
+}
+
+// isWatchedDir will return true if the path is a directory being watched
+func (w *fileWatcher) isWatchedDir(path string) bool {
+	_, ok := w.watched[path]
+	return ok
+}
+
+// watchDirs returns the existing directories that must be watched to notice new files
+// matching an include pattern. These are the directories matching each level of the
+// pattern below its static prefix, apart from excluded directories. If the static prefix
+// does not exist yet, its closest existing parent is watched instead, so that its creation
+// is noticed.
+func watchDirs(include string, excludeDirs []string) []string {
+	static, components := splitPattern(filepath.Dir(filepath.Clean(include)))
+	if isExcludedPath(static, excludeDirs) {
+		return nil
+	}
+
+	existing := static
+	for !isDir(existing) {
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+
+	dirs := []string{existing}
+	if existing != static {
+		return dirs
+	}
+
+	seen := map[string]struct{}{existing: {}}
+	prefix := static
+	for _, component := range components {
+		prefix = filepath.Join(prefix, component)
+		for _, match := range glob(prefix, excludeDirs) {
+			if _, ok := seen[match]; ok || !isDir(match) {
+				continue
+			}
+			seen[match] = struct{}{}
+			dirs = append(dirs, match)
+		}
+	}
+
+	return dirs
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// handleWatchEvent will check the files affected by a watched event. It is not safe to call from
+// multiple goroutines, and returns the watcher that should be used from then on, which is nil if
+// watching has failed and the operator has fallen back to polling.
+func (f *InputOperator) handleWatchEvent(ctx context.Context, watcher *fileWatcher, event fsnotify.Event) *fileWatcher {
+	switch {
+	case event.Op&fsnotify.Create != 0 && (isDir(event.Name) || isSymlink(event.Name)):
+		// New directories and symlinks change the set of directories to watch
+		return f.rescan(ctx, watcher)
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && watcher.isWatchedDir(event.Name):
+		return f.rescan(ctx, watcher)
+	case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
+		for _, path := range watcher.resolve(event.Name) {
+			if !matchesPatterns(path, f.Include, f.Exclude, f.ExcludeDirs) {
+				continue
+			}
+
+			// If the file is being read, it may have already reached the end of the file
+			// before this write, so check the file again once it is finished
+			if _, ok := f.runningFiles[path]; ok {
+				f.pendingFiles[path] = struct{}{}
+				continue
+			}
+
+			f.checkFile(ctx, path, false)
+		}
+	}
+
+	return watcher
+}
+
+// checkPendingFile will check a file again if it was changed while it was being read
+func (f *InputOperator) checkPendingFile(ctx context.Context, path string) {
+	if _, ok := f.pendingFiles[path]; !ok {
+		return
+	}
+	delete(f.pendingFiles, path)
+	f.checkFile(ctx, path, false)
+}
+
+// rescan will check every matching file and refresh the watched directories
+func (f *InputOperator) rescan(ctx context.Context, watcher *fileWatcher) *fileWatcher {
+	matches := f.pollFiles(ctx, false)
+	if watcher == nil {
+		return nil
+	}
+	return f.refreshWatcher(watcher, matches)
+}
+
+// refreshWatcher will refresh the watched directories, falling back to polling if any cannot be watched
+func (f *InputOperator) refreshWatcher(watcher *fileWatcher, matches []string) *fileWatcher {
+	if err := watcher.refresh(matches); err != nil {
+		f.Warnw("Failed to watch for file changes. Falling back to polling for file changes", zap.Error(err))
+		watcher.close()
+		return nil
+	}
+	return watcher
+}
@@ -0,0 +1,33 @@
+// +build linux
+
+package file
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// unsupportedFilesystems are filesystems where inotify does not report changes made by other hosts
+var unsupportedFilesystems = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x73757245: "coda",
+	0x5346414f: "afs",
+	0x01021997: "9p",
+	0x65735546: "fuse",
+}
+
+// checkFilesystem will return an error if a directory is on a filesystem that does not support inotify
+func checkFilesystem(dir string) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return fmt.Errorf("stat filesystem of %s: %s", dir, err)
+	}
+
+	if name, ok := unsupportedFilesystems[uint32(stat.Type)]; ok {
+		return fmt.Errorf("directory %s is on a %s filesystem, which does not support inotify", dir, name)
+	}
+	return nil
+}
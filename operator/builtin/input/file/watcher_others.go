@@ -0,0 +1,10 @@
+// +build !linux
+
+package file
+
+import "fmt"
+
+// checkFilesystem will always return an error, because inotify is only available on linux
+func checkFilesystem(dir string) error {
+	return fmt.Errorf("inotify is only supported on linux")
+}
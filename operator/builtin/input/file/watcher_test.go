@@ -0,0 +1,260 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWatchingFileSource(t *testing.T) (*InputOperator, chan *entry.Entry) {
+	if runtime.GOOS != "linux" {
+		t.Skip("inotify is only supported on linux")
+	}
+
+	// After the first check, files are only read in response to watched
+	// events, even though the poll interval is short
+	source, logReceived := newTestFileSource(t)
+	source.watchMode = watchModeInotify
+	return source, logReceived
+}
+
+func TestFileWatcher_ReadExistingAndNewLogs(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestWatchingFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+
+	temp, err := ioutil.TempFile(tempDir, "")
+	require.NoError(t, err)
+	defer temp.Close()
+
+	_, err = temp.WriteString("testlog1\n")
+	require.NoError(t, err)
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessage(t, logReceived, "testlog1")
+
+	_, err = temp.WriteString("testlog2\n")
+	require.NoError(t, err)
+
+	waitForMessage(t, logReceived, "testlog2")
+}
+
+func TestFileWatcher_NewFiles(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestWatchingFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*/*.log", tempDir)}
+
+	subDir := filepath.Join(tempDir, "first")
+	require.NoError(t, os.Mkdir(subDir, 0755))
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	// Wait for the first check to complete
+	time.Sleep(200 * time.Millisecond)
+
+	err = ioutil.WriteFile(filepath.Join(subDir, "a.log"), []byte("testlog1\n"), 0666)
+	require.NoError(t, err)
+	waitForMessage(t, logReceived, "testlog1")
+
+	// Files in new directories are noticed as well
+	newDir := filepath.Join(tempDir, "second")
+	require.NoError(t, os.Mkdir(newDir, 0755))
+	time.Sleep(50 * time.Millisecond)
+	err = ioutil.WriteFile(filepath.Join(newDir, "b.log"), []byte("testlog2\n"), 0666)
+	require.NoError(t, err)
+	waitForMessage(t, logReceived, "testlog2")
+
+	// Excluded by the include pattern
+	err = ioutil.WriteFile(filepath.Join(newDir, "c.txt"), []byte("testlog3\n"), 0666)
+	require.NoError(t, err)
+	expectNoMessages(t, logReceived)
+}
+
+func TestFileWatcher_RecursiveInclude(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestWatchingFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{filepath.Join(tempDir, "**", "*.log")}
+	source.ExcludeDirs = []string{filepath.Join(tempDir, "**", "archive")}
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	// Wait for the first check to complete
+	time.Sleep(200 * time.Millisecond)
+
+	// Nested directories created after startup are watched as well
+	nestedDir := filepath.Join(tempDir, "apps", "web")
+	require.NoError(t, os.MkdirAll(nestedDir, 0755))
+	time.Sleep(50 * time.Millisecond)
+	err = ioutil.WriteFile(filepath.Join(nestedDir, "a.log"), []byte("testlog1\n"), 0666)
+	require.NoError(t, err)
+	waitForMessage(t, logReceived, "testlog1")
+
+	archiveDir := filepath.Join(nestedDir, "archive")
+	require.NoError(t, os.Mkdir(archiveDir, 0755))
+	time.Sleep(50 * time.Millisecond)
+	err = ioutil.WriteFile(filepath.Join(archiveDir, "b.log"), []byte("testlog2\n"), 0666)
+	require.NoError(t, err)
+	expectNoMessages(t, logReceived)
+}
+
+func TestFileWatcher_Symlink(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestWatchingFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	linkDir := filepath.Join(tempDir, "links")
+	targetDir := filepath.Join(tempDir, "targets")
+	require.NoError(t, os.Mkdir(linkDir, 0755))
+	require.NoError(t, os.Mkdir(targetDir, 0755))
+	source.Include = []string{fmt.Sprintf("%s/*.log", linkDir)}
+
+	target, err := os.Create(filepath.Join(targetDir, "0.log"))
+	require.NoError(t, err)
+	defer target.Close()
+	require.NoError(t, os.Symlink(target.Name(), filepath.Join(linkDir, "container.log")))
+
+	_, err = target.WriteString("testlog1\n")
+	require.NoError(t, err)
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	waitForMessage(t, logReceived, "testlog1")
+
+	_, err = target.WriteString("testlog2\n")
+	require.NoError(t, err)
+	waitForMessage(t, logReceived, "testlog2")
+}
+
+func TestWatchDirs(t *testing.T) {
+	t.Parallel()
+	tempDir := testutil.NewTempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "a", "x"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "b", "y"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "c"), nil, 0666))
+
+	cases := []struct {
+		name        string
+		include     string
+		excludeDirs []string
+		expected    []string
+	}{
+		{
+			"Static",
+			filepath.Join(tempDir, "a", "*.log"),
+			nil,
+			[]string{filepath.Join(tempDir, "a")},
+		},
+		{
+			"Wildcards",
+			filepath.Join(tempDir, "*", "*", "*.log"),
+			nil,
+			[]string{
+				tempDir,
+				filepath.Join(tempDir, "a"),
+				filepath.Join(tempDir, "b"),
+				filepath.Join(tempDir, "a", "x"),
+				filepath.Join(tempDir, "b", "y"),
+			},
+		},
+		{
+			"MissingStaticPrefix",
+			filepath.Join(tempDir, "missing", "dir", "*.log"),
+			nil,
+			[]string{tempDir},
+		},
+		{
+			"GlobStar",
+			filepath.Join(tempDir, "**", "*.log"),
+			nil,
+			[]string{
+				tempDir,
+				filepath.Join(tempDir, "a"),
+				filepath.Join(tempDir, "b"),
+				filepath.Join(tempDir, "a", "x"),
+				filepath.Join(tempDir, "b", "y"),
+			},
+		},
+		{
+			"ExcludeDirs",
+			filepath.Join(tempDir, "**", "*.log"),
+			[]string{filepath.Join(tempDir, "b")},
+			[]string{
+				tempDir,
+				filepath.Join(tempDir, "a"),
+				filepath.Join(tempDir, "a", "x"),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ElementsMatch(t, tc.expected, watchDirs(tc.include, tc.excludeDirs))
+		})
+	}
+}
+
+func TestFileWatcher_MultilineForceFlush(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestWatchingFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.SplitFunc = NewLineStartSplitFunc(regexp.MustCompile("START "))
+	source.ForceFlushPeriod = 200 * time.Millisecond
+
+	temp, err := ioutil.TempFile(tempDir, "")
+	require.NoError(t, err)
+	defer temp.Close()
+
+	err = source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	// Without further writes, no events are received, so the flush must not depend on them
+	_, err = temp.WriteString("START log1\ncontinued\n")
+	require.NoError(t, err)
+	waitForMessage(t, logReceived, "START log1\ncontinued\n")
+}
+
+func TestFileWatcher_OnCompleteDelete(t *testing.T) {
+	t.Parallel()
+	source, logReceived := newTestWatchingFileSource(t)
+	tempDir := testutil.NewTempDir(t)
+	source.Include = []string{fmt.Sprintf("%s/*", tempDir)}
+	source.onComplete = onCompleteDelete
+
+	err := source.Start()
+	require.NoError(t, err)
+	defer source.Stop()
+
+	// Wait for the first check to complete
+	time.Sleep(200 * time.Millisecond)
+
+	// Files are completed even though no further changes are watched
+	path := filepath.Join(tempDir, "spool.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("testlog1\n"), 0666))
+	waitForMessage(t, logReceived, "testlog1")
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+}
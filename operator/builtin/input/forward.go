@@ -0,0 +1,296 @@
+package input
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/forward"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("forward_input", func() operator.Builder { return NewForwardInputConfig("") })
+}
+
+// defaultMaxForwardMessageSize is the default maximum size of a message of the forward protocol,
+// which contains a chunk of entries
+const defaultMaxForwardMessageSize = 16 * 1024 * 1024
+
+func NewForwardInputConfig(operatorID string) *ForwardInputConfig {
+	return &ForwardInputConfig{
+		InputConfig:    helper.NewInputConfig(operatorID, "forward_input"),
+		ListenAddress:  "0.0.0.0:24224",
+		MaxMessageSize: defaultMaxForwardMessageSize,
+	}
+}
+
+// ForwardInputConfig is the configuration of a forward input operator.
+type ForwardInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	ListenAddress  string              `json:"listen_address,omitempty"   yaml:"listen_address,omitempty"`
+	TLS            *helper.TLSConfig   `json:"tls,omitempty"              yaml:"tls,omitempty"`
+	SharedKey      string              `json:"shared_key,omitempty"       yaml:"shared_key,omitempty"`
+	Users          []ForwardUserConfig `json:"users,omitempty"            yaml:"users,omitempty"`
+	SelfHostname   string              `json:"self_hostname,omitempty"    yaml:"self_hostname,omitempty"`
+	MaxMessageSize int                 `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
+}
+
+// ForwardUserConfig is a user that clients can authenticate as.
+type ForwardUserConfig struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// Build will build a forward input operator.
+func (c ForwardInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ListenAddress == "" {
+		return nil, fmt.Errorf("missing required parameter 'listen_address'")
+	}
+
+	address, err := net.ResolveTCPAddr("tcp", c.ListenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
+	}
+
+	if c.MaxMessageSize <= 0 {
+		return nil, fmt.Errorf("max_message_size must be greater than 0")
+	}
+
+	if len(c.Users) > 0 && c.SharedKey == "" {
+		return nil, fmt.Errorf("users can only be configured with a shared_key")
+	}
+	users := make(map[string]string, len(c.Users))
+	for _, user := range c.Users {
+		if user.Username == "" {
+			return nil, fmt.Errorf("missing username of user")
+		}
+		if _, ok := users[user.Username]; ok {
+			return nil, fmt.Errorf("duplicate user '%s'", user.Username)
+		}
+		users[user.Username] = user.Password
+	}
+
+	selfHostname := c.SelfHostname
+	if selfHostname == "" {
+		selfHostname, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("get hostname for self_hostname: %s", err)
+		}
+	}
+
+	forwardInput := &ForwardInput{
+		InputOperator:  inputOperator,
+		address:        address,
+		sharedKey:      c.SharedKey,
+		users:          users,
+		selfHostname:   selfHostname,
+		maxMessageSize: c.MaxMessageSize,
+	}
+
+	if c.TLS != nil {
+		forwardInput.tlsConfig, err = c.TLS.Build(inputOperator.SugaredLogger)
+		if err != nil {
+			return nil, fmt.Errorf("build tls config: %s", err)
+		}
+	}
+	return forwardInput, nil
+}
+
+// ForwardInput is an operator that receives log entries with the fluent forward protocol.
+type ForwardInput struct {
+	helper.InputOperator
+	address   *net.TCPAddr
+	tlsConfig *tls.Config
+
+	sharedKey      string
+	users          map[string]string
+	selfHostname   string
+	maxMessageSize int
+
+	listener  net.Listener
+	cancel    context.CancelFunc
+	waitGroup *sync.WaitGroup
+}
+
+// Start will start listening for forward connections.
+func (f *ForwardInput) Start() error {
+	listener, err := net.ListenTCP("tcp", f.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on interface: %w", err)
+	}
+
+	f.listener = listener
+	if f.tlsConfig != nil {
+		f.listener = tls.NewListener(listener, f.tlsConfig)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.waitGroup = &sync.WaitGroup{}
+
+	f.waitGroup.Add(1)
+	go func() {
+		defer f.waitGroup.Done()
+		var delay time.Duration
+		for {
+			conn, err := f.listener.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					delay = nextErrorDelay(delay)
+					f.Errorw("Listener accept error", zap.Error(err), "retry_delay", delay)
+					if !waitErrorDelay(ctx, delay) {
+						return
+					}
+					continue
+				}
+			}
+			delay = 0
+
+			f.Debugf("Received connection: %s", conn.RemoteAddr().String())
+			subctx, cancel := context.WithCancel(ctx)
+			f.waitGroup.Add(2)
+			go func() {
+				defer f.waitGroup.Done()
+				<-subctx.Done()
+				if err := conn.Close(); err != nil {
+					f.Debugf("Failed to close connection: %s", err)
+				}
+			}()
+			go func() {
+				defer f.waitGroup.Done()
+				defer cancel()
+				f.handleConnection(subctx, conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// handleConnection will authenticate a connection, and write the entries of the messages it sends
+func (f *ForwardInput) handleConnection(ctx context.Context, conn net.Conn) {
+	remoteAddress := conn.RemoteAddr().String()
+	decoder := forward.NewDecoder(conn, f.maxMessageSize)
+
+	if err := f.handshake(conn, decoder); err != nil {
+		f.Warnw("Closing connection that failed the handshake", "remote_address", remoteAddress, zap.Error(err))
+		return
+	}
+
+	for {
+		value, err := decoder.Decode()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			default:
+				f.Warnw("Closing connection that sent an invalid message", "remote_address", remoteAddress, zap.Error(err))
+			}
+			return
+		}
+
+		msg, err := forward.ParseMessage(value, f.maxMessageSize)
+		if err != nil {
+			f.Warnw("Closing connection that sent an invalid message", "remote_address", remoteAddress, zap.Error(err))
+			return
+		}
+
+		for _, e := range msg.Entries {
+			entry := f.NewEntry(e.Record)
+			entry.Timestamp = e.Time
+			entry.AddLabel("tag", msg.Tag)
+			f.Write(ctx, entry)
+		}
+
+		// The chunk is acknowledged once its entries are written, so that the client can discard it
+		if msg.Chunk != "" {
+			ack, err := forward.Marshal(map[string]string{"ack": msg.Chunk})
+			if err != nil {
+				f.Errorw("Failed to encode ack", zap.Error(err))
+				return
+			}
+			if _, err := conn.Write(ack); err != nil {
+				f.Debugf("Failed to write ack: %s", err)
+				return
+			}
+		}
+	}
+}
+
+// handshake will complete the tls handshake of a connection, and authenticate the client if a
+// shared key is configured. Both must complete before the tls handshake timeout.
+func (f *ForwardInput) handshake(conn net.Conn, decoder *forward.Decoder) error {
+	_, isTLS := conn.(*tls.Conn)
+	if !isTLS && f.sharedKey == "" {
+		return nil
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout)); err != nil {
+		return err
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+	}
+
+	if f.sharedKey != "" {
+		handshake, err := forward.NewHandshake(f.sharedKey, f.selfHostname, f.users)
+		if err != nil {
+			return err
+		}
+
+		helo, err := handshake.Helo()
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Write(helo); err != nil {
+			return err
+		}
+
+		ping, err := decoder.Decode()
+		if err != nil {
+			return err
+		}
+		pong, verifyErr := handshake.Verify(ping)
+		if pong != nil {
+			if _, err := conn.Write(pong); err != nil {
+				return err
+			}
+		}
+		if verifyErr != nil {
+			return verifyErr
+		}
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// Stop will stop listening for forward connections.
+func (f *ForwardInput) Stop() error {
+	f.cancel()
+
+	if err := f.listener.Close(); err != nil {
+		return err
+	}
+
+	f.waitGroup.Wait()
+	return nil
+}
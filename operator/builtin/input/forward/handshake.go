@@ -0,0 +1,128 @@
+package forward
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// Handshake authenticates a client with a shared key, and optionally a username and password,
+// by exchanging the HELO, PING and PONG messages of the forward protocol
+type Handshake struct {
+	sharedKey    string
+	selfHostname string
+	users        map[string]string
+
+	nonce    []byte
+	authSalt []byte
+}
+
+// NewHandshake will create the handshake of a connection. Users are only authenticated if
+// the map of usernames to passwords is not empty.
+func NewHandshake(sharedKey, selfHostname string, users map[string]string) (*Handshake, error) {
+	h := &Handshake{
+		sharedKey:    sharedKey,
+		selfHostname: selfHostname,
+		users:        users,
+		nonce:        make([]byte, 16),
+	}
+	if _, err := rand.Read(h.nonce); err != nil {
+		return nil, err
+	}
+
+	if len(users) > 0 {
+		h.authSalt = make([]byte, 16)
+		if _, err := rand.Read(h.authSalt); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Helo will return the HELO message that is sent to a client when it connects
+func (h *Handshake) Helo() ([]byte, error) {
+	authSalt := h.authSalt
+	if authSalt == nil {
+		authSalt = []byte{}
+	}
+	return Marshal([]interface{}{
+		"HELO",
+		map[string]interface{}{
+			"nonce":     h.nonce,
+			"auth":      authSalt,
+			"keepalive": true,
+		},
+	})
+}
+
+// Verify will verify the PING message of a client, and return the PONG message it is answered
+// with. It returns an error if the client was not authenticated, in which case the PONG
+// message reports the reason.
+func (h *Handshake) Verify(value interface{}) ([]byte, error) {
+	ping, ok := value.([]interface{})
+	if !ok || len(ping) != 6 || ping[0] != "PING" {
+		return nil, fmt.Errorf("expected a PING message")
+	}
+
+	fields := make([]string, 0, 5)
+	for _, field := range ping[1:] {
+		switch v := field.(type) {
+		case string:
+			fields = append(fields, v)
+		case []byte:
+			fields = append(fields, string(v))
+		default:
+			return nil, fmt.Errorf("invalid PING message")
+		}
+	}
+	hostname, salt, digest, username, password := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	if hostname == h.selfHostname {
+		return h.pong(salt, "same hostname as the server")
+	}
+	if !equalDigest(digest, Digest(salt, hostname, string(h.nonce), h.sharedKey)) {
+		return h.pong(salt, "shared key mismatch")
+	}
+	if len(h.users) > 0 {
+		expected, ok := h.users[username]
+		if !ok || !equalDigest(password, Digest(string(h.authSalt), username, expected)) {
+			return h.pong(salt, "username/password mismatch")
+		}
+	}
+	return h.pong(salt, "")
+}
+
+// pong will return a PONG message, which is a failure if reason is not empty
+func (h *Handshake) pong(salt string, reason string) ([]byte, error) {
+	if reason != "" {
+		pong, err := Marshal([]interface{}{"PONG", false, reason, "", ""})
+		if err != nil {
+			return nil, err
+		}
+		return pong, fmt.Errorf("authentication failed: %s", reason)
+	}
+
+	// The client verifies the server with a digest that includes the hostname of the server
+	return Marshal([]interface{}{
+		"PONG",
+		true,
+		"",
+		h.selfHostname,
+		Digest(salt, h.selfHostname, string(h.nonce), h.sharedKey),
+	})
+}
+
+// Digest will return the hex encoded SHA-512 digest of the concatenated values
+func Digest(values ...string) string {
+	hash := sha512.New()
+	for _, value := range values {
+		hash.Write([]byte(value))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func equalDigest(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
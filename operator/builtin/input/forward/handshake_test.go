@@ -0,0 +1,77 @@
+package forward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ping will return the PING message of a client that answers a HELO message
+func ping(t *testing.T, helo []byte, hostname, sharedKey, username, password string) []interface{} {
+	value, err := Unmarshal(helo)
+	require.NoError(t, err)
+	options := value.([]interface{})[1].(map[string]interface{})
+	nonce, authSalt := options["nonce"].([]byte), options["auth"].([]byte)
+
+	salt := "client-salt"
+	var passwordDigest string
+	if username != "" {
+		passwordDigest = Digest(string(authSalt), username, password)
+	}
+	return []interface{}{"PING", hostname, salt, Digest(salt, hostname, string(nonce), sharedKey), username, passwordDigest}
+}
+
+func TestHandshake(t *testing.T) {
+	users := map[string]string{"fluent": "secret"}
+
+	cases := []struct {
+		name           string
+		users          map[string]string
+		hostname       string
+		sharedKey      string
+		username       string
+		password       string
+		expectedReason string
+	}{
+		{"SharedKey", nil, "client", "key", "", "", ""},
+		{"User", users, "client", "key", "fluent", "secret", ""},
+		{"SharedKeyMismatch", nil, "client", "other", "", "", "shared key mismatch"},
+		{"PasswordMismatch", users, "client", "key", "fluent", "wrong", "username/password mismatch"},
+		{"UnknownUser", users, "client", "key", "other", "secret", "username/password mismatch"},
+		{"SameHostname", nil, "server", "key", "", "", "same hostname as the server"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handshake, err := NewHandshake("key", "server", tc.users)
+			require.NoError(t, err)
+			helo, err := handshake.Helo()
+			require.NoError(t, err)
+
+			pong, err := handshake.Verify(ping(t, helo, tc.hostname, tc.sharedKey, tc.username, tc.password))
+			if tc.expectedReason != "" {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			value, err := Unmarshal(pong)
+			require.NoError(t, err)
+			fields := value.([]interface{})
+			require.Equal(t, "PONG", fields[0])
+			require.Equal(t, tc.expectedReason == "", fields[1])
+			require.Equal(t, tc.expectedReason, fields[2])
+			if tc.expectedReason == "" {
+				require.Equal(t, "server", fields[3])
+				require.Len(t, fields[4], 128)
+			}
+		})
+	}
+
+	t.Run("InvalidPing", func(t *testing.T) {
+		handshake, err := NewHandshake("key", "server", nil)
+		require.NoError(t, err)
+		_, err = handshake.Verify([]interface{}{"HELO"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,393 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// eventTimeExtType is the msgpack extension type of the EventTime of the forward protocol
+const eventTimeExtType = 0
+
+// maxDepth is the maximum nesting of arrays and maps in a decoded value
+const maxDepth = 100
+
+// Ext is a msgpack extension value of a type other than EventTime
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Decoder decodes msgpack values from a stream
+type Decoder struct {
+	r         *bufio.Reader
+	maxSize   int
+	remaining int
+}
+
+// NewDecoder will return a decoder of values that are each at most maxSize bytes long
+func NewDecoder(r io.Reader, maxSize int) *Decoder {
+	return &Decoder{
+		r:       bufio.NewReader(r),
+		maxSize: maxSize,
+	}
+}
+
+// Decode will decode the next value of the stream. Integers are decoded as int64, or as uint64 if
+// they are too large, strings as string, binary data as []byte, arrays as []interface{}, maps as
+// map[string]interface{}, and EventTime extensions as time.Time. It returns io.EOF if the stream
+// ended before the value.
+func (d *Decoder) Decode() (interface{}, error) {
+	d.remaining = d.maxSize
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	value, err := d.decode(0)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return value, err
+}
+
+// Unmarshal will decode a single value from data
+func Unmarshal(data []byte) (interface{}, error) {
+	return NewDecoder(bytes.NewReader(data), len(data)).Decode()
+}
+
+// errTooLarge is returned when a value is larger than the maximum size of the decoder
+var errTooLarge = fmt.Errorf("message is larger than the maximum size")
+
+func (d *Decoder) read(n int) ([]byte, error) {
+	if n > d.remaining {
+		return nil, errTooLarge
+	}
+	d.remaining -= n
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	buf, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(buf[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(buf)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(buf)), nil
+	default:
+		return binary.BigEndian.Uint64(buf), nil
+	}
+}
+
+// readLength will read a length of a string, binary, array or map, which must fit in the remaining size
+func (d *Decoder) readLength(size int) (int, error) {
+	length, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if length > uint64(d.remaining) {
+		return 0, errTooLarge
+	}
+	return int(length), nil
+}
+
+func (d *Decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("message is nested too deeply")
+	}
+
+	buf, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	b := buf[0]
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.decodeMap(int(b&0x0f), depth)
+	case b&0xf0 == 0x90:
+		return d.decodeArray(int(b&0x0f), depth)
+	case b&0xe0 == 0xa0:
+		return d.decodeString(int(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		length, err := d.readLength(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.read(length)
+	case 0xc7, 0xc8, 0xc9:
+		length, err := d.readLength(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(length)
+	case 0xca:
+		bits, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(bits))), nil
+	case 0xcb:
+		bits, err := d.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		value, err := d.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if value > math.MaxInt64 {
+			return value, nil
+		}
+		return int64(value), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		value, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// The value is sign extended from its size
+		shift := uint(64 - 8*size)
+		return int64(value<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		length, err := d.readLength(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(length)
+	case 0xdc, 0xdd:
+		length, err := d.readLength(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(length, depth)
+	case 0xde, 0xdf:
+		length, err := d.readLength(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(length, depth)
+	default:
+		return nil, fmt.Errorf("invalid msgpack type 0x%x", b)
+	}
+}
+
+func (d *Decoder) decodeString(length int) (interface{}, error) {
+	buf, err := d.read(length)
+	if err != nil {
+		return nil, err
+	}
+	return string(buf), nil
+}
+
+func (d *Decoder) decodeArray(length int, depth int) (interface{}, error) {
+	// Each element is at least one byte, so the length is limited by the remaining size
+	if length > d.remaining {
+		return nil, errTooLarge
+	}
+
+	array := make([]interface{}, 0, length)
+	for i := 0; i < length; i++ {
+		value, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+	}
+	return array, nil
+}
+
+func (d *Decoder) decodeMap(length int, depth int) (interface{}, error) {
+	if length > d.remaining {
+		return nil, errTooLarge
+	}
+
+	m := make(map[string]interface{}, length)
+	for i := 0; i < length; i++ {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch k := key.(type) {
+		case string:
+			m[k] = value
+		case []byte:
+			m[string(k)] = value
+		default:
+			m[fmt.Sprint(k)] = value
+		}
+	}
+	return m, nil
+}
+
+func (d *Decoder) decodeExt(length int) (interface{}, error) {
+	extType, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(length)
+	if err != nil {
+		return nil, err
+	}
+
+	// EventTime is the seconds and nanoseconds of a timestamp as big endian 32-bit integers
+	if int8(extType[0]) == eventTimeExtType && length == 8 {
+		seconds := binary.BigEndian.Uint32(data)
+		nanoseconds := binary.BigEndian.Uint32(data[4:])
+		return time.Unix(int64(seconds), int64(nanoseconds)), nil
+	}
+	return Ext{Type: int8(extType[0]), Data: data}, nil
+}
+
+// Marshal will encode a value as msgpack. It supports the types that are returned by a Decoder,
+// and other integer types and map[string]string, which are used in responses.
+func Marshal(value interface{}) ([]byte, error) {
+	return appendValue(nil, value)
+}
+
+func appendValue(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return appendUint64(append(b, 0xcf), v), nil
+		}
+		return appendInt(b, int64(v)), nil
+	case float64:
+		return appendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case string:
+		return append(appendLength(b, len(v), 0xa0, 0xd9), v...), nil
+	case []byte:
+		return append(appendLength(b, len(v), 0, 0xc4), v...), nil
+	case time.Time:
+		b = append(b, 0xd7, eventTimeExtType)
+		b = appendUint32(b, uint32(v.Unix()))
+		return appendUint32(b, uint32(v.Nanosecond())), nil
+	case Ext:
+		b = appendLength(b, len(v.Data), 0, 0xc7)
+		return append(append(b, byte(v.Type)), v.Data...), nil
+	case []interface{}:
+		b = appendCollectionLength(b, len(v), 0x90, 0xdc)
+		for _, element := range v {
+			var err error
+			if b, err = appendValue(b, element); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = appendCollectionLength(b, len(v), 0x80, 0xde)
+		for key, element := range v {
+			b = append(appendLength(b, len(key), 0xa0, 0xd9), key...)
+			var err error
+			if b, err = appendValue(b, element); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]string:
+		b = appendCollectionLength(b, len(v), 0x80, 0xde)
+		for key, element := range v {
+			b = append(appendLength(b, len(key), 0xa0, 0xd9), key...)
+			b = append(appendLength(b, len(element), 0xa0, 0xd9), element...)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("can not encode value of type %T", value)
+	}
+}
+
+func appendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		return append(b, byte(v))
+	case v < 0 && v >= -32:
+		return append(b, byte(int8(v)))
+	default:
+		return appendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+// appendLength will append the length of a string or binary value. A fix type is used for lengths
+// below 32 if fix is not 0, followed by the 8, 16 and 32-bit types that follow the first type.
+func appendLength(b []byte, length int, fix byte, first byte) []byte {
+	switch {
+	case fix != 0 && length < 32:
+		return append(b, fix|byte(length))
+	case length <= math.MaxUint8:
+		return append(b, first, byte(length))
+	case length <= math.MaxUint16:
+		return appendUint16(append(b, first+1), uint16(length))
+	default:
+		return appendUint32(append(b, first+2), uint32(length))
+	}
+}
+
+// appendCollectionLength will append the length of an array or map
+func appendCollectionLength(b []byte, length int, fix byte, first byte) []byte {
+	switch {
+	case length < 16:
+		return append(b, fix|byte(length))
+	case length <= math.MaxUint16:
+		return appendUint16(append(b, first), uint16(length))
+	default:
+		return appendUint32(append(b, first+1), uint32(length))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
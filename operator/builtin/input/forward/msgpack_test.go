@@ -0,0 +1,114 @@
+package forward
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgpack(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+	}{
+		{"Nil", nil},
+		{"True", true},
+		{"False", false},
+		{"FixInt", int64(5)},
+		{"NegativeFixInt", int64(-7)},
+		{"Int", int64(-1 << 40)},
+		{"LargeInt", int64(1 << 40)},
+		{"Uint64", uint64(1<<64 - 1)},
+		{"Float", 1.5},
+		{"FixString", "short"},
+		{"String", strings.Repeat("a", 300)},
+		{"LongString", strings.Repeat("a", 70000)},
+		{"Binary", []byte{0x00, 0xff}},
+		{"EventTime", time.Unix(1596620460, 123456789)},
+		{"Ext", Ext{Type: 5, Data: []byte{1, 2, 3}}},
+		{"Array", []interface{}{int64(1), "two", []interface{}{}}},
+		{"LongArray", make([]interface{}, 20)},
+		{"Map", map[string]interface{}{"a": int64(1), "b": map[string]interface{}{"c": "d"}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Marshal(tc.value)
+			require.NoError(t, err)
+			value, err := Unmarshal(data)
+			require.NoError(t, err)
+			require.Equal(t, tc.value, value)
+		})
+	}
+}
+
+func TestMsgpackDecode(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{"Uint8", []byte{0xcc, 0xff}, int64(255)},
+		{"Uint16", []byte{0xcd, 0x01, 0x00}, int64(256)},
+		{"Int8", []byte{0xd0, 0x80}, int64(-128)},
+		{"Int16", []byte{0xd1, 0xff, 0x00}, int64(-256)},
+		{"Int32", []byte{0xd2, 0xff, 0xff, 0xff, 0xfe}, int64(-2)},
+		{"Float32", []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, 1.5},
+		{"Str8", []byte{0xd9, 0x02, 'o', 'k'}, "ok"},
+		{"Array16", []byte{0xdc, 0x00, 0x01, 0xc0}, []interface{}{nil}},
+		{"Map16", []byte{0xde, 0x00, 0x01, 0xa1, 'k', 0x01}, map[string]interface{}{"k": int64(1)}},
+		{"IntegerKey", []byte{0x81, 0x01, 0x02}, map[string]interface{}{"1": int64(2)}},
+		{"FixExt8EventTime", []byte{0xd7, 0x00, 0x5f, 0x2a, 0x85, 0xcc, 0x00, 0x00, 0x00, 0x01}, time.Unix(1596622284, 1)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := Unmarshal(tc.data)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+
+	errorCases := []struct {
+		name string
+		data []byte
+	}{
+		{"Truncated", []byte{0xa5, 'a'}},
+		{"InvalidType", []byte{0xc1}},
+		{"LengthTooLarge", []byte{0xdb, 0xff, 0xff, 0xff, 0xff}},
+		{"ArrayTooLarge", []byte{0xdd, 0x00, 0xff, 0xff, 0xff}},
+		{"TooDeep", bytes.Repeat([]byte{0x91}, maxDepth+2)},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Unmarshal(tc.data)
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		decoder := NewDecoder(bytes.NewReader([]byte{0x01, 0xa2, 'o', 'k', 0xc0}), 10)
+		for _, expected := range []interface{}{int64(1), "ok", nil} {
+			value, err := decoder.Decode()
+			require.NoError(t, err)
+			require.Equal(t, expected, value)
+		}
+		_, err := decoder.Decode()
+		require.Equal(t, io.EOF, err)
+	})
+
+	t.Run("MaxSize", func(t *testing.T) {
+		// The maximum size applies to each value of the stream
+		decoder := NewDecoder(bytes.NewReader([]byte{0xa3, 'a', 'b', 'c', 0xa4, 'a', 'b', 'c', 'd'}), 4)
+		value, err := decoder.Decode()
+		require.NoError(t, err)
+		require.Equal(t, "abc", value)
+		_, err = decoder.Decode()
+		require.Equal(t, errTooLarge, err)
+	})
+}
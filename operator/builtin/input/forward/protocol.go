@@ -0,0 +1,205 @@
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Message is a message of the forward protocol, in any of its modes
+type Message struct {
+	Tag     string
+	Entries []Entry
+
+	// Chunk is the id that the message must be acknowledged with, if the client requested it
+	Chunk string
+}
+
+// Entry is an event of a message
+type Entry struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// ParseMessage will parse a decoded msgpack value as a message in the Message, Forward,
+// PackedForward or CompressedPackedForward mode. The entries of packed messages are
+// decompressed and decoded up to maxSize bytes.
+func ParseMessage(value interface{}, maxSize int) (*Message, error) {
+	array, ok := value.([]interface{})
+	if !ok || len(array) < 2 {
+		return nil, fmt.Errorf("message is not an array of at least 2 elements")
+	}
+
+	tag, ok := array[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("tag is not a string")
+	}
+	msg := &Message{Tag: tag}
+
+	// The option is the last element of each mode, and is optional
+	var option map[string]interface{}
+	optionIndex := 2
+	if _, ok := array[1].([]interface{}); !ok && !isBinary(array[1]) {
+		optionIndex = 3
+	}
+	if len(array) > optionIndex {
+		if option, ok = array[optionIndex].(map[string]interface{}); !ok && array[optionIndex] != nil {
+			return nil, fmt.Errorf("option is not a map")
+		}
+	}
+	if chunk, ok := option["chunk"]; ok {
+		if msg.Chunk, ok = chunk.(string); !ok {
+			return nil, fmt.Errorf("chunk option is not a string")
+		}
+	}
+
+	switch entries := array[1].(type) {
+	case []interface{}:
+		// Forward mode has an array of entries
+		for _, value := range entries {
+			e, err := parseEntry(value)
+			if err != nil {
+				return nil, err
+			}
+			msg.Entries = append(msg.Entries, e)
+		}
+	case []byte, string:
+		// PackedForward mode has a stream of entries, which is compressed in CompressedPackedForward mode
+		stream, err := unpack(toBytes(entries), option["compressed"], maxSize)
+		if err != nil {
+			return nil, err
+		}
+		decoder := NewDecoder(bytes.NewReader(stream), maxSize)
+		for {
+			value, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("decode packed entries: %s", err)
+			}
+			e, err := parseEntry(value)
+			if err != nil {
+				return nil, err
+			}
+			msg.Entries = append(msg.Entries, e)
+		}
+	default:
+		// Message mode has the time and record of a single entry
+		if len(array) < 3 {
+			return nil, fmt.Errorf("message is missing a record")
+		}
+		e, err := parseEntry([]interface{}{array[1], array[2]})
+		if err != nil {
+			return nil, err
+		}
+		msg.Entries = append(msg.Entries, e)
+	}
+
+	return msg, nil
+}
+
+func isBinary(value interface{}) bool {
+	switch value.(type) {
+	case []byte, string:
+		return true
+	default:
+		return false
+	}
+}
+
+func toBytes(value interface{}) []byte {
+	if s, ok := value.(string); ok {
+		return []byte(s)
+	}
+	return value.([]byte)
+}
+
+// unpack will decompress the entries of a packed message with the compression of its option
+func unpack(entries []byte, compressed interface{}, maxSize int) ([]byte, error) {
+	switch compressed {
+	case nil, "text":
+		return entries, nil
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(entries))
+		if err != nil {
+			return nil, fmt.Errorf("decompress entries: %s", err)
+		}
+		defer reader.Close()
+
+		// The decompressed entries are limited, since a small payload can decompress to a large one
+		decompressed, err := ioutil.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+		if err != nil {
+			return nil, fmt.Errorf("decompress entries: %s", err)
+		}
+		if len(decompressed) > maxSize {
+			return nil, errTooLarge
+		}
+		return decompressed, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression '%v'", compressed)
+	}
+}
+
+// parseEntry will parse an entry, which is an array of its time and record
+func parseEntry(value interface{}) (Entry, error) {
+	array, ok := value.([]interface{})
+	if !ok || len(array) < 2 {
+		return Entry{}, fmt.Errorf("entry is not an array of a time and a record")
+	}
+
+	t, err := parseTime(array[0])
+	if err != nil {
+		return Entry{}, err
+	}
+
+	record, ok := array[1].(map[string]interface{})
+	if !ok {
+		return Entry{}, fmt.Errorf("record is not a map")
+	}
+	return Entry{Time: t, Record: normalize(record).(map[string]interface{})}, nil
+}
+
+// parseTime will parse the time of an entry, which is an EventTime or a number of seconds
+func parseTime(value interface{}) (time.Time, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, nil
+	case int64:
+		return time.Unix(t, 0), nil
+	case float64:
+		seconds, fraction := math.Modf(t)
+		return time.Unix(int64(seconds), int64(fraction*1e9)), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid time of type %T", value)
+	}
+}
+
+// normalize will convert binary values of a record to strings, like fluentd does, unless they
+// are not valid UTF-8
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalize(v[key])
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,117 @@
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func marshal(t *testing.T, value interface{}) []byte {
+	data, err := Marshal(value)
+	require.NoError(t, err)
+	return data
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestParseMessage(t *testing.T) {
+	eventTime := time.Unix(1596620460, 5000)
+	record := func(message string) map[string]interface{} {
+		return map[string]interface{}{"message": message}
+	}
+	entry := func(message string) []interface{} {
+		return []interface{}{eventTime, record(message)}
+	}
+	packed := append(marshal(t, entry("first")), marshal(t, entry("second"))...)
+
+	cases := []struct {
+		name     string
+		message  []interface{}
+		expected *Message
+	}{
+		{
+			"Message",
+			[]interface{}{"app.log", eventTime, record("first")},
+			&Message{Tag: "app.log", Entries: []Entry{{eventTime, record("first")}}},
+		},
+		{
+			"MessageIntegerTime",
+			[]interface{}{"app.log", int64(1596620460), record("first"), map[string]interface{}{"chunk": "abc"}},
+			&Message{Tag: "app.log", Entries: []Entry{{time.Unix(1596620460, 0), record("first")}}, Chunk: "abc"},
+		},
+		{
+			"Forward",
+			[]interface{}{"app.log", []interface{}{entry("first"), entry("second")}, map[string]interface{}{"chunk": "abc"}},
+			&Message{Tag: "app.log", Entries: []Entry{{eventTime, record("first")}, {eventTime, record("second")}}, Chunk: "abc"},
+		},
+		{
+			"PackedForward",
+			[]interface{}{"app.log", packed, map[string]interface{}{"size": int64(2)}},
+			&Message{Tag: "app.log", Entries: []Entry{{eventTime, record("first")}, {eventTime, record("second")}}},
+		},
+		{
+			"PackedForwardString",
+			[]interface{}{"app.log", string(packed)},
+			&Message{Tag: "app.log", Entries: []Entry{{eventTime, record("first")}, {eventTime, record("second")}}},
+		},
+		{
+			"CompressedPackedForward",
+			[]interface{}{"app.log", gzipData(t, packed), map[string]interface{}{"compressed": "gzip", "chunk": "abc"}},
+			&Message{Tag: "app.log", Entries: []Entry{{eventTime, record("first")}, {eventTime, record("second")}}, Chunk: "abc"},
+		},
+		{
+			"BinaryValues",
+			[]interface{}{"app.log", eventTime, map[string]interface{}{"log": []byte("text"), "raw": []byte{0xff}}},
+			&Message{Tag: "app.log", Entries: []Entry{{eventTime, map[string]interface{}{"log": "text", "raw": []byte{0xff}}}}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The message is encoded and decoded, as it would be received
+			value, err := Unmarshal(marshal(t, tc.message))
+			require.NoError(t, err)
+			msg, err := ParseMessage(value, 1024)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, msg)
+		})
+	}
+
+	errorCases := []struct {
+		name    string
+		message interface{}
+	}{
+		{"NotArray", map[string]interface{}{}},
+		{"MissingRecord", []interface{}{"app.log", eventTime}},
+		{"InvalidTag", []interface{}{int64(1), eventTime, record("first")}},
+		{"InvalidTime", []interface{}{"app.log", true, record("first")}},
+		{"InvalidRecord", []interface{}{"app.log", eventTime, "first"}},
+		{"InvalidOption", []interface{}{"app.log", eventTime, record("first"), "option"}},
+		{"InvalidChunk", []interface{}{"app.log", eventTime, record("first"), map[string]interface{}{"chunk": int64(1)}}},
+		{"InvalidEntry", []interface{}{"app.log", []interface{}{"entry"}}},
+		{"InvalidPackedEntries", []interface{}{"app.log", []byte{0xc1}}},
+		{"InvalidCompression", []interface{}{"app.log", packed, map[string]interface{}{"compressed": "zip"}}},
+		{"InvalidGzip", []interface{}{"app.log", packed, map[string]interface{}{"compressed": "gzip"}}},
+		{"DecompressedTooLarge", []interface{}{"app.log", gzipData(t, marshal(t, entry(strings.Repeat("a", 2000)))), map[string]interface{}{"compressed": "gzip"}}},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := Unmarshal(marshal(t, tc.message))
+			require.NoError(t, err)
+			_, err = ParseMessage(value, 1024)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package input
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/forward"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardInput(t *testing.T) {
+	basicForwardInputConfig := func() *ForwardInputConfig {
+		cfg := NewForwardInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		cfg.ListenAddress = "127.0.0.1:0"
+		cfg.SelfHostname = "server"
+		return cfg
+	}
+
+	// startForwardInput will start a forward input, and return a connection to it and a channel of the entries it writes
+	startForwardInput := func(t *testing.T, cfg *ForwardInputConfig) (*ForwardInput, net.Conn, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		forwardInput := newOperator.(*ForwardInput)
+		forwardInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, forwardInput.Start())
+		conn, err := net.Dial("tcp", forwardInput.listener.Addr().String())
+		require.NoError(t, err)
+		return forwardInput, conn, entryChan
+	}
+
+	send := func(t *testing.T, conn net.Conn, value interface{}) {
+		data, err := forward.Marshal(value)
+		require.NoError(t, err)
+		_, err = conn.Write(data)
+		require.NoError(t, err)
+	}
+
+	receive := func(t *testing.T, conn net.Conn) interface{} {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		value, err := forward.NewDecoder(conn, 1024).Decode()
+		require.NoError(t, err)
+		return value
+	}
+
+	expectEntry := func(t *testing.T, entryChan chan *entry.Entry) *entry.Entry {
+		select {
+		case e := <-entryChan:
+			return e
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for entry to be written")
+			return nil
+		}
+	}
+
+	eventTime := time.Unix(1596620460, 5000)
+
+	t.Run("Message", func(t *testing.T) {
+		forwardInput, conn, entryChan := startForwardInput(t, basicForwardInputConfig())
+		defer forwardInput.Stop()
+		defer conn.Close()
+
+		send(t, conn, []interface{}{"docker.web", eventTime, map[string]interface{}{"log": "GET /", "container_name": "/web"}})
+
+		e := expectEntry(t, entryChan)
+		require.Equal(t, map[string]interface{}{"log": "GET /", "container_name": "/web"}, e.Record)
+		require.Equal(t, eventTime, e.Timestamp)
+		require.Equal(t, map[string]string{"tag": "docker.web"}, e.Labels)
+	})
+
+	t.Run("ForwardWithAck", func(t *testing.T) {
+		forwardInput, conn, entryChan := startForwardInput(t, basicForwardInputConfig())
+		defer forwardInput.Stop()
+		defer conn.Close()
+
+		send(t, conn, []interface{}{
+			"app",
+			[]interface{}{
+				[]interface{}{eventTime, map[string]interface{}{"message": "first"}},
+				[]interface{}{eventTime, map[string]interface{}{"message": "second"}},
+			},
+			map[string]interface{}{"chunk": "p8n9gmxTQVC8/nh2wlKKeQ=="},
+		})
+
+		require.Equal(t, "first", expectEntry(t, entryChan).Record.(map[string]interface{})["message"])
+		require.Equal(t, "second", expectEntry(t, entryChan).Record.(map[string]interface{})["message"])
+		require.Equal(t, map[string]interface{}{"ack": "p8n9gmxTQVC8/nh2wlKKeQ=="}, receive(t, conn))
+	})
+
+	t.Run("InvalidMessage", func(t *testing.T) {
+		forwardInput, conn, _ := startForwardInput(t, basicForwardInputConfig())
+		defer forwardInput.Stop()
+		defer conn.Close()
+
+		// The connection is closed
+		send(t, conn, []interface{}{"app"})
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, err := conn.Read(make([]byte, 1))
+		require.Error(t, err)
+		require.False(t, isTimeout(err))
+	})
+
+	t.Run("SharedKey", func(t *testing.T) {
+		cfg := basicForwardInputConfig()
+		cfg.SharedKey = "key"
+		cfg.Users = []ForwardUserConfig{{Username: "fluent", Password: "secret"}}
+
+		handshake := func(t *testing.T, conn net.Conn, sharedKey, password string) []interface{} {
+			helo := receive(t, conn).([]interface{})
+			require.Equal(t, "HELO", helo[0])
+			options := helo[1].(map[string]interface{})
+			nonce, authSalt := string(options["nonce"].([]byte)), string(options["auth"].([]byte))
+
+			send(t, conn, []interface{}{
+				"PING",
+				"client",
+				"salt",
+				forward.Digest("salt", "client", nonce, sharedKey),
+				"fluent",
+				forward.Digest(authSalt, "fluent", password),
+			})
+			return receive(t, conn).([]interface{})
+		}
+
+		t.Run("Authenticated", func(t *testing.T) {
+			forwardInput, conn, entryChan := startForwardInput(t, cfg)
+			defer forwardInput.Stop()
+			defer conn.Close()
+
+			pong := handshake(t, conn, "key", "secret")
+			require.Equal(t, true, pong[1])
+			require.Equal(t, "server", pong[3])
+
+			send(t, conn, []interface{}{"app", eventTime, map[string]interface{}{"message": "authenticated"}})
+			require.Equal(t, "authenticated", expectEntry(t, entryChan).Record.(map[string]interface{})["message"])
+		})
+
+		t.Run("WrongPassword", func(t *testing.T) {
+			forwardInput, conn, _ := startForwardInput(t, cfg)
+			defer forwardInput.Stop()
+			defer conn.Close()
+
+			pong := handshake(t, conn, "key", "wrong")
+			require.Equal(t, false, pong[1])
+			require.Equal(t, "username/password mismatch", pong[2])
+		})
+
+		t.Run("WrongSharedKey", func(t *testing.T) {
+			forwardInput, conn, _ := startForwardInput(t, cfg)
+			defer forwardInput.Stop()
+			defer conn.Close()
+
+			pong := handshake(t, conn, "other", "secret")
+			require.Equal(t, false, pong[1])
+			require.Equal(t, "shared key mismatch", pong[2])
+		})
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*ForwardInputConfig)
+		}{
+			{"MissingListenAddress", func(c *ForwardInputConfig) { c.ListenAddress = "" }},
+			{"InvalidListenAddress", func(c *ForwardInputConfig) { c.ListenAddress = "localhost:port" }},
+			{"ZeroMaxMessageSize", func(c *ForwardInputConfig) { c.MaxMessageSize = 0 }},
+			{"UsersWithoutSharedKey", func(c *ForwardInputConfig) { c.Users = []ForwardUserConfig{{Username: "fluent"}} }},
+			{"MissingUsername", func(c *ForwardInputConfig) {
+				c.SharedKey = "key"
+				c.Users = []ForwardUserConfig{{Password: "secret"}}
+			}},
+			{"DuplicateUser", func(c *ForwardInputConfig) {
+				c.SharedKey = "key"
+				c.Users = []ForwardUserConfig{{Username: "fluent"}, {Username: "fluent"}}
+			}},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicForwardInputConfig()
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
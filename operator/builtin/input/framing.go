@@ -0,0 +1,146 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/observiq/carbon/operator/builtin/input/file"
+	"golang.org/x/text/encoding"
+)
+
+const (
+	framingNewline       = "newline"
+	framingOctetCounting = "octet_counting"
+	framingNull          = "null"
+	framingMultiline     = "multiline"
+	framingAuto          = "auto"
+)
+
+// defaultMaxMessageSize is the default maximum size of a message received over a stream
+const defaultMaxMessageSize = 1024 * 1024
+
+// newFramingSplitFunc will return a split function that splits a stream into messages using the framing method
+func newFramingSplitFunc(framing string, multiline *file.MultilineConfig, maxMessageSize int) (bufio.SplitFunc, error) {
+	if multiline != nil && framing != framingMultiline {
+		return nil, fmt.Errorf("multiline can only be used with framing '%s'", framingMultiline)
+	}
+
+	switch framing {
+	case framingNewline, "":
+		splitFunc, err := file.NewNewlineSplitFunc(encoding.Nop)
+		if err != nil {
+			return nil, err
+		}
+		return flushAtEOF(splitFunc), nil
+	case framingOctetCounting:
+		return newOctetCountingSplitFunc(maxMessageSize), nil
+	case framingNull:
+		return flushAtEOF(nullSplitFunc), nil
+	case framingMultiline:
+		splitFunc, err := newMultilineSplitFunc(multiline)
+		if err != nil {
+			return nil, err
+		}
+		return flushAtEOF(splitFunc), nil
+	case framingAuto:
+		splitFunc, err := file.NewNewlineSplitFunc(encoding.Nop)
+		if err != nil {
+			return nil, err
+		}
+		return newAutoSplitFunc(newOctetCountingSplitFunc(maxMessageSize), flushAtEOF(splitFunc)), nil
+	default:
+		return nil, fmt.Errorf("invalid framing '%s'", framing)
+	}
+}
+
+// newMultilineSplitFunc will return a split function for the patterns of a multiline config
+func newMultilineSplitFunc(multiline *file.MultilineConfig) (bufio.SplitFunc, error) {
+	if multiline == nil {
+		return nil, fmt.Errorf("framing '%s' requires a multiline configuration", framingMultiline)
+	}
+
+	// The data remaining when a connection is closed is always sent, but idle connections are not flushed
+	if multiline.ForceFlushPeriod.Raw() != 0 {
+		return nil, fmt.Errorf("multiline force_flush_period is not supported for streams")
+	}
+
+	return multiline.SplitFunc()
+}
+
+// flushAtEOF will wrap a split function so that the data remaining when a stream is closed is returned as a message
+func flushAtEOF(splitFunc bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = splitFunc(data, atEOF)
+		if err == nil && advance == 0 && token == nil && atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return
+	}
+}
+
+// nullSplitFunc splits a stream into messages that are terminated by a null byte
+func nullSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	return 0, nil, nil
+}
+
+// newOctetCountingSplitFunc will return a split function for messages framed as `<length> <message>`,
+// as described by RFC 6587. A stream with an invalid length can not be split further, so it is an error.
+func newOctetCountingSplitFunc(maxMessageSize int) bufio.SplitFunc {
+	maxDigits := len(strconv.Itoa(maxMessageSize))
+
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		// Some senders terminate each frame with a newline, which is not part of the next length
+		if len(data) > 0 && (data[0] == '\n' || data[0] == '\r') {
+			return 1, nil, nil
+		}
+
+		space := bytes.IndexByte(data, ' ')
+		if space < 0 {
+			if len(data) > maxDigits {
+				return 0, nil, fmt.Errorf("invalid octet count")
+			}
+			return 0, nil, nil
+		}
+
+		length := 0
+		for _, digit := range data[:space] {
+			if digit < '0' || digit > '9' {
+				return 0, nil, fmt.Errorf("invalid octet count '%s'", data[:space])
+			}
+			length = length*10 + int(digit-'0')
+			if length > maxMessageSize {
+				return 0, nil, fmt.Errorf("message of %s bytes exceeds max_message_size", data[:space])
+			}
+		}
+		if space == 0 || length == 0 {
+			return 0, nil, fmt.Errorf("invalid octet count '%s'", data[:space])
+		}
+
+		end := space + 1 + length
+		if len(data) < end {
+			return 0, nil, nil
+		}
+		return end, data[space+1 : end], nil
+	}
+}
+
+// newAutoSplitFunc will return a split function that splits each message with octet counting if it begins with
+// a digit, and with the newline split function otherwise. This is how syslog servers receive messages over tcp.
+func newAutoSplitFunc(octetCounting, newline bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		// Skip the line endings between messages, which can not begin a message of either framing
+		if len(data) > 0 && (data[0] == '\n' || data[0] == '\r') {
+			return 1, nil, nil
+		}
+
+		if len(data) > 0 && data[0] >= '0' && data[0] <= '9' {
+			return octetCounting(data, atEOF)
+		}
+		return newline(data, atEOF)
+	}
+}
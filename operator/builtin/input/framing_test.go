@@ -0,0 +1,86 @@
+package input
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func scanMessages(splitFunc bufio.SplitFunc, input string) ([]string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	scanner.Split(splitFunc)
+
+	var messages []string
+	for scanner.Scan() {
+		messages = append(messages, scanner.Text())
+	}
+	return messages, scanner.Err()
+}
+
+func TestOctetCountingSplitFunc(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		expected  []string
+		expectErr bool
+	}{
+		{"Single", "5 hello", []string{"hello"}, false},
+		{"Multiple", "5 hello5 world", []string{"hello", "world"}, false},
+		{"TrailingNewlines", "5 hello\n5 world\r\n", []string{"hello", "world"}, false},
+		{"ContainsNewline", "11 hello\nworld", []string{"hello\nworld"}, false},
+		{"InvalidCount", "5x hello", nil, true},
+		{"MissingCount", " hello", nil, true},
+		{"ZeroCount", "0 hello", nil, true},
+		{"TooLarge", "101 hello", nil, true},
+		{"CountWithoutSpace", "123456 hello", nil, true},
+		{"Truncated", "10 hello", nil, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages, err := scanMessages(newOctetCountingSplitFunc(100), tc.input)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestNewFramingSplitFunc(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		splitFunc, err := newFramingSplitFunc("", nil, defaultMaxMessageSize)
+		require.NoError(t, err)
+
+		messages, err := scanMessages(splitFunc, "message1\nmessage2")
+		require.NoError(t, err)
+		require.Equal(t, []string{"message1", "message2"}, messages)
+	})
+
+	t.Run("Null", func(t *testing.T) {
+		splitFunc, err := newFramingSplitFunc(framingNull, nil, defaultMaxMessageSize)
+		require.NoError(t, err)
+
+		messages, err := scanMessages(splitFunc, "message1\x00message2\x00")
+		require.NoError(t, err)
+		require.Equal(t, []string{"message1", "message2"}, messages)
+	})
+
+	t.Run("Auto", func(t *testing.T) {
+		splitFunc, err := newFramingSplitFunc(framingAuto, nil, defaultMaxMessageSize)
+		require.NoError(t, err)
+
+		messages, err := scanMessages(splitFunc, "<34>newline\n11 <34>counted<34>newline\r\n\n14 <34>multi\nline")
+		require.NoError(t, err)
+		require.Equal(t, []string{"<34>newline", "<34>counted", "<34>newline", "<34>multi\nline"}, messages)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := newFramingSplitFunc("length", nil, defaultMaxMessageSize)
+		require.Error(t, err)
+	})
+}
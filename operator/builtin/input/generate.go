@@ -1,9 +1,15 @@
 package input
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/observiq/carbon/entry"
@@ -24,9 +30,14 @@ func NewGenerateInputConfig(operatorID string) *GenerateInputConfig {
 // GenerateInputConfig is the configuration of a generate input operator.
 type GenerateInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
-	Entry              entry.Entry `json:"entry"           yaml:"entry"`
-	Count              int         `json:"count,omitempty" yaml:"count,omitempty"`
-	Static             bool        `json:"static"          yaml:"static,omitempty"`
+	Entry              entry.Entry       `json:"entry"                 yaml:"entry"`
+	Count              int               `json:"count,omitempty"       yaml:"count,omitempty"`
+	Static             bool              `json:"static"                yaml:"static,omitempty"`
+	Templated          bool              `json:"templated,omitempty"   yaml:"templated,omitempty"`
+	CorpusFile         string            `json:"corpus_file,omitempty" yaml:"corpus_file,omitempty"`
+	Rate               float64           `json:"rate,omitempty"        yaml:"rate,omitempty"`
+	Duration           operator.Duration `json:"duration,omitempty"    yaml:"duration,omitempty"`
+	Seed               int64             `json:"seed,omitempty"        yaml:"seed,omitempty"`
 }
 
 // Build will build a generate input operator.
@@ -36,6 +47,26 @@ func (c *GenerateInputConfig) Build(context operator.BuildContext) (operator.Ope
 		return nil, err
 	}
 
+	if context.Once && c.Count == 0 && c.Duration.Raw() == 0 {
+		return nil, fmt.Errorf("count or duration must be set to run generate_input once")
+	}
+
+	if c.Count < 0 {
+		return nil, fmt.Errorf("count must not be negative")
+	}
+
+	if c.Rate < 0 {
+		return nil, fmt.Errorf("rate must not be negative")
+	}
+
+	if c.Duration.Raw() < 0 {
+		return nil, fmt.Errorf("duration must not be negative")
+	}
+
+	if c.CorpusFile != "" && !c.Templated {
+		return nil, fmt.Errorf("corpus_file can only be configured with templated")
+	}
+
 	c.Entry.Record = recursiveMapInterfaceToMapString(c.Entry.Record)
 
 	generateInput := &GenerateInput{
@@ -43,18 +74,40 @@ func (c *GenerateInputConfig) Build(context operator.BuildContext) (operator.Ope
 		entry:         c.Entry,
 		count:         c.Count,
 		static:        c.Static,
+		rate:          c.Rate,
+		duration:      c.Duration.Raw(),
+		seed:          c.Seed,
+	}
+
+	if c.Templated {
+		generateInput.generator = &entryGenerator{}
+		if c.CorpusFile != "" {
+			generateInput.generator.corpus, err = readCorpus(c.CorpusFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := generateInput.generator.compile(c.Entry); err != nil {
+			return nil, err
+		}
 	}
+
 	return generateInput, nil
 }
 
 // GenerateInput is an operator that generates log entries.
 type GenerateInput struct {
 	helper.InputOperator
-	entry  entry.Entry
-	count  int
-	static bool
-	cancel context.CancelFunc
-	wg     *sync.WaitGroup
+	entry     entry.Entry
+	count     int
+	static    bool
+	rate      float64
+	duration  time.Duration
+	seed      int64
+	generator *entryGenerator
+	cancel    context.CancelFunc
+	wg        *sync.WaitGroup
+	finished  chan struct{}
 }
 
 // Start will start generating log entries.
@@ -62,21 +115,61 @@ func (g *GenerateInput) Start() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	g.cancel = cancel
 	g.wg = &sync.WaitGroup{}
+	g.finished = make(chan struct{})
+
+	if g.generator != nil {
+		seed := g.seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		g.generator.reset(seed)
+	}
 
 	g.wg.Add(1)
 	go func() {
 		defer g.wg.Done()
+		defer close(g.finished)
+
+		start := time.Now()
+		var deadline <-chan time.Time
+		if g.duration > 0 {
+			timer := time.NewTimer(g.duration)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
 		i := 0
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-deadline:
+				return
 			default:
 			}
 
-			entry := g.entry.Copy()
-			if !g.static {
-				entry.Timestamp = time.Now()
+			// Entries are paced against the start time, so that entries that are late
+			// because of a slow pipeline are caught up on without waiting
+			if g.rate > 0 {
+				next := start.Add(time.Duration(float64(i) / g.rate * float64(time.Second)))
+				if wait := time.Until(next); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return
+					case <-deadline:
+						timer.Stop()
+						return
+					case <-timer.C:
+					}
+				}
+			}
+
+			entry, err := g.nextEntry()
+			if err != nil {
+				g.Errorf("Failed to render entry: %s", err)
+				return
 			}
 			g.Write(ctx, entry)
 
@@ -90,6 +183,24 @@ func (g *GenerateInput) Start() error {
 	return nil
 }
 
+// nextEntry will return the next entry to write
+func (g *GenerateInput) nextEntry() (*entry.Entry, error) {
+	var e *entry.Entry
+	if g.generator != nil {
+		var err error
+		if e, err = g.generator.render(g.entry); err != nil {
+			return nil, err
+		}
+	} else {
+		e = g.entry.Copy()
+	}
+
+	if !g.static {
+		e.Timestamp = time.Now()
+	}
+	return e, nil
+}
+
 // Stop will stop generating logs.
 func (g *GenerateInput) Stop() error {
 	g.cancel()
@@ -97,6 +208,233 @@ func (g *GenerateInput) Stop() error {
 	return nil
 }
 
+// Finished returns a channel that is closed once count entries have been generated, or the
+// duration has passed
+func (g *GenerateInput) Finished() <-chan struct{} {
+	return g.finished
+}
+
+// entryGenerator renders the templates in the record and labels of an entry. Templates
+// are rendered by a single goroutine, which owns the random source.
+type entryGenerator struct {
+	corpus    []string
+	random    *rand.Rand
+	sequence  int64
+	templates map[string]*template.Template
+}
+
+// compile will parse the templates in the record and label values of an entry
+func (g *entryGenerator) compile(e entry.Entry) error {
+	g.templates = make(map[string]*template.Template)
+	funcs := g.funcs()
+
+	var compileValue func(value interface{}) error
+	compileValue = func(value interface{}) error {
+		switch v := value.(type) {
+		case string:
+			if !strings.Contains(v, "{{") {
+				return nil
+			}
+			if _, ok := g.templates[v]; ok {
+				return nil
+			}
+			tmpl, err := template.New("").Funcs(funcs).Parse(v)
+			if err != nil {
+				return fmt.Errorf("parse template '%s': %s", v, err)
+			}
+			g.templates[v] = tmpl
+		case map[string]interface{}:
+			for _, element := range v {
+				if err := compileValue(element); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, element := range v {
+				if err := compileValue(element); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := compileValue(e.Record); err != nil {
+		return err
+	}
+	for _, value := range e.Labels {
+		if err := compileValue(value); err != nil {
+			return err
+		}
+	}
+
+	// The entry is rendered once, so that templates that fail to execute, such as with invalid
+	// arguments, are rejected when they are built
+	g.reset(1)
+	if _, err := g.render(e); err != nil {
+		return fmt.Errorf("render template: %s", err)
+	}
+	return nil
+}
+
+// reset will reset the random source and sequence of the generator
+func (g *entryGenerator) reset(seed int64) {
+	g.random = rand.New(rand.NewSource(seed))
+	g.sequence = 0
+}
+
+// render will return a copy of an entry, with its templates rendered. Values are rendered in
+// the order of their keys, so that the same seed generates the same entries.
+func (g *entryGenerator) render(e entry.Entry) (*entry.Entry, error) {
+	g.sequence++
+
+	var err error
+	rendered := e.Copy()
+	if rendered.Record, err = g.renderValue(e.Record); err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(e.Labels) {
+		if rendered.Labels[key], err = g.renderString(e.Labels[key]); err != nil {
+			return nil, err
+		}
+	}
+	return rendered, nil
+}
+
+func (g *entryGenerator) renderValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return g.renderString(v)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for _, key := range sortedKeys(v) {
+			renderedElement, err := g.renderValue(v[key])
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = renderedElement
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			renderedElement, err := g.renderValue(element)
+			if err != nil {
+				return nil, err
+			}
+			rendered = append(rendered, renderedElement)
+		}
+		return rendered, nil
+	default:
+		return v, nil
+	}
+}
+
+func (g *entryGenerator) renderString(value string) (string, error) {
+	tmpl, ok := g.templates[value]
+	if !ok {
+		return value, nil
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// funcs returns the functions that templates can use to generate values
+func (g *entryGenerator) funcs() template.FuncMap {
+	return template.FuncMap{
+		"randInt": func(min, max int) (int, error) {
+			if max < min {
+				return 0, fmt.Errorf("randInt max %d is less than min %d", max, min)
+			}
+			return min + g.random.Intn(max-min+1), nil
+		},
+		"randFloat": func(min, max float64) (float64, error) {
+			if max < min {
+				return 0, fmt.Errorf("randFloat max %g is less than min %g", max, min)
+			}
+			return min + g.random.Float64()*(max-min), nil
+		},
+		"choice": func(values ...interface{}) (interface{}, error) {
+			if len(values) == 0 {
+				return nil, fmt.Errorf("choice requires at least one value")
+			}
+			return values[g.random.Intn(len(values))], nil
+		},
+		"uuid": func() string {
+			var b [16]byte
+			_, _ = g.random.Read(b[:])
+			b[6] = b[6]&0x0f | 0x40
+			b[8] = b[8]&0x3f | 0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+		},
+		"timestamp": func(layout ...string) (string, error) {
+			switch len(layout) {
+			case 0:
+				return time.Now().Format(time.RFC3339Nano), nil
+			case 1:
+				return time.Now().Format(layout[0]), nil
+			default:
+				return "", fmt.Errorf("timestamp accepts at most one layout")
+			}
+		},
+		"corpus": func() (string, error) {
+			if len(g.corpus) == 0 {
+				return "", fmt.Errorf("corpus requires a corpus_file")
+			}
+			return g.corpus[g.random.Intn(len(g.corpus))], nil
+		},
+		"seq": func() int64 {
+			return g.sequence
+		},
+	}
+}
+
+// sortedKeys will return the sorted keys of a map of strings or values
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]interface{}:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readCorpus will read the non-empty lines of a corpus file
+func readCorpus(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open corpus_file: %s", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read corpus_file: %s", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("corpus_file '%s' does not contain any lines", path)
+	}
+	return lines, nil
+}
+
 func recursiveMapInterfaceToMapString(m interface{}) interface{} {
 	switch m := m.(type) {
 	case map[string]interface{}:
@@ -115,6 +453,12 @@ func recursiveMapInterfaceToMapString(m interface{}) interface{} {
 			newMap[kStr] = recursiveMapInterfaceToMapString(v)
 		}
 		return newMap
+	case []interface{}:
+		newArray := make([]interface{}, 0, len(m))
+		for _, v := range m {
+			newArray = append(newArray, recursiveMapInterfaceToMapString(v))
+		}
+		return newArray
 	default:
 		return m
 	}
@@ -1,6 +1,9 @@
 package input
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"text/template"
 	"time"
@@ -51,6 +54,186 @@ func TestInputGenerate(t *testing.T) {
 			require.FailNow(t, "Timed out waiting for generated entries")
 		}
 	}
+
+	select {
+	case <-generateInput.Finished():
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for generate input to finish")
+	}
+}
+
+func TestInputGenerateOnceRequiresCount(t *testing.T) {
+	cfg := NewGenerateInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Entry = entry.Entry{
+		Record: "test message",
+	}
+
+	buildContext := testutil.NewBuildContext(t)
+	buildContext.Once = true
+	_, err := cfg.Build(buildContext)
+	require.Error(t, err)
+
+	cfg.Count = 1
+	_, err = cfg.Build(buildContext)
+	require.NoError(t, err)
+
+	cfg.Count = 0
+	cfg.Duration = operator.Duration{Duration: time.Second}
+	_, err = cfg.Build(buildContext)
+	require.NoError(t, err)
+}
+
+// startGenerateInput will build and start a generate input, returning a channel of the entries it writes
+func startGenerateInput(t *testing.T, cfg *GenerateInputConfig) (*GenerateInput, chan *entry.Entry) {
+	newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	receivedEntries := make(chan *entry.Entry, 1000)
+	mockOutput := testutil.NewMockOperator("output1")
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		receivedEntries <- args.Get(1).(*entry.Entry)
+	})
+
+	generateInput := newOperator.(*GenerateInput)
+	require.NoError(t, generateInput.SetOutputs([]operator.Operator{mockOutput}))
+	require.NoError(t, generateInput.Start())
+	return generateInput, receivedEntries
+}
+
+func TestInputGenerateTemplated(t *testing.T) {
+	tempDir := testutil.NewTempDir(t)
+	corpusFile := filepath.Join(tempDir, "corpus.log")
+	require.NoError(t, ioutil.WriteFile(corpusFile, []byte("line one\n\nline two\n"), 0666))
+
+	basicConfig := func() *GenerateInputConfig {
+		cfg := NewGenerateInputConfig("test_operator_id")
+		cfg.OutputIDs = []string{"output1"}
+		cfg.Templated = true
+		cfg.CorpusFile = corpusFile
+		cfg.Seed = 1
+		cfg.Count = 20
+		cfg.Entry = entry.Entry{
+			Record: map[interface{}]interface{}{
+				"status":  `{{ choice "200" "404" "500" }}`,
+				"latency": "{{ randInt 1 5 }}",
+				"ratio":   "{{ printf \"%.1f\" (randFloat 0 1) }}",
+				"id":      "{{ uuid }}",
+				"time":    `{{ timestamp "2006" }}`,
+				"message": "{{ corpus }}",
+				"seq":     "{{ seq }}",
+				"static":  "static",
+				"nested":  []interface{}{map[interface{}]interface{}{"count": 1, "level": `{{ choice "info" }}`}},
+			},
+			Labels: map[string]string{
+				"host": `host-{{ randInt 1 1 }}`,
+			},
+		}
+		return cfg
+	}
+
+	generateInput, receivedEntries := startGenerateInput(t, basicConfig())
+	<-generateInput.Finished()
+	require.NoError(t, generateInput.Stop())
+	require.Len(t, receivedEntries, 20)
+
+	var records []interface{}
+	for i := 1; i <= 20; i++ {
+		e := <-receivedEntries
+		record := e.Record.(map[string]interface{})
+		require.Contains(t, []string{"200", "404", "500"}, record["status"])
+		require.Contains(t, []string{"1", "2", "3", "4", "5"}, record["latency"])
+		require.Regexp(t, `^0\.\d$|^1\.0$`, record["ratio"])
+		require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, record["id"])
+		require.Equal(t, time.Now().Format("2006"), record["time"])
+		require.Contains(t, []string{"line one", "line two"}, record["message"])
+		require.Equal(t, strconv.Itoa(i), record["seq"])
+		require.Equal(t, "static", record["static"])
+		require.Equal(t, []interface{}{map[string]interface{}{"count": 1, "level": "info"}}, record["nested"])
+		require.Equal(t, map[string]string{"host": "host-1"}, e.Labels)
+
+		delete(record, "time")
+		records = append(records, record)
+	}
+
+	// The same seed generates the same records
+	generateInput, receivedEntries = startGenerateInput(t, basicConfig())
+	<-generateInput.Finished()
+	require.NoError(t, generateInput.Stop())
+	for _, expected := range records {
+		record := (<-receivedEntries).Record.(map[string]interface{})
+		delete(record, "time")
+		require.Equal(t, expected, record)
+	}
+}
+
+func TestInputGenerateTemplatedInvalid(t *testing.T) {
+	cases := map[string]func(*GenerateInputConfig){
+		"ParseError":        func(cfg *GenerateInputConfig) { cfg.Entry.Record = "{{ randInt 1" },
+		"UnknownFunction":   func(cfg *GenerateInputConfig) { cfg.Entry.Record = "{{ unknown }}" },
+		"InvalidRange":      func(cfg *GenerateInputConfig) { cfg.Entry.Record = "{{ randInt 5 1 }}" },
+		"CorpusWithoutFile": func(cfg *GenerateInputConfig) { cfg.Entry.Record = "{{ corpus }}" },
+		"CorpusNotTemplated": func(cfg *GenerateInputConfig) {
+			cfg.Templated = false
+			cfg.CorpusFile = "/does/not/matter"
+		},
+		"MissingCorpusFile": func(cfg *GenerateInputConfig) { cfg.CorpusFile = "/does/not/exist" },
+		"NegativeRate":      func(cfg *GenerateInputConfig) { cfg.Rate = -1 },
+	}
+
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := NewGenerateInputConfig("test_operator_id")
+			cfg.OutputIDs = []string{"output1"}
+			cfg.Templated = true
+			cfg.Entry = entry.Entry{Record: "test message"}
+			modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestInputGenerateRate(t *testing.T) {
+	cfg := NewGenerateInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Entry = entry.Entry{Record: "test message"}
+	cfg.Rate = 100
+	cfg.Count = 20
+
+	start := time.Now()
+	generateInput, receivedEntries := startGenerateInput(t, cfg)
+	defer generateInput.Stop()
+
+	select {
+	case <-generateInput.Finished():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for generate input to finish")
+	}
+
+	// The first entry is written immediately, and the rest are paced at 10ms intervals
+	require.Len(t, receivedEntries, 20)
+	require.True(t, time.Since(start) >= 190*time.Millisecond, "finished after %s", time.Since(start))
+}
+
+func TestInputGenerateDuration(t *testing.T) {
+	cfg := NewGenerateInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Entry = entry.Entry{Record: "test message"}
+	cfg.Rate = 100
+	cfg.Duration = operator.Duration{Duration: 100 * time.Millisecond}
+
+	generateInput, receivedEntries := startGenerateInput(t, cfg)
+	defer generateInput.Stop()
+
+	select {
+	case <-generateInput.Finished():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for generate input to finish")
+	}
+
+	count := len(receivedEntries)
+	require.True(t, count >= 5 && count <= 12, "generated %d entries", count)
 }
 
 func TestRenderFromPluginTemplate(t *testing.T) {
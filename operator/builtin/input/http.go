@@ -0,0 +1,334 @@
+package input
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("http_input", func() operator.Builder { return NewHTTPInputConfig("") })
+}
+
+// acceptedRecordsHeader is the header of a 429 response with the number of records of the request that were
+// accepted before the backpressure timeout
+const acceptedRecordsHeader = "X-Accepted-Records"
+
+func NewHTTPInputConfig(operatorID string) *HTTPInputConfig {
+	return &HTTPInputConfig{
+		InputConfig:         helper.NewInputConfig(operatorID, "http_input"),
+		MaxBodySize:         10 * 1024 * 1024,
+		BackpressureTimeout: operator.Duration{Duration: 5 * time.Second},
+	}
+}
+
+// HTTPInputConfig is the configuration of an http input operator.
+type HTTPInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	ListenAddress       string            `json:"listen_address,omitempty"       yaml:"listen_address,omitempty"`
+	TLS                 *helper.TLSConfig `json:"tls,omitempty"                  yaml:"tls,omitempty"`
+	Auth                *HTTPAuthConfig   `json:"auth,omitempty"                 yaml:"auth,omitempty"`
+	MaxBodySize         int64             `json:"max_body_size,omitempty"        yaml:"max_body_size,omitempty"`
+	BackpressureTimeout operator.Duration `json:"backpressure_timeout,omitempty" yaml:"backpressure_timeout,omitempty"`
+	HeaderLabels        map[string]string `json:"header_labels,omitempty"        yaml:"header_labels,omitempty"`
+	QueryLabels         map[string]string `json:"query_labels,omitempty"         yaml:"query_labels,omitempty"`
+}
+
+// HTTPAuthConfig is the configuration of the credentials required by an http input.
+// Either a bearer token or a username and password can be required.
+type HTTPAuthConfig struct {
+	BearerToken string `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	Username    string `json:"username,omitempty"     yaml:"username,omitempty"`
+	Password    string `json:"password,omitempty"     yaml:"password,omitempty"`
+}
+
+// Build will build an http input operator.
+func (c HTTPInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ListenAddress == "" {
+		return nil, fmt.Errorf("missing required parameter 'listen_address'")
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		return nil, fmt.Errorf("invalid listen_address: %s", err)
+	}
+
+	if c.Auth != nil {
+		switch {
+		case c.Auth.BearerToken != "" && (c.Auth.Username != "" || c.Auth.Password != ""):
+			return nil, fmt.Errorf("auth can not contain both a bearer_token and a username and password")
+		case c.Auth.BearerToken == "" && (c.Auth.Username == "" || c.Auth.Password == ""):
+			return nil, fmt.Errorf("auth requires either a bearer_token or a username and password")
+		}
+	}
+
+	if c.MaxBodySize <= 0 {
+		return nil, fmt.Errorf("max_body_size must be greater than 0")
+	}
+
+	if c.BackpressureTimeout.Raw() <= 0 {
+		return nil, fmt.Errorf("backpressure_timeout must be greater than 0")
+	}
+
+	httpInput := &HTTPInput{
+		InputOperator:       inputOperator,
+		address:             c.ListenAddress,
+		auth:                c.Auth,
+		maxBodySize:         c.MaxBodySize,
+		backpressureTimeout: c.BackpressureTimeout.Raw(),
+		headerLabels:        c.HeaderLabels,
+		queryLabels:         c.QueryLabels,
+	}
+
+	if c.TLS != nil {
+		httpInput.tlsConfig, err = c.TLS.Build(inputOperator.SugaredLogger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return httpInput, nil
+}
+
+// HTTPInput is an operator that receives log entries in the bodies of http requests.
+type HTTPInput struct {
+	helper.InputOperator
+	address             string
+	tlsConfig           *tls.Config
+	auth                *HTTPAuthConfig
+	maxBodySize         int64
+	backpressureTimeout time.Duration
+	headerLabels        map[string]string
+	queryLabels         map[string]string
+
+	listener  net.Listener
+	server    *http.Server
+	waitGroup sync.WaitGroup
+}
+
+// Start will start listening for http requests.
+func (h *HTTPInput) Start() error {
+	listener, err := net.Listen("tcp", h.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on interface: %s", err)
+	}
+	if h.tlsConfig != nil {
+		listener = tls.NewListener(listener, h.tlsConfig)
+	}
+	h.listener = listener
+
+	h.server = &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          zap.NewStdLog(h.Desugar()),
+	}
+
+	h.waitGroup.Add(1)
+	go func() {
+		defer h.waitGroup.Done()
+		if err := h.server.Serve(listener); err != http.ErrServerClosed {
+			h.Errorw("Failed to serve http requests", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Stop will stop listening for http requests, after the requests in progress are handled.
+func (h *HTTPInput) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.backpressureTimeout+time.Second)
+	defer cancel()
+
+	err := h.server.Shutdown(ctx)
+	h.waitGroup.Wait()
+	return err
+}
+
+// ServeHTTP will write the entries in the body of a request, and respond once they have been handed off
+func (h *HTTPInput) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(r) {
+		if h.auth.BearerToken == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="carbon"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	records, status, err := h.readRecords(w, r)
+	if err != nil {
+		h.Debugw("Rejected request", "remote_address", r.RemoteAddr, "status", status, zap.Error(err))
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	labels := h.requestLabels(r)
+
+	// Writes block while the outputs are applying backpressure, such as when their buffers are full
+	ctx, cancel := context.WithTimeout(r.Context(), h.backpressureTimeout)
+	defer cancel()
+	for i, record := range records {
+		e := h.NewEntry(record)
+		for key, value := range labels {
+			e.AddLabel(key, value)
+		}
+		h.Write(ctx, e)
+
+		// The records before this one were accepted. This one may or may not have been, so a client that
+		// retries the rest of the records can duplicate it.
+		if ctx.Err() != nil {
+			h.Warnw("Rejected request because entries were not accepted before the backpressure_timeout",
+				"remote_address", r.RemoteAddr, "accepted", i, "records", len(records))
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set(acceptedRecordsHeader, strconv.Itoa(i))
+			http.Error(w, fmt.Sprintf("too many requests: accepted %d of %d records", i, len(records)), http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized will return true if a request has the required credentials
+func (h *HTTPInput) authorized(r *http.Request) bool {
+	if h.auth == nil {
+		return true
+	}
+
+	if h.auth.BearerToken != "" {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return false
+		}
+		return secureEqual(strings.TrimPrefix(header, "Bearer "), h.auth.BearerToken)
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	// Both are compared, so that the time taken does not reveal which was wrong
+	usernameOK := secureEqual(username, h.auth.Username)
+	passwordOK := secureEqual(password, h.auth.Password)
+	return usernameOK && passwordOK
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// readRecords will read the records in the body of a request. Bodies can contain a JSON value, a JSON array
+// of values, or newline delimited JSON values. If the body is invalid, the status to respond with is returned.
+func (h *HTTPInput) readRecords(w http.ResponseWriter, r *http.Request) ([]interface{}, int, error) {
+	body := io.Reader(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+
+	switch encoding := strings.ToLower(r.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid gzip body: %s", err)
+		}
+		defer gzipReader.Close()
+		// The decompressed body is limited as well, so that small requests can not expand to use all memory
+		body = &limitedReader{reader: gzipReader, remaining: h.maxBodySize}
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+
+	var records []interface{}
+	decoder := json.NewDecoder(body)
+	for {
+		var value interface{}
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if isBodyTooLarge(err) {
+				return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body is larger than max_body_size")
+			}
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %s", err)
+		}
+
+		if values, ok := value.([]interface{}); ok {
+			records = append(records, values...)
+			continue
+		}
+		records = append(records, value)
+	}
+
+	return records, 0, nil
+}
+
+// requestLabels will return the labels mapped from the headers and query parameters of a request
+func (h *HTTPInput) requestLabels(r *http.Request) map[string]string {
+	labels := make(map[string]string)
+	for header, label := range h.headerLabels {
+		if value := r.Header.Get(header); value != "" {
+			labels[label] = value
+		}
+	}
+
+	query := r.URL.Query()
+	for param, label := range h.queryLabels {
+		if value := query.Get(param); value != "" {
+			labels[label] = value
+		}
+	}
+	return labels
+}
+
+// errBodyTooLarge is returned when a decompressed body is larger than the max body size
+var errBodyTooLarge = fmt.Errorf("http: request body too large")
+
+func isBodyTooLarge(err error) bool {
+	// http.MaxBytesReader does not return a typed error
+	return err != nil && strings.Contains(err.Error(), errBodyTooLarge.Error())
+}
+
+// limitedReader returns errBodyTooLarge once more than the remaining bytes are read
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,289 @@
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPInput(t *testing.T) {
+	basicHTTPInputConfig := func(address string) *HTTPInputConfig {
+		cfg := NewHTTPInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		cfg.ListenAddress = address
+		return cfg
+	}
+
+	// startHTTPInput will build and start an http input, returning a channel of the entries it writes
+	startHTTPInput := func(t *testing.T, cfg *HTTPInputConfig) (*HTTPInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		httpInput := newOperator.(*HTTPInput)
+		httpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, httpInput.Start())
+		return httpInput, entryChan
+	}
+
+	post := func(t *testing.T, url string, body []byte, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		require.NoError(t, err)
+		// Connections are not reused, since each test starts a new server on the same address
+		req.Close = true
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	receivedRecords := func(entryChan chan *entry.Entry) []interface{} {
+		records := []interface{}{}
+		for len(entryChan) > 0 {
+			records = append(records, (<-entryChan).Record)
+		}
+		return records
+	}
+
+	gzipped := func(t *testing.T, body string) []byte {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err := writer.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		return buf.Bytes()
+	}
+
+	t.Run("Bodies", func(t *testing.T) {
+		cfg := basicHTTPInputConfig("127.0.0.1:63201")
+		cfg.MaxBodySize = 100
+		httpInput, entryChan := startHTTPInput(t, cfg)
+		defer httpInput.Stop()
+
+		cases := []struct {
+			name           string
+			body           []byte
+			headers        map[string]string
+			expectedStatus int
+			expected       []interface{}
+		}{
+			{
+				"Object",
+				[]byte(`{"message":"test"}`),
+				nil,
+				http.StatusOK,
+				[]interface{}{map[string]interface{}{"message": "test"}},
+			},
+			{
+				"Array",
+				[]byte(`[{"message":"test1"},{"message":"test2"}]`),
+				nil,
+				http.StatusOK,
+				[]interface{}{map[string]interface{}{"message": "test1"}, map[string]interface{}{"message": "test2"}},
+			},
+			{
+				"NDJSON",
+				[]byte("{\"message\":\"test1\"}\n{\"message\":\"test2\"}\n"),
+				map[string]string{"Content-Type": "application/x-ndjson"},
+				http.StatusOK,
+				[]interface{}{map[string]interface{}{"message": "test1"}, map[string]interface{}{"message": "test2"}},
+			},
+			{
+				"Gzip",
+				gzipped(t, `{"message":"test"}`),
+				map[string]string{"Content-Encoding": "gzip"},
+				http.StatusOK,
+				[]interface{}{map[string]interface{}{"message": "test"}},
+			},
+			{
+				"InvalidJSON",
+				[]byte("{\"message\":\"test1\"}\n{\"message\""),
+				nil,
+				http.StatusBadRequest,
+				[]interface{}{},
+			},
+			{
+				"InvalidGzip",
+				[]byte(`{"message":"test"}`),
+				map[string]string{"Content-Encoding": "gzip"},
+				http.StatusBadRequest,
+				[]interface{}{},
+			},
+			{
+				"UnsupportedEncoding",
+				[]byte(`{"message":"test"}`),
+				map[string]string{"Content-Encoding": "br"},
+				http.StatusUnsupportedMediaType,
+				[]interface{}{},
+			},
+			{
+				"TooLarge",
+				[]byte(`{"message":"` + strings.Repeat("a", 100) + `"}`),
+				nil,
+				http.StatusRequestEntityTooLarge,
+				[]interface{}{},
+			},
+			{
+				"GzipTooLarge",
+				gzipped(t, `{"message":"`+strings.Repeat("a", 1000)+`"}`),
+				map[string]string{"Content-Encoding": "gzip"},
+				http.StatusRequestEntityTooLarge,
+				[]interface{}{},
+			},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				resp := post(t, "http://127.0.0.1:63201/", tc.body, tc.headers)
+				require.Equal(t, tc.expectedStatus, resp.StatusCode)
+				require.Equal(t, tc.expected, receivedRecords(entryChan))
+			})
+		}
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		httpInput, _ := startHTTPInput(t, basicHTTPInputConfig("127.0.0.1:63202"))
+		defer httpInput.Stop()
+
+		resp, err := http.Get("http://127.0.0.1:63202/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("Labels", func(t *testing.T) {
+		cfg := basicHTTPInputConfig("127.0.0.1:63203")
+		cfg.HeaderLabels = map[string]string{"X-Source": "source"}
+		cfg.QueryLabels = map[string]string{"env": "environment", "missing": "missing"}
+		httpInput, entryChan := startHTTPInput(t, cfg)
+		defer httpInput.Stop()
+
+		resp := post(t, "http://127.0.0.1:63203/logs?env=ci", []byte(`{"message":"test"}`), map[string]string{"X-Source": "build"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		e := <-entryChan
+		require.Equal(t, map[string]string{"source": "build", "environment": "ci"}, e.Labels)
+	})
+
+	t.Run("Auth", func(t *testing.T) {
+		cases := []struct {
+			name           string
+			auth           *HTTPAuthConfig
+			headers        map[string]string
+			expectedStatus int
+		}{
+			{"BearerToken", &HTTPAuthConfig{BearerToken: "secret"}, map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+			{"WrongBearerToken", &HTTPAuthConfig{BearerToken: "secret"}, map[string]string{"Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+			{"MissingBearerToken", &HTTPAuthConfig{BearerToken: "secret"}, nil, http.StatusUnauthorized},
+			{"Basic", &HTTPAuthConfig{Username: "user", Password: "pass"}, map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, http.StatusOK},
+			{"WrongBasic", &HTTPAuthConfig{Username: "user", Password: "pass"}, map[string]string{"Authorization": "Basic dXNlcjp3cm9uZw=="}, http.StatusUnauthorized},
+			{"BasicForBearerToken", &HTTPAuthConfig{BearerToken: "secret"}, map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, http.StatusUnauthorized},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicHTTPInputConfig("127.0.0.1:63204")
+				cfg.Auth = tc.auth
+				httpInput, entryChan := startHTTPInput(t, cfg)
+				defer httpInput.Stop()
+
+				resp := post(t, "http://127.0.0.1:63204/", []byte(`{"message":"test"}`), tc.headers)
+				require.Equal(t, tc.expectedStatus, resp.StatusCode)
+				if tc.expectedStatus == http.StatusOK {
+					require.Len(t, entryChan, 1)
+				} else {
+					require.Len(t, entryChan, 0)
+				}
+			})
+		}
+	})
+
+	t.Run("Backpressure", func(t *testing.T) {
+		cfg := basicHTTPInputConfig("127.0.0.1:63205")
+		cfg.BackpressureTimeout = operator.Duration{Duration: 100 * time.Millisecond}
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		// The output accepts the first entry, and then blocks until the context is done, like a full buffer
+		mockOutput := testutil.Operator{}
+		httpInput := newOperator.(*HTTPInput)
+		httpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			if args.Get(1).(*entry.Entry).Record.(map[string]interface{})["message"] != "first" {
+				<-args.Get(0).(context.Context).Done()
+			}
+		}).Return(nil)
+
+		require.NoError(t, httpInput.Start())
+		defer httpInput.Stop()
+
+		// The response reports how many records were accepted, so that only the rest are retried
+		body := []byte("{\"message\":\"first\"}\n{\"message\":\"second\"}\n{\"message\":\"third\"}\n")
+		resp := post(t, "http://127.0.0.1:63205/", body, nil)
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.Equal(t, "1", resp.Header.Get("Retry-After"))
+		require.Equal(t, "1", resp.Header.Get(acceptedRecordsHeader))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		files := testutil.NewTLSFiles(t, "client")
+
+		cfg := basicHTTPInputConfig("127.0.0.1:63206")
+		cfg.TLS = &helper.TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile}
+		httpInput, entryChan := startHTTPInput(t, cfg)
+		defer httpInput.Stop()
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: files.ClientConfigWithoutCertificate()}}
+		resp, err := client.Post("https://127.0.0.1:63206/", "application/json", strings.NewReader(`{"message":"test"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, entryChan, 1)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*HTTPInputConfig)
+		}{
+			{"MissingListenAddress", func(c *HTTPInputConfig) { c.ListenAddress = "" }},
+			{"InvalidListenAddress", func(c *HTTPInputConfig) { c.ListenAddress = "localhost" }},
+			{"EmptyAuth", func(c *HTTPInputConfig) { c.Auth = &HTTPAuthConfig{} }},
+			{"MissingPassword", func(c *HTTPInputConfig) { c.Auth = &HTTPAuthConfig{Username: "user"} }},
+			{"BearerTokenAndBasic", func(c *HTTPInputConfig) {
+				c.Auth = &HTTPAuthConfig{BearerToken: "secret", Username: "user", Password: "pass"}
+			}},
+			{"ZeroMaxBodySize", func(c *HTTPInputConfig) { c.MaxBodySize = 0 }},
+			{"ZeroBackpressureTimeout", func(c *HTTPInputConfig) { c.BackpressureTimeout = operator.Duration{} }},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicHTTPInputConfig("127.0.0.1:63207")
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
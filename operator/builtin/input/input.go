@@ -1,6 +1,7 @@
 package input
 
 import (
-	// Load file package when importing input operators
+	// Load embedded packages when importing input operators
 	_ "github.com/observiq/carbon/operator/builtin/input/file"
+	_ "github.com/observiq/carbon/operator/builtin/input/k8s"
 )
@@ -0,0 +1,122 @@
+package journal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+// decompress will decompress the payload of a data object according to its flags
+func decompress(payload []byte, flags uint8) ([]byte, error) {
+	switch {
+	case flags&objectCompressedZstd != 0:
+		// The decoder starts goroutines, so it is only created once it is needed
+		zstdDecoderOnce.Do(func() {
+			zstdDecoder, zstdDecoderErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxObjectSize))
+		})
+		if zstdDecoderErr != nil {
+			return nil, zstdDecoderErr
+		}
+		decompressed, err := zstdDecoder.DecodeAll(payload, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decompress zstd data: %s", err)
+		}
+		return decompressed, nil
+	case flags&objectCompressedLZ4 != 0:
+		// LZ4 payloads are preceded by their decompressed size
+		if len(payload) < 8 {
+			return nil, fmt.Errorf("invalid lz4 data")
+		}
+		size := binary.LittleEndian.Uint64(payload)
+		if size > maxObjectSize {
+			return nil, fmt.Errorf("invalid lz4 decompressed size %d", size)
+		}
+		return decompressLZ4Block(payload[8:], int(size))
+	case flags&objectCompressedXZ != 0:
+		return nil, fmt.Errorf("xz compressed data is not supported")
+	default:
+		return payload, nil
+	}
+}
+
+// decompressLZ4Block will decompress a raw LZ4 block into a buffer of the decompressed size
+func decompressLZ4Block(src []byte, size int) ([]byte, error) {
+	errInvalid := fmt.Errorf("invalid lz4 data")
+	dst := make([]byte, 0, size)
+
+	// readLength will extend a length of 15 with the bytes that follow it
+	i := 0
+	readLength := func(length int) (int, error) {
+		if length != 15 {
+			return length, nil
+		}
+		for {
+			if i >= len(src) {
+				return 0, errInvalid
+			}
+			b := src[i]
+			i++
+			length += int(b)
+			if b != 255 {
+				return length, nil
+			}
+		}
+	}
+
+	for i < len(src) {
+		token := src[i]
+		i++
+
+		literals, err := readLength(int(token >> 4))
+		if err != nil {
+			return nil, err
+		}
+		if literals > len(src)-i || literals > size-len(dst) {
+			return nil, errInvalid
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		// The last sequence only contains literals
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errInvalid
+		}
+		matchOffset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if matchOffset == 0 || matchOffset > len(dst) {
+			return nil, errInvalid
+		}
+
+		matchLength, err := readLength(int(token & 0x0f))
+		if err != nil {
+			return nil, err
+		}
+		matchLength += 4
+		if matchLength > size-len(dst) {
+			return nil, errInvalid
+		}
+
+		// Matches can overlap the bytes they produce, so they are copied one byte at a time
+		start := len(dst) - matchOffset
+		for j := 0; j < matchLength; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+
+	if len(dst) != size {
+		return nil, errInvalid
+	}
+	return dst, nil
+}
@@ -0,0 +1,73 @@
+package journal
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	lz4Payload := func(size uint64, block []byte) []byte {
+		payload := make([]byte, 8)
+		binary.LittleEndian.PutUint64(payload, size)
+		return append(payload, block...)
+	}
+
+	cases := []struct {
+		name     string
+		payload  []byte
+		flags    uint8
+		expected string
+	}{
+		{
+			"Uncompressed",
+			[]byte("MESSAGE=test"),
+			0,
+			"MESSAGE=test",
+		},
+		{
+			"LZ4",
+			// 3 literals, a match of 9 bytes at offset 3, and 3 final literals
+			lz4Payload(15, []byte{0x35, 'a', 'b', 'c', 0x03, 0x00, 0x30, 'x', 'y', 'z'}),
+			objectCompressedLZ4,
+			"abcabcabcabcxyz",
+		},
+		{
+			"LZ4LongLengths",
+			// 20 literals, and a match of 4+15+10 bytes at offset 1
+			lz4Payload(49, append(append([]byte{0xff, 5}, []byte("MESSAGE=aaaaaaaaaaaa")...), 0x01, 0x00, 10)),
+			objectCompressedLZ4,
+			"MESSAGE=" + strings.Repeat("a", 41),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			decompressed, err := decompress(tc.payload, tc.flags)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(decompressed))
+		})
+	}
+
+	errorCases := []struct {
+		name    string
+		payload []byte
+		flags   uint8
+	}{
+		{"LZ4WrongSize", lz4Payload(16, []byte{0x35, 'a', 'b', 'c', 0x03, 0x00, 0x30, 'x', 'y', 'z'}), objectCompressedLZ4},
+		{"LZ4InvalidOffset", lz4Payload(15, []byte{0x35, 'a', 'b', 'c', 0x04, 0x00, 0x30, 'x', 'y', 'z'}), objectCompressedLZ4},
+		{"LZ4Truncated", lz4Payload(15, []byte{0x35, 'a', 'b', 'c', 0x03}), objectCompressedLZ4},
+		{"LZ4MissingSize", []byte{0x35}, objectCompressedLZ4},
+		{"Zstd", []byte("not zstd"), objectCompressedZstd},
+		{"XZ", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, objectCompressedXZ},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decompress(tc.payload, tc.flags)
+			require.Error(t, err)
+		})
+	}
+}
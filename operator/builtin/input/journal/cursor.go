@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cursor identifies the position of an entry in the journal. Cursors are formatted the same way as journalctl
+// formats them, so that a cursor saved while reading with journalctl can be used to resume reading files directly.
+type Cursor struct {
+	SeqnumID  ID
+	Seqnum    uint64
+	BootID    ID
+	Monotonic uint64
+	Realtime  uint64
+	XORHash   uint64
+}
+
+// String will return the cursor in the format used by journalctl
+func (c Cursor) String() string {
+	return fmt.Sprintf("s=%s;i=%x;b=%s;m=%x;t=%x;x=%x", c.SeqnumID, c.Seqnum, c.BootID, c.Monotonic, c.Realtime, c.XORHash)
+}
+
+// ParseCursor will parse a cursor in the format used by journalctl
+func ParseCursor(cursor string) (*Cursor, error) {
+	c := &Cursor{}
+	found := make(map[string]bool)
+	for _, part := range strings.Split(cursor, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("invalid cursor '%s'", cursor)
+		}
+
+		var err error
+		key, value := keyValue[0], keyValue[1]
+		switch key {
+		case "s":
+			err = parseID(value, &c.SeqnumID)
+		case "i":
+			c.Seqnum, err = strconv.ParseUint(value, 16, 64)
+		case "b":
+			err = parseID(value, &c.BootID)
+		case "m":
+			c.Monotonic, err = strconv.ParseUint(value, 16, 64)
+		case "t":
+			c.Realtime, err = strconv.ParseUint(value, 16, 64)
+		case "x":
+			c.XORHash, err = strconv.ParseUint(value, 16, 64)
+		default:
+			// Unknown parts are ignored, as journalctl does
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor '%s': %s", cursor, err)
+		}
+		found[key] = true
+	}
+
+	// Entries are found by their sequence number, or by their timestamp if they are from another sequence
+	if !(found["s"] && found["i"]) && !found["t"] {
+		return nil, fmt.Errorf("invalid cursor '%s': missing sequence number and timestamp", cursor)
+	}
+	return c, nil
+}
+
+func parseID(value string, id *ID) error {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(id) {
+		return fmt.Errorf("invalid id length %d", len(decoded))
+	}
+	copy(id[:], decoded)
+	return nil
+}
+
+// After will return true if an entry comes after the position of the cursor
+func (c *Cursor) After(e *Entry) bool {
+	if c.SeqnumID != (ID{}) && c.SeqnumID == e.SeqnumID {
+		return e.Seqnum > c.Seqnum
+	}
+	return e.Realtime > c.Realtime
+}
@@ -0,0 +1,424 @@
+// Package journal reads systemd journal files directly, without journalctl.
+//
+// The file format is described at https://systemd.io/JOURNAL_FILE_FORMAT/
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"unicode/utf8"
+)
+
+var signature = []byte("LPKSHHRH")
+
+// Incompatible header flags
+const (
+	incompatibleCompressedXZ   = 1 << 0
+	incompatibleCompressedLZ4  = 1 << 1
+	incompatibleKeyedHash      = 1 << 2
+	incompatibleCompressedZstd = 1 << 3
+	incompatibleCompact        = 1 << 4
+
+	incompatibleSupported = incompatibleCompressedXZ | incompatibleCompressedLZ4 | incompatibleKeyedHash |
+		incompatibleCompressedZstd | incompatibleCompact
+)
+
+// Object types
+const (
+	objectData       = 1
+	objectEntry      = 3
+	objectEntryArray = 6
+)
+
+// Object flags
+const (
+	objectCompressedXZ   = 1 << 0
+	objectCompressedLZ4  = 1 << 1
+	objectCompressedZstd = 1 << 2
+)
+
+const (
+	minHeaderSize    = 208
+	objectHeaderSize = 16
+	entryHeaderSize  = 64
+	// maxObjectSize limits the memory used by a corrupted object size
+	maxObjectSize = 256 * 1024 * 1024
+)
+
+// ID is a 128 bit identifier, such as a file, boot or sequence number id
+type ID [16]byte
+
+// String will return the identifier as lowercase hex, as journalctl formats it
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// header is the part of the journal file header used for reading entries
+type header struct {
+	incompatibleFlags uint32
+	fileID            ID
+	seqnumID          ID
+	headerSize        uint64
+	arenaSize         uint64
+	nEntries          uint64
+	entryArrayOffset  uint64
+}
+
+func (h *header) compact() bool {
+	return h.incompatibleFlags&incompatibleCompact != 0
+}
+
+// Entry is an entry read from a journal file
+type Entry struct {
+	SeqnumID  ID
+	Seqnum    uint64
+	BootID    ID
+	Realtime  uint64 // microseconds since the epoch
+	Monotonic uint64 // microseconds since boot
+	XORHash   uint64
+
+	// Fields contains the fields of the entry. Values are strings if they are valid UTF-8, and
+	// byte slices otherwise. Fields that occur more than once have a slice of the values.
+	Fields map[string]interface{}
+}
+
+// Cursor will return the cursor of the entry, in the format used by journalctl
+func (e *Entry) Cursor() string {
+	return Cursor{
+		SeqnumID:  e.SeqnumID,
+		Seqnum:    e.Seqnum,
+		BootID:    e.BootID,
+		Monotonic: e.Monotonic,
+		Realtime:  e.Realtime,
+		XORHash:   e.XORHash,
+	}.String()
+}
+
+// File is a journal file that is read as it is written
+type File struct {
+	file   *os.File
+	header header
+
+	// The position of the next entry in the chain of entry arrays
+	arrayOffset   uint64
+	arrayCapacity uint64
+	arrayIndex    uint64
+	read          uint64
+}
+
+// Open will open a journal file and read its header
+func Open(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{file: file}
+	if err := f.Refresh(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Name will return the path the file was opened with
+func (f *File) Name() string {
+	return f.file.Name()
+}
+
+// ID will return the unique identifier of the file, which does not change when it is rotated
+func (f *File) ID() ID {
+	return f.header.fileID
+}
+
+// Close will close the file
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+// Refresh will read the header of the file again, so that entries written since it was last read are found
+func (f *File) Refresh() error {
+	buf := make([]byte, minHeaderSize)
+	if _, err := f.file.ReadAt(buf, 0); err != nil {
+		return fmt.Errorf("read header: %s", err)
+	}
+
+	if !bytes.Equal(buf[:8], signature) {
+		return fmt.Errorf("not a journal file")
+	}
+
+	h := header{
+		incompatibleFlags: binary.LittleEndian.Uint32(buf[12:]),
+		headerSize:        binary.LittleEndian.Uint64(buf[88:]),
+		arenaSize:         binary.LittleEndian.Uint64(buf[96:]),
+		nEntries:          binary.LittleEndian.Uint64(buf[152:]),
+		entryArrayOffset:  binary.LittleEndian.Uint64(buf[176:]),
+	}
+	copy(h.fileID[:], buf[24:40])
+	copy(h.seqnumID[:], buf[72:88])
+
+	if unsupported := h.incompatibleFlags &^ incompatibleSupported; unsupported != 0 {
+		return fmt.Errorf("unsupported incompatible flags %#x", unsupported)
+	}
+	if h.headerSize < minHeaderSize {
+		return fmt.Errorf("invalid header size %d", h.headerSize)
+	}
+
+	if f.header.fileID != (ID{}) && f.header.fileID != h.fileID {
+		return fmt.Errorf("file id changed from %s to %s", f.header.fileID, h.fileID)
+	}
+	f.header = h
+	return nil
+}
+
+// Next will return the next entry of the file, or nil if all of the entries that were written when the header was
+// last refreshed have been read. If some of the fields of an entry can not be read, the entry is returned without
+// them along with an error.
+func (f *File) Next() (*Entry, error) {
+	offset, err := f.nextEntryOffset()
+	if err != nil || offset == 0 {
+		return nil, err
+	}
+
+	entry, err := f.readEntry(offset)
+	if entry == nil {
+		return nil, err
+	}
+	f.arrayIndex++
+	f.read++
+	return entry, err
+}
+
+// SeekEnd will skip the entries that were written when the header was last refreshed. Only the headers of
+// the entry arrays are read, so that skipping a large file does not read its entries.
+func (f *File) SeekEnd() error {
+	if f.header.entryArrayOffset == 0 || f.read >= f.header.nEntries {
+		return nil
+	}
+
+	var skipped uint64
+	offset := f.header.entryArrayOffset
+	for {
+		if err := f.loadArray(offset); err != nil {
+			return err
+		}
+		if skipped+f.arrayCapacity >= f.header.nEntries {
+			f.arrayIndex = f.header.nEntries - skipped
+			f.read = f.header.nEntries
+			return nil
+		}
+		skipped += f.arrayCapacity
+
+		next, err := f.readUint64(f.arrayOffset + objectHeaderSize)
+		if err != nil {
+			return err
+		}
+		if next == 0 {
+			// The chain ends before the number of entries in the header, so reading continues at its end
+			f.arrayIndex = f.arrayCapacity
+			f.read = skipped
+			return nil
+		}
+		offset = next
+	}
+}
+
+// nextEntryOffset will return the offset of the next entry, following the chain of entry arrays
+func (f *File) nextEntryOffset() (uint64, error) {
+	if f.read >= f.header.nEntries {
+		return 0, nil
+	}
+
+	if f.arrayOffset == 0 {
+		if f.header.entryArrayOffset == 0 {
+			return 0, nil
+		}
+		if err := f.loadArray(f.header.entryArrayOffset); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.arrayIndex >= f.arrayCapacity {
+		next, err := f.readUint64(f.arrayOffset + objectHeaderSize)
+		if err != nil {
+			return 0, err
+		}
+		if next == 0 {
+			return 0, nil
+		}
+		if err := f.loadArray(next); err != nil {
+			return 0, err
+		}
+	}
+
+	itemSize := f.arrayItemSize()
+	itemOffset := f.arrayOffset + objectHeaderSize + 8 + f.arrayIndex*itemSize
+	if itemSize == 4 {
+		item, err := f.readUint32(itemOffset)
+		return uint64(item), err
+	}
+	return f.readUint64(itemOffset)
+}
+
+func (f *File) arrayItemSize() uint64 {
+	if f.header.compact() {
+		return 4
+	}
+	return 8
+}
+
+// loadArray will position the reader at the start of an entry array
+func (f *File) loadArray(offset uint64) error {
+	objectType, _, size, err := f.readObjectHeader(offset)
+	if err != nil {
+		return err
+	}
+	if objectType != objectEntryArray || size < objectHeaderSize+8 {
+		return fmt.Errorf("invalid entry array object at offset %d", offset)
+	}
+
+	f.arrayOffset = offset
+	f.arrayCapacity = (size - objectHeaderSize - 8) / f.arrayItemSize()
+	f.arrayIndex = 0
+	return nil
+}
+
+// readEntry will read the entry object at an offset, along with its data objects
+func (f *File) readEntry(offset uint64) (*Entry, error) {
+	objectType, _, size, err := f.readObjectHeader(offset)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != objectEntry || size < entryHeaderSize {
+		return nil, fmt.Errorf("invalid entry object at offset %d", offset)
+	}
+
+	buf := make([]byte, size)
+	if _, err := f.file.ReadAt(buf, int64(offset)); err != nil {
+		return nil, fmt.Errorf("read entry: %s", err)
+	}
+
+	entry := &Entry{
+		SeqnumID:  f.header.seqnumID,
+		Seqnum:    binary.LittleEndian.Uint64(buf[16:]),
+		Realtime:  binary.LittleEndian.Uint64(buf[24:]),
+		Monotonic: binary.LittleEndian.Uint64(buf[32:]),
+		XORHash:   binary.LittleEndian.Uint64(buf[56:]),
+		Fields:    make(map[string]interface{}),
+	}
+	copy(entry.BootID[:], buf[40:56])
+
+	// Items are the offset and hash of a data object, or only the offset in compact files
+	itemSize := uint64(16)
+	if f.header.compact() {
+		itemSize = 4
+	}
+
+	var fieldErr error
+	for item := uint64(entryHeaderSize); item+itemSize <= size; item += itemSize {
+		var dataOffset uint64
+		if f.header.compact() {
+			dataOffset = uint64(binary.LittleEndian.Uint32(buf[item:]))
+		} else {
+			dataOffset = binary.LittleEndian.Uint64(buf[item:])
+		}
+
+		name, value, err := f.readData(dataOffset)
+		if err != nil {
+			fieldErr = fmt.Errorf("entry %d: %s", entry.Seqnum, err)
+			continue
+		}
+		addField(entry.Fields, name, value)
+	}
+
+	return entry, fieldErr
+}
+
+// readData will read the field name and value of the data object at an offset
+func (f *File) readData(offset uint64) (string, interface{}, error) {
+	objectType, flags, size, err := f.readObjectHeader(offset)
+	if err != nil {
+		return "", nil, err
+	}
+
+	payloadOffset := uint64(64)
+	if f.header.compact() {
+		payloadOffset = 72
+	}
+	if objectType != objectData || size < payloadOffset {
+		return "", nil, fmt.Errorf("invalid data object at offset %d", offset)
+	}
+
+	payload := make([]byte, size-payloadOffset)
+	if _, err := f.file.ReadAt(payload, int64(offset+payloadOffset)); err != nil {
+		return "", nil, fmt.Errorf("read data: %s", err)
+	}
+
+	payload, err = decompress(payload, flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	separator := bytes.IndexByte(payload, '=')
+	if separator < 1 {
+		return "", nil, fmt.Errorf("invalid data object at offset %d", offset)
+	}
+
+	name := string(payload[:separator])
+	value := payload[separator+1:]
+	if utf8.Valid(value) {
+		return name, string(value), nil
+	}
+	return name, value, nil
+}
+
+// addField will add a value to the fields of an entry. Fields that occur more than once have a slice of their values.
+func addField(fields map[string]interface{}, name string, value interface{}) {
+	existing, ok := fields[name]
+	if !ok {
+		fields[name] = value
+		return
+	}
+
+	if values, ok := existing.([]interface{}); ok {
+		fields[name] = append(values, value)
+		return
+	}
+	fields[name] = []interface{}{existing, value}
+}
+
+func (f *File) readObjectHeader(offset uint64) (objectType, flags uint8, size uint64, err error) {
+	if offset < f.header.headerSize || offset%8 != 0 {
+		return 0, 0, 0, fmt.Errorf("invalid object offset %d", offset)
+	}
+
+	buf := make([]byte, objectHeaderSize)
+	if _, err := f.file.ReadAt(buf, int64(offset)); err != nil {
+		return 0, 0, 0, fmt.Errorf("read object header: %s", err)
+	}
+
+	size = binary.LittleEndian.Uint64(buf[8:])
+	if size < objectHeaderSize || size > maxObjectSize {
+		return 0, 0, 0, fmt.Errorf("invalid object size %d at offset %d", size, offset)
+	}
+	return buf[0], buf[1], size, nil
+}
+
+func (f *File) readUint64(offset uint64) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := f.file.ReadAt(buf, int64(offset)); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+func (f *File) readUint32(offset uint64) (uint32, error) {
+	buf := make([]byte, 4)
+	if _, err := f.file.ReadAt(buf, int64(offset)); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf), nil
+}
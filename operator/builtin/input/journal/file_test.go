@@ -0,0 +1,118 @@
+package journal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// copyFile will overwrite a file with the contents of a sample file, as if more entries were written to it
+func copyFile(t *testing.T, src, dst string) {
+	contents, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, contents, 0600))
+}
+
+func readAll(t *testing.T, file *File) []*Entry {
+	var entries []*Entry
+	for {
+		entry, err := file.Next()
+		require.NoError(t, err)
+		if entry == nil {
+			return entries
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestFile(t *testing.T) {
+	cases := []struct {
+		name           string
+		path           string
+		expectedCursor string
+	}{
+		{
+			"Compact",
+			"testdata/compact.journal",
+			"s=8a4f067825344c19afe5c05a81e1290d;i=3;b=c37fccf56d5442888bc01b4b0e0dc365;m=195bac261;t=65e1ed28a87f4;x=ef6ef122dd1e0fbd",
+		},
+		{
+			"Regular",
+			"testdata/regular.journal",
+			"s=4978f091af624ad88099806f5e540e7d;i=3;b=c37fccf56d5442888bc01b4b0e0dc365;m=195e70254;t=65e1ed2b6c7e7;x=94e4fb780301e4c5",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := Open(tc.path)
+			require.NoError(t, err)
+			defer file.Close()
+
+			entries := readAll(t, file)
+			require.Len(t, entries, 9)
+			for i, entry := range entries {
+				require.Equal(t, uint64(i+1), entry.Seqnum)
+			}
+
+			first := entries[2]
+			require.Equal(t, tc.expectedCursor, first.Cursor())
+			require.Equal(t, "first message", first.Fields["MESSAGE"])
+			require.Equal(t, "6", first.Fields["PRIORITY"])
+			require.Equal(t, "carbon-test", first.Fields["SYSLOG_IDENTIFIER"])
+			require.Equal(t, "app.service", first.Fields["UNIT"])
+			require.Equal(t, first.BootID.String(), first.Fields["_BOOT_ID"])
+			require.Len(t, first.Fields, 18)
+
+			require.Equal(t, "multi\nline message", entries[3].Fields["MESSAGE"])
+			require.Equal(t, []byte{0x00, 0x01, 0xfe, 0xff}, entries[4].Fields["BINARY"])
+			require.Equal(t, []interface{}{"a", "b"}, entries[5].Fields["TAG"])
+			require.Equal(t, "large "+strings.Repeat("x", 2000), entries[6].Fields["MESSAGE"])
+			require.Equal(t, "second batch 2", entries[8].Fields["MESSAGE"])
+		})
+	}
+
+	t.Run("Follow", func(t *testing.T) {
+		path := filepath.Join(testutil.NewTempDir(t), "system.journal")
+		copyFile(t, "testdata/compact-partial.journal", path)
+		file, err := Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+
+		require.Len(t, readAll(t, file), 7)
+
+		// Entries written after the header was read are found once it is refreshed
+		copyFile(t, "testdata/compact.journal", path)
+		require.Len(t, readAll(t, file), 0)
+		require.NoError(t, file.Refresh())
+		entries := readAll(t, file)
+		require.Len(t, entries, 2)
+		require.Equal(t, "second batch 1", entries[0].Fields["MESSAGE"])
+	})
+
+	t.Run("SeekEnd", func(t *testing.T) {
+		path := filepath.Join(testutil.NewTempDir(t), "system.journal")
+		copyFile(t, "testdata/compact-partial.journal", path)
+		file, err := Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+
+		require.NoError(t, file.SeekEnd())
+		require.Len(t, readAll(t, file), 0)
+
+		copyFile(t, "testdata/compact.journal", path)
+		require.NoError(t, file.Refresh())
+		entries := readAll(t, file)
+		require.Len(t, entries, 2)
+		require.Equal(t, "second batch 1", entries[0].Fields["MESSAGE"])
+	})
+
+	t.Run("NotJournal", func(t *testing.T) {
+		_, err := Open("file_test.go")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,83 @@
+package journal
+
+import (
+	"strconv"
+)
+
+// unitFields are the fields that identify the unit an entry is about, which journalctl --unit also matches
+var unitFields = []string{"_SYSTEMD_UNIT", "UNIT", "OBJECT_SYSTEMD_UNIT", "COREDUMP_UNIT"}
+
+// Filter selects entries of the journal, like the matches of journalctl do
+type Filter struct {
+	// Units selects the entries of any of the systemd units
+	Units []string
+
+	// Priority selects the entries with a PRIORITY within a range
+	Priority *PriorityRange
+
+	// Matches selects the entries that have one of the values of each field
+	Matches map[string][]string
+}
+
+// PriorityRange is an inclusive range of syslog priorities, where 0 is emerg and 7 is debug
+type PriorityRange struct {
+	Min int
+	Max int
+}
+
+// Match will return true if an entry is selected by the filter
+func (f *Filter) Match(e *Entry) bool {
+	if len(f.Units) > 0 && !matchAny(e, unitFields, f.Units) {
+		return false
+	}
+
+	if f.Priority != nil {
+		value, ok := e.Fields["PRIORITY"].(string)
+		if !ok {
+			return false
+		}
+		priority, err := strconv.Atoi(value)
+		if err != nil || priority < f.Priority.Min || priority > f.Priority.Max {
+			return false
+		}
+	}
+
+	for field, values := range f.Matches {
+		if !matchAny(e, []string{field}, values) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny will return true if any of the fields of an entry has any of the values
+func matchAny(e *Entry, fields []string, values []string) bool {
+	for _, field := range fields {
+		for _, value := range fieldValues(e.Fields[field]) {
+			for _, expected := range values {
+				if value == expected {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fieldValues will return the values of a field as strings, since fields can occur more than once
+func fieldValues(field interface{}) []string {
+	switch value := field.(type) {
+	case string:
+		return []string{value}
+	case []byte:
+		return []string{string(value)}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			values = append(values, fieldValues(v)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	file, err := Open("testdata/compact.journal")
+	require.NoError(t, err)
+	defer file.Close()
+	entries := readAll(t, file)
+
+	cases := []struct {
+		name     string
+		filter   *Filter
+		expected []uint64
+	}{
+		{
+			"Empty",
+			&Filter{},
+			[]uint64{1, 2, 3, 4, 5, 6, 7, 8, 9},
+		},
+		{
+			"Units",
+			&Filter{Units: []string{"db.service"}},
+			[]uint64{4, 7, 9},
+		},
+		{
+			"Priority",
+			&Filter{Priority: &PriorityRange{Min: 2, Max: 4}},
+			[]uint64{4, 5, 8},
+		},
+		{
+			"Matches",
+			&Filter{Matches: map[string][]string{"SYSLOG_IDENTIFIER": {"carbon-test", "other"}, "UNIT": {"app.service"}}},
+			[]uint64{3, 5, 8},
+		},
+		{
+			"RepeatedField",
+			&Filter{Matches: map[string][]string{"TAG": {"b"}}},
+			[]uint64{6},
+		},
+		{
+			"Combined",
+			&Filter{Units: []string{"app.service", "db.service"}, Priority: &PriorityRange{Min: 0, Max: 5}, Matches: map[string][]string{"SYSLOG_IDENTIFIER": {"carbon-test"}}},
+			[]uint64{4, 5, 7, 8},
+		},
+		{
+			"NoMatch",
+			&Filter{Matches: map[string][]string{"MISSING": {"value"}}},
+			[]uint64{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			matched := []uint64{}
+			for _, entry := range entries {
+				if tc.filter.Match(entry) {
+					matched = append(matched, entry.Seqnum)
+				}
+			}
+			require.Equal(t, tc.expected, matched)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultPollLimit is the number of entries that a poll returns at most, unless it is changed with SetPollLimit
+const DefaultPollLimit = 1000
+
+// Reader follows the entries of a set of journal files
+type Reader struct {
+	directories  []string
+	files        []string
+	filter       *Filter
+	after        *Cursor
+	skipExisting bool
+	limit        int
+
+	// Files are tracked by their id, since they are renamed when they are rotated
+	tracked map[ID]*trackedFile
+	paths   map[string]*trackedPath
+
+	// The last entry returned, so that copies of it in other files are skipped
+	last *Entry
+}
+
+type trackedPath struct {
+	id   ID
+	info os.FileInfo
+}
+
+type trackedFile struct {
+	file   *File
+	path   string
+	failed bool
+
+	// The next entry of the file that is selected, which has been read but not yet returned
+	next *Entry
+}
+
+// NewDirectoryReader will create a reader for the journal files in directories, and in the directories
+// they contain, like journalctl --directory does.
+func NewDirectoryReader(directories ...string) *Reader {
+	return &Reader{
+		directories: directories,
+		limit:       DefaultPollLimit,
+		tracked:     make(map[ID]*trackedFile),
+		paths:       make(map[string]*trackedPath),
+	}
+}
+
+// NewFilesReader will create a reader for a list of journal files. Paths can contain glob patterns.
+func NewFilesReader(files []string) *Reader {
+	return &Reader{
+		files:   files,
+		limit:   DefaultPollLimit,
+		tracked: make(map[ID]*trackedFile),
+		paths:   make(map[string]*trackedPath),
+	}
+}
+
+// SetFilter will only read the entries that are selected by a filter
+func (r *Reader) SetFilter(filter *Filter) {
+	r.filter = filter
+}
+
+// SetPollLimit will change the number of entries that a poll returns at most
+func (r *Reader) SetPollLimit(limit int) {
+	r.limit = limit
+}
+
+// PollLimit will return the number of entries that a poll returns at most
+func (r *Reader) PollLimit() int {
+	return r.limit
+}
+
+// SeekAfter will skip the entries that are not after a cursor. It must be called before the first poll.
+func (r *Reader) SeekAfter(cursor *Cursor) {
+	r.after = cursor
+}
+
+// SeekEnd will skip the entries that exist before the first poll. It must be called before the first poll.
+func (r *Reader) SeekEnd() {
+	r.skipExisting = true
+}
+
+// Poll will read the entries written since the last poll, ordered by their realtime timestamp. At most the
+// poll limit of entries are returned, and the rest are returned by the following polls. Entries are returned
+// even if some of their fields could not be read, and the problems encountered are returned as errors.
+func (r *Reader) Poll() ([]*Entry, []error) {
+	var errs []error
+
+	paths, err := r.findPaths()
+	if err != nil {
+		return nil, []error{err}
+	}
+	errs = append(errs, r.updateTracked(paths)...)
+
+	for _, tracked := range r.tracked {
+		if tracked.failed {
+			continue
+		}
+		if err := tracked.file.Refresh(); err != nil {
+			tracked.failed = true
+			errs = append(errs, fmt.Errorf("%s: %s", tracked.file.Name(), err))
+		}
+	}
+
+	if r.skipExisting {
+		r.skipExisting = false
+		for _, tracked := range r.tracked {
+			if tracked.failed {
+				continue
+			}
+			if err := tracked.file.SeekEnd(); err != nil {
+				tracked.failed = true
+				errs = append(errs, fmt.Errorf("%s: %s", tracked.file.Name(), err))
+			}
+		}
+		return nil, errs
+	}
+
+	// The files are merged by taking the earliest of their next entries, so that only a single entry
+	// of each file is held in memory besides the entries returned
+	fieldErrs := make(map[ID]bool)
+	var entries []*Entry
+	for len(entries) < r.limit {
+		var earliest *trackedFile
+		for id, tracked := range r.tracked {
+			if err := r.readNext(tracked, fieldErrs); err != nil {
+				errs = append(errs, err)
+				fieldErrs[id] = true
+			}
+			if tracked.next != nil && (earliest == nil || before(tracked.next, earliest.next)) {
+				earliest = tracked
+			}
+		}
+		if earliest == nil {
+			break
+		}
+
+		entry := earliest.next
+		earliest.next = nil
+
+		// Entries are copied when journald flushes the entries in /run/log/journal to /var/log/journal
+		if r.last != nil && entry.SeqnumID == r.last.SeqnumID && entry.Seqnum == r.last.Seqnum {
+			continue
+		}
+		r.last = entry
+		entries = append(entries, entry)
+	}
+	return entries, errs
+}
+
+// before will return true if an entry is ordered before another. Entries of the same sequence are
+// ordered by sequence number, since the realtime clock can go backwards.
+func before(a, b *Entry) bool {
+	if a.SeqnumID == b.SeqnumID {
+		return a.Seqnum < b.Seqnum
+	}
+	return a.Realtime < b.Realtime
+}
+
+// readNext will read the next selected entry of a file, unless one has already been read. Only the
+// first error of the fields of the entries of a file is returned during a poll.
+func (r *Reader) readNext(tracked *trackedFile, fieldErrs map[ID]bool) error {
+	if tracked.failed || tracked.next != nil {
+		return nil
+	}
+
+	file := tracked.file
+	var fieldErr error
+	for {
+		entry, err := file.Next()
+		if entry == nil {
+			if err != nil {
+				// The position in the file is lost, so it is not read again
+				tracked.failed = true
+				return fmt.Errorf("%s: %s", file.Name(), err)
+			}
+			return fieldErr
+		}
+		if err != nil && fieldErr == nil && !fieldErrs[file.ID()] {
+			fieldErr = fmt.Errorf("%s: %s", file.Name(), err)
+		}
+
+		if r.after != nil && !r.after.After(entry) {
+			continue
+		}
+		if r.filter != nil && !r.filter.Match(entry) {
+			continue
+		}
+		tracked.next = entry
+		return fieldErr
+	}
+}
+
+// findPaths will return the paths of the journal files that should currently be read
+func (r *Reader) findPaths() (map[string]bool, error) {
+	patterns := r.files
+	for _, directory := range r.directories {
+		for _, dir := range []string{directory, filepath.Join(directory, "*")} {
+			// Files that journald found to be corrupted are renamed with a trailing ~, and can still be read
+			patterns = append(patterns, filepath.Join(dir, "*.journal"), filepath.Join(dir, "*.journal~"))
+		}
+	}
+
+	paths := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file pattern '%s': %s", pattern, err)
+		}
+		for _, match := range matches {
+			paths[match] = true
+		}
+	}
+	return paths, nil
+}
+
+// updateTracked will open the files that have been added, and close the files that have been removed
+func (r *Reader) updateTracked(paths map[string]bool) []error {
+	var errs []error
+	for path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		// A path is opened again if it refers to a new file, such as after the file it referred to was rotated
+		if known, ok := r.paths[path]; ok && os.SameFile(known.info, info) {
+			continue
+		}
+
+		file, err := Open(path)
+		if err != nil {
+			// Files that are not valid are not opened again, unless they are replaced
+			r.paths[path] = &trackedPath{info: info}
+			errs = append(errs, fmt.Errorf("%s: %s", path, err))
+			continue
+		}
+
+		r.paths[path] = &trackedPath{id: file.ID(), info: info}
+		if tracked, ok := r.tracked[file.ID()]; ok {
+			// The file was renamed, so reading continues with the file that is already open
+			tracked.path = path
+			file.Close()
+			continue
+		}
+		r.tracked[file.ID()] = &trackedFile{file: file, path: path}
+	}
+
+	for path := range r.paths {
+		if !paths[path] {
+			delete(r.paths, path)
+		}
+	}
+
+	for id, tracked := range r.tracked {
+		if known, ok := r.paths[tracked.path]; !ok || known.id != id {
+			tracked.file.Close()
+			delete(r.tracked, id)
+		}
+	}
+	return errs
+}
+
+// Close will close the files that are being read
+func (r *Reader) Close() error {
+	var err error
+	for id, tracked := range r.tracked {
+		if closeErr := tracked.file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(r.tracked, id)
+	}
+	r.paths = make(map[string]*trackedPath)
+	return err
+}
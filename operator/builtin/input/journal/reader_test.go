@@ -0,0 +1,170 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func messages(entries []*Entry) []interface{} {
+	messages := []interface{}{}
+	for _, entry := range entries {
+		messages = append(messages, entry.Fields["MESSAGE"])
+	}
+	return messages
+}
+
+func TestReader(t *testing.T) {
+	poll := func(t *testing.T, r *Reader) []*Entry {
+		entries, errs := r.Poll()
+		require.Empty(t, errs)
+		return entries
+	}
+
+	t.Run("Directory", func(t *testing.T) {
+		dir := testutil.NewTempDir(t)
+		machineDir := filepath.Join(dir, "fed6b2924c424cf1b9a322f606b4de6d")
+		require.NoError(t, os.Mkdir(machineDir, 0755))
+		copyFile(t, "testdata/compact-partial.journal", filepath.Join(machineDir, "system.journal"))
+		copyFile(t, "testdata/regular.journal", filepath.Join(dir, "user.journal"))
+		copyFile(t, "testdata/compact.journal", filepath.Join(dir, "other.log"))
+
+		r := NewDirectoryReader(dir)
+		defer r.Close()
+
+		// Entries of all files are ordered by their timestamp
+		entries := poll(t, r)
+		require.Len(t, entries, 16)
+		for i := 1; i < len(entries); i++ {
+			require.True(t, entries[i-1].Realtime <= entries[i].Realtime)
+		}
+		require.Equal(t, "Journal started", entries[0].Fields["MESSAGE"])
+		require.Equal(t, "second batch 2", entries[15].Fields["MESSAGE"])
+
+		require.Empty(t, poll(t, r))
+
+		copyFile(t, "testdata/compact.journal", filepath.Join(machineDir, "system.journal"))
+		require.Equal(t, []interface{}{"second batch 1", "second batch 2"}, messages(poll(t, r)))
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		dir := testutil.NewTempDir(t)
+		path := filepath.Join(dir, "system.journal")
+		copyFile(t, "testdata/compact-partial.journal", path)
+
+		r := NewFilesReader([]string{filepath.Join(dir, "*.journal")})
+		defer r.Close()
+		require.Len(t, poll(t, r), 7)
+
+		// The rotated file is not read again, and entries written before it was rotated are still read
+		copyFile(t, "testdata/compact.journal", path)
+		require.NoError(t, os.Rename(path, filepath.Join(dir, "system@0001.journal")))
+		copyFile(t, "testdata/regular.journal", path)
+		entries := poll(t, r)
+		require.Len(t, entries, 11)
+		require.Equal(t, "second batch 1", entries[0].Fields["MESSAGE"])
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "system@0001.journal")))
+		require.Empty(t, poll(t, r))
+		require.Len(t, r.tracked, 1)
+	})
+
+	t.Run("SeekAfter", func(t *testing.T) {
+		cursor, err := ParseCursor("s=8a4f067825344c19afe5c05a81e1290d;i=7;b=c37fccf56d5442888bc01b4b0e0dc365;m=195bacb67;t=65e1ed28a90fa;x=e14f33cd404136f0")
+		require.NoError(t, err)
+
+		r := NewFilesReader([]string{"testdata/compact.journal", "testdata/regular.journal"})
+		defer r.Close()
+		r.SeekAfter(cursor)
+
+		// Entries of the same sequence are found by sequence number, and entries of other sequences by timestamp
+		entries := poll(t, r)
+		require.Len(t, entries, 11)
+		require.Equal(t, "second batch 1", entries[0].Fields["MESSAGE"])
+		require.Equal(t, uint64(8), entries[0].Seqnum)
+		require.Equal(t, "Journal started", entries[2].Fields["MESSAGE"])
+	})
+
+	t.Run("SeekEnd", func(t *testing.T) {
+		dir := testutil.NewTempDir(t)
+		path := filepath.Join(dir, "system.journal")
+		copyFile(t, "testdata/compact-partial.journal", path)
+
+		r := NewDirectoryReader(dir)
+		defer r.Close()
+		r.SeekEnd()
+		require.Empty(t, poll(t, r))
+
+		copyFile(t, "testdata/compact.journal", path)
+		require.Equal(t, []interface{}{"second batch 1", "second batch 2"}, messages(poll(t, r)))
+	})
+
+	t.Run("PollLimit", func(t *testing.T) {
+		r := NewFilesReader([]string{"testdata/compact.journal", "testdata/regular.journal"})
+		defer r.Close()
+		r.SetPollLimit(5)
+
+		// The entries of all files are still ordered by their timestamp across polls
+		var entries []*Entry
+		for _, expected := range []int{5, 5, 5, 3, 0} {
+			polled := poll(t, r)
+			require.Len(t, polled, expected)
+			entries = append(entries, polled...)
+		}
+		for i := 1; i < len(entries); i++ {
+			require.True(t, entries[i-1].Realtime <= entries[i].Realtime)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		r := NewFilesReader([]string{"testdata/compact.journal"})
+		defer r.Close()
+		r.SetFilter(&Filter{Units: []string{"app.service"}})
+		require.Equal(t, []interface{}{"first message", "binary field", "second batch 1"}, messages(poll(t, r)))
+	})
+
+	t.Run("InvalidFile", func(t *testing.T) {
+		dir := testutil.NewTempDir(t)
+		copyFile(t, "reader_test.go", filepath.Join(dir, "invalid.journal"))
+
+		r := NewDirectoryReader(dir)
+		defer r.Close()
+
+		entries, errs := r.Poll()
+		require.Empty(t, entries)
+		require.Len(t, errs, 1)
+
+		// Invalid files are only reported once
+		entries, errs = r.Poll()
+		require.Empty(t, entries)
+		require.Empty(t, errs)
+	})
+}
+
+func TestCursor(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		value := "s=8a4f067825344c19afe5c05a81e1290d;i=3;b=c37fccf56d5442888bc01b4b0e0dc365;m=195bac261;t=65e1ed28a87f4;x=ef6ef122dd1e0fbd"
+		cursor, err := ParseCursor(value)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), cursor.Seqnum)
+		require.Equal(t, uint64(1792336334587892), cursor.Realtime)
+		require.Equal(t, value, cursor.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := []string{
+			"",
+			"s=8a4f067825344c19afe5c05a81e1290d",
+			"s=8a4f;i=3",
+			"i=3;t=zz",
+			"invalid",
+		}
+		for _, value := range cases {
+			_, err := ParseCursor(value)
+			require.Error(t, err, value)
+		}
+	})
+}
@@ -10,12 +10,14 @@ import (
 	"io"
 	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/observiq/carbon/entry"
 	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/journal"
 	"github.com/observiq/carbon/operator/helper"
 	"go.uber.org/zap"
 )
@@ -24,9 +26,29 @@ func init() {
 	operator.Register("journald_input", func() operator.Builder { return NewJournaldInputConfig("") })
 }
 
+// Readers supported by the journald input
+const (
+	journaldReaderJournalctl = "journalctl"
+	journaldReaderNative     = "native"
+)
+
+// journaldPriorities are the names of the syslog priorities, which journalctl also accepts
+var journaldPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// journaldSeverities maps syslog priorities to entry severities
+var journaldSeverities = []entry.Severity{
+	entry.Emergency, entry.Alert, entry.Critical, entry.Error, entry.Warning, entry.Notice, entry.Info, entry.Debug,
+}
+
+// defaultJournalDirectories are the directories that journald writes to, and that journalctl reads by default
+var defaultJournalDirectories = []string{"/run/log/journal", "/var/log/journal"}
+
 func NewJournaldInputConfig(operatorID string) *JournaldInputConfig {
 	return &JournaldInputConfig{
-		InputConfig: helper.NewInputConfig(operatorID, "journald_input"),
+		InputConfig:  helper.NewInputConfig(operatorID, "journald_input"),
+		Reader:       journaldReaderJournalctl,
+		PollInterval: operator.Duration{Duration: 200 * time.Millisecond},
+		StartAt:      "end",
 	}
 }
 
@@ -34,8 +56,14 @@ func NewJournaldInputConfig(operatorID string) *JournaldInputConfig {
 type JournaldInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	Directory *string  `json:"directory,omitempty" yaml:"directory,omitempty"`
-	Files     []string `json:"files,omitempty"     yaml:"files,omitempty"`
+	Directory    *string           `json:"directory,omitempty"     yaml:"directory,omitempty"`
+	Files        []string          `json:"files,omitempty"         yaml:"files,omitempty"`
+	Reader       string            `json:"reader,omitempty"        yaml:"reader,omitempty"`
+	PollInterval operator.Duration `json:"poll_interval,omitempty" yaml:"poll_interval,omitempty"`
+	Units        []string          `json:"units,omitempty"         yaml:"units,omitempty"`
+	Priority     string            `json:"priority,omitempty"      yaml:"priority,omitempty"`
+	Matches      []string          `json:"matches,omitempty"       yaml:"matches,omitempty"`
+	StartAt      string            `json:"start_at,omitempty"      yaml:"start_at,omitempty"`
 }
 
 // Build will build a journald input operator from the supplied configuration
@@ -45,6 +73,49 @@ func (c JournaldInputConfig) Build(buildContext operator.BuildContext) (operator
 		return nil, err
 	}
 
+	var startAtBeginning bool
+	switch c.StartAt {
+	case "beginning":
+		startAtBeginning = true
+	case "end":
+	default:
+		return nil, fmt.Errorf("invalid start_at location '%s'", c.StartAt)
+	}
+
+	filter, err := c.buildFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	journaldInput := &JournaldInput{
+		InputOperator:    inputOperator,
+		persist:          helper.NewScopedDBPersister(buildContext.Database, c.ID()),
+		json:             jsoniter.ConfigFastest,
+		startAtBeginning: startAtBeginning,
+	}
+
+	switch c.Reader {
+	case journaldReaderJournalctl:
+	case journaldReaderNative:
+		if c.PollInterval.Raw() <= 0 {
+			return nil, fmt.Errorf("poll_interval must be greater than 0")
+		}
+		journaldInput.pollInterval = c.PollInterval.Raw()
+
+		switch {
+		case c.Directory != nil:
+			journaldInput.reader = journal.NewDirectoryReader(*c.Directory)
+		case len(c.Files) > 0:
+			journaldInput.reader = journal.NewFilesReader(c.Files)
+		default:
+			journaldInput.reader = journal.NewDirectoryReader(defaultJournalDirectories...)
+		}
+		journaldInput.reader.SetFilter(filter)
+		return journaldInput, nil
+	default:
+		return nil, fmt.Errorf("invalid reader '%s'", c.Reader)
+	}
+
 	args := make([]string, 0, 10)
 
 	// Export logs in UTC time
@@ -65,26 +136,129 @@ func (c JournaldInputConfig) Build(buildContext operator.BuildContext) (operator
 		}
 	}
 
-	journaldInput := &JournaldInput{
-		InputOperator: inputOperator,
-		persist:       helper.NewScopedDBPersister(buildContext.Database, c.ID()),
-		newCmd: func(ctx context.Context, cursor []byte) cmd {
-			if cursor != nil {
-				args = append(args, "--after-cursor", string(cursor))
-			}
-			return exec.CommandContext(ctx, "journalctl", args...)
-		},
-		json: jsoniter.ConfigFastest,
+	for _, unit := range filter.Units {
+		args = append(args, "--unit", unit)
+	}
+
+	if filter.Priority != nil {
+		args = append(args, fmt.Sprintf("--priority=%d..%d", filter.Priority.Min, filter.Priority.Max))
+	}
+
+	// Matches of the same field are alternatives, and matches of different fields must all match
+	args = append(args, c.Matches...)
+
+	journaldInput.newCmd = func(ctx context.Context, cursor []byte) cmd {
+		cmdArgs := append([]string{}, args...)
+		switch {
+		case cursor != nil:
+			cmdArgs = append(cmdArgs, "--after-cursor", string(cursor))
+		case startAtBeginning:
+			cmdArgs = append(cmdArgs, "--no-tail")
+		default:
+			cmdArgs = append(cmdArgs, "--lines=0")
+		}
+		return exec.CommandContext(ctx, "journalctl", cmdArgs...)
 	}
 	return journaldInput, nil
 }
 
+// buildFilter will build the filter that selects the entries to read
+func (c JournaldInputConfig) buildFilter() (*journal.Filter, error) {
+	filter := &journal.Filter{
+		Matches: make(map[string][]string),
+	}
+
+	for _, unit := range c.Units {
+		if unit == "" {
+			return nil, fmt.Errorf("units can not contain an empty unit")
+		}
+		// Units without a type are services, as they are for journalctl
+		if !strings.Contains(unit, ".") {
+			unit += ".service"
+		}
+		filter.Units = append(filter.Units, unit)
+	}
+
+	if c.Priority != "" {
+		priority, err := parsePriorityRange(c.Priority)
+		if err != nil {
+			return nil, err
+		}
+		filter.Priority = priority
+	}
+
+	for _, match := range c.Matches {
+		fieldValue := strings.SplitN(match, "=", 2)
+		if len(fieldValue) != 2 || !validJournalField(fieldValue[0]) {
+			return nil, fmt.Errorf("invalid match '%s', expected FIELD=value", match)
+		}
+		filter.Matches[fieldValue[0]] = append(filter.Matches[fieldValue[0]], fieldValue[1])
+	}
+
+	return filter, nil
+}
+
+// parsePriorityRange will parse a priority, or a range of priorities separated by "..". A single priority
+// selects that priority and the more important priorities, as it does for journalctl.
+func parsePriorityRange(value string) (*journal.PriorityRange, error) {
+	bounds := strings.SplitN(value, "..", 2)
+	if len(bounds) == 1 {
+		max, err := parsePriority(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		return &journal.PriorityRange{Min: 0, Max: max}, nil
+	}
+
+	first, err := parsePriority(bounds[0])
+	if err != nil {
+		return nil, err
+	}
+	second, err := parsePriority(bounds[1])
+	if err != nil {
+		return nil, err
+	}
+	if first > second {
+		first, second = second, first
+	}
+	return &journal.PriorityRange{Min: first, Max: second}, nil
+}
+
+// parsePriority will parse a priority name or number
+func parsePriority(value string) (int, error) {
+	for i, name := range journaldPriorities {
+		if value == name || value == strconv.Itoa(i) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid priority '%s'", value)
+}
+
+// validJournalField will return true if a name is a valid journal field name
+func validJournalField(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
 // JournaldInput is an operator that process logs using journald
 type JournaldInput struct {
 	helper.InputOperator
 
 	newCmd func(ctx context.Context, cursor []byte) cmd
 
+	// reader is used instead of journalctl when the files are read natively
+	reader       *journal.Reader
+	pollInterval time.Duration
+
+	startAtBeginning bool
+
 	persist helper.Persister
 	json    jsoniter.API
 	cancel  context.CancelFunc
@@ -112,17 +286,6 @@ func (operator *JournaldInput) Start() error {
 	// Start from a cursor if there is a saved offset
 	cursor := operator.persist.Get(lastReadCursorKey)
 
-	// Start journalctl
-	cmd := operator.newCmd(ctx, cursor)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get journalctl stdout: %s", err)
-	}
-	err = cmd.Start()
-	if err != nil {
-		return fmt.Errorf("start journalctl: %s", err)
-	}
-
 	// Start a goroutine to periodically flush the offsets
 	operator.wg.Add(1)
 	go func() {
@@ -137,6 +300,26 @@ func (operator *JournaldInput) Start() error {
 		}
 	}()
 
+	if operator.reader != nil {
+		operator.startNative(ctx, cursor)
+		return nil
+	}
+	return operator.startJournalctl(ctx, cursor)
+}
+
+// startJournalctl will start reading entries from the output of journalctl
+func (operator *JournaldInput) startJournalctl(ctx context.Context, cursor []byte) error {
+	// Start journalctl
+	cmd := operator.newCmd(ctx, cursor)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get journalctl stdout: %s", err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("start journalctl: %s", err)
+	}
+
 	// Start the reader goroutine
 	operator.wg.Add(1)
 	go func() {
@@ -167,6 +350,75 @@ func (operator *JournaldInput) Start() error {
 	return nil
 }
 
+// startNative will start polling the journal files for entries
+func (operator *JournaldInput) startNative(ctx context.Context, cursor []byte) {
+	var after *journal.Cursor
+	if cursor != nil {
+		var err error
+		after, err = journal.ParseCursor(string(cursor))
+		if err != nil {
+			operator.Warnw("Ignoring saved cursor that is invalid", zap.Error(err))
+		}
+	}
+
+	switch {
+	case after != nil:
+		operator.reader.SeekAfter(after)
+	case !operator.startAtBeginning:
+		operator.reader.SeekEnd()
+	}
+
+	operator.wg.Add(1)
+	go func() {
+		defer operator.wg.Done()
+		defer operator.syncOffsets()
+
+		ticker := time.NewTicker(operator.pollInterval)
+		defer ticker.Stop()
+		for {
+			// A poll that returns as many entries as it can is followed by another without waiting,
+			// so that a backlog is read as quickly as the outputs accept it
+			if operator.poll(ctx) && ctx.Err() == nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// poll will write the entries added to the journal files since the last poll. It returns true if the
+// poll was limited, and there may be more entries to read.
+func (operator *JournaldInput) poll(ctx context.Context) bool {
+	journalEntries, errs := operator.reader.Poll()
+	for _, err := range errs {
+		operator.Warnw("Failed to read journal file", zap.Error(err))
+	}
+
+	for _, journalEntry := range journalEntries {
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+		}
+
+		// The record has the same fields as the JSON output of journalctl
+		cursor := journalEntry.Cursor()
+		record := journalEntry.Fields
+		record["__CURSOR"] = cursor
+		record["__MONOTONIC_TIMESTAMP"] = strconv.FormatUint(journalEntry.Monotonic, 10)
+
+		entry := operator.newEntry(record, time.Unix(0, int64(journalEntry.Realtime)*1000)) // in microseconds
+		operator.persist.Set(lastReadCursorKey, []byte(cursor))
+		operator.Write(ctx, entry)
+	}
+	return len(journalEntries) >= operator.reader.PollLimit()
+}
+
 func (operator *JournaldInput) parseJournalEntry(line []byte) (*entry.Entry, string, error) {
 	var record map[string]interface{}
 	err := operator.json.Unmarshal(line, &record)
@@ -201,11 +453,35 @@ func (operator *JournaldInput) parseJournalEntry(line []byte) (*entry.Entry, str
 		return nil, "", errors.New("journald field for cursor is not a string")
 	}
 
-	entry := operator.NewEntry(record)
-	entry.Timestamp = time.Unix(0, timestampInt*1000) // in microseconds
+	entry := operator.newEntry(record, time.Unix(0, timestampInt*1000)) // in microseconds
 	return entry, cursorString, nil
 }
 
+// newEntry will create an entry from the fields of a journal entry. The priority and unit are moved from
+// the record to the severity and labels of the entry.
+func (operator *JournaldInput) newEntry(record map[string]interface{}, timestamp time.Time) *entry.Entry {
+	severity := entry.Default
+	if priority, ok := record["PRIORITY"].(string); ok {
+		if value, err := strconv.Atoi(priority); err == nil && value >= 0 && value < len(journaldSeverities) {
+			severity = journaldSeverities[value]
+			delete(record, "PRIORITY")
+		}
+	}
+
+	unit, hasUnit := record["_SYSTEMD_UNIT"].(string)
+	if hasUnit {
+		delete(record, "_SYSTEMD_UNIT")
+	}
+
+	e := operator.NewEntry(record)
+	e.Timestamp = timestamp
+	e.Severity = severity
+	if hasUnit {
+		e.AddLabel("unit", unit)
+	}
+	return e
+}
+
 func (operator *JournaldInput) syncOffsets() {
 	err := operator.persist.Sync()
 	if err != nil {
@@ -217,5 +493,8 @@ func (operator *JournaldInput) syncOffsets() {
 func (operator *JournaldInput) Stop() error {
 	operator.cancel()
 	operator.wg.Wait()
+	if operator.reader != nil {
+		return operator.reader.Close()
+	}
 	return nil
 }
@@ -7,6 +7,9 @@ import (
 	"context"
 	"io"
 	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -52,6 +55,7 @@ func TestInputJournald(t *testing.T) {
 
 	err = journaldInput.Start()
 	require.NoError(t, err)
+	defer journaldInput.Stop()
 
 	expected := map[string]interface{}{
 		"_BOOT_ID":                   "c4fa36de06824d21835c05ff80c54468",
@@ -81,9 +85,7 @@ func TestInputJournald(t *testing.T) {
 		"USER_INVOCATION_ID":         "88f7ca6bbf244dc8828fa901f9fe9be1",
 		"CODE_LINE":                  "5487",
 		"_SYSTEMD_INVOCATION_ID":     "83f7fc7799064520b26eb6de1630429c",
-		"PRIORITY":                   "6",
 		"_GID":                       "1000",
-		"_SYSTEMD_UNIT":              "user@1000.service",
 		"_SYSTEMD_USER_SLICE":        "-.slice",
 		"__CURSOR":                   "s=b1e713b587ae4001a9ca482c4b12c005;i=1eed30;b=c4fa36de06824d21835c05ff80c54468;m=9f9d630205;t=5a369604ee333;x=16c2d4fd4fdb7c36",
 		"__MONOTONIC_TIMESTAMP":      "685540311557",
@@ -93,7 +95,212 @@ func TestInputJournald(t *testing.T) {
 	select {
 	case e := <-received:
 		require.Equal(t, expected, e.Record)
+		require.Equal(t, entry.Info, e.Severity)
+		require.Equal(t, map[string]string{"unit": "user@1000.service"}, e.Labels)
+		require.Equal(t, time.Unix(0, 1587047866229555000), e.Timestamp)
 	case <-time.After(time.Second):
 		require.FailNow(t, "Timed out waiting for entry to be read")
 	}
 }
+
+func TestInputJournaldNative(t *testing.T) {
+	dir := testutil.NewTempDir(t)
+	path := filepath.Join(dir, "system.journal")
+	copyJournal := func(src string) {
+		contents, err := ioutil.ReadFile(filepath.Join("journal", "testdata", src))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, contents, 0600))
+	}
+	copyJournal("compact-partial.journal")
+
+	buildContext := testutil.NewBuildContext(t)
+	cfg := NewJournaldInputConfig("my_journald_input")
+	cfg.OutputIDs = []string{"output"}
+	cfg.Reader = "native"
+	cfg.Directory = &dir
+	cfg.StartAt = "beginning"
+
+	received := make(chan *entry.Entry, 10)
+	startJournaldInput := func() operator.Operator {
+		journaldInput, err := cfg.Build(buildContext)
+		require.NoError(t, err)
+
+		mockOutput := testutil.NewMockOperator("output")
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			received <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+		require.NoError(t, journaldInput.SetOutputs([]operator.Operator{mockOutput}))
+		require.NoError(t, journaldInput.Start())
+		return journaldInput
+	}
+
+	expectMessages := func(expected ...string) []*entry.Entry {
+		var entries []*entry.Entry
+		for _, message := range expected {
+			select {
+			case e := <-received:
+				require.Equal(t, message, e.Record.(map[string]interface{})["MESSAGE"])
+				entries = append(entries, e)
+			case <-time.After(time.Second):
+				require.FailNow(t, "Timed out waiting for entry to be read")
+			}
+		}
+		select {
+		case e := <-received:
+			require.FailNow(t, "Unexpected entry", e.Record)
+		case <-time.After(100 * time.Millisecond):
+		}
+		return entries
+	}
+
+	journaldInput := startJournaldInput()
+	entries := expectMessages("Journal started", "Runtime Journal (/run/log/journal/fed6b2924c424cf1b9a322f606b4de6d) is 512.0K, max 4.0M, 3.5M free.",
+		"first message", "multi\nline message", "binary field", "duplicate fields", "large "+strings.Repeat("x", 2000))
+
+	record := entries[2].Record.(map[string]interface{})
+	require.Equal(t, "s=8a4f067825344c19afe5c05a81e1290d;i=3;b=c37fccf56d5442888bc01b4b0e0dc365;m=195bac261;t=65e1ed28a87f4;x=ef6ef122dd1e0fbd", record["__CURSOR"])
+	require.Equal(t, "6807011937", record["__MONOTONIC_TIMESTAMP"])
+	require.Equal(t, "carbon-test", record["SYSLOG_IDENTIFIER"])
+	require.NotContains(t, record, "PRIORITY")
+	require.Equal(t, entry.Info, entries[2].Severity)
+	require.Equal(t, entry.Error, entries[3].Severity)
+	require.Equal(t, time.Unix(0, 1792336334587892000), entries[2].Timestamp)
+	require.Equal(t, []byte{0x00, 0x01, 0xfe, 0xff}, entries[4].Record.(map[string]interface{})["BINARY"])
+
+	// Entries written while the operator was stopped are read after the saved cursor
+	require.NoError(t, journaldInput.Stop())
+	copyJournal("compact.journal")
+	journaldInput = startJournaldInput()
+	defer journaldInput.Stop()
+	expectMessages("second batch 1", "second batch 2")
+}
+
+func TestInputJournaldNativeFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		modify   func(*JournaldInputConfig)
+		expected []string
+	}{
+		{
+			"StartAtEnd",
+			func(c *JournaldInputConfig) { c.StartAt = "end" },
+			[]string{"second batch 1", "second batch 2"},
+		},
+		{
+			"Units",
+			func(c *JournaldInputConfig) { c.Units = []string{"db"} },
+			[]string{"multi\nline message", "large " + strings.Repeat("x", 2000), "second batch 2"},
+		},
+		{
+			"Priority",
+			func(c *JournaldInputConfig) { c.Priority = "crit..warning" },
+			[]string{"multi\nline message", "binary field", "second batch 1"},
+		},
+		{
+			"Matches",
+			func(c *JournaldInputConfig) {
+				c.Matches = []string{"SYSLOG_IDENTIFIER=other", "SYSLOG_IDENTIFIER=systemd-journald", "PRIORITY=6"}
+			},
+			[]string{"Journal started", "Runtime Journal (/run/log/journal/fed6b2924c424cf1b9a322f606b4de6d) is 512.0K, max 4.0M, 3.5M free.", "second batch 2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := testutil.NewTempDir(t)
+			path := filepath.Join(dir, "system.journal")
+			copyJournal := func(src string) {
+				contents, err := ioutil.ReadFile(filepath.Join("journal", "testdata", src))
+				require.NoError(t, err)
+				require.NoError(t, ioutil.WriteFile(path, contents, 0600))
+			}
+			copyJournal("compact-partial.journal")
+
+			cfg := NewJournaldInputConfig("my_journald_input")
+			cfg.OutputIDs = []string{"output"}
+			cfg.Reader = "native"
+			cfg.Files = []string{path}
+			cfg.StartAt = "beginning"
+			tc.modify(cfg)
+
+			journaldInput, err := cfg.Build(testutil.NewBuildContext(t))
+			require.NoError(t, err)
+
+			received := make(chan *entry.Entry, 10)
+			mockOutput := testutil.NewMockOperator("output")
+			mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				received <- args.Get(1).(*entry.Entry)
+			}).Return(nil)
+			require.NoError(t, journaldInput.SetOutputs([]operator.Operator{mockOutput}))
+			require.NoError(t, journaldInput.Start())
+			defer journaldInput.Stop()
+
+			// Entries are written after the first poll, so that start_at end is tested
+			time.Sleep(100 * time.Millisecond)
+			copyJournal("compact.journal")
+
+			messages := []string{}
+			for range tc.expected {
+				select {
+				case e := <-received:
+					messages = append(messages, e.Record.(map[string]interface{})["MESSAGE"].(string))
+				case <-time.After(time.Second):
+					require.FailNow(t, "Timed out waiting for entry to be read", messages)
+				}
+			}
+			require.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestInputJournaldArgs(t *testing.T) {
+	cfg := NewJournaldInputConfig("my_journald_input")
+	cfg.Units = []string{"nginx", "docker.socket"}
+	cfg.Priority = "warning"
+	cfg.Matches = []string{"_TRANSPORT=kernel"}
+
+	journaldInput, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	newCmd := journaldInput.(*JournaldInput).newCmd
+	expected := []string{
+		"journalctl", "--utc", "--output=json", "--follow", "--unit", "nginx.service", "--unit", "docker.socket",
+		"--priority=0..4", "_TRANSPORT=kernel",
+	}
+	require.Equal(t, append(expected, "--lines=0"), newCmd(context.Background(), nil).(*exec.Cmd).Args)
+	require.Equal(t, append(expected, "--after-cursor", "s=1"), newCmd(context.Background(), []byte("s=1")).(*exec.Cmd).Args)
+}
+
+func TestInputJournaldInvalidConfig(t *testing.T) {
+	cfg := NewJournaldInputConfig("my_journald_input")
+	cfg.Reader = "sd-journal"
+	_, err := cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+
+	cfg = NewJournaldInputConfig("my_journald_input")
+	cfg.Reader = "native"
+	cfg.PollInterval = operator.Duration{}
+	_, err = cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+
+	cases := []struct {
+		name   string
+		modify func(*JournaldInputConfig)
+	}{
+		{"InvalidStartAt", func(c *JournaldInputConfig) { c.StartAt = "middle" }},
+		{"EmptyUnit", func(c *JournaldInputConfig) { c.Units = []string{""} }},
+		{"InvalidPriority", func(c *JournaldInputConfig) { c.Priority = "loud" }},
+		{"InvalidPriorityRange", func(c *JournaldInputConfig) { c.Priority = "err..8" }},
+		{"MatchWithoutValue", func(c *JournaldInputConfig) { c.Matches = []string{"_TRANSPORT"} }},
+		{"InvalidMatchField", func(c *JournaldInputConfig) { c.Matches = []string{"transport=kernel"} }},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewJournaldInputConfig("my_journald_input")
+			tc.modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			require.Error(t, err)
+		})
+	}
+}
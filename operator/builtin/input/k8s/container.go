@@ -0,0 +1,323 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/errors"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/file"
+	"github.com/observiq/carbon/operator/builtin/parser"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func init() {
+	operator.Register("k8s_container_input", func() operator.Builder { return NewContainerInputConfig("") })
+}
+
+func NewContainerInputConfig(operatorID string) *ContainerInputConfig {
+	return &ContainerInputConfig{
+		InputConfig:   helper.NewInputConfig(operatorID, "k8s_container_input"),
+		PodsDirectory: "/var/log/pods",
+		PollInterval:  operator.Duration{Duration: 200 * time.Millisecond},
+		StartAt:       "end",
+		MaxLogSize:    1024 * 1024,
+	}
+}
+
+// ContainerInputConfig is the configuration of a kubernetes container input operator
+type ContainerInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	PodsDirectory     string   `json:"pods_directory,omitempty"     yaml:"pods_directory,omitempty"`
+	Namespaces        []string `json:"namespaces,omitempty"         yaml:"namespaces,omitempty"`
+	ExcludeNamespaces []string `json:"exclude_namespaces,omitempty" yaml:"exclude_namespaces,omitempty"`
+	LabelSelector     string   `json:"label_selector,omitempty"     yaml:"label_selector,omitempty"`
+
+	PollInterval operator.Duration `json:"poll_interval,omitempty" yaml:"poll_interval,omitempty"`
+	StartAt      string            `json:"start_at,omitempty"      yaml:"start_at,omitempty"`
+	MaxLogSize   int               `json:"max_log_size,omitempty"  yaml:"max_log_size,omitempty"`
+}
+
+// Build will build a kubernetes container input operator from the supplied configuration
+func (c ContainerInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.PodsDirectory == "" {
+		return nil, fmt.Errorf("required argument `pods_directory` is empty")
+	}
+	podsDirectory := filepath.Clean(c.PodsDirectory)
+
+	for _, namespaces := range [][]string{c.Namespaces, c.ExcludeNamespaces} {
+		for _, namespace := range namespaces {
+			if !namespacePattern.MatchString(namespace) {
+				return nil, fmt.Errorf("invalid namespace '%s'", namespace)
+			}
+		}
+	}
+
+	var selector labels.Selector
+	if c.LabelSelector != "" {
+		selector, err = labels.Parse(c.LabelSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse label_selector")
+		}
+	}
+
+	// Entries are read from files by a file input, and parsed by a container parser
+	// before they are filtered by pod and written to the outputs of this operator
+	fileConfig := file.NewInputConfig(c.ID())
+	fileConfig.Include = logPatterns(podsDirectory, c.Namespaces)
+	fileConfig.Exclude = logPatterns(podsDirectory, c.ExcludeNamespaces)
+	if len(fileConfig.Include) == 0 {
+		fileConfig.Include = logPatterns(podsDirectory, []string{"*"})
+	}
+	fileConfig.PollInterval = c.PollInterval
+	fileConfig.StartAt = c.StartAt
+	fileConfig.MaxLogSize = c.MaxLogSize
+	fileConfig.FilePathField = entry.NewLabelField("file_path")
+	fileConfig.PathPattern = fmt.Sprintf(
+		`^%s/(?P<namespace>[^_/]+)_(?P<pod_name>[^_/]+)_(?P<pod_uid>[^_/]+)/(?P<container>[^/]+)/`,
+		regexp.QuoteMeta(filepath.ToSlash(podsDirectory)),
+	)
+	fileConfig.PathFields = make(map[string]entry.Field)
+	for _, name := range []string{"namespace", "pod_name", "pod_uid", "container"} {
+		fileConfig.PathFields[name] = entry.NewLabelField(name)
+	}
+	fileOperator, err := fileConfig.Build(context)
+	if err != nil {
+		return nil, errors.Wrap(err, "build file input")
+	}
+
+	parserConfig := parser.NewContainerParserConfig(c.ID())
+	parserConfig.ParseTo = c.WriteTo
+	parserConfig.SourceField = fileConfig.FilePathField
+	parserConfig.MaxLogSize = c.MaxLogSize
+	parserOperator, err := parserConfig.Build(context)
+	if err != nil {
+		return nil, errors.Wrap(err, "build container parser")
+	}
+
+	fileInput := fileOperator.(*file.InputOperator)
+	containerInput := &ContainerInput{
+		InputOperator:   inputOperator,
+		podsDirectory:   podsDirectory,
+		selector:        selector,
+		fileInput:       fileInput,
+		parser:          parserOperator.(*parser.ContainerParser),
+		forget:          fileInput.Forget,
+		cleanupInterval: 10 * time.Second,
+		selectedPods:    make(map[string]bool),
+	}
+	return containerInput, nil
+}
+
+// namespacePattern matches valid namespace names, which can not contain glob syntax
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// logPatterns will return the patterns matching the log files of containers in the namespaces
+func logPatterns(podsDirectory string, namespaces []string) []string {
+	var patterns []string
+	for _, namespace := range namespaces {
+		patterns = append(patterns, filepath.Join(podsDirectory, namespace+"_*_*", "*", "*.log"))
+	}
+	return patterns
+}
+
+// ContainerInput is an operator that reads the logs of the containers running on a kubernetes node
+type ContainerInput struct {
+	helper.InputOperator
+
+	podsDirectory string
+	selector      labels.Selector
+	client        kubernetes.Interface
+
+	fileInput *file.InputOperator
+	parser    *parser.ContainerParser
+	filter    *podFilter
+
+	// forget is called with the log directory of each pod that was removed
+	forget          func(dir string)
+	cleanupInterval time.Duration
+	podDirs         map[string]string
+
+	selectedPods map[string]bool
+	selectedMux  sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start will start reading the logs of containers
+func (k *ContainerInput) Start() error {
+	if k.selector != nil && k.client == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return errors.NewError(
+				"agent not in kubernetes cluster",
+				"the label_selector parameter of the k8s_container_input operator is only supported in a pod inside a kubernetes cluster",
+			)
+		}
+
+		k.client, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return errors.Wrap(err, "build client")
+		}
+	}
+
+	k.filter = &podFilter{
+		WriterOperator: k.WriterOperator,
+		input:          k,
+	}
+	k.parser.OutputOperators = []operator.Operator{k.filter}
+	k.fileInput.OutputOperators = []operator.Operator{k.parser}
+
+	if err := k.parser.Start(); err != nil {
+		return errors.Wrap(err, "start container parser")
+	}
+	if err := k.fileInput.Start(); err != nil {
+		_ = k.parser.Stop()
+		return errors.Wrap(err, "start file input")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.podDirs = listPodDirs(k.podsDirectory)
+
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		ticker := time.NewTicker(k.cleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				k.cleanupRemovedPods()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop will stop reading the logs of containers
+func (k *ContainerInput) Stop() error {
+	k.cancel()
+	k.wg.Wait()
+
+	// Partial entries are sent by the parser once no more lines are read
+	fileErr := k.fileInput.Stop()
+	parserErr := k.parser.Stop()
+	if fileErr != nil {
+		return fileErr
+	}
+	return parserErr
+}
+
+// Finished returns a channel that is closed once all container logs have been read, when running once
+func (k *ContainerInput) Finished() <-chan struct{} {
+	return k.fileInput.Finished()
+}
+
+// cleanupRemovedPods will forget the offsets of the log files of pods whose log directories were removed
+func (k *ContainerInput) cleanupRemovedPods() {
+	podDirs := listPodDirs(k.podsDirectory)
+	for dir, uid := range k.podDirs {
+		if _, ok := podDirs[dir]; ok {
+			continue
+		}
+
+		k.Debugw("Pod log directory was removed", "path", dir)
+		k.forget(dir)
+
+		k.selectedMux.Lock()
+		delete(k.selectedPods, uid)
+		k.selectedMux.Unlock()
+	}
+	k.podDirs = podDirs
+}
+
+// isSelected will return true if the pod that an entry was read from matches the label selector
+func (k *ContainerInput) isSelected(ctx context.Context, e *entry.Entry) bool {
+	if k.selector == nil {
+		return true
+	}
+
+	namespace, name, uid := e.Labels["namespace"], e.Labels["pod_name"], e.Labels["pod_uid"]
+
+	k.selectedMux.Lock()
+	selected, ok := k.selectedPods[uid]
+	k.selectedMux.Unlock()
+	if ok {
+		return selected
+	}
+
+	pod, err := k.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case k8serrors.IsNotFound(err):
+		selected = false
+	case err != nil:
+		// The lookup is retried for the next entry, so entries are not dropped while the API is unavailable
+		k.Warnw("Failed to get pod to check label_selector", "namespace", namespace, "pod_name", name, zap.Error(err))
+		return true
+	default:
+		// A pod with the same name may have replaced the pod that wrote the logs
+		selected = string(pod.UID) == uid && k.selector.Matches(labels.Set(pod.Labels))
+	}
+
+	k.selectedMux.Lock()
+	k.selectedPods[uid] = selected
+	k.selectedMux.Unlock()
+	return selected
+}
+
+// listPodDirs will return the log directories of pods, mapped to the uid of their pod
+func listPodDirs(podsDirectory string) map[string]string {
+	podDirs := make(map[string]string)
+	infos, _ := ioutil.ReadDir(podsDirectory)
+	for _, info := range infos {
+		parts := strings.Split(info.Name(), "_")
+		if !info.IsDir() || len(parts) != 3 {
+			continue
+		}
+		podDirs[filepath.Join(podsDirectory, info.Name())] = parts[2]
+	}
+	return podDirs
+}
+
+// podFilter writes the parsed entries of selected pods to the outputs of a container input
+type podFilter struct {
+	helper.WriterOperator
+	input *ContainerInput
+}
+
+// CanProcess will always return true for a pod filter.
+func (p *podFilter) CanProcess() bool {
+	return true
+}
+
+// Process will write an entry to the outputs of the container input if its pod is selected.
+func (p *podFilter) Process(ctx context.Context, e *entry.Entry) error {
+	if p.input.isSelected(ctx, e) {
+		p.Write(ctx, e)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestContainerInput(t *testing.T, configure func(*ContainerInputConfig)) (*ContainerInput, chan *entry.Entry) {
+	cfg := NewContainerInputConfig("test")
+	cfg.PodsDirectory = testutil.NewTempDir(t)
+	cfg.PollInterval = operator.Duration{Duration: 50 * time.Millisecond}
+	cfg.StartAt = "beginning"
+	if configure != nil {
+		configure(cfg)
+	}
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	input := op.(*ContainerInput)
+
+	entryChan := make(chan *entry.Entry, 100)
+	mockOutput := testutil.NewMockOperator("output")
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		entryChan <- args.Get(1).(*entry.Entry)
+	})
+	input.OutputOperators = []operator.Operator{mockOutput}
+
+	return input, entryChan
+}
+
+// writePodLog will write lines to the log file of a container in a fake pods directory
+func writePodLog(t *testing.T, podsDirectory, namespace, pod, uid, container string, lines ...string) string {
+	dir := filepath.Join(podsDirectory, namespace+"_"+pod+"_"+uid, container)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	var contents []byte
+	for _, line := range lines {
+		contents = append(contents, line+"\n"...)
+	}
+	path := filepath.Join(dir, "0.log")
+	require.NoError(t, ioutil.WriteFile(path, contents, 0666))
+	return path
+}
+
+func waitForEntries(t *testing.T, entryChan chan *entry.Entry, count int) []*entry.Entry {
+	entries := make([]*entry.Entry, 0, count)
+	for len(entries) < count {
+		select {
+		case e := <-entryChan:
+			entries = append(entries, e)
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for entries", "received %d of %d", len(entries), count)
+		}
+	}
+
+	// Files are read concurrently, so entries are sorted by pod for comparison
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Labels["pod_name"] < entries[j].Labels["pod_name"]
+	})
+	return entries
+}
+
+func expectNoEntries(t *testing.T, entryChan chan *entry.Entry) {
+	select {
+	case e := <-entryChan:
+		require.FailNow(t, "Received unexpected entry", "%v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestContainerInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		configure func(*ContainerInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(c *ContainerInputConfig) {}, false},
+		{"Namespaces", func(c *ContainerInputConfig) { c.Namespaces = []string{"default", "kube-system"} }, false},
+		{"InvalidNamespace", func(c *ContainerInputConfig) { c.Namespaces = []string{"*"} }, true},
+		{"InvalidExcludeNamespace", func(c *ContainerInputConfig) { c.ExcludeNamespaces = []string{"Default"} }, true},
+		{"LabelSelector", func(c *ContainerInputConfig) { c.LabelSelector = "app=web,tier!=db" }, false},
+		{"InvalidLabelSelector", func(c *ContainerInputConfig) { c.LabelSelector = "app in (web" }, true},
+		{"MissingPodsDirectory", func(c *ContainerInputConfig) { c.PodsDirectory = "" }, true},
+		{"InvalidStartAt", func(c *ContainerInputConfig) { c.StartAt = "middle" }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewContainerInputConfig("test")
+			tc.configure(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestContainerInput(t *testing.T) {
+	t.Parallel()
+	input, entryChan := newTestContainerInput(t, nil)
+	podsDirectory := input.podsDirectory
+
+	criPath := writePodLog(t, podsDirectory, "default", "api-1", "uid-a", "api",
+		`2020-07-21T12:30:15.000000001Z stdout P first `,
+		`2020-07-21T12:30:15.000000002Z stdout F second`,
+	)
+	dockerPath := writePodLog(t, podsDirectory, "kube-system", "dns-1", "uid-b", "coredns",
+		`{"log":"started\n","stream":"stderr","time":"2020-07-21T12:30:16Z"}`,
+	)
+
+	require.NoError(t, input.Start())
+	defer input.Stop()
+
+	entries := waitForEntries(t, entryChan, 2)
+
+	require.Equal(t, map[string]interface{}{"log": "first second", "stream": "stdout"}, entries[0].Record)
+	require.Equal(t, time.Date(2020, 7, 21, 12, 30, 15, 1, time.UTC), entries[0].Timestamp)
+	require.Equal(t, map[string]string{
+		"namespace": "default",
+		"pod_name":  "api-1",
+		"pod_uid":   "uid-a",
+		"container": "api",
+		"file_path": criPath,
+	}, entries[0].Labels)
+
+	require.Equal(t, map[string]interface{}{"log": "started", "stream": "stderr"}, entries[1].Record)
+	require.Equal(t, map[string]string{
+		"namespace": "kube-system",
+		"pod_name":  "dns-1",
+		"pod_uid":   "uid-b",
+		"container": "coredns",
+		"file_path": dockerPath,
+	}, entries[1].Labels)
+
+	// Pods created after startup are read from the beginning
+	writePodLog(t, podsDirectory, "default", "web-1", "uid-c", "web", `2020-07-21T12:30:17Z stdout F new pod`)
+	entries = waitForEntries(t, entryChan, 1)
+	require.Equal(t, map[string]interface{}{"log": "new pod", "stream": "stdout"}, entries[0].Record)
+}
+
+func TestContainerInputNamespaces(t *testing.T) {
+	t.Parallel()
+	input, entryChan := newTestContainerInput(t, func(c *ContainerInputConfig) {
+		c.Namespaces = []string{"default", "monitoring"}
+		c.ExcludeNamespaces = []string{"monitoring"}
+	})
+	podsDirectory := input.podsDirectory
+
+	writePodLog(t, podsDirectory, "default", "api-1", "uid-a", "api", `2020-07-21T12:30:15Z stdout F included`)
+	writePodLog(t, podsDirectory, "kube-system", "dns-1", "uid-b", "coredns", `2020-07-21T12:30:15Z stdout F other namespace`)
+	writePodLog(t, podsDirectory, "monitoring", "agent-1", "uid-c", "agent", `2020-07-21T12:30:15Z stdout F excluded`)
+
+	require.NoError(t, input.Start())
+	defer input.Stop()
+
+	entries := waitForEntries(t, entryChan, 1)
+	require.Equal(t, "included", entries[0].Record.(map[string]interface{})["log"])
+	expectNoEntries(t, entryChan)
+}
+
+func newTestPod(namespace, name, uid string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       types.UID(uid),
+			Labels:    labels,
+		},
+	}
+}
+
+func TestContainerInputLabelSelector(t *testing.T) {
+	t.Parallel()
+	input, entryChan := newTestContainerInput(t, func(c *ContainerInputConfig) {
+		c.LabelSelector = "app=web"
+	})
+	podsDirectory := input.podsDirectory
+	input.client = fake.NewSimpleClientset(
+		newTestPod("default", "web-1", "uid-a", map[string]string{"app": "web"}),
+		newTestPod("default", "db-1", "uid-b", map[string]string{"app": "db"}),
+		newTestPod("default", "web-2", "uid-new", map[string]string{"app": "web"}),
+	)
+
+	writePodLog(t, podsDirectory, "default", "web-1", "uid-a", "web", `2020-07-21T12:30:15Z stdout F selected`, `2020-07-21T12:30:16Z stdout F selected again`)
+	writePodLog(t, podsDirectory, "default", "db-1", "uid-b", "db", `2020-07-21T12:30:15Z stdout F not selected`)
+	writePodLog(t, podsDirectory, "default", "web-2", "uid-old", "web", `2020-07-21T12:30:15Z stdout F replaced pod`)
+	writePodLog(t, podsDirectory, "default", "web-3", "uid-d", "web", `2020-07-21T12:30:15Z stdout F deleted pod`)
+
+	require.NoError(t, input.Start())
+	defer input.Stop()
+
+	entries := waitForEntries(t, entryChan, 2)
+	require.Equal(t, "selected", entries[0].Record.(map[string]interface{})["log"])
+	require.Equal(t, "selected again", entries[1].Record.(map[string]interface{})["log"])
+	expectNoEntries(t, entryChan)
+}
+
+func TestContainerInputLabelSelectorOutsideCluster(t *testing.T) {
+	t.Parallel()
+	input, _ := newTestContainerInput(t, func(c *ContainerInputConfig) {
+		c.LabelSelector = "app=web"
+	})
+	require.Error(t, input.Start())
+}
+
+func TestContainerInputCleanupRemovedPods(t *testing.T) {
+	t.Parallel()
+	input, entryChan := newTestContainerInput(t, func(c *ContainerInputConfig) {
+		c.LabelSelector = "app=web"
+	})
+	podsDirectory := input.podsDirectory
+	input.client = fake.NewSimpleClientset(
+		newTestPod("default", "web-1", "uid-a", map[string]string{"app": "web"}),
+		newTestPod("default", "web-2", "uid-b", map[string]string{"app": "web"}),
+	)
+	input.cleanupInterval = 50 * time.Millisecond
+
+	var forgottenMux sync.Mutex
+	var forgotten []string
+	input.forget = func(dir string) {
+		forgottenMux.Lock()
+		defer forgottenMux.Unlock()
+		forgotten = append(forgotten, dir)
+	}
+
+	removedPath := writePodLog(t, podsDirectory, "default", "web-1", "uid-a", "web", `2020-07-21T12:30:15Z stdout F removed`)
+	writePodLog(t, podsDirectory, "default", "web-2", "uid-b", "web", `2020-07-21T12:30:15Z stdout F kept`)
+
+	require.NoError(t, input.Start())
+	defer input.Stop()
+	waitForEntries(t, entryChan, 2)
+
+	removedDir := filepath.Dir(filepath.Dir(removedPath))
+	require.NoError(t, os.RemoveAll(removedDir))
+
+	require.Eventually(t, func() bool {
+		forgottenMux.Lock()
+		defer forgottenMux.Unlock()
+		return len(forgotten) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{removedDir}, forgotten)
+
+	input.selectedMux.Lock()
+	defer input.selectedMux.Unlock()
+	require.NotContains(t, input.selectedPods, "uid-a")
+	require.Contains(t, input.selectedPods, "uid-b")
+}
+
+func TestListPodDirs(t *testing.T) {
+	t.Parallel()
+	tempDir := testutil.NewTempDir(t)
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "default_web-1_uid-a"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "not-a-pod"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "default_file_uid-b"), nil, 0666))
+
+	require.Equal(t, map[string]string{
+		filepath.Join(tempDir, "default_web-1_uid-a"): "uid-a",
+	}, listPodDirs(tempDir))
+	require.Empty(t, listPodDirs(filepath.Join(tempDir, "missing")))
+}
@@ -0,0 +1,418 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/errors"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func init() {
+	operator.Register("k8s_events_input", func() operator.Builder { return NewEventsInputConfig("") })
+}
+
+func NewEventsInputConfig(operatorID string) *EventsInputConfig {
+	return &EventsInputConfig{
+		InputConfig: helper.NewInputConfig(operatorID, "k8s_events_input"),
+		StartAt:     "end",
+	}
+}
+
+// EventsInputConfig is the configuration of a kubernetes events input operator
+type EventsInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Namespaces        []string `json:"namespaces,omitempty"         yaml:"namespaces,omitempty"`
+	ExcludeNamespaces []string `json:"exclude_namespaces,omitempty" yaml:"exclude_namespaces,omitempty"`
+	StartAt           string   `json:"start_at,omitempty"           yaml:"start_at,omitempty"`
+}
+
+// Build will build a kubernetes events input operator from the supplied configuration
+func (c EventsInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespaces := range [][]string{c.Namespaces, c.ExcludeNamespaces} {
+		for _, namespace := range namespaces {
+			if !namespacePattern.MatchString(namespace) {
+				return nil, fmt.Errorf("invalid namespace '%s'", namespace)
+			}
+		}
+	}
+
+	var startAtBeginning bool
+	switch c.StartAt {
+	case "beginning":
+		startAtBeginning = true
+	case "end":
+	default:
+		return nil, fmt.Errorf("invalid start_at location '%s'", c.StartAt)
+	}
+
+	// All namespaces are watched at once, unless namespaces are selected
+	namespaces := c.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	excluded := make(map[string]bool)
+	for _, namespace := range c.ExcludeNamespaces {
+		excluded[namespace] = true
+	}
+
+	eventsInput := &EventsInput{
+		InputOperator:    inputOperator,
+		namespaces:       namespaces,
+		excluded:         excluded,
+		startAtBeginning: startAtBeginning,
+		persist:          helper.NewScopedDBPersister(context.Database, c.ID()),
+		retryInterval:    5 * time.Second,
+		syncInterval:     time.Second,
+	}
+	return eventsInput, nil
+}
+
+// EventsInput is an operator that watches the events of a kubernetes cluster
+type EventsInput struct {
+	helper.InputOperator
+
+	namespaces       []string
+	excluded         map[string]bool
+	startAtBeginning bool
+	client           kubernetes.Interface
+	persist          helper.Persister
+
+	retryInterval time.Duration
+	syncInterval  time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start will start watching events
+func (k *EventsInput) Start() error {
+	if k.client == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return errors.NewError(
+				"agent not in kubernetes cluster",
+				"the k8s_events_input operator only supports running in a pod inside a kubernetes cluster",
+			)
+		}
+
+		k.client, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return errors.Wrap(err, "build client")
+		}
+	}
+
+	if err := k.persist.Load(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+
+	for _, namespace := range k.namespaces {
+		k.wg.Add(1)
+		go func(namespace string) {
+			defer k.wg.Done()
+			k.watchNamespace(ctx, namespace)
+		}(namespace)
+	}
+
+	// The resource versions are flushed periodically, and when the operator stops
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		ticker := time.NewTicker(k.syncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				k.syncResourceVersions()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop will stop watching events
+func (k *EventsInput) Stop() error {
+	k.cancel()
+	k.wg.Wait()
+	return k.persist.Sync()
+}
+
+func (k *EventsInput) syncResourceVersions() {
+	if err := k.persist.Sync(); err != nil {
+		k.Errorw("Failed to sync resource versions", zap.Error(err))
+	}
+}
+
+// resourceVersionKey will return the key of the resource version a namespace is watched from
+func resourceVersionKey(namespace string) string {
+	if namespace == metav1.NamespaceAll {
+		return "resourceVersion"
+	}
+	return "resourceVersion." + namespace
+}
+
+// watchNamespace will write the events of a namespace until the context is done. Events are
+// watched from the saved resource version, or from the version of a list of the current events.
+func (k *EventsInput) watchNamespace(ctx context.Context, namespace string) {
+	key := resourceVersionKey(namespace)
+	resourceVersion := string(k.persist.Get(key))
+
+	// The current events are only written if the operator starts at the beginning, or the
+	// resource version expired, in which case they are written if they are newer than the last event
+	writeListed := k.startAtBeginning
+	var lastTimestamp time.Time
+
+	for {
+		if resourceVersion == "" {
+			var err error
+			resourceVersion, err = k.list(ctx, namespace, writeListed, lastTimestamp)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				k.Warnw("Failed to list events", "namespace", namespace, zap.Error(err))
+				if !k.wait(ctx) {
+					return
+				}
+				continue
+			}
+			k.persist.Set(key, []byte(resourceVersion))
+		}
+
+		watcher, err := k.client.CoreV1().Events(namespace).Watch(ctx, metav1.ListOptions{
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if k8serrors.IsResourceExpired(err) || k8serrors.IsGone(err) {
+				k.Warnw("Resource version of events expired, events may have been missed", "namespace", namespace)
+				resourceVersion, writeListed = "", true
+				continue
+			}
+			k.Warnw("Failed to watch events", "namespace", namespace, zap.Error(err))
+			if !k.wait(ctx) {
+				return
+			}
+			continue
+		}
+
+		var expired bool
+		resourceVersion, lastTimestamp, expired = k.consume(ctx, watcher, key, resourceVersion, lastTimestamp)
+		watcher.Stop()
+		if ctx.Err() != nil {
+			return
+		}
+		if expired {
+			k.Warnw("Resource version of events expired, events may have been missed", "namespace", namespace)
+			resourceVersion, writeListed = "", true
+		}
+	}
+}
+
+// list will list the current events of a namespace, writing them if requested, and return the
+// resource version the events can be watched from
+func (k *EventsInput) list(ctx context.Context, namespace string, write bool, after time.Time) (string, error) {
+	list, err := k.client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	if write {
+		events := list.Items
+		sort.SliceStable(events, func(i, j int) bool {
+			return eventTimestamp(&events[i]).Before(eventTimestamp(&events[j]))
+		})
+		for i := range events {
+			if eventTimestamp(&events[i]).After(after) {
+				k.writeEvent(ctx, &events[i])
+			}
+		}
+	}
+	return list.ResourceVersion, nil
+}
+
+// consume will write the events received by a watch until it is closed, and return the last
+// resource version and event timestamp that it received
+func (k *EventsInput) consume(ctx context.Context, watcher watch.Interface, key, resourceVersion string, lastTimestamp time.Time) (string, time.Time, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion, lastTimestamp, false
+		case watchEvent, ok := <-watcher.ResultChan():
+			if !ok {
+				return resourceVersion, lastTimestamp, false
+			}
+
+			switch watchEvent.Type {
+			case watch.Error:
+				status := k8serrors.FromObject(watchEvent.Object)
+				if k8serrors.IsResourceExpired(status) || k8serrors.IsGone(status) {
+					return resourceVersion, lastTimestamp, true
+				}
+				k.Warnw("Received error watching events", zap.Error(status))
+				return resourceVersion, lastTimestamp, false
+			case watch.Added, watch.Modified, watch.Bookmark:
+				event, ok := watchEvent.Object.(*corev1.Event)
+				if !ok {
+					continue
+				}
+
+				// Bookmarks only update the resource version, and events are modified when they occur again
+				if watchEvent.Type != watch.Bookmark {
+					k.writeEvent(ctx, event)
+					lastTimestamp = eventTimestamp(event)
+				}
+				if event.ResourceVersion != "" {
+					resourceVersion = event.ResourceVersion
+					k.persist.Set(key, []byte(resourceVersion))
+				}
+			}
+		}
+	}
+}
+
+// wait will wait before an operation is retried, and return false if the context is done
+func (k *EventsInput) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(k.retryInterval):
+		return true
+	}
+}
+
+// writeEvent will write an entry for an event, unless its namespace is excluded
+func (k *EventsInput) writeEvent(ctx context.Context, event *corev1.Event) {
+	if k.excluded[event.Namespace] {
+		return
+	}
+
+	e := k.NewEntry(eventRecord(event))
+	e.Timestamp = eventTimestamp(event)
+	e.Severity = eventSeverity(event)
+	for key, value := range map[string]string{
+		"namespace": event.Namespace,
+		"type":      event.Type,
+		"reason":    event.Reason,
+		"kind":      event.InvolvedObject.Kind,
+		"name":      event.InvolvedObject.Name,
+	} {
+		if value != "" {
+			e.AddLabel(key, value)
+		}
+	}
+	k.Write(ctx, e)
+}
+
+// errorReasons are the reasons of events that are reported with an error severity
+var errorReasons = map[string]bool{
+	"Evicted":    true,
+	"OOMKilling": true,
+	"SystemOOM":  true,
+}
+
+// eventSeverity will return the severity of an event from its type and reason
+func eventSeverity(event *corev1.Event) entry.Severity {
+	if errorReasons[event.Reason] {
+		return entry.Error
+	}
+
+	switch event.Type {
+	case corev1.EventTypeNormal:
+		return entry.Info
+	case corev1.EventTypeWarning:
+		return entry.Warning
+	default:
+		return entry.Default
+	}
+}
+
+// eventTimestamp will return the time an event last occurred
+func eventTimestamp(event *corev1.Event) time.Time {
+	switch {
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// eventRecord will return the record of an event, without its empty fields
+func eventRecord(event *corev1.Event) map[string]interface{} {
+	record := make(map[string]interface{})
+	setString := func(m map[string]interface{}, key, value string) {
+		if value != "" {
+			m[key] = value
+		}
+	}
+
+	setString(record, "name", event.Name)
+	setString(record, "namespace", event.Namespace)
+	setString(record, "type", event.Type)
+	setString(record, "reason", event.Reason)
+	setString(record, "message", event.Message)
+	setString(record, "action", event.Action)
+	setString(record, "reporting_component", event.ReportingController)
+	setString(record, "reporting_instance", event.ReportingInstance)
+	if event.Count != 0 {
+		record["count"] = int(event.Count)
+	}
+	if !event.FirstTimestamp.IsZero() {
+		record["first_timestamp"] = event.FirstTimestamp.UTC().Format(time.RFC3339)
+	}
+	if !event.LastTimestamp.IsZero() {
+		record["last_timestamp"] = event.LastTimestamp.UTC().Format(time.RFC3339)
+	}
+
+	involved := make(map[string]interface{})
+	setString(involved, "kind", event.InvolvedObject.Kind)
+	setString(involved, "name", event.InvolvedObject.Name)
+	setString(involved, "namespace", event.InvolvedObject.Namespace)
+	setString(involved, "uid", string(event.InvolvedObject.UID))
+	setString(involved, "api_version", event.InvolvedObject.APIVersion)
+	setString(involved, "field_path", event.InvolvedObject.FieldPath)
+	if len(involved) > 0 {
+		record["involved_object"] = involved
+	}
+
+	source := make(map[string]interface{})
+	setString(source, "component", event.Source.Component)
+	setString(source, "host", event.Source.Host)
+	if len(source) > 0 {
+		record["source"] = source
+	}
+
+	return record
+}
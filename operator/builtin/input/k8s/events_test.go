@@ -0,0 +1,318 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// fakeEventsAPI serves lists of events, and watches that are controlled by a test
+type fakeEventsAPI struct {
+	client   *fake.Clientset
+	watchers chan *testWatcher
+}
+
+type testWatcher struct {
+	*watch.FakeWatcher
+	namespace       string
+	resourceVersion string
+}
+
+func newFakeEventsAPI(listVersion string, events ...*corev1.Event) *fakeEventsAPI {
+	api := &fakeEventsAPI{
+		client:   fake.NewSimpleClientset(),
+		watchers: make(chan *testWatcher, 10),
+	}
+
+	api.client.PrependReactor("list", "events", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		list := &corev1.EventList{ListMeta: metav1.ListMeta{ResourceVersion: listVersion}}
+		for _, event := range events {
+			if action.GetNamespace() == metav1.NamespaceAll || action.GetNamespace() == event.Namespace {
+				list.Items = append(list.Items, *event)
+			}
+		}
+		return true, list, nil
+	})
+
+	api.client.PrependWatchReactor("events", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		watcher := &testWatcher{
+			FakeWatcher:     watch.NewFake(),
+			namespace:       action.GetNamespace(),
+			resourceVersion: action.(clienttesting.WatchActionImpl).GetWatchRestrictions().ResourceVersion,
+		}
+		api.watchers <- watcher
+		return true, watcher, nil
+	})
+
+	return api
+}
+
+func (api *fakeEventsAPI) expectWatch(t *testing.T) *testWatcher {
+	select {
+	case watcher := <-api.watchers:
+		return watcher
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for events to be watched")
+		return nil
+	}
+}
+
+func newTestEvent(namespace, name, resourceVersion, eventType, reason string, timestamp time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			ResourceVersion: resourceVersion,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: namespace,
+			Name:      "web-1",
+			UID:       "uid-a",
+		},
+		Type:          eventType,
+		Reason:        reason,
+		Message:       "message of " + name,
+		LastTimestamp: metav1.NewTime(timestamp),
+	}
+}
+
+func newTestEventsInput(t *testing.T, buildContext operator.BuildContext, api *fakeEventsAPI, configure func(*EventsInputConfig)) (*EventsInput, chan *entry.Entry) {
+	cfg := NewEventsInputConfig("test")
+	if configure != nil {
+		configure(cfg)
+	}
+
+	op, err := cfg.Build(buildContext)
+	require.NoError(t, err)
+	input := op.(*EventsInput)
+	input.client = api.client
+	input.retryInterval = 10 * time.Millisecond
+
+	entryChan := make(chan *entry.Entry, 100)
+	mockOutput := testutil.NewMockOperator("output")
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		entryChan <- args.Get(1).(*entry.Entry)
+	})
+	input.OutputOperators = []operator.Operator{mockOutput}
+
+	return input, entryChan
+}
+
+func eventMessages(t *testing.T, entryChan chan *entry.Entry, count int) []string {
+	var messages []string
+	for len(messages) < count {
+		select {
+		case e := <-entryChan:
+			messages = append(messages, e.Record.(map[string]interface{})["message"].(string))
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "Timed out waiting for entries", "received %d of %d", len(messages), count)
+		}
+	}
+	return messages
+}
+
+func TestEventsInputBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		configure func(*EventsInputConfig)
+		expectErr bool
+	}{
+		{"Default", func(c *EventsInputConfig) {}, false},
+		{"Namespaces", func(c *EventsInputConfig) { c.Namespaces = []string{"default", "kube-system"} }, false},
+		{"InvalidNamespace", func(c *EventsInputConfig) { c.Namespaces = []string{"*"} }, true},
+		{"InvalidExcludeNamespace", func(c *EventsInputConfig) { c.ExcludeNamespaces = []string{"Default"} }, true},
+		{"StartAtBeginning", func(c *EventsInputConfig) { c.StartAt = "beginning" }, false},
+		{"InvalidStartAt", func(c *EventsInputConfig) { c.StartAt = "middle" }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewEventsInputConfig("test")
+			tc.configure(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEventsInput(t *testing.T) {
+	start := time.Date(2020, 8, 5, 10, 0, 0, 0, time.UTC)
+	api := newFakeEventsAPI("100",
+		newTestEvent("default", "second", "90", corev1.EventTypeNormal, "Pulled", start.Add(time.Second)),
+		newTestEvent("default", "first", "80", corev1.EventTypeNormal, "Scheduled", start),
+	)
+	buildContext := testutil.NewBuildContext(t)
+	input, entryChan := newTestEventsInput(t, buildContext, api, func(c *EventsInputConfig) {
+		c.StartAt = "beginning"
+	})
+	require.NoError(t, input.Start())
+
+	// The current events are written in order, and then watched from the version of the list
+	require.Equal(t, []string{"message of first", "message of second"}, eventMessages(t, entryChan, 2))
+	watcher := api.expectWatch(t)
+	require.Equal(t, metav1.NamespaceAll, watcher.namespace)
+	require.Equal(t, "100", watcher.resourceVersion)
+
+	event := newTestEvent("kube-system", "oom", "101", corev1.EventTypeWarning, "OOMKilling", start.Add(time.Minute))
+	event.Count = 3
+	event.Source = corev1.EventSource{Component: "kubelet", Host: "node-1"}
+	watcher.Add(event)
+
+	var e *entry.Entry
+	select {
+	case e = <-entryChan:
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+	}
+	require.Equal(t, start.Add(time.Minute), e.Timestamp)
+	require.Equal(t, entry.Error, e.Severity)
+	require.Equal(t, map[string]string{
+		"namespace": "kube-system",
+		"type":      "Warning",
+		"reason":    "OOMKilling",
+		"kind":      "Pod",
+		"name":      "web-1",
+	}, e.Labels)
+	require.Equal(t, map[string]interface{}{
+		"name":           "oom",
+		"namespace":      "kube-system",
+		"type":           "Warning",
+		"reason":         "OOMKilling",
+		"message":        "message of oom",
+		"count":          3,
+		"last_timestamp": "2020-08-05T10:01:00Z",
+		"involved_object": map[string]interface{}{
+			"kind":      "Pod",
+			"name":      "web-1",
+			"namespace": "kube-system",
+			"uid":       "uid-a",
+		},
+		"source": map[string]interface{}{
+			"component": "kubelet",
+			"host":      "node-1",
+		},
+	}, e.Record)
+
+	// Bookmarks update the resource version without writing an entry
+	watcher.Action(watch.Bookmark, &corev1.Event{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "105"}})
+	watcher.Stop()
+	watcher = api.expectWatch(t)
+	require.Equal(t, "105", watcher.resourceVersion)
+	require.NoError(t, input.Stop())
+
+	// A restarted operator resumes from the saved resource version, without listing events again
+	input, entryChan = newTestEventsInput(t, buildContext, api, func(c *EventsInputConfig) {
+		c.StartAt = "beginning"
+	})
+	require.NoError(t, input.Start())
+	defer input.Stop()
+	watcher = api.expectWatch(t)
+	require.Equal(t, "105", watcher.resourceVersion)
+	watcher.Modify(newTestEvent("default", "first", "106", corev1.EventTypeNormal, "Scheduled", start.Add(2*time.Minute)))
+	require.Equal(t, []string{"message of first"}, eventMessages(t, entryChan, 1))
+}
+
+func TestEventsInputStartAtEnd(t *testing.T) {
+	start := time.Date(2020, 8, 5, 10, 0, 0, 0, time.UTC)
+	api := newFakeEventsAPI("100", newTestEvent("default", "old", "90", corev1.EventTypeNormal, "Pulled", start))
+	input, entryChan := newTestEventsInput(t, testutil.NewBuildContext(t), api, nil)
+	require.NoError(t, input.Start())
+	defer input.Stop()
+
+	watcher := api.expectWatch(t)
+	require.Equal(t, "100", watcher.resourceVersion)
+	watcher.Add(newTestEvent("default", "new", "101", corev1.EventTypeNormal, "Pulled", start.Add(time.Second)))
+	require.Equal(t, []string{"message of new"}, eventMessages(t, entryChan, 1))
+}
+
+func TestEventsInputNamespaces(t *testing.T) {
+	start := time.Date(2020, 8, 5, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Namespaces", func(t *testing.T) {
+		api := newFakeEventsAPI("100")
+		input, _ := newTestEventsInput(t, testutil.NewBuildContext(t), api, func(c *EventsInputConfig) {
+			c.Namespaces = []string{"default", "monitoring"}
+		})
+		require.NoError(t, input.Start())
+		defer input.Stop()
+
+		// Each namespace is watched separately
+		namespaces := []string{api.expectWatch(t).namespace, api.expectWatch(t).namespace}
+		require.ElementsMatch(t, []string{"default", "monitoring"}, namespaces)
+	})
+
+	t.Run("ExcludeNamespaces", func(t *testing.T) {
+		api := newFakeEventsAPI("100")
+		input, entryChan := newTestEventsInput(t, testutil.NewBuildContext(t), api, func(c *EventsInputConfig) {
+			c.ExcludeNamespaces = []string{"kube-system"}
+		})
+		require.NoError(t, input.Start())
+		defer input.Stop()
+
+		watcher := api.expectWatch(t)
+		watcher.Add(newTestEvent("kube-system", "excluded", "101", corev1.EventTypeNormal, "Pulled", start))
+		watcher.Add(newTestEvent("default", "included", "102", corev1.EventTypeNormal, "Pulled", start))
+		require.Equal(t, []string{"message of included"}, eventMessages(t, entryChan, 1))
+	})
+}
+
+func TestEventsInputExpired(t *testing.T) {
+	start := time.Date(2020, 8, 5, 10, 0, 0, 0, time.UTC)
+	api := newFakeEventsAPI("200",
+		newTestEvent("default", "written", "150", corev1.EventTypeNormal, "Pulled", start),
+		newTestEvent("default", "missed", "190", corev1.EventTypeWarning, "BackOff", start.Add(time.Minute)),
+	)
+	input, entryChan := newTestEventsInput(t, testutil.NewBuildContext(t), api, nil)
+	require.NoError(t, input.Start())
+	defer input.Stop()
+
+	watcher := api.expectWatch(t)
+	watcher.Add(newTestEvent("default", "written", "150", corev1.EventTypeNormal, "Pulled", start))
+	require.Equal(t, []string{"message of written"}, eventMessages(t, entryChan, 1))
+
+	// Once the resource version expires, events that are newer than the last event are listed
+	watcher.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	})
+	require.Equal(t, []string{"message of missed"}, eventMessages(t, entryChan, 1))
+	require.Equal(t, "200", api.expectWatch(t).resourceVersion)
+}
+
+func TestEventSeverity(t *testing.T) {
+	cases := []struct {
+		eventType string
+		reason    string
+		expected  entry.Severity
+	}{
+		{corev1.EventTypeNormal, "Scheduled", entry.Info},
+		{corev1.EventTypeWarning, "BackOff", entry.Warning},
+		{corev1.EventTypeWarning, "Evicted", entry.Error},
+		{corev1.EventTypeWarning, "OOMKilling", entry.Error},
+		{"", "Unknown", entry.Default},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.reason, func(t *testing.T) {
+			event := &corev1.Event{Type: tc.eventType, Reason: tc.reason}
+			require.Equal(t, tc.expected, eventSeverity(event))
+		})
+	}
+}
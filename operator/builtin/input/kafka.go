@@ -0,0 +1,242 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/kafka"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("kafka_input", func() operator.Builder { return NewKafkaInputConfig("") })
+}
+
+// Formats that a kafka input can read message values as
+const (
+	kafkaFormatText  = "text"
+	kafkaFormatEntry = "entry"
+)
+
+// Positions that a kafka input can start reading partitions without a committed offset at
+const (
+	kafkaStartAtBeginning = "beginning"
+	kafkaStartAtEnd       = "end"
+)
+
+// kafkaRetryInterval is the delay before the consumer rejoins the group after an error
+var kafkaRetryInterval = 5 * time.Second
+
+func NewKafkaInputConfig(operatorID string) *KafkaInputConfig {
+	return &KafkaInputConfig{
+		InputConfig:    helper.NewInputConfig(operatorID, "kafka_input"),
+		ClientConfig:   kafka.NewClientConfig(),
+		Format:         kafkaFormatText,
+		StartAt:        kafkaStartAtEnd,
+		CommitInterval: operator.Duration{Duration: time.Second},
+	}
+}
+
+// KafkaInputConfig is the configuration of a kafka input operator.
+type KafkaInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+	kafka.ClientConfig `yaml:",inline"`
+
+	Topics         []string          `json:"topics"                    yaml:"topics,flow"`
+	GroupID        string            `json:"group_id"                  yaml:"group_id"`
+	Format         string            `json:"format,omitempty"          yaml:"format,omitempty"`
+	StartAt        string            `json:"start_at,omitempty"        yaml:"start_at,omitempty"`
+	CommitInterval operator.Duration `json:"commit_interval,omitempty" yaml:"commit_interval,omitempty"`
+}
+
+// Build will build a kafka input operator.
+func (c KafkaInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.Topics) == 0 {
+		return nil, fmt.Errorf("missing required parameter 'topics'")
+	}
+
+	if c.GroupID == "" {
+		return nil, fmt.Errorf("missing required parameter 'group_id'")
+	}
+
+	if c.Format != kafkaFormatText && c.Format != kafkaFormatEntry {
+		return nil, fmt.Errorf("invalid format '%s', expected '%s' or '%s'", c.Format, kafkaFormatText, kafkaFormatEntry)
+	}
+
+	if c.CommitInterval.Raw() <= 0 {
+		return nil, fmt.Errorf("commit_interval must be greater than 0")
+	}
+
+	saramaConfig, err := c.ClientConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.StartAt {
+	case kafkaStartAtBeginning:
+		saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case kafkaStartAtEnd:
+		saramaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("invalid start_at '%s', expected '%s' or '%s'", c.StartAt, kafkaStartAtBeginning, kafkaStartAtEnd)
+	}
+
+	// Offsets are only marked once an entry has been written to the outputs, and the marked
+	// offsets are committed on every commit interval, and when the consumer leaves the group
+	saramaConfig.Consumer.Offsets.AutoCommit.Enable = true
+	saramaConfig.Consumer.Offsets.AutoCommit.Interval = c.CommitInterval.Raw()
+	saramaConfig.Consumer.Return.Errors = true
+	if err := saramaConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka configuration: %s", err)
+	}
+
+	kafkaInput := &KafkaInput{
+		InputOperator: inputOperator,
+		brokers:       c.Brokers,
+		saramaConfig:  saramaConfig,
+		topics:        c.Topics,
+		groupID:       c.GroupID,
+		format:        c.Format,
+	}
+	return kafkaInput, nil
+}
+
+// KafkaInput is an operator that reads entries from kafka topics as a member of a consumer group.
+type KafkaInput struct {
+	helper.InputOperator
+	brokers      []string
+	saramaConfig *sarama.Config
+	topics       []string
+	groupID      string
+	format       string
+
+	group  sarama.ConsumerGroup
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+// Start will join the consumer group, and start consuming the partitions assigned to it.
+func (k *KafkaInput) Start() error {
+	group, err := sarama.NewConsumerGroup(k.brokers, k.groupID, k.saramaConfig)
+	if err != nil {
+		return fmt.Errorf("create kafka consumer group: %s", err)
+	}
+	k.group = group
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.wg = &sync.WaitGroup{}
+
+	k.wg.Add(2)
+	go k.consume(ctx)
+	go k.logErrors()
+	return nil
+}
+
+// consume will consume the topics until the context is done. Each session lasts until the
+// partitions of the group are rebalanced, and then a new session is started.
+func (k *KafkaInput) consume(ctx context.Context) {
+	defer k.wg.Done()
+	for {
+		err := k.group.Consume(ctx, k.topics, k)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == sarama.ErrClosedConsumerGroup {
+			return
+		}
+
+		if err != nil {
+			k.Errorw("Failed to consume topics", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(kafkaRetryInterval):
+			}
+		}
+	}
+}
+
+// logErrors will log the errors of the consumer group until it is closed
+func (k *KafkaInput) logErrors() {
+	defer k.wg.Done()
+	for err := range k.group.Errors() {
+		k.Warnw("Kafka consumer error", zap.Error(err))
+	}
+}
+
+// Stop will leave the consumer group, committing the offsets of the entries that have been written.
+func (k *KafkaInput) Stop() error {
+	if k.cancel == nil {
+		return nil
+	}
+	k.cancel()
+	err := k.group.Close()
+	k.wg.Wait()
+	return err
+}
+
+// Setup is called at the start of a consumer group session.
+func (k *KafkaInput) Setup(session sarama.ConsumerGroupSession) error {
+	k.Debugw("Joined consumer group", "member_id", session.MemberID(), "claims", session.Claims())
+	return nil
+}
+
+// Cleanup is called at the end of a consumer group session.
+func (k *KafkaInput) Cleanup(session sarama.ConsumerGroupSession) error {
+	k.Debugw("Left consumer group session", "member_id", session.MemberID())
+	return nil
+}
+
+// ConsumeClaim will write an entry for each message of a partition. A message is only marked as
+// consumed once its entry has been written to the outputs.
+func (k *KafkaInput) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+	for message := range claim.Messages() {
+		k.Write(ctx, k.newEntry(message))
+		session.MarkMessage(message, "")
+	}
+	return nil
+}
+
+// newEntry will create the entry of a message
+func (k *KafkaInput) newEntry(message *sarama.ConsumerMessage) *entry.Entry {
+	var e *entry.Entry
+	if k.format == kafkaFormatEntry {
+		e = &entry.Entry{}
+		if err := json.Unmarshal(message.Value, e); err != nil {
+			k.Warnw("Failed to decode message as an entry. Reading it as text instead", zap.Error(err),
+				"topic", message.Topic, "partition", message.Partition, "offset", message.Offset)
+			e = nil
+		}
+	}
+
+	if e == nil {
+		e = k.NewEntry(string(message.Value))
+		if !message.Timestamp.IsZero() {
+			e.Timestamp = message.Timestamp
+		}
+	}
+
+	e.AddLabel("topic", message.Topic)
+	e.AddLabel("partition", strconv.Itoa(int(message.Partition)))
+	e.AddLabel("offset", strconv.FormatInt(message.Offset, 10))
+	if len(message.Key) > 0 {
+		e.AddLabel("key", string(message.Key))
+	}
+	return e
+}
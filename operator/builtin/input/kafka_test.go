@@ -0,0 +1,245 @@
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeKafkaAssignment will encode the assignment of a consumer group member to the
+// partitions of a topic
+func encodeKafkaAssignment(topic string, partitions ...int32) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, int16(0)) // version
+	_ = binary.Write(&buf, binary.BigEndian, int32(1))
+	_ = binary.Write(&buf, binary.BigEndian, int16(len(topic)))
+	buf.WriteString(topic)
+	_ = binary.Write(&buf, binary.BigEndian, int32(len(partitions)))
+	for _, partition := range partitions {
+		_ = binary.Write(&buf, binary.BigEndian, partition)
+	}
+	_ = binary.Write(&buf, binary.BigEndian, int32(0)) // user data
+	return buf.Bytes()
+}
+
+// newKafkaConsumerBroker will create a mock broker that coordinates a consumer group with a
+// single member, which is assigned the only partition of a topic with two messages. The
+// responses are encoded with the versions of the requests of the default kafka version.
+func newKafkaConsumerBroker(t *testing.T) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("logs", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "carbon", broker),
+		"JoinGroupRequest": sarama.NewMockWrapper(&sarama.JoinGroupResponse{
+			Version:       1,
+			GenerationId:  1,
+			GroupProtocol: sarama.BalanceStrategyRange.Name(),
+			LeaderId:      "leader",
+			MemberId:      "member",
+		}),
+		"SyncGroupRequest": sarama.NewMockWrapper(&sarama.SyncGroupResponse{
+			MemberAssignment: encodeKafkaAssignment("logs", 0),
+		}),
+		"HeartbeatRequest":  sarama.NewMockWrapper(&sarama.HeartbeatResponse{}),
+		"LeaveGroupRequest": sarama.NewMockWrapper(&sarama.LeaveGroupResponse{}),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("carbon", "logs", 0, -1, "", sarama.ErrNoError),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("logs", 0, sarama.OffsetOldest, 0).
+			SetOffset("logs", 0, sarama.OffsetNewest, 2),
+		"FetchRequest": sarama.NewMockFetchResponse(t, 2).
+			SetVersion(7).
+			SetMessage("logs", 0, 0, sarama.StringEncoder("first message")).
+			SetMessage("logs", 0, 1, sarama.StringEncoder("second message")).
+			SetHighWaterMark("logs", 0, 2),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+	})
+	return broker
+}
+
+// committedKafkaOffset returns the last offset of a partition that was committed to a broker
+func committedKafkaOffset(broker *sarama.MockBroker, topic string, partition int32) int64 {
+	committed := int64(-1)
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.OffsetCommitRequest); ok {
+			if offset, _, err := req.Offset(topic, partition); err == nil {
+				committed = offset
+			}
+		}
+	}
+	return committed
+}
+
+func TestKafkaInput(t *testing.T) {
+	broker := newKafkaConsumerBroker(t)
+	defer broker.Close()
+
+	cfg := NewKafkaInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Brokers = []string{broker.Addr()}
+	cfg.Topics = []string{"logs"}
+	cfg.GroupID = "carbon"
+	cfg.StartAt = kafkaStartAtBeginning
+	cfg.CommitInterval = operator.Duration{Duration: 50 * time.Millisecond}
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	receivedEntries := make(chan *entry.Entry, 10)
+	mockOutput := testutil.NewMockOperator("output1")
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		receivedEntries <- args.Get(1).(*entry.Entry)
+	})
+	require.NoError(t, op.SetOutputs([]operator.Operator{mockOutput}))
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	for i, expected := range []string{"first message", "second message"} {
+		select {
+		case e := <-receivedEntries:
+			require.Equal(t, expected, e.Record)
+			require.Equal(t, map[string]string{
+				"topic":     "logs",
+				"partition": "0",
+				"offset":    []string{"0", "1"}[i],
+			}, e.Labels)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for entry")
+		}
+	}
+
+	// The offset after the last written entry is committed
+	require.Eventually(t, func() bool {
+		return committedKafkaOffset(broker, "logs", 0) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, op.Stop())
+}
+
+func TestKafkaInputCommitsAfterWrite(t *testing.T) {
+	broker := newKafkaConsumerBroker(t)
+	defer broker.Close()
+
+	cfg := NewKafkaInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Brokers = []string{broker.Addr()}
+	cfg.Topics = []string{"logs"}
+	cfg.GroupID = "carbon"
+	cfg.StartAt = kafkaStartAtBeginning
+	cfg.CommitInterval = operator.Duration{Duration: 10 * time.Millisecond}
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	// The output blocks on the second entry, so only the first is handed off
+	processed := make(chan struct{}, 10)
+	release := make(chan struct{})
+	mockOutput := testutil.NewMockOperator("output1")
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		processed <- struct{}{}
+		if args.Get(1).(*entry.Entry).Record == "second message" {
+			<-release
+		}
+	})
+	require.NoError(t, op.SetOutputs([]operator.Operator{mockOutput}))
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-processed:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for entry")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return committedKafkaOffset(broker, "logs", 0) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int64(1), committedKafkaOffset(broker, "logs", 0))
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return committedKafkaOffset(broker, "logs", 0) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKafkaInputNewEntry(t *testing.T) {
+	cfg := NewKafkaInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Brokers = []string{"localhost:9092"}
+	cfg.Topics = []string{"logs"}
+	cfg.GroupID = "carbon"
+	cfg.Format = kafkaFormatEntry
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	kafkaInput := op.(*KafkaInput)
+
+	timestamp := time.Date(2020, time.July, 21, 10, 30, 0, 0, time.UTC)
+	e := kafkaInput.newEntry(&sarama.ConsumerMessage{
+		Topic:     "logs",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("web-1"),
+		Timestamp: timestamp.Add(time.Hour),
+		Value:     []byte(`{"timestamp":"2020-07-21T10:30:00Z","severity":60,"labels":{"host":"web-1"},"record":{"message":"failed"}}`),
+	})
+	require.True(t, timestamp.Equal(e.Timestamp))
+	require.Equal(t, entry.Error, e.Severity)
+	require.Equal(t, map[string]interface{}{"message": "failed"}, e.Record)
+	require.Equal(t, map[string]string{
+		"host":      "web-1",
+		"topic":     "logs",
+		"partition": "3",
+		"offset":    "42",
+		"key":       "web-1",
+	}, e.Labels)
+
+	// Messages that are not entries are read as text
+	e = kafkaInput.newEntry(&sarama.ConsumerMessage{
+		Topic:     "logs",
+		Timestamp: timestamp,
+		Value:     []byte("not an entry"),
+	})
+	require.True(t, timestamp.Equal(e.Timestamp))
+	require.Equal(t, "not an entry", e.Record)
+}
+
+func TestKafkaInputInvalid(t *testing.T) {
+	cases := map[string]func(*KafkaInputConfig){
+		"MissingBrokers":  func(cfg *KafkaInputConfig) { cfg.Brokers = nil },
+		"MissingTopics":   func(cfg *KafkaInputConfig) { cfg.Topics = nil },
+		"MissingGroupID":  func(cfg *KafkaInputConfig) { cfg.GroupID = "" },
+		"InvalidFormat":   func(cfg *KafkaInputConfig) { cfg.Format = "xml" },
+		"InvalidStartAt":  func(cfg *KafkaInputConfig) { cfg.StartAt = "middle" },
+		"InvalidVersion":  func(cfg *KafkaInputConfig) { cfg.Version = "latest" },
+		"OldVersion":      func(cfg *KafkaInputConfig) { cfg.Version = "0.9.0" },
+		"InvalidInterval": func(cfg *KafkaInputConfig) { cfg.CommitInterval = operator.Duration{} },
+	}
+
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := NewKafkaInputConfig("test_operator_id")
+			cfg.OutputIDs = []string{"output1"}
+			cfg.Brokers = []string{"localhost:9092"}
+			cfg.Topics = []string{"logs"}
+			cfg.GroupID = "carbon"
+			modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			require.Error(t, err)
+		})
+	}
+}
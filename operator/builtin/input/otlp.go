@@ -0,0 +1,361 @@
+package input
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/observiq/carbon/internal/otlp"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	operator.Register("otlp_input", func() operator.Builder { return NewOTLPInputConfig("") })
+}
+
+// Default addresses of the OTLP protocols
+const (
+	defaultOTLPGRPCAddress = "0.0.0.0:4317"
+	defaultOTLPHTTPAddress = "0.0.0.0:4318"
+)
+
+// protobufContentType is the content type of OTLP requests and responses over http
+const protobufContentType = "application/x-protobuf"
+
+func NewOTLPInputConfig(operatorID string) *OTLPInputConfig {
+	return &OTLPInputConfig{
+		InputConfig:         helper.NewInputConfig(operatorID, "otlp_input"),
+		MaxRequestSize:      4 * 1024 * 1024,
+		BackpressureTimeout: operator.Duration{Duration: 5 * time.Second},
+	}
+}
+
+// OTLPInputConfig is the configuration of an otlp input operator.
+type OTLPInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	GRPC                *OTLPProtocolConfig `json:"grpc,omitempty"                 yaml:"grpc,omitempty"`
+	HTTP                *OTLPProtocolConfig `json:"http,omitempty"                 yaml:"http,omitempty"`
+	MaxRequestSize      int                 `json:"max_request_size,omitempty"     yaml:"max_request_size,omitempty"`
+	BackpressureTimeout operator.Duration   `json:"backpressure_timeout,omitempty" yaml:"backpressure_timeout,omitempty"`
+}
+
+// OTLPProtocolConfig is the configuration of a protocol that an otlp input receives requests with.
+type OTLPProtocolConfig struct {
+	ListenAddress string            `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	TLS           *helper.TLSConfig `json:"tls,omitempty"            yaml:"tls,omitempty"`
+}
+
+// Build will build an otlp input operator.
+func (c OTLPInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.MaxRequestSize <= 0 {
+		return nil, fmt.Errorf("max_request_size must be greater than 0")
+	}
+
+	if c.BackpressureTimeout.Raw() <= 0 {
+		return nil, fmt.Errorf("backpressure_timeout must be greater than 0")
+	}
+
+	grpcConfig, httpConfig := c.GRPC, c.HTTP
+	if grpcConfig == nil && httpConfig == nil {
+		grpcConfig = &OTLPProtocolConfig{ListenAddress: defaultOTLPGRPCAddress}
+		httpConfig = &OTLPProtocolConfig{ListenAddress: defaultOTLPHTTPAddress}
+	}
+
+	otlpInput := &OTLPInput{
+		InputOperator:       inputOperator,
+		maxRequestSize:      c.MaxRequestSize,
+		backpressureTimeout: c.BackpressureTimeout.Raw(),
+	}
+
+	if grpcConfig != nil {
+		otlpInput.grpcAddress, otlpInput.grpcTLSConfig, err = grpcConfig.build(inputOperator, "grpc", defaultOTLPGRPCAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if httpConfig != nil {
+		otlpInput.httpAddress, otlpInput.httpTLSConfig, err = httpConfig.build(inputOperator, "http", defaultOTLPHTTPAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return otlpInput, nil
+}
+
+// build will return the listen address and tls config of a protocol
+func (c OTLPProtocolConfig) build(inputOperator helper.InputOperator, protocol, defaultAddress string) (string, *tls.Config, error) {
+	address := c.ListenAddress
+	if address == "" {
+		address = defaultAddress
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", nil, fmt.Errorf("invalid %s listen_address: %s", protocol, err)
+	}
+
+	if c.TLS == nil {
+		return address, nil, nil
+	}
+
+	tlsConfig, err := c.TLS.Build(inputOperator.SugaredLogger)
+	if err != nil {
+		return "", nil, fmt.Errorf("build %s tls config: %s", protocol, err)
+	}
+	return address, tlsConfig, nil
+}
+
+// OTLPInput is an operator that receives log entries with the OpenTelemetry protocol.
+type OTLPInput struct {
+	helper.InputOperator
+	grpcAddress         string
+	grpcTLSConfig       *tls.Config
+	httpAddress         string
+	httpTLSConfig       *tls.Config
+	maxRequestSize      int
+	backpressureTimeout time.Duration
+
+	grpcListener net.Listener
+	grpcServer   *grpc.Server
+	httpListener net.Listener
+	httpServer   *http.Server
+	waitGroup    sync.WaitGroup
+}
+
+// Start will start listening for otlp requests.
+func (o *OTLPInput) Start() error {
+	if o.grpcAddress != "" {
+		if err := o.startGRPC(); err != nil {
+			return err
+		}
+	}
+
+	if o.httpAddress != "" {
+		if err := o.startHTTP(); err != nil {
+			if o.grpcServer != nil {
+				o.grpcServer.Stop()
+				o.waitGroup.Wait()
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *OTLPInput) startGRPC() error {
+	listener, err := net.Listen("tcp", o.grpcAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on grpc interface: %s", err)
+	}
+	o.grpcListener = listener
+
+	options := []grpc.ServerOption{grpc.MaxRecvMsgSize(o.maxRequestSize)}
+	if o.grpcTLSConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(o.grpcTLSConfig)))
+	}
+	o.grpcServer = grpc.NewServer(options...)
+	otlp.RegisterLogsServer(o.grpcServer, o)
+
+	o.waitGroup.Add(1)
+	go func() {
+		defer o.waitGroup.Done()
+		if err := o.grpcServer.Serve(listener); err != nil {
+			o.Errorw("Failed to serve grpc requests", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (o *OTLPInput) startHTTP() error {
+	listener, err := net.Listen("tcp", o.httpAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on http interface: %s", err)
+	}
+	if o.httpTLSConfig != nil {
+		listener = tls.NewListener(listener, o.httpTLSConfig)
+	}
+	o.httpListener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(otlp.LogsPath, o.serveLogs)
+	o.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          zap.NewStdLog(o.Desugar()),
+	}
+
+	o.waitGroup.Add(1)
+	go func() {
+		defer o.waitGroup.Done()
+		if err := o.httpServer.Serve(listener); err != http.ErrServerClosed {
+			o.Errorw("Failed to serve http requests", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Stop will stop listening for otlp requests, after the requests in progress are handled.
+func (o *OTLPInput) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.backpressureTimeout+time.Second)
+	defer cancel()
+
+	var err error
+	if o.httpServer != nil {
+		err = o.httpServer.Shutdown(ctx)
+	}
+
+	if o.grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			o.grpcServer.GracefulStop()
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			o.grpcServer.Stop()
+			<-stopped
+		}
+	}
+
+	o.waitGroup.Wait()
+	return err
+}
+
+// Export will write the entries of an export request received with grpc.
+func (o *OTLPInput) Export(ctx context.Context, req *otlp.ExportLogsServiceRequest) (*otlp.ExportLogsServiceResponse, error) {
+	if !o.writeEntries(ctx, req) {
+		o.Warnw("Rejected grpc request because entries were not accepted before the backpressure_timeout")
+		return nil, status.Error(codes.Unavailable, "entries were not accepted before the backpressure timeout")
+	}
+	return &otlp.ExportLogsServiceResponse{}, nil
+}
+
+// writeEntries will write the entries of a request, and return false if they were not all accepted
+// before the backpressure timeout
+func (o *OTLPInput) writeEntries(ctx context.Context, req *otlp.ExportLogsServiceRequest) bool {
+	// Writes block while the outputs are applying backpressure, such as when their buffers are full
+	ctx, cancel := context.WithTimeout(ctx, o.backpressureTimeout)
+	defer cancel()
+
+	for _, e := range otlp.ToEntries(req, o.NewEntry) {
+		o.Write(ctx, e)
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// serveLogs will write the entries of an export request received with http, and respond once they
+// have been handed off
+func (o *OTLPInput) serveLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		o.writeHTTPError(w, http.StatusMethodNotAllowed, codes.Unimplemented, "method not allowed")
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || contentType != protobufContentType {
+		o.writeHTTPError(w, http.StatusUnsupportedMediaType, codes.InvalidArgument, fmt.Sprintf("unsupported content type, expected '%s'", protobufContentType))
+		return
+	}
+
+	req, statusCode, err := o.readRequest(w, r)
+	if err != nil {
+		o.Debugw("Rejected request", "remote_address", r.RemoteAddr, "status", statusCode, zap.Error(err))
+		o.writeHTTPError(w, statusCode, codes.InvalidArgument, err.Error())
+		return
+	}
+
+	if !o.writeEntries(r.Context(), req) {
+		o.Warnw("Rejected http request because entries were not accepted before the backpressure_timeout", "remote_address", r.RemoteAddr)
+		w.Header().Set("Retry-After", "1")
+		o.writeHTTPError(w, http.StatusServiceUnavailable, codes.Unavailable, "entries were not accepted before the backpressure timeout")
+		return
+	}
+
+	body, err := (&otlp.ExportLogsServiceResponse{}).Marshal()
+	if err != nil {
+		o.Errorw("Failed to encode response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", protobufContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// readRequest will read the export request in the body of an http request. If the body is invalid,
+// the status to respond with is returned.
+func (o *OTLPInput) readRequest(w http.ResponseWriter, r *http.Request) (*otlp.ExportLogsServiceRequest, int, error) {
+	maxSize := int64(o.maxRequestSize)
+	body := io.Reader(http.MaxBytesReader(w, r.Body, maxSize))
+
+	switch encoding := strings.ToLower(r.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid gzip body: %s", err)
+		}
+		defer gzipReader.Close()
+		// The decompressed body is limited as well, so that small requests can not expand to use all memory
+		body = &limitedReader{reader: gzipReader, remaining: maxSize}
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body is larger than max_request_size")
+		}
+		return nil, http.StatusBadRequest, fmt.Errorf("read body: %s", err)
+	}
+
+	req := &otlp.ExportLogsServiceRequest{}
+	if err := req.Unmarshal(data); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid protobuf body: %s", err)
+	}
+	return req, 0, nil
+}
+
+// writeHTTPError will respond with an encoded status, as expected by otlp clients
+func (o *OTLPInput) writeHTTPError(w http.ResponseWriter, statusCode int, code codes.Code, message string) {
+	body, err := proto.Marshal(&rpcstatus.Status{Code: int32(code), Message: message})
+	if err != nil {
+		http.Error(w, message, statusCode)
+		return
+	}
+	w.Header().Set("Content-Type", protobufContentType)
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
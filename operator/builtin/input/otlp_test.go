@@ -0,0 +1,239 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/otlp"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOTLPInput(t *testing.T) {
+	basicOTLPInputConfig := func() *OTLPInputConfig {
+		cfg := NewOTLPInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		cfg.GRPC = &OTLPProtocolConfig{ListenAddress: "127.0.0.1:0"}
+		cfg.HTTP = &OTLPProtocolConfig{ListenAddress: "127.0.0.1:0"}
+		return cfg
+	}
+
+	// startOTLPInput will build and start an otlp input, returning a channel of the entries it writes
+	startOTLPInput := func(t *testing.T, cfg *OTLPInputConfig) (*OTLPInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		otlpInput := newOperator.(*OTLPInput)
+		otlpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, otlpInput.Start())
+		return otlpInput, entryChan
+	}
+
+	request := func(bodies ...interface{}) *otlp.ExportLogsServiceRequest {
+		records := make([]*otlp.LogRecord, 0, len(bodies))
+		for _, body := range bodies {
+			records = append(records, &otlp.LogRecord{SeverityNumber: 9, Body: body})
+		}
+		return &otlp.ExportLogsServiceRequest{
+			ResourceLogs: []*otlp.ResourceLogs{{
+				Resource:  &otlp.Resource{Attributes: []*otlp.KeyValue{{Key: "service.name", Value: "test"}}},
+				ScopeLogs: []*otlp.ScopeLogs{{LogRecords: records}},
+			}},
+		}
+	}
+
+	post := func(t *testing.T, url, contentType string, body []byte) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		respBody, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, respBody
+	}
+
+	t.Run("GRPC", func(t *testing.T) {
+		otlpInput, entryChan := startOTLPInput(t, basicOTLPInputConfig())
+		defer otlpInput.Stop()
+
+		conn, err := grpc.Dial(otlpInput.grpcListener.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = otlp.Export(ctx, conn, request("message 1", []*otlp.KeyValue{{Key: "key", Value: int64(1)}}))
+		require.NoError(t, err)
+
+		require.Len(t, entryChan, 2)
+		e := <-entryChan
+		require.Equal(t, "message 1", e.Record)
+		require.Equal(t, entry.Info, e.Severity)
+		require.Equal(t, map[string]string{"resource.service.name": "test"}, e.Labels)
+		require.Equal(t, map[string]interface{}{"key": int64(1)}, (<-entryChan).Record)
+	})
+
+	t.Run("GRPCTooLarge", func(t *testing.T) {
+		cfg := basicOTLPInputConfig()
+		cfg.MaxRequestSize = 100
+		otlpInput, entryChan := startOTLPInput(t, cfg)
+		defer otlpInput.Stop()
+
+		conn, err := grpc.Dial(otlpInput.grpcListener.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = otlp.Export(ctx, conn, request(string(make([]byte, 200))))
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+		require.Len(t, entryChan, 0)
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		otlpInput, entryChan := startOTLPInput(t, basicOTLPInputConfig())
+		defer otlpInput.Stop()
+		url := "http://" + otlpInput.httpListener.Addr().String() + otlp.LogsPath
+
+		body, err := request("message 1").Marshal()
+		require.NoError(t, err)
+		resp, respBody := post(t, url, "application/x-protobuf", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/x-protobuf", resp.Header.Get("Content-Type"))
+		require.NoError(t, (&otlp.ExportLogsServiceResponse{}).Unmarshal(respBody))
+
+		require.Len(t, entryChan, 1)
+		e := <-entryChan
+		require.Equal(t, "message 1", e.Record)
+		require.Equal(t, map[string]string{"resource.service.name": "test"}, e.Labels)
+	})
+
+	t.Run("HTTPRejected", func(t *testing.T) {
+		cfg := basicOTLPInputConfig()
+		cfg.MaxRequestSize = 100
+		otlpInput, entryChan := startOTLPInput(t, cfg)
+		defer otlpInput.Stop()
+		address := "http://" + otlpInput.httpListener.Addr().String()
+
+		tooLarge, err := request(string(make([]byte, 200))).Marshal()
+		require.NoError(t, err)
+
+		cases := []struct {
+			name           string
+			url            string
+			contentType    string
+			body           []byte
+			expectedStatus int
+		}{
+			{"JSON", address + otlp.LogsPath, "application/json", []byte("{}"), http.StatusUnsupportedMediaType},
+			{"InvalidProtobuf", address + otlp.LogsPath, "application/x-protobuf", []byte{0x0a, 0x05}, http.StatusBadRequest},
+			{"TooLarge", address + otlp.LogsPath, "application/x-protobuf", tooLarge, http.StatusRequestEntityTooLarge},
+			{"UnknownPath", address + "/v1/traces", "application/x-protobuf", []byte{}, http.StatusNotFound},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				resp, respBody := post(t, tc.url, tc.contentType, tc.body)
+				require.Equal(t, tc.expectedStatus, resp.StatusCode)
+				if tc.expectedStatus != http.StatusNotFound {
+					s := &rpcstatus.Status{}
+					require.NoError(t, proto.Unmarshal(respBody, s))
+					require.NotEmpty(t, s.Message)
+				}
+			})
+		}
+		require.Len(t, entryChan, 0)
+	})
+
+	t.Run("Backpressure", func(t *testing.T) {
+		cfg := basicOTLPInputConfig()
+		cfg.BackpressureTimeout = operator.Duration{Duration: 50 * time.Millisecond}
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		// The output blocks until the context of the write is done, as a full buffer would
+		mockOutput := testutil.Operator{}
+		otlpInput := newOperator.(*OTLPInput)
+		otlpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).Return(nil)
+		require.NoError(t, otlpInput.Start())
+		defer otlpInput.Stop()
+
+		conn, err := grpc.Dial(otlpInput.grpcListener.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = otlp.Export(ctx, conn, request("message 1"))
+		require.Equal(t, codes.Unavailable, status.Code(err))
+
+		body, err := request("message 1").Marshal()
+		require.NoError(t, err)
+		resp, _ := post(t, "http://"+otlpInput.httpListener.Addr().String()+otlp.LogsPath, "application/x-protobuf", body)
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.Equal(t, "1", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("DefaultProtocols", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		otlpInput := newOperator.(*OTLPInput)
+		require.Equal(t, "0.0.0.0:4317", otlpInput.grpcAddress)
+		require.Equal(t, "0.0.0.0:4318", otlpInput.httpAddress)
+	})
+
+	t.Run("SingleProtocol", func(t *testing.T) {
+		cfg := NewOTLPInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		cfg.HTTP = &OTLPProtocolConfig{}
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		otlpInput := newOperator.(*OTLPInput)
+		require.Equal(t, "", otlpInput.grpcAddress)
+		require.Equal(t, "0.0.0.0:4318", otlpInput.httpAddress)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := map[string]func(*OTLPInputConfig){
+			"ListenAddress":       func(cfg *OTLPInputConfig) { cfg.GRPC.ListenAddress = "invalid" },
+			"MaxRequestSize":      func(cfg *OTLPInputConfig) { cfg.MaxRequestSize = 0 },
+			"BackpressureTimeout": func(cfg *OTLPInputConfig) { cfg.BackpressureTimeout = operator.Duration{} },
+		}
+		for name, modify := range cases {
+			t.Run(name, func(t *testing.T) {
+				cfg := basicOTLPInputConfig()
+				modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,79 @@
+// +build linux
+
+package input
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentialsSupported is true if the credentials of the peer of a unix socket can be read
+const peerCredentialsSupported = true
+
+// peerCredentialsSize is the size of the control message that holds the credentials of a datagram
+var peerCredentialsSize = unix.CmsgSpace(unix.SizeofUcred)
+
+// enablePassCredentials will enable SO_PASSCRED on a datagram socket, so that each message includes the credentials of its sender
+func enablePassCredentials(conn *net.UnixConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PASSCRED, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// connPeerCredentials will return the labels of the credentials of the process connected to a stream socket
+func connPeerCredentials(conn *net.UnixConn) (map[string]string, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ucred *unix.Ucred
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, sockErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+	return peerCredentialLabels(ucred), nil
+}
+
+// messagePeerCredentials will return the labels of the credentials in the control message of a datagram
+func messagePeerCredentials(oob []byte) (map[string]string, error) {
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		ucred, err := unix.ParseUnixCredentials(&messages[i])
+		if err == nil {
+			return peerCredentialLabels(ucred), nil
+		}
+	}
+	return nil, fmt.Errorf("message does not include credentials")
+}
+
+func peerCredentialLabels(ucred *unix.Ucred) map[string]string {
+	return map[string]string{
+		"peer_pid": strconv.Itoa(int(ucred.Pid)),
+		"peer_uid": strconv.Itoa(int(ucred.Uid)),
+		"peer_gid": strconv.Itoa(int(ucred.Gid)),
+	}
+}
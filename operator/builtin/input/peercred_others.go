@@ -0,0 +1,26 @@
+// +build !linux
+
+package input
+
+import (
+	"fmt"
+	"net"
+)
+
+// peerCredentialsSupported is true if the credentials of the peer of a unix socket can be read
+const peerCredentialsSupported = false
+
+// peerCredentialsSize is the size of the control message that holds the credentials of a datagram
+var peerCredentialsSize = 0
+
+func enablePassCredentials(conn *net.UnixConn) error {
+	return fmt.Errorf("peer credentials are not supported on this platform")
+}
+
+func connPeerCredentials(conn *net.UnixConn) (map[string]string, error) {
+	return nil, fmt.Errorf("peer credentials are not supported on this platform")
+}
+
+func messagePeerCredentials(oob []byte) (map[string]string, error) {
+	return nil, fmt.Errorf("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,158 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("replay_input", func() operator.Builder { return NewReplayInputConfig("") })
+}
+
+func NewReplayInputConfig(operatorID string) *ReplayInputConfig {
+	return &ReplayInputConfig{
+		InputConfig: helper.NewInputConfig(operatorID, "replay_input"),
+		Speed:       1,
+	}
+}
+
+// ReplayInputConfig is the configuration of a replay input operator.
+type ReplayInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Path           string  `json:"path"                      yaml:"path"`
+	PreserveTiming bool    `json:"preserve_timing,omitempty" yaml:"preserve_timing,omitempty"`
+	Speed          float64 `json:"speed,omitempty"           yaml:"speed,omitempty"`
+}
+
+// Build will build a replay input operator.
+func (c ReplayInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Path == "" {
+		return nil, fmt.Errorf("missing required parameter 'path'")
+	}
+
+	if c.Speed <= 0 {
+		return nil, fmt.Errorf("speed must be greater than 0")
+	}
+
+	replayInput := &ReplayInput{
+		InputOperator:  inputOperator,
+		path:           c.Path,
+		preserveTiming: c.PreserveTiming,
+		speed:          c.Speed,
+	}
+	return replayInput, nil
+}
+
+// ReplayInput is an operator that emits the entries of a capture written by a lossless file output.
+type ReplayInput struct {
+	helper.InputOperator
+	path           string
+	preserveTiming bool
+	speed          float64
+
+	file     *os.File
+	cancel   context.CancelFunc
+	wg       *sync.WaitGroup
+	finished chan struct{}
+}
+
+// Start will start replaying the capture.
+func (r *ReplayInput) Start() error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("open capture: %s", err)
+	}
+	r.file = file
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg = &sync.WaitGroup{}
+	r.finished = make(chan struct{})
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(r.finished)
+		r.replay(ctx)
+	}()
+
+	return nil
+}
+
+// replay will write each entry of the capture, until the end of the capture or the context is done
+func (r *ReplayInput) replay(ctx context.Context) {
+	decoder := entry.NewCaptureDecoder(r.file)
+
+	var start time.Time
+	var first time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		e, err := decoder.Decode()
+		if err == io.EOF {
+			r.Debugw("Finished replaying capture", zap.String("path", r.path))
+			return
+		}
+		if err != nil {
+			r.Errorw("Failed to read capture", zap.Error(err), zap.String("path", r.path))
+			return
+		}
+
+		// Entries are paced against the first entry, so that the time taken to write
+		// the entries does not add up over the capture
+		if r.preserveTiming {
+			if start.IsZero() {
+				start = time.Now()
+				first = e.Timestamp
+			}
+			offset := time.Duration(float64(e.Timestamp.Sub(first)) / r.speed)
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+
+		r.Write(ctx, e)
+	}
+}
+
+// Stop will stop replaying the capture.
+func (r *ReplayInput) Stop() error {
+	if r.cancel != nil {
+		r.cancel()
+		r.wg.Wait()
+	}
+	if r.file != nil {
+		r.file.Close()
+	}
+	return nil
+}
+
+// Finished returns a channel that is closed once every entry of the capture has been written
+func (r *ReplayInput) Finished() <-chan struct{} {
+	return r.finished
+}
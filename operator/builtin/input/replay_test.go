@@ -0,0 +1,154 @@
+package input
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCapture will write entries to a capture file, returning its path
+func writeCapture(t *testing.T, entries []*entry.Entry) string {
+	var buf bytes.Buffer
+	encoder := entry.NewCaptureEncoder(&buf)
+	for _, e := range entries {
+		require.NoError(t, encoder.Encode(e))
+	}
+
+	path := filepath.Join(testutil.NewTempDir(t), "capture.ndjson")
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0666))
+	return path
+}
+
+// startReplayInput will build and start a replay input, returning a channel of the entries it writes
+func startReplayInput(t *testing.T, cfg *ReplayInputConfig) (*ReplayInput, chan *entry.Entry) {
+	newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+
+	receivedEntries := make(chan *entry.Entry, 100)
+	mockOutput := testutil.NewMockOperator("output1")
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		receivedEntries <- args.Get(1).(*entry.Entry)
+	})
+
+	replayInput := newOperator.(*ReplayInput)
+	require.NoError(t, replayInput.SetOutputs([]operator.Operator{mockOutput}))
+	require.NoError(t, replayInput.Start())
+	return replayInput, receivedEntries
+}
+
+func TestReplayInput(t *testing.T) {
+	start := time.Date(2020, time.July, 21, 10, 30, 0, 0, time.UTC)
+	entries := []*entry.Entry{
+		{
+			Timestamp: start,
+			Severity:  entry.Info,
+			Tags:      []string{"tag"},
+			Labels:    map[string]string{"host": "web-1"},
+			Record:    map[string]interface{}{"status": 200, "message": "first"},
+		},
+		{
+			Timestamp: start.Add(time.Hour),
+			Severity:  entry.Error,
+			Record:    "second",
+		},
+	}
+
+	cfg := NewReplayInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Path = writeCapture(t, entries)
+
+	replayInput, receivedEntries := startReplayInput(t, cfg)
+	defer replayInput.Stop()
+
+	select {
+	case <-replayInput.Finished():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for replay input to finish")
+	}
+
+	require.Len(t, receivedEntries, len(entries))
+	for _, expected := range entries {
+		e := <-receivedEntries
+		require.True(t, expected.Timestamp.Equal(e.Timestamp))
+		e.Timestamp = expected.Timestamp
+		require.Equal(t, expected, e)
+	}
+}
+
+func TestReplayInputPreserveTiming(t *testing.T) {
+	start := time.Date(2020, time.July, 21, 10, 30, 0, 0, time.UTC)
+	entries := []*entry.Entry{
+		{Timestamp: start, Record: "first"},
+		{Timestamp: start.Add(200 * time.Millisecond), Record: "second"},
+		{Timestamp: start.Add(400 * time.Millisecond), Record: "third"},
+	}
+
+	cfg := NewReplayInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Path = writeCapture(t, entries)
+	cfg.PreserveTiming = true
+	cfg.Speed = 2
+
+	started := time.Now()
+	replayInput, receivedEntries := startReplayInput(t, cfg)
+	defer replayInput.Stop()
+
+	select {
+	case <-replayInput.Finished():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for replay input to finish")
+	}
+
+	// The last entry is 400ms after the first, replayed at twice the speed
+	require.True(t, time.Since(started) >= 200*time.Millisecond)
+	require.Len(t, receivedEntries, len(entries))
+	for _, expected := range entries {
+		require.True(t, expected.Timestamp.Equal((<-receivedEntries).Timestamp))
+	}
+}
+
+func TestReplayInputStopWhileWaiting(t *testing.T) {
+	start := time.Date(2020, time.July, 21, 10, 30, 0, 0, time.UTC)
+	cfg := NewReplayInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Path = writeCapture(t, []*entry.Entry{
+		{Timestamp: start, Record: "first"},
+		{Timestamp: start.Add(time.Hour), Record: "second"},
+	})
+	cfg.PreserveTiming = true
+
+	replayInput, receivedEntries := startReplayInput(t, cfg)
+
+	select {
+	case <-receivedEntries:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the first entry")
+	}
+	require.NoError(t, replayInput.Stop())
+	require.Len(t, receivedEntries, 0)
+}
+
+func TestReplayInputInvalid(t *testing.T) {
+	cfg := NewReplayInputConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	_, err := cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+
+	cfg.Path = "/does/not/exist"
+	cfg.Speed = 0
+	_, err = cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+
+	cfg.Speed = 1
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.Error(t, op.Start())
+}
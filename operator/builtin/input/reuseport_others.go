@@ -0,0 +1,15 @@
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package input
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// reusePortSupported is true if multiple sockets can listen on the same port
+const reusePortSupported = false
+
+func reusePort(network, address string, conn syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on this platform")
+}
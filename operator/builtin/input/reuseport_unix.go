@@ -0,0 +1,24 @@
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package input
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortSupported is true if multiple sockets can listen on the same port
+const reusePortSupported = true
+
+// reusePort will set SO_REUSEPORT on a socket, so that the kernel balances datagrams between the sockets of the port
+func reusePort(network, address string, conn syscall.RawConn) error {
+	var sockErr error
+	err := conn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,164 @@
+package input
+
+import (
+	"fmt"
+
+	"github.com/observiq/carbon/errors"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/parser"
+	"github.com/observiq/carbon/operator/helper"
+)
+
+func init() {
+	operator.Register("syslog_input", func() operator.Builder { return NewSyslogInputConfig("") })
+}
+
+// defaultSyslogAddress is the address that syslog is received on when no transport is configured
+const defaultSyslogAddress = "0.0.0.0:514"
+
+func NewSyslogInputConfig(operatorID string) *SyslogInputConfig {
+	return &SyslogInputConfig{
+		InputConfig: helper.NewInputConfig(operatorID, "syslog_input"),
+		Protocol:    parser.SyslogProtocolAuto,
+	}
+}
+
+// SyslogInputConfig is the configuration of a syslog input operator.
+type SyslogInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	Protocol string           `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	UDP      *SyslogUDPConfig `json:"udp,omitempty"      yaml:"udp,omitempty"`
+	TCP      *SyslogTCPConfig `json:"tcp,omitempty"      yaml:"tcp,omitempty"`
+}
+
+// SyslogUDPConfig is the configuration of receiving syslog over udp.
+type SyslogUDPConfig struct {
+	ListenAddress     string `json:"listen_address,omitempty"      yaml:"listen_address,omitempty"`
+	MaxMessageSize    int    `json:"max_message_size,omitempty"    yaml:"max_message_size,omitempty"`
+	ReceiveBufferSize int    `json:"receive_buffer_size,omitempty" yaml:"receive_buffer_size,omitempty"`
+	Readers           int    `json:"readers,omitempty"             yaml:"readers,omitempty"`
+}
+
+// SyslogTCPConfig is the configuration of receiving syslog over tcp.
+type SyslogTCPConfig struct {
+	ListenAddress  string            `json:"listen_address,omitempty"   yaml:"listen_address,omitempty"`
+	TLS            *helper.TLSConfig `json:"tls,omitempty"              yaml:"tls,omitempty"`
+	Framing        string            `json:"framing,omitempty"          yaml:"framing,omitempty"`
+	MaxMessageSize int               `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
+	IdleTimeout    operator.Duration `json:"idle_timeout,omitempty"     yaml:"idle_timeout,omitempty"`
+	MaxConnections int               `json:"max_connections,omitempty"  yaml:"max_connections,omitempty"`
+}
+
+// Build will build a syslog input operator.
+func (c SyslogInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	udpConfig, tcpConfig := c.UDP, c.TCP
+	if udpConfig == nil && tcpConfig == nil {
+		udpConfig = &SyslogUDPConfig{ListenAddress: defaultSyslogAddress}
+		tcpConfig = &SyslogTCPConfig{ListenAddress: defaultSyslogAddress}
+	}
+
+	syslogInput := &SyslogInput{
+		InputOperator: inputOperator,
+	}
+
+	// Messages are received by udp and tcp inputs, and parsed by a syslog parser
+	// that writes them to the outputs of this operator
+	if udpConfig != nil {
+		udpInputConfig := NewUDPInputConfig(c.ID())
+		udpInputConfig.ListenAddress = udpConfig.ListenAddress
+		udpInputConfig.MaxMessageSize = udpConfig.MaxMessageSize
+		udpInputConfig.ReceiveBufferSize = udpConfig.ReceiveBufferSize
+		udpInputConfig.Readers = udpConfig.Readers
+		udpOperator, err := udpInputConfig.Build(context)
+		if err != nil {
+			return nil, errors.Wrap(err, "build udp input")
+		}
+		syslogInput.udpInput = udpOperator.(*UDPInput)
+	}
+
+	if tcpConfig != nil {
+		tcpInputConfig := NewTCPInputConfig(c.ID())
+		tcpInputConfig.ListenAddress = tcpConfig.ListenAddress
+		tcpInputConfig.TLS = tcpConfig.TLS
+		tcpInputConfig.Framing = framingAuto
+		if tcpConfig.Framing != "" {
+			tcpInputConfig.Framing = tcpConfig.Framing
+		}
+		tcpInputConfig.MaxMessageSize = tcpConfig.MaxMessageSize
+		tcpInputConfig.IdleTimeout = tcpConfig.IdleTimeout
+		tcpInputConfig.MaxConnections = tcpConfig.MaxConnections
+		tcpOperator, err := tcpInputConfig.Build(context)
+		if err != nil {
+			return nil, errors.Wrap(err, "build tcp input")
+		}
+		syslogInput.tcpInput = tcpOperator.(*TCPInput)
+	}
+
+	if c.Protocol == "" {
+		return nil, fmt.Errorf("missing field 'protocol'")
+	}
+
+	parserConfig := parser.NewSyslogParserConfig(c.ID())
+	parserConfig.Protocol = c.Protocol
+	parserConfig.ParseTo = c.WriteTo
+	parserOperator, err := parserConfig.Build(context)
+	if err != nil {
+		return nil, errors.Wrap(err, "build syslog parser")
+	}
+	syslogInput.parser = parserOperator.(*parser.SyslogParser)
+
+	return syslogInput, nil
+}
+
+// SyslogInput is an operator that receives and parses syslog messages.
+type SyslogInput struct {
+	helper.InputOperator
+
+	udpInput *UDPInput
+	tcpInput *TCPInput
+	parser   *parser.SyslogParser
+}
+
+// Start will start receiving syslog messages.
+func (s *SyslogInput) Start() error {
+	s.parser.OutputOperators = s.OutputOperators
+
+	if s.udpInput != nil {
+		s.udpInput.OutputOperators = []operator.Operator{s.parser}
+		if err := s.udpInput.Start(); err != nil {
+			return errors.Wrap(err, "start udp input")
+		}
+	}
+
+	if s.tcpInput != nil {
+		s.tcpInput.OutputOperators = []operator.Operator{s.parser}
+		if err := s.tcpInput.Start(); err != nil {
+			if s.udpInput != nil {
+				_ = s.udpInput.Stop()
+			}
+			return errors.Wrap(err, "start tcp input")
+		}
+	}
+
+	return nil
+}
+
+// Stop will stop receiving syslog messages.
+func (s *SyslogInput) Stop() error {
+	var err error
+	if s.udpInput != nil {
+		err = s.udpInput.Stop()
+	}
+	if s.tcpInput != nil {
+		if tcpErr := s.tcpInput.Stop(); tcpErr != nil {
+			err = tcpErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package input
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogInput(t *testing.T) {
+	basicSyslogInputConfig := func() *SyslogInputConfig {
+		cfg := NewSyslogInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		return cfg
+	}
+
+	// startSyslogInput will build and start a syslog input, returning a channel of the entries it writes
+	startSyslogInput := func(t *testing.T, cfg *SyslogInputConfig) (*SyslogInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		syslogInput := newOperator.(*SyslogInput)
+		syslogInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, syslogInput.Start())
+		return syslogInput, entryChan
+	}
+
+	expectEntry := func(t *testing.T, entryChan chan *entry.Entry) *entry.Entry {
+		select {
+		case e := <-entryChan:
+			return e
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for message to be written")
+		}
+		return nil
+	}
+
+	rfc3164Message := "<34>Jan 12 06:30:00 1.2.3.4 apache_server: test message"
+	rfc5424Message := `<86>1 2015-08-05T21:58:59.693Z 192.168.2.132 SecureAuth0 23108 ID52020 [SecureAuth@27389 UserID="Tester2"] Found the user`
+
+	t.Run("Default", func(t *testing.T) {
+		newOperator, err := basicSyslogInputConfig().Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		syslogInput := newOperator.(*SyslogInput)
+		require.Equal(t, defaultSyslogAddress, syslogInput.udpInput.address.String())
+		require.Equal(t, defaultSyslogAddress, syslogInput.tcpInput.address.String())
+	})
+
+	t.Run("UDP", func(t *testing.T) {
+		cfg := basicSyslogInputConfig()
+		cfg.UDP = &SyslogUDPConfig{ListenAddress: "127.0.0.1:63101"}
+		syslogInput, entryChan := startSyslogInput(t, cfg)
+		defer syslogInput.Stop()
+		require.Nil(t, syslogInput.tcpInput)
+
+		conn, err := net.Dial("udp", cfg.UDP.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte(rfc3164Message))
+		require.NoError(t, err)
+		_, err = conn.Write([]byte(rfc5424Message))
+		require.NoError(t, err)
+
+		e := expectEntry(t, entryChan)
+		require.Equal(t, entry.Critical, e.Severity)
+		require.Equal(t, time.Date(time.Now().Year(), 1, 12, 6, 30, 0, 0, time.UTC), e.Timestamp)
+		require.Equal(t, "test message", e.Record.(map[string]interface{})["message"])
+		require.Equal(t, "127.0.0.1", e.Labels["remote_ip"])
+
+		e = expectEntry(t, entryChan)
+		require.Equal(t, entry.Info, e.Severity)
+		value, ok := e.Get(entry.NewRecordField("structured_data", "SecureAuth@27389", "UserID"))
+		require.True(t, ok)
+		require.Equal(t, "Tester2", value)
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		cfg := basicSyslogInputConfig()
+		cfg.TCP = &SyslogTCPConfig{ListenAddress: "127.0.0.1:63102"}
+		syslogInput, entryChan := startSyslogInput(t, cfg)
+		defer syslogInput.Stop()
+		require.Nil(t, syslogInput.udpInput)
+
+		conn, err := net.Dial("tcp", cfg.TCP.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// Newline separated and octet counted messages are both received
+		_, err = conn.Write([]byte(rfc3164Message + "\n"))
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("29 <14>1 - - - - - - line1\nline2"))
+		require.NoError(t, err)
+
+		e := expectEntry(t, entryChan)
+		require.Equal(t, "apache_server", e.Record.(map[string]interface{})["appname"])
+
+		e = expectEntry(t, entryChan)
+		require.Equal(t, entry.Info, e.Severity)
+		require.Equal(t, "line1\nline2", e.Record.(map[string]interface{})["message"])
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		files := testutil.NewTLSFiles(t, "client")
+
+		cfg := basicSyslogInputConfig()
+		cfg.Protocol = "rfc5424"
+		cfg.TCP = &SyslogTCPConfig{
+			ListenAddress: "127.0.0.1:63103",
+			TLS:           &helper.TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile},
+		}
+		syslogInput, entryChan := startSyslogInput(t, cfg)
+		defer syslogInput.Stop()
+
+		conn, err := tls.Dial("tcp", cfg.TCP.ListenAddress, files.ClientConfigWithoutCertificate())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte(rfc5424Message + "\n"))
+		require.NoError(t, err)
+
+		e := expectEntry(t, entryChan)
+		require.Equal(t, "Found the user", e.Record.(map[string]interface{})["message"])
+	})
+
+	t.Run("InvalidMessage", func(t *testing.T) {
+		cfg := basicSyslogInputConfig()
+		cfg.UDP = &SyslogUDPConfig{ListenAddress: "127.0.0.1:63104"}
+		syslogInput, entryChan := startSyslogInput(t, cfg)
+		defer syslogInput.Stop()
+
+		conn, err := net.Dial("udp", cfg.UDP.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("not syslog"))
+		require.NoError(t, err)
+
+		e := expectEntry(t, entryChan)
+		require.Equal(t, "not syslog", e.Record)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*SyslogInputConfig)
+		}{
+			{"InvalidProtocol", func(c *SyslogInputConfig) { c.Protocol = "rfc1234" }},
+			{"MissingUDPAddress", func(c *SyslogInputConfig) { c.UDP = &SyslogUDPConfig{} }},
+			{"MissingTCPAddress", func(c *SyslogInputConfig) { c.TCP = &SyslogTCPConfig{} }},
+			{"InvalidFraming", func(c *SyslogInputConfig) {
+				c.TCP = &SyslogTCPConfig{ListenAddress: "127.0.0.1:63105", Framing: "length"}
+			}},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicSyslogInputConfig()
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
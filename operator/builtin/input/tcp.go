@@ -3,13 +3,17 @@ package input
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/file"
 	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
 )
 
 func init() {
@@ -18,7 +22,9 @@ func init() {
 
 func NewTCPInputConfig(operatorID string) *TCPInputConfig {
 	return &TCPInputConfig{
-		InputConfig: helper.NewInputConfig(operatorID, "tcp_input"),
+		InputConfig:    helper.NewInputConfig(operatorID, "tcp_input"),
+		Framing:        framingNewline,
+		MaxMessageSize: defaultMaxMessageSize,
 	}
 }
 
@@ -26,7 +32,13 @@ func NewTCPInputConfig(operatorID string) *TCPInputConfig {
 type TCPInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	ListenAddress string `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	ListenAddress  string                `json:"listen_address,omitempty"   yaml:"listen_address,omitempty"`
+	TLS            *helper.TLSConfig     `json:"tls,omitempty"              yaml:"tls,omitempty"`
+	Framing        string                `json:"framing,omitempty"          yaml:"framing,omitempty"`
+	Multiline      *file.MultilineConfig `json:"multiline,omitempty"        yaml:"multiline,omitempty"`
+	MaxMessageSize int                   `json:"max_message_size,omitempty" yaml:"max_message_size,omitempty"`
+	IdleTimeout    operator.Duration     `json:"idle_timeout,omitempty"     yaml:"idle_timeout,omitempty"`
+	MaxConnections int                   `json:"max_connections,omitempty"  yaml:"max_connections,omitempty"`
 }
 
 // Build will build a tcp input operator.
@@ -45,9 +57,42 @@ func (c TCPInputConfig) Build(context operator.BuildContext) (operator.Operator,
 		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
 	}
 
+	maxMessageSize := c.MaxMessageSize
+	if maxMessageSize == 0 {
+		maxMessageSize = defaultMaxMessageSize
+	}
+	if maxMessageSize < 0 {
+		return nil, fmt.Errorf("max_message_size must be greater than 0")
+	}
+
+	splitFunc, err := newFramingSplitFunc(c.Framing, c.Multiline, maxMessageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IdleTimeout.Raw() < 0 {
+		return nil, fmt.Errorf("idle_timeout must not be negative")
+	}
+
+	if c.MaxConnections < 0 {
+		return nil, fmt.Errorf("max_connections must not be negative")
+	}
+
 	tcpInput := &TCPInput{
-		InputOperator: inputOperator,
-		address:       address,
+		InputOperator:  inputOperator,
+		address:        address,
+		splitFunc:      splitFunc,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    c.IdleTimeout.Raw(),
+		maxConnections: c.MaxConnections,
+	}
+
+	if c.TLS != nil {
+		tcpInput.tlsConfig, err = c.TLS.Build(inputOperator.SugaredLogger)
+		if err != nil {
+			return nil, fmt.Errorf("build tls config: %s", err)
+		}
+		tcpInput.clientCertificateLabels = c.TLS.ClientCertificateLabels
 	}
 	return tcpInput, nil
 }
@@ -57,7 +102,16 @@ type TCPInput struct {
 	helper.InputOperator
 	address *net.TCPAddr
 
-	listener  *net.TCPListener
+	tlsConfig               *tls.Config
+	clientCertificateLabels bool
+
+	splitFunc      bufio.SplitFunc
+	maxMessageSize int
+	idleTimeout    time.Duration
+	maxConnections int
+	connections    int64
+
+	listener  net.Listener
 	cancel    context.CancelFunc
 	waitGroup *sync.WaitGroup
 }
@@ -70,6 +124,9 @@ func (t *TCPInput) Start() error {
 	}
 
 	t.listener = listener
+	if t.tlsConfig != nil {
+		t.listener = tls.NewListener(listener, t.tlsConfig)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	t.cancel = cancel
 	t.waitGroup = &sync.WaitGroup{}
@@ -84,14 +141,37 @@ func (t *TCPInput) goListen(ctx context.Context) {
 	go func() {
 		defer t.waitGroup.Done()
 
+		var delay time.Duration
 		for {
-			conn, err := t.listener.AcceptTCP()
+			conn, err := t.listener.Accept()
 			if err != nil {
-				t.Debugf("Exiting listener: %s", err)
-				break
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					delay = nextErrorDelay(delay)
+					t.Errorw("Listener accept error", zap.Error(err), "retry_delay", delay)
+					if !waitErrorDelay(ctx, delay) {
+						return
+					}
+					continue
+				}
 			}
+			delay = 0
 
 			t.Debugf("Received connection: %s", conn.RemoteAddr().String())
+			if connections := atomic.AddInt64(&t.connections, 1); t.maxConnections > 0 && connections > int64(t.maxConnections) {
+				t.Warnw("Closing connection because max_connections are already open",
+					"remote_address", conn.RemoteAddr().String(),
+					"max_connections", t.maxConnections,
+				)
+				atomic.AddInt64(&t.connections, -1)
+				if err := conn.Close(); err != nil {
+					t.Errorf("Failed to close connection: %s", err)
+				}
+				continue
+			}
+
 			subctx, cancel := context.WithCancel(ctx)
 			t.goHandleClose(subctx, conn)
 			t.goHandleMessages(subctx, conn, cancel)
@@ -105,6 +185,7 @@ func (t *TCPInput) goHandleClose(ctx context.Context, conn net.Conn) {
 
 	go func() {
 		defer t.waitGroup.Done()
+		defer atomic.AddInt64(&t.connections, -1)
 		<-ctx.Done()
 		t.Debugf("Closing connection: %s", conn.RemoteAddr().String())
 		if err := conn.Close(); err != nil {
@@ -121,31 +202,102 @@ func (t *TCPInput) goHandleMessages(ctx context.Context, conn net.Conn, cancel c
 		defer t.waitGroup.Done()
 		defer cancel()
 
-		reader := bufio.NewReaderSize(conn, 1024*64)
-		for {
-			message, err := t.readMessage(conn, reader)
-			if err != nil {
-				t.Debugf("Exiting message handler: %s", err)
-				break
+		labels, err := t.handshake(conn)
+		if err != nil {
+			t.Warnw("Failed tls handshake", "remote_address", conn.RemoteAddr().String(), zap.Error(err))
+			return
+		}
+		labels = addRemoteLabels(labels, conn.RemoteAddr())
+
+		scanner := bufio.NewScanner(&idleTimeoutReader{conn: conn, timeout: t.idleTimeout})
+		// The buffer must also fit the length that precedes octet counted messages
+		scanner.Buffer(make([]byte, 0, 1024*64), t.maxMessageSize+64)
+		scanner.Split(t.splitFunc)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) > t.maxMessageSize {
+				t.Warnw("Closing connection that sent a message larger than max_message_size", "remote_address", conn.RemoteAddr().String())
+				return
 			}
 
-			entry := t.NewEntry(message)
+			entry := t.NewEntry(scanner.Text())
+			for key, value := range labels {
+				entry.AddLabel(key, value)
+			}
 			t.Write(ctx, entry)
 		}
+
+		switch err := scanner.Err(); {
+		case err == bufio.ErrTooLong:
+			t.Warnw("Closing connection that sent a message larger than max_message_size", "remote_address", conn.RemoteAddr().String())
+		case isTimeout(err):
+			t.Debugf("Closing idle connection: %s", conn.RemoteAddr().String())
+		case err != nil:
+			t.Debugf("Exiting message handler: %s", err)
+		}
 	}()
 }
 
-// readMessage will read a log message from a TCP connection.
-func (t *TCPInput) readMessage(conn net.Conn, reader *bufio.Reader) (string, error) {
-	message, err := reader.ReadBytes('\n')
+// tlsHandshakeTimeout is the maximum duration of a tls handshake
+var tlsHandshakeTimeout = 10 * time.Second
+
+// handshake will complete the tls handshake of a connection, so that clients that never complete
+// it do not hold the connection open. It returns the labels of the client certificate, if enabled.
+func (t *TCPInput) handshake(conn net.Conn) (map[string]string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout)); err != nil {
+		return nil, err
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	if err := tlsConn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	if !t.clientCertificateLabels {
+		return nil, nil
+	}
+	return helper.ClientCertificateLabels(tlsConn.ConnectionState()), nil
+}
+
+// addRemoteLabels will add the ip and port of the remote address of a connection to its labels
+func addRemoteLabels(labels map[string]string, addr net.Addr) map[string]string {
+	host, port, err := net.SplitHostPort(addr.String())
 	if err != nil {
-		if err == io.EOF {
-			return string(message), err
+		return labels
+	}
+
+	if labels == nil {
+		labels = make(map[string]string, 2)
+	}
+	labels["remote_ip"] = host
+	labels["remote_port"] = port
+	return labels
+}
+
+// idleTimeoutReader closes the connection it reads from if no data is received for the timeout
+type idleTimeoutReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// Read will read from the connection, failing if no data is received before the timeout
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	if r.timeout > 0 {
+		if err := r.conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+			return 0, err
 		}
-		return "", err
 	}
+	return r.conn.Read(p)
+}
 
-	return string(message[:len(message)-1]), nil
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
 }
 
 // Stop will stop listening for log entries over TCP.
@@ -1,13 +1,18 @@
 package input
 
 import (
+	"crypto/tls"
+	"fmt"
+	"io"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/observiq/carbon/entry"
 	"github.com/observiq/carbon/internal/testutil"
 	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/file"
 	"github.com/observiq/carbon/operator/helper"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -57,13 +62,272 @@ func TestTCPInput(t *testing.T) {
 		_, err = conn.Write([]byte("message1\n"))
 		require.NoError(t, err)
 
+		_, localPort, err := net.SplitHostPort(conn.LocalAddr().String())
+		require.NoError(t, err)
+
 		expectedRecord := "message1"
 		select {
 		case entry := <-entryChan:
 			require.Equal(t, expectedRecord, entry.Record)
+			require.Equal(t, map[string]string{
+				"remote_ip":   "127.0.0.1",
+				"remote_port": localPort,
+			}, entry.Labels)
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for message to be written")
+		}
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		files := testutil.NewTLSFiles(t, "host1.example.com")
+
+		cfg := basicTCPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:64002"
+		cfg.TLS = &helper.TLSConfig{
+			CertFile:                files.ServerCertFile,
+			KeyFile:                 files.ServerKeyFile,
+			CAFile:                  files.CAFile,
+			ClientAuth:              "require",
+			ClientCertificateLabels: true,
+		}
+
+		buildContext := testutil.NewBuildContext(t)
+		newOperator, err := cfg.Build(buildContext)
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		tcpInput := newOperator.(*TCPInput)
+		tcpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 1)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		err = tcpInput.Start()
+		require.NoError(t, err)
+		defer tcpInput.Stop()
+
+		// Connections without a client certificate are rejected
+		conn, err := tls.Dial("tcp", "127.0.0.1:64002", files.ClientConfigWithoutCertificate())
+		if err == nil {
+			_, _ = conn.Write([]byte("rejected\n"))
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = conn.Read(make([]byte, 1))
+			require.Error(t, err)
+			conn.Close()
+		}
+
+		conn, err = tls.Dial("tcp", "127.0.0.1:64002", files.ClientConfig(t))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("message1\n"))
+		require.NoError(t, err)
+
+		_, localPort, err := net.SplitHostPort(conn.LocalAddr().String())
+		require.NoError(t, err)
+
+		select {
+		case entry := <-entryChan:
+			require.Equal(t, "message1", entry.Record)
+			require.Equal(t, map[string]string{
+				"tls_client_subject":      "CN=host1.example.com,O=carbon",
+				"tls_client_common_name":  "host1.example.com",
+				"tls_client_organization": "carbon",
+				"remote_ip":               "127.0.0.1",
+				"remote_port":             localPort,
+			}, entry.Labels)
 		case <-time.After(time.Second):
 			require.FailNow(t, "Timed out waiting for message to be written")
 		}
 	})
 
+	t.Run("InvalidTLS", func(t *testing.T) {
+		cfg := basicTCPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:64003"
+		cfg.TLS = &helper.TLSConfig{
+			CertFile: "/does/not/exist.crt",
+			KeyFile:  "/does/not/exist.key",
+		}
+
+		_, err := cfg.Build(testutil.NewBuildContext(t))
+		require.Error(t, err)
+	})
+
+	// startTCPInput will build and start a tcp input, returning a channel of the entries it writes
+	startTCPInput := func(t *testing.T, cfg *TCPInputConfig) (*TCPInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		tcpInput := newOperator.(*TCPInput)
+		tcpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, tcpInput.Start())
+		return tcpInput, entryChan
+	}
+
+	expectRecords := func(t *testing.T, entryChan chan *entry.Entry, expected ...string) {
+		for _, record := range expected {
+			select {
+			case entry := <-entryChan:
+				require.Equal(t, record, entry.Record)
+			case <-time.After(time.Second):
+				require.FailNow(t, "Timed out waiting for message to be written")
+			}
+		}
+	}
+
+	expectClosed := func(t *testing.T, conn net.Conn) {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		require.Equal(t, io.EOF, err)
+	}
+
+	t.Run("Framing", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			framing  string
+			input    string
+			expected []string
+		}{
+			{"Newline", framingNewline, "message1\nmessage2\r\npartial", []string{"message1", "message2", "partial"}},
+			{"OctetCounting", framingOctetCounting, "8 message18 message2\n10 multi\nline", []string{"message1", "message2", "multi\nline"}},
+			{"Null", framingNull, "message1\x00multi\nline\x00partial", []string{"message1", "multi\nline", "partial"}},
+		}
+
+		for i, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicTCPInputConfig()
+				cfg.ListenAddress = fmt.Sprintf("127.0.0.1:%d", 64010+i)
+				cfg.Framing = tc.framing
+				tcpInput, entryChan := startTCPInput(t, cfg)
+				defer tcpInput.Stop()
+
+				conn, err := net.Dial("tcp", cfg.ListenAddress)
+				require.NoError(t, err)
+				_, err = conn.Write([]byte(tc.input))
+				require.NoError(t, err)
+				conn.Close()
+
+				expectRecords(t, entryChan, tc.expected...)
+			})
+		}
+	})
+
+	t.Run("Multiline", func(t *testing.T) {
+		cfg := basicTCPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:64020"
+		cfg.Framing = framingMultiline
+		cfg.Multiline = &file.MultilineConfig{LineStartPattern: "start "}
+		tcpInput, entryChan := startTCPInput(t, cfg)
+		defer tcpInput.Stop()
+
+		conn, err := net.Dial("tcp", cfg.ListenAddress)
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("start 1\n  continued\nstart 2\n"))
+		require.NoError(t, err)
+		conn.Close()
+
+		expectRecords(t, entryChan, "start 1\n  continued\n", "start 2\n")
+	})
+
+	t.Run("MessageTooLarge", func(t *testing.T) {
+		cfg := basicTCPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:64021"
+		cfg.MaxMessageSize = 10
+		tcpInput, entryChan := startTCPInput(t, cfg)
+		defer tcpInput.Stop()
+
+		conn, err := net.Dial("tcp", cfg.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("short\nthis message is too long\nnext\n"))
+		require.NoError(t, err)
+
+		expectRecords(t, entryChan, "short")
+		expectClosed(t, conn)
+		require.Len(t, entryChan, 0)
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		cfg := basicTCPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:64022"
+		cfg.IdleTimeout = operator.Duration{Duration: 100 * time.Millisecond}
+		tcpInput, entryChan := startTCPInput(t, cfg)
+		defer tcpInput.Stop()
+
+		conn, err := net.Dial("tcp", cfg.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("message1\n"))
+		require.NoError(t, err)
+
+		expectRecords(t, entryChan, "message1")
+		expectClosed(t, conn)
+	})
+
+	t.Run("MaxConnections", func(t *testing.T) {
+		cfg := basicTCPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:64023"
+		cfg.MaxConnections = 1
+		tcpInput, entryChan := startTCPInput(t, cfg)
+		defer tcpInput.Stop()
+
+		first, err := net.Dial("tcp", cfg.ListenAddress)
+		require.NoError(t, err)
+		_, err = first.Write([]byte("first\n"))
+		require.NoError(t, err)
+		expectRecords(t, entryChan, "first")
+
+		rejected, err := net.Dial("tcp", cfg.ListenAddress)
+		require.NoError(t, err)
+		defer rejected.Close()
+		expectClosed(t, rejected)
+
+		// A connection is accepted again once the open connection is closed
+		first.Close()
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt64(&tcpInput.connections) == 0
+		}, time.Second, 10*time.Millisecond)
+
+		second, err := net.Dial("tcp", cfg.ListenAddress)
+		require.NoError(t, err)
+		defer second.Close()
+		_, err = second.Write([]byte("second\n"))
+		require.NoError(t, err)
+		expectRecords(t, entryChan, "second")
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*TCPInputConfig)
+		}{
+			{"InvalidFraming", func(c *TCPInputConfig) { c.Framing = "length" }},
+			{"MultilineWithoutFraming", func(c *TCPInputConfig) { c.Multiline = &file.MultilineConfig{LineStartPattern: "^start"} }},
+			{"MultilineFramingWithoutConfig", func(c *TCPInputConfig) { c.Framing = framingMultiline }},
+			{"NegativeMaxMessageSize", func(c *TCPInputConfig) { c.MaxMessageSize = -1 }},
+			{"NegativeIdleTimeout", func(c *TCPInputConfig) { c.IdleTimeout = operator.Duration{Duration: -time.Second} }},
+			{"NegativeMaxConnections", func(c *TCPInputConfig) { c.MaxConnections = -1 }},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicTCPInputConfig()
+				cfg.ListenAddress = "127.0.0.1:64024"
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
 }
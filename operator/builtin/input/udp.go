@@ -9,15 +9,21 @@ import (
 
 	"github.com/observiq/carbon/operator"
 	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
 )
 
 func init() {
 	operator.Register("udp_input", func() operator.Builder { return NewUDPInputConfig("") })
 }
 
+// maxUDPMessageSize is the largest payload that fits in a udp datagram
+const maxUDPMessageSize = 65535
+
 func NewUDPInputConfig(operatorID string) *UDPInputConfig {
 	return &UDPInputConfig{
-		InputConfig: helper.NewInputConfig(operatorID, "udp_input"),
+		InputConfig:    helper.NewInputConfig(operatorID, "udp_input"),
+		MaxMessageSize: maxUDPMessageSize,
+		Readers:        1,
 	}
 }
 
@@ -25,7 +31,10 @@ func NewUDPInputConfig(operatorID string) *UDPInputConfig {
 type UDPInputConfig struct {
 	helper.InputConfig `yaml:",inline"`
 
-	ListenAddress string `json:"listen_address,omitempty" yaml:"listen_address,omitempty"`
+	ListenAddress     string `json:"listen_address,omitempty"      yaml:"listen_address,omitempty"`
+	MaxMessageSize    int    `json:"max_message_size,omitempty"    yaml:"max_message_size,omitempty"`
+	ReceiveBufferSize int    `json:"receive_buffer_size,omitempty" yaml:"receive_buffer_size,omitempty"`
+	Readers           int    `json:"readers,omitempty"             yaml:"readers,omitempty"`
 }
 
 // Build will build a udp input operator.
@@ -44,9 +53,40 @@ func (c UDPInputConfig) Build(context operator.BuildContext) (operator.Operator,
 		return nil, fmt.Errorf("failed to resolve listen_address: %s", err)
 	}
 
+	maxMessageSize := c.MaxMessageSize
+	if maxMessageSize == 0 {
+		maxMessageSize = maxUDPMessageSize
+	}
+	if maxMessageSize < 0 || maxMessageSize > maxUDPMessageSize {
+		return nil, fmt.Errorf("max_message_size must be between 1 and %d", maxUDPMessageSize)
+	}
+
+	if c.ReceiveBufferSize < 0 {
+		return nil, fmt.Errorf("receive_buffer_size must not be negative")
+	}
+
+	readers := c.Readers
+	if readers == 0 {
+		readers = 1
+	}
+	if readers < 0 {
+		return nil, fmt.Errorf("readers must be greater than 0")
+	}
+
 	udpInput := &UDPInput{
-		InputOperator: inputOperator,
-		address:       address,
+		InputOperator:     inputOperator,
+		address:           address,
+		maxMessageSize:    maxMessageSize,
+		receiveBufferSize: c.ReceiveBufferSize,
+		readers:           readers,
+		buffers: sync.Pool{
+			// A datagram larger than the maximum fills the extra byte, so truncation can be detected
+			// Pointers are pooled, so that putting a buffer back does not allocate
+			New: func() interface{} {
+				buffer := make([]byte, maxMessageSize+1)
+				return &buffer
+			},
+		},
 	}
 	return udpInput, nil
 }
@@ -54,11 +94,15 @@ func (c UDPInputConfig) Build(context operator.BuildContext) (operator.Operator,
 // UDPInput is an operator that listens to a socket for log entries.
 type UDPInput struct {
 	helper.InputOperator
-	address *net.UDPAddr
-
-	connection net.PacketConn
-	cancel     context.CancelFunc
-	waitGroup  *sync.WaitGroup
+	address           *net.UDPAddr
+	maxMessageSize    int
+	receiveBufferSize int
+	readers           int
+	buffers           sync.Pool
+
+	connections []net.PacketConn
+	cancel      context.CancelFunc
+	waitGroup   *sync.WaitGroup
 }
 
 // Start will start listening for messages on a socket.
@@ -67,49 +111,106 @@ func (u *UDPInput) Start() error {
 	u.cancel = cancel
 	u.waitGroup = &sync.WaitGroup{}
 
-	conn, err := net.ListenUDP("udp", u.address)
+	conns, err := u.listen(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to open connection: %s", err)
+		return err
 	}
-	u.connection = conn
+	u.connections = conns
 
-	u.goHandleMessages(ctx)
+	// Without SO_REUSEPORT, the readers share a single socket
+	for i := 0; i < u.readers; i++ {
+		u.goHandleMessages(ctx, conns[i%len(conns)])
+	}
 	return nil
 }
 
+// listen will open a socket for each reader if the port can be reused, and a single socket otherwise
+func (u *UDPInput) listen(ctx context.Context) ([]net.PacketConn, error) {
+	count := 1
+	listenConfig := net.ListenConfig{}
+	if u.readers > 1 && reusePortSupported {
+		count = u.readers
+		listenConfig.Control = reusePort
+	}
+
+	// The sockets after the first listen on its address, so that they share the port it was assigned
+	// if the configured port is 0
+	address := u.address.String()
+	conns := make([]net.PacketConn, 0, count)
+	for i := 0; i < count; i++ {
+		conn, err := listenConfig.ListenPacket(ctx, "udp", address)
+		if err != nil {
+			closeAll(conns)
+			return nil, fmt.Errorf("failed to open connection: %s", err)
+		}
+		conns = append(conns, conn)
+		address = conn.LocalAddr().String()
+
+		if u.receiveBufferSize == 0 {
+			continue
+		}
+		if err := conn.(*net.UDPConn).SetReadBuffer(u.receiveBufferSize); err != nil {
+			closeAll(conns)
+			return nil, fmt.Errorf("failed to set receive_buffer_size: %s", err)
+		}
+	}
+	return conns, nil
+}
+
+func closeAll(conns []net.PacketConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
 // goHandleMessages will handle messages from a udp connection.
-func (u *UDPInput) goHandleMessages(ctx context.Context) {
+func (u *UDPInput) goHandleMessages(ctx context.Context, conn net.PacketConn) {
 	u.waitGroup.Add(1)
 
 	go func() {
 		defer u.waitGroup.Done()
 
 		for {
-			message, err := u.readMessage()
-			if err != nil && u.isExpectedClose(err) {
-				u.Debugf("Exiting message handler: %s", err)
-				break
+			message, addr, err := u.readMessage(conn)
+			if err != nil {
+				if u.isExpectedClose(err) {
+					u.Debugf("Exiting message handler: %s", err)
+					break
+				}
+				u.Warnw("Failed to read message", zap.Error(err))
+				continue
 			}
 
 			entry := u.NewEntry(message)
+			for key, value := range addRemoteLabels(nil, addr) {
+				entry.AddLabel(key, value)
+			}
 			u.Write(ctx, entry)
 		}
 	}()
 }
 
-// readMessage will read log messages from the connection.
-func (u *UDPInput) readMessage() (string, error) {
-	buffer := make([]byte, 1024)
-	n, _, err := u.connection.ReadFrom(buffer)
+// readMessage will read a log message and the address of its sender from the connection.
+func (u *UDPInput) readMessage(conn net.PacketConn) (string, net.Addr, error) {
+	bufferPtr := u.buffers.Get().(*[]byte)
+	defer u.buffers.Put(bufferPtr)
+	buffer := *bufferPtr
+
+	n, addr, err := conn.ReadFrom(buffer)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	if n > u.maxMessageSize {
+		u.Warnw("Truncated message larger than max_message_size", "remote_address", addr.String(), "max_message_size", u.maxMessageSize)
+		n = u.maxMessageSize
 	}
 
 	// Remove trailing characters and NULs
 	for ; (n > 0) && (buffer[n-1] < 32); n-- {
 	}
 
-	return string(buffer[:n]), nil
+	return string(buffer[:n]), addr, nil
 }
 
 // isExpectedClose will determine if an error was the result of a closed connection.
@@ -120,7 +221,7 @@ func (u *UDPInput) isExpectedClose(err error) bool {
 // Stop will stop listening for udp messages.
 func (u *UDPInput) Stop() error {
 	u.cancel()
-	u.connection.Close()
+	closeAll(u.connections)
 	u.waitGroup.Wait()
 	return nil
 }
@@ -1,7 +1,9 @@
 package input
 
 import (
+	"fmt"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -59,13 +61,155 @@ func TestUDPInput(t *testing.T) {
 		_, err = conn.Write([]byte("message1\n"))
 		require.NoError(t, err)
 
+		_, localPort, err := net.SplitHostPort(conn.LocalAddr().String())
+		require.NoError(t, err)
+
 		expectedRecord := "message1"
 		select {
 		case entry := <-entryChan:
 			require.Equal(t, expectedRecord, entry.Record)
+			require.Equal(t, map[string]string{
+				"remote_ip":   "127.0.0.1",
+				"remote_port": localPort,
+			}, entry.Labels)
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for message to be written")
+		}
+	})
+
+	// startUDPInput will build and start a udp input, returning a channel of the entries it writes
+	startUDPInput := func(t *testing.T, cfg *UDPInputConfig) (*UDPInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		udpInput := newOperator.(*UDPInput)
+		udpInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 100)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, udpInput.Start())
+		return udpInput, entryChan
+	}
+
+	t.Run("LargeMessage", func(t *testing.T) {
+		cfg := basicUDPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:63002"
+		cfg.MaxMessageSize = maxUDPMessageSize
+		udpInput, entryChan := startUDPInput(t, cfg)
+		defer udpInput.Stop()
+
+		conn, err := net.Dial("udp", cfg.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		message := strings.Repeat("a", 16*1024)
+		_, err = conn.Write([]byte(message))
+		require.NoError(t, err)
+
+		select {
+		case entry := <-entryChan:
+			require.Equal(t, message, entry.Record)
 		case <-time.After(time.Second):
 			require.FailNow(t, "Timed out waiting for message to be written")
 		}
 	})
 
+	t.Run("Truncated", func(t *testing.T) {
+		cfg := basicUDPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:63003"
+		cfg.MaxMessageSize = 10
+		udpInput, entryChan := startUDPInput(t, cfg)
+		defer udpInput.Stop()
+
+		conn, err := net.Dial("udp", cfg.ListenAddress)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("this message is too long"))
+		require.NoError(t, err)
+
+		select {
+		case entry := <-entryChan:
+			require.Equal(t, "this messa", entry.Record)
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for message to be written")
+		}
+	})
+
+	t.Run("MultipleReaders", func(t *testing.T) {
+		cfg := basicUDPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:63004"
+		cfg.Readers = 4
+		cfg.ReceiveBufferSize = 1024 * 1024
+		udpInput, entryChan := startUDPInput(t, cfg)
+		defer udpInput.Stop()
+
+		if reusePortSupported {
+			require.Len(t, udpInput.connections, 4)
+		}
+
+		// Each sender uses a different source port, so that datagrams are balanced between the sockets
+		expected := make(map[string]bool)
+		for i := 0; i < 20; i++ {
+			conn, err := net.Dial("udp", cfg.ListenAddress)
+			require.NoError(t, err)
+			message := fmt.Sprintf("message%d", i)
+			_, err = conn.Write([]byte(message))
+			require.NoError(t, err)
+			conn.Close()
+			expected[message] = true
+		}
+
+		received := make(map[string]bool)
+		for range expected {
+			select {
+			case entry := <-entryChan:
+				received[entry.Record.(string)] = true
+			case <-time.After(time.Second):
+				require.FailNow(t, "Timed out waiting for message to be written")
+			}
+		}
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("MultipleReadersAnyPort", func(t *testing.T) {
+		cfg := basicUDPInputConfig()
+		cfg.ListenAddress = "127.0.0.1:0"
+		cfg.Readers = 4
+		udpInput, _ := startUDPInput(t, cfg)
+		defer udpInput.Stop()
+
+		// The sockets share the port that was assigned to the first
+		port := udpInput.connections[0].LocalAddr().(*net.UDPAddr).Port
+		require.NotZero(t, port)
+		for _, conn := range udpInput.connections {
+			require.Equal(t, port, conn.LocalAddr().(*net.UDPAddr).Port)
+		}
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*UDPInputConfig)
+		}{
+			{"MaxMessageSizeTooLarge", func(c *UDPInputConfig) { c.MaxMessageSize = maxUDPMessageSize + 1 }},
+			{"NegativeMaxMessageSize", func(c *UDPInputConfig) { c.MaxMessageSize = -1 }},
+			{"NegativeReceiveBufferSize", func(c *UDPInputConfig) { c.ReceiveBufferSize = -1 }},
+			{"NegativeReaders", func(c *UDPInputConfig) { c.Readers = -1 }},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicUDPInputConfig()
+				cfg.ListenAddress = "127.0.0.1:63005"
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
 }
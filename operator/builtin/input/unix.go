@@ -0,0 +1,417 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input/file"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("unix_input", func() operator.Builder { return NewUnixInputConfig("") })
+}
+
+// Socket types supported by the unix input
+const (
+	socketTypeStream   = "stream"
+	socketTypeDatagram = "datagram"
+)
+
+func NewUnixInputConfig(operatorID string) *UnixInputConfig {
+	return &UnixInputConfig{
+		InputConfig:    helper.NewInputConfig(operatorID, "unix_input"),
+		SocketType:     socketTypeDatagram,
+		Mode:           "0666",
+		Framing:        framingNewline,
+		MaxMessageSize: defaultMaxMessageSize,
+	}
+}
+
+// UnixInputConfig is the configuration of a unix input operator.
+type UnixInputConfig struct {
+	helper.InputConfig `yaml:",inline"`
+
+	SocketPath           string                `json:"socket_path,omitempty"            yaml:"socket_path,omitempty"`
+	SocketType           string                `json:"socket_type,omitempty"            yaml:"socket_type,omitempty"`
+	Mode                 string                `json:"mode,omitempty"                   yaml:"mode,omitempty"`
+	Owner                string                `json:"owner,omitempty"                  yaml:"owner,omitempty"`
+	Group                string                `json:"group,omitempty"                  yaml:"group,omitempty"`
+	Framing              string                `json:"framing,omitempty"                yaml:"framing,omitempty"`
+	Multiline            *file.MultilineConfig `json:"multiline,omitempty"              yaml:"multiline,omitempty"`
+	MaxMessageSize       int                   `json:"max_message_size,omitempty"       yaml:"max_message_size,omitempty"`
+	PeerCredentialLabels bool                  `json:"peer_credential_labels,omitempty" yaml:"peer_credential_labels,omitempty"`
+}
+
+// Build will build a unix input operator.
+func (c UnixInputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.SocketPath == "" {
+		return nil, fmt.Errorf("missing required parameter 'socket_path'")
+	}
+
+	maxMessageSize := c.MaxMessageSize
+	if maxMessageSize == 0 {
+		maxMessageSize = defaultMaxMessageSize
+	}
+	if maxMessageSize < 0 {
+		return nil, fmt.Errorf("max_message_size must be greater than 0")
+	}
+
+	unixInput := &UnixInput{
+		InputOperator:        inputOperator,
+		socketPath:           c.SocketPath,
+		socketType:           c.SocketType,
+		maxMessageSize:       maxMessageSize,
+		peerCredentialLabels: c.PeerCredentialLabels,
+		uid:                  -1,
+		gid:                  -1,
+	}
+
+	switch c.SocketType {
+	case socketTypeStream:
+		unixInput.splitFunc, err = newFramingSplitFunc(c.Framing, c.Multiline, maxMessageSize)
+		if err != nil {
+			return nil, err
+		}
+	case socketTypeDatagram:
+		if c.Framing != "" && c.Framing != framingNewline || c.Multiline != nil {
+			return nil, fmt.Errorf("framing can only be configured for socket_type '%s'", socketTypeStream)
+		}
+	default:
+		return nil, fmt.Errorf("invalid socket_type '%s'", c.SocketType)
+	}
+
+	if c.PeerCredentialLabels && !peerCredentialsSupported {
+		return nil, fmt.Errorf("peer_credential_labels is not supported on this platform")
+	}
+
+	mode, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return nil, fmt.Errorf("invalid mode '%s'", c.Mode)
+	}
+	unixInput.mode = os.FileMode(mode)
+
+	if c.Owner != "" {
+		unixInput.uid, err = lookupID(c.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner: %s", err)
+		}
+	}
+
+	if c.Group != "" {
+		unixInput.gid, err = lookupID(c.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid group: %s", err)
+		}
+	}
+
+	return unixInput, nil
+}
+
+// lookupID will return a numeric id as is, and look up the id of a name otherwise
+func lookupID(nameOrID string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	id, err := lookup(nameOrID)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// UnixInput is an operator that listens for log entries on a unix domain socket.
+type UnixInput struct {
+	helper.InputOperator
+	socketPath           string
+	socketType           string
+	mode                 os.FileMode
+	uid                  int
+	gid                  int
+	splitFunc            bufio.SplitFunc
+	maxMessageSize       int
+	peerCredentialLabels bool
+
+	listener  *net.UnixListener
+	conn      *net.UnixConn
+	cancel    context.CancelFunc
+	waitGroup *sync.WaitGroup
+}
+
+// Start will start listening for log entries on the socket.
+func (u *UnixInput) Start() error {
+	if err := u.removeStaleSocket(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u.cancel = cancel
+	u.waitGroup = &sync.WaitGroup{}
+
+	address := &net.UnixAddr{Name: u.socketPath}
+	switch u.socketType {
+	case socketTypeStream:
+		address.Net = "unix"
+		listener, err := net.ListenUnix("unix", address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on socket: %s", err)
+		}
+		u.listener = listener
+	default:
+		address.Net = "unixgram"
+		conn, err := net.ListenUnixgram("unixgram", address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on socket: %s", err)
+		}
+		u.conn = conn
+	}
+
+	if err := u.setPermissions(); err != nil {
+		_ = u.close()
+		return err
+	}
+
+	if u.listener != nil {
+		u.goListen(ctx)
+		return nil
+	}
+
+	if u.peerCredentialLabels {
+		if err := enablePassCredentials(u.conn); err != nil {
+			_ = u.close()
+			return fmt.Errorf("failed to enable peer credentials: %s", err)
+		}
+	}
+	u.goHandleDatagrams(ctx)
+	return nil
+}
+
+// removeStaleSocket will remove a socket file left behind by a previous process, but not a socket that is in use
+func (u *UnixInput) removeStaleSocket() error {
+	info, err := os.Lstat(u.socketPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check socket_path: %s", err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("socket_path '%s' exists and is not a socket", u.socketPath)
+	}
+
+	network := "unixgram"
+	if u.socketType == socketTypeStream {
+		network = "unix"
+	}
+	if conn, err := net.Dial(network, u.socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket_path '%s' is in use by another process", u.socketPath)
+	}
+
+	u.Debugw("Removing stale socket file", "socket_path", u.socketPath)
+	if err := os.Remove(u.socketPath); err != nil {
+		return fmt.Errorf("failed to remove stale socket: %s", err)
+	}
+	return nil
+}
+
+// setPermissions will set the mode and ownership of the socket file
+func (u *UnixInput) setPermissions() error {
+	if err := os.Chmod(u.socketPath, u.mode); err != nil {
+		return fmt.Errorf("failed to set socket mode: %s", err)
+	}
+
+	if u.uid == -1 && u.gid == -1 {
+		return nil
+	}
+	if err := os.Chown(u.socketPath, u.uid, u.gid); err != nil {
+		return fmt.Errorf("failed to set socket owner: %s", err)
+	}
+	return nil
+}
+
+// goListen will listen for connections to a stream socket.
+func (u *UnixInput) goListen(ctx context.Context) {
+	u.waitGroup.Add(1)
+
+	go func() {
+		defer u.waitGroup.Done()
+
+		var delay time.Duration
+		for {
+			conn, err := u.listener.AcceptUnix()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					delay = nextErrorDelay(delay)
+					u.Errorw("Listener accept error", zap.Error(err), "retry_delay", delay)
+					if !waitErrorDelay(ctx, delay) {
+						return
+					}
+					continue
+				}
+			}
+			delay = 0
+
+			subctx, cancel := context.WithCancel(ctx)
+			u.goHandleClose(subctx, conn)
+			u.goHandleConnection(subctx, conn, cancel)
+		}
+	}()
+}
+
+// goHandleClose will wait for the context to finish before closing a connection.
+func (u *UnixInput) goHandleClose(ctx context.Context, conn *net.UnixConn) {
+	u.waitGroup.Add(1)
+
+	go func() {
+		defer u.waitGroup.Done()
+		<-ctx.Done()
+		if err := conn.Close(); err != nil {
+			u.Errorf("Failed to close connection: %s", err)
+		}
+	}()
+}
+
+// goHandleConnection will handle the messages of a connection to a stream socket.
+func (u *UnixInput) goHandleConnection(ctx context.Context, conn *net.UnixConn, cancel context.CancelFunc) {
+	u.waitGroup.Add(1)
+
+	go func() {
+		defer u.waitGroup.Done()
+		defer cancel()
+
+		var labels map[string]string
+		if u.peerCredentialLabels {
+			var err error
+			labels, err = connPeerCredentials(conn)
+			if err != nil {
+				u.Warnw("Failed to get peer credentials", zap.Error(err))
+			}
+		}
+
+		scanner := bufio.NewScanner(conn)
+		// The buffer must also fit the length that precedes octet counted messages
+		scanner.Buffer(make([]byte, 0, 1024*64), u.maxMessageSize+64)
+		scanner.Split(u.splitFunc)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) > u.maxMessageSize {
+				u.Warnw("Closing connection that sent a message larger than max_message_size")
+				return
+			}
+			u.write(ctx, scanner.Text(), labels)
+		}
+
+		if err := scanner.Err(); err != nil {
+			u.Debugf("Exiting message handler: %s", err)
+		}
+	}()
+}
+
+// goHandleDatagrams will handle the messages received by a datagram socket.
+func (u *UnixInput) goHandleDatagrams(ctx context.Context) {
+	u.waitGroup.Add(1)
+
+	go func() {
+		defer u.waitGroup.Done()
+
+		// A datagram larger than the maximum fills the extra byte, so truncation can be detected
+		buffer := make([]byte, u.maxMessageSize+1)
+		oob := make([]byte, peerCredentialsSize)
+		var delay time.Duration
+		for {
+			n, oobn, _, _, err := u.conn.ReadMsgUnix(buffer, oob)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					delay = nextErrorDelay(delay)
+					u.Warnw("Failed to read message", zap.Error(err), "retry_delay", delay)
+					if !waitErrorDelay(ctx, delay) {
+						return
+					}
+					continue
+				}
+			}
+			delay = 0
+
+			if n > u.maxMessageSize {
+				u.Warnw("Truncated message larger than max_message_size", "max_message_size", u.maxMessageSize)
+				n = u.maxMessageSize
+			}
+
+			var labels map[string]string
+			if u.peerCredentialLabels {
+				labels, err = messagePeerCredentials(oob[:oobn])
+				if err != nil {
+					u.Warnw("Failed to get peer credentials", zap.Error(err))
+				}
+			}
+
+			// Remove trailing characters and NULs
+			for ; (n > 0) && (buffer[n-1] < 32); n-- {
+			}
+			u.write(ctx, string(buffer[:n]), labels)
+		}
+	}()
+}
+
+func (u *UnixInput) write(ctx context.Context, message string, labels map[string]string) {
+	entry := u.NewEntry(message)
+	for key, value := range labels {
+		entry.AddLabel(key, value)
+	}
+	u.Write(ctx, entry)
+}
+
+// close will close the socket. Datagram sockets are not removed when they are closed, so they are removed here.
+func (u *UnixInput) close() error {
+	if u.listener != nil {
+		return u.listener.Close()
+	}
+
+	err := u.conn.Close()
+	if removeErr := os.Remove(u.socketPath); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Stop will stop listening for log entries on the socket, and remove the socket file.
+func (u *UnixInput) Stop() error {
+	u.cancel()
+	err := u.close()
+	u.waitGroup.Wait()
+	return err
+}
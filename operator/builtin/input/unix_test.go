@@ -0,0 +1,235 @@
+package input
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixInput(t *testing.T) {
+	basicUnixInputConfig := func(t *testing.T, socketType string) *UnixInputConfig {
+		cfg := NewUnixInputConfig("test_id")
+		cfg.OutputIDs = []string{"test_output_id"}
+		cfg.SocketPath = filepath.Join(testutil.NewTempDir(t), "test.sock")
+		cfg.SocketType = socketType
+		return cfg
+	}
+
+	// startUnixInput will build and start a unix input, returning a channel of the entries it writes
+	startUnixInput := func(t *testing.T, cfg *UnixInputConfig) (*UnixInput, chan *entry.Entry) {
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		unixInput := newOperator.(*UnixInput)
+		unixInput.InputOperator.OutputOperators = []operator.Operator{&mockOutput}
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, unixInput.Start())
+		return unixInput, entryChan
+	}
+
+	expectEntry := func(t *testing.T, entryChan chan *entry.Entry) *entry.Entry {
+		select {
+		case e := <-entryChan:
+			return e
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for message to be written")
+		}
+		return nil
+	}
+
+	expectedCredentials := map[string]string{
+		"peer_pid": strconv.Itoa(os.Getpid()),
+		"peer_uid": strconv.Itoa(os.Getuid()),
+		"peer_gid": strconv.Itoa(os.Getgid()),
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		cfg := basicUnixInputConfig(t, socketTypeStream)
+		unixInput, entryChan := startUnixInput(t, cfg)
+		defer unixInput.Stop()
+
+		conn, err := net.Dial("unix", cfg.SocketPath)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("message1\nmessage2\n"))
+		require.NoError(t, err)
+
+		require.Equal(t, "message1", expectEntry(t, entryChan).Record)
+		require.Equal(t, "message2", expectEntry(t, entryChan).Record)
+	})
+
+	t.Run("Datagram", func(t *testing.T) {
+		cfg := basicUnixInputConfig(t, socketTypeDatagram)
+		unixInput, entryChan := startUnixInput(t, cfg)
+		defer unixInput.Stop()
+
+		conn, err := net.Dial("unixgram", cfg.SocketPath)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("<13>Jan 12 06:30:00 app: multi\nline\n"))
+		require.NoError(t, err)
+
+		e := expectEntry(t, entryChan)
+		require.Equal(t, "<13>Jan 12 06:30:00 app: multi\nline", e.Record)
+		require.Nil(t, e.Labels)
+	})
+
+	t.Run("StreamPeerCredentials", func(t *testing.T) {
+		if !peerCredentialsSupported {
+			t.Skip("peer credentials are not supported on this platform")
+		}
+
+		cfg := basicUnixInputConfig(t, socketTypeStream)
+		cfg.PeerCredentialLabels = true
+		unixInput, entryChan := startUnixInput(t, cfg)
+		defer unixInput.Stop()
+
+		conn, err := net.Dial("unix", cfg.SocketPath)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("message1\n"))
+		require.NoError(t, err)
+
+		require.Equal(t, expectedCredentials, expectEntry(t, entryChan).Labels)
+	})
+
+	t.Run("DatagramPeerCredentials", func(t *testing.T) {
+		if !peerCredentialsSupported {
+			t.Skip("peer credentials are not supported on this platform")
+		}
+
+		cfg := basicUnixInputConfig(t, socketTypeDatagram)
+		cfg.PeerCredentialLabels = true
+		unixInput, entryChan := startUnixInput(t, cfg)
+		defer unixInput.Stop()
+
+		conn, err := net.Dial("unixgram", cfg.SocketPath)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("message1"))
+		require.NoError(t, err)
+
+		require.Equal(t, expectedCredentials, expectEntry(t, entryChan).Labels)
+	})
+
+	t.Run("Permissions", func(t *testing.T) {
+		cfg := basicUnixInputConfig(t, socketTypeDatagram)
+		cfg.Mode = "0620"
+		cfg.Owner = strconv.Itoa(os.Getuid())
+		cfg.Group = strconv.Itoa(os.Getgid())
+		unixInput, _ := startUnixInput(t, cfg)
+		defer unixInput.Stop()
+
+		info, err := os.Stat(cfg.SocketPath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0620), info.Mode().Perm())
+	})
+
+	t.Run("RemovedOnStop", func(t *testing.T) {
+		for _, socketType := range []string{socketTypeStream, socketTypeDatagram} {
+			cfg := basicUnixInputConfig(t, socketType)
+			unixInput, _ := startUnixInput(t, cfg)
+			require.NoError(t, unixInput.Stop())
+
+			_, err := os.Stat(cfg.SocketPath)
+			require.True(t, os.IsNotExist(err), socketType)
+		}
+	})
+
+	t.Run("StaleSocket", func(t *testing.T) {
+		cfg := basicUnixInputConfig(t, socketTypeDatagram)
+
+		// A socket that is closed without being removed is left behind
+		stale, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: cfg.SocketPath, Net: "unixgram"})
+		require.NoError(t, err)
+		require.NoError(t, stale.Close())
+		_, err = os.Stat(cfg.SocketPath)
+		require.NoError(t, err)
+
+		unixInput, entryChan := startUnixInput(t, cfg)
+		defer unixInput.Stop()
+
+		conn, err := net.Dial("unixgram", cfg.SocketPath)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("message1"))
+		require.NoError(t, err)
+		require.Equal(t, "message1", expectEntry(t, entryChan).Record)
+	})
+
+	t.Run("SocketInUse", func(t *testing.T) {
+		cfg := basicUnixInputConfig(t, socketTypeStream)
+		listener, err := net.Listen("unix", cfg.SocketPath)
+		require.NoError(t, err)
+		defer listener.Close()
+
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		require.Error(t, newOperator.Start())
+
+		_, err = os.Stat(cfg.SocketPath)
+		require.NoError(t, err)
+	})
+
+	t.Run("NotASocket", func(t *testing.T) {
+		cfg := basicUnixInputConfig(t, socketTypeDatagram)
+		require.NoError(t, ioutil.WriteFile(cfg.SocketPath, []byte("data"), 0600))
+
+		newOperator, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		require.Error(t, newOperator.Start())
+
+		contents, err := ioutil.ReadFile(cfg.SocketPath)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(contents))
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			modify func(*UnixInputConfig)
+		}{
+			{"MissingSocketPath", func(c *UnixInputConfig) { c.SocketPath = "" }},
+			{"InvalidSocketType", func(c *UnixInputConfig) { c.SocketType = "seqpacket" }},
+			{"InvalidMode", func(c *UnixInputConfig) { c.Mode = "0999" }},
+			{"ModeTooLarge", func(c *UnixInputConfig) { c.Mode = "7777" }},
+			{"UnknownOwner", func(c *UnixInputConfig) { c.Owner = "carbon-test-missing-user" }},
+			{"UnknownGroup", func(c *UnixInputConfig) { c.Group = "carbon-test-missing-group" }},
+			{"DatagramFraming", func(c *UnixInputConfig) { c.Framing = framingOctetCounting }},
+			{"InvalidStreamFraming", func(c *UnixInputConfig) {
+				c.SocketType = socketTypeStream
+				c.Framing = "length"
+			}},
+			{"NegativeMaxMessageSize", func(c *UnixInputConfig) { c.MaxMessageSize = -1 }},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := basicUnixInputConfig(t, socketTypeDatagram)
+				tc.modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"strconv"
+	"time"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
@@ -94,6 +95,17 @@ type ElasticOutput struct {
 	idField    *entry.Field
 }
 
+// Stop will flush any buffered entries to elasticsearch.
+func (e *ElasticOutput) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := e.Buffer.Flush(ctx)
+	if err != nil {
+		e.Warnw("Failed to flush", zap.Error(err))
+	}
+	return nil
+}
+
 // ProcessMulti will send entries to elasticsearch.
 func (e *ElasticOutput) ProcessMulti(ctx context.Context, entries []*entry.Entry) error {
 	type indexDirective struct {
@@ -27,8 +27,9 @@ func NewFileOutputConfig(operatorID string) *FileOutputConfig {
 type FileOutputConfig struct {
 	helper.OutputConfig `yaml:",inline"`
 
-	Path   string `json:"path" yaml:"path"`
-	Format string `json:"format,omitempty" path:"format,omitempty"`
+	Path     string `json:"path" yaml:"path"`
+	Format   string `json:"format,omitempty" path:"format,omitempty"`
+	Lossless bool   `json:"lossless,omitempty" yaml:"lossless,omitempty"`
 }
 
 // Build will build a file output operator.
@@ -50,10 +51,15 @@ func (c FileOutputConfig) Build(context operator.BuildContext) (operator.Operato
 		return nil, fmt.Errorf("must provide a path to output to")
 	}
 
+	if c.Lossless && c.Format != "" {
+		return nil, fmt.Errorf("format can not be configured with lossless")
+	}
+
 	fileOutput := &FileOutput{
 		OutputOperator: outputOperator,
 		path:           c.Path,
 		tmpl:           tmpl,
+		lossless:       c.Lossless,
 	}
 
 	return fileOutput, nil
@@ -63,11 +69,16 @@ func (c FileOutputConfig) Build(context operator.BuildContext) (operator.Operato
 type FileOutput struct {
 	helper.OutputOperator
 
-	path    string
-	tmpl    *template.Template
-	encoder *json.Encoder
-	file    *os.File
-	mux     sync.Mutex
+	path     string
+	tmpl     *template.Template
+	lossless bool
+	encoder  *json.Encoder
+	capture  *entry.CaptureEncoder
+	file     *os.File
+	mux      sync.Mutex
+
+	// failures is the number of entries that could not be written
+	failures int64
 }
 
 // Start will open the output file.
@@ -79,6 +90,7 @@ func (fo *FileOutput) Start() error {
 	}
 
 	fo.encoder = json.NewEncoder(fo.file)
+	fo.capture = entry.NewCaptureEncoder(fo.file)
 
 	return nil
 }
@@ -99,14 +111,29 @@ func (fo *FileOutput) Process(ctx context.Context, entry *entry.Entry) error {
 	if fo.tmpl != nil {
 		err := fo.tmpl.Execute(fo.file, entry)
 		if err != nil {
+			fo.failures++
+			return err
+		}
+	} else if fo.lossless {
+		err := fo.capture.Encode(entry)
+		if err != nil {
+			fo.failures++
 			return err
 		}
 	} else {
 		err := fo.encoder.Encode(entry)
 		if err != nil {
+			fo.failures++
 			return err
 		}
 	}
 
 	return nil
 }
+
+// DeliveryFailures returns the number of entries that could not be written to the output file.
+func (fo *FileOutput) DeliveryFailures() int64 {
+	fo.mux.Lock()
+	defer fo.mux.Unlock()
+	return fo.failures
+}
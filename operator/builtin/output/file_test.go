@@ -0,0 +1,92 @@
+package output
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileOutputLossless(t *testing.T) {
+	path := filepath.Join(testutil.NewTempDir(t), "capture.ndjson")
+	cfg := NewFileOutputConfig("test_operator_id")
+	cfg.Path = path
+	cfg.Lossless = true
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.NoError(t, op.Start())
+
+	e := &entry.Entry{
+		Timestamp: time.Unix(1591042864, 123),
+		Severity:  entry.Error,
+		Tags:      []string{"tag"},
+		Labels:    map[string]string{"label": "value"},
+		Record: map[string]interface{}{
+			"count": 3,
+			"raw":   []byte("raw"),
+		},
+	}
+	require.NoError(t, op.Process(context.Background(), e))
+	require.NoError(t, op.Stop())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	decoded, err := entry.NewCaptureDecoder(file).Decode()
+	require.NoError(t, err)
+	require.True(t, e.Timestamp.Equal(decoded.Timestamp))
+	decoded.Timestamp = e.Timestamp
+	require.Equal(t, e, decoded)
+}
+
+func TestFileOutputLosslessUnsupported(t *testing.T) {
+	cfg := NewFileOutputConfig("test_operator_id")
+	cfg.Path = filepath.Join(testutil.NewTempDir(t), "capture.ndjson")
+	cfg.Lossless = true
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	// Entries that can not be captured exactly are counted as failures
+	err = op.Process(context.Background(), &entry.Entry{Record: map[string]int{"count": 3}})
+	require.Error(t, err)
+	require.Equal(t, int64(1), op.(*FileOutput).DeliveryFailures())
+}
+
+func TestFileOutputLosslessWithFormat(t *testing.T) {
+	cfg := NewFileOutputConfig("test_operator_id")
+	cfg.Path = filepath.Join(testutil.NewTempDir(t), "capture.ndjson")
+	cfg.Lossless = true
+	cfg.Format = "{{ .Record }}"
+
+	_, err := cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+}
+
+func TestFileOutputDefaultFormat(t *testing.T) {
+	path := filepath.Join(testutil.NewTempDir(t), "output.json")
+	cfg := NewFileOutputConfig("test_operator_id")
+	cfg.Path = path
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.NoError(t, op.Start())
+
+	e := &entry.Entry{Record: map[string]interface{}{"count": 3}}
+	require.NoError(t, op.Process(context.Background(), e))
+	require.NoError(t, op.Stop())
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `"record":{"count":3}`)
+}
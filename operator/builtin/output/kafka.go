@@ -0,0 +1,275 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/kafka"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/buffer"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	operator.Register("kafka_output", func() operator.Builder { return NewKafkaOutputConfig("") })
+}
+
+// Formats that a kafka output can write message values with
+const (
+	kafkaFormatEntry  = "entry"
+	kafkaFormatRecord = "record"
+)
+
+// kafkaCompressionCodecs maps the supported compression values to sarama codecs
+var kafkaCompressionCodecs = map[string]sarama.CompressionCodec{
+	"none":   sarama.CompressionNone,
+	"gzip":   sarama.CompressionGZIP,
+	"snappy": sarama.CompressionSnappy,
+	"lz4":    sarama.CompressionLZ4,
+	"zstd":   sarama.CompressionZSTD,
+}
+
+// kafkaRequiredAcks maps the supported required_acks values to sarama acks
+var kafkaRequiredAcks = map[string]sarama.RequiredAcks{
+	"none":   sarama.NoResponse,
+	"leader": sarama.WaitForLocal,
+	"all":    sarama.WaitForAll,
+}
+
+func NewKafkaOutputConfig(operatorID string) *KafkaOutputConfig {
+	return &KafkaOutputConfig{
+		OutputConfig:    helper.NewOutputConfig(operatorID, "kafka_output"),
+		ClientConfig:    kafka.NewClientConfig(),
+		BufferConfig:    buffer.NewConfig(),
+		Format:          kafkaFormatEntry,
+		Compression:     "none",
+		RequiredAcks:    "all",
+		Timeout:         operator.Duration{Duration: 10 * time.Second},
+		MaxMessageBytes: 1000000,
+	}
+}
+
+// KafkaOutputConfig is the configuration of a kafka output operator.
+type KafkaOutputConfig struct {
+	helper.OutputConfig `yaml:",inline"`
+	kafka.ClientConfig  `yaml:",inline"`
+	BufferConfig        buffer.Config `json:"buffer,omitempty" yaml:"buffer,omitempty"`
+
+	Topic           string            `json:"topic"                       yaml:"topic"`
+	KeyField        *entry.Field      `json:"key_field,omitempty"         yaml:"key_field,omitempty"`
+	Format          string            `json:"format,omitempty"            yaml:"format,omitempty"`
+	Compression     string            `json:"compression,omitempty"       yaml:"compression,omitempty"`
+	RequiredAcks    string            `json:"required_acks,omitempty"     yaml:"required_acks,omitempty"`
+	Timeout         operator.Duration `json:"timeout,omitempty"           yaml:"timeout,omitempty"`
+	MaxMessageBytes int               `json:"max_message_bytes,omitempty" yaml:"max_message_bytes,omitempty"`
+}
+
+// Build will build a kafka output operator.
+func (c KafkaOutputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	outputOperator, err := c.OutputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Topic == "" {
+		return nil, fmt.Errorf("missing required parameter 'topic'")
+	}
+
+	if c.Format != kafkaFormatEntry && c.Format != kafkaFormatRecord {
+		return nil, fmt.Errorf("invalid format '%s', expected '%s' or '%s'", c.Format, kafkaFormatEntry, kafkaFormatRecord)
+	}
+
+	if c.Timeout.Raw() <= 0 {
+		return nil, fmt.Errorf("timeout must be greater than 0")
+	}
+
+	if c.MaxMessageBytes <= 0 {
+		return nil, fmt.Errorf("max_message_bytes must be greater than 0")
+	}
+
+	saramaConfig, err := c.ClientConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	compression, ok := kafkaCompressionCodecs[c.Compression]
+	if !ok {
+		return nil, fmt.Errorf("invalid compression '%s', expected one of 'none', 'gzip', 'snappy', 'lz4' or 'zstd'", c.Compression)
+	}
+
+	acks, ok := kafkaRequiredAcks[c.RequiredAcks]
+	if !ok {
+		return nil, fmt.Errorf("invalid required_acks '%s', expected 'none', 'leader' or 'all'", c.RequiredAcks)
+	}
+
+	// Messages without a key are spread over the partitions at random, and messages with
+	// a key are sent to the partition of the hash of the key
+	saramaConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	saramaConfig.Producer.Compression = compression
+	saramaConfig.Producer.RequiredAcks = acks
+	saramaConfig.Producer.Timeout = c.Timeout.Raw()
+	saramaConfig.Producer.MaxMessageBytes = c.MaxMessageBytes
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+	if err := saramaConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka configuration: %s", err)
+	}
+
+	kafkaOutput := &KafkaOutput{
+		OutputOperator: outputOperator,
+		brokers:        c.Brokers,
+		saramaConfig:   saramaConfig,
+		topic:          c.Topic,
+		keyField:       c.KeyField,
+		format:         c.Format,
+	}
+
+	newBuffer, err := c.BufferConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+	kafkaOutput.Buffer = newBuffer
+	newBuffer.SetHandler(kafkaOutput)
+
+	return kafkaOutput, nil
+}
+
+// KafkaOutput is an operator that sends entries to a kafka topic.
+type KafkaOutput struct {
+	helper.OutputOperator
+	buffer.Buffer
+
+	brokers      []string
+	saramaConfig *sarama.Config
+	topic        string
+	keyField     *entry.Field
+	format       string
+
+	producer sarama.SyncProducer
+	dropped  int64
+}
+
+// Start will connect the producer to the brokers.
+func (k *KafkaOutput) Start() error {
+	producer, err := sarama.NewSyncProducer(k.brokers, k.saramaConfig)
+	if err != nil {
+		return fmt.Errorf("create kafka producer: %s", err)
+	}
+	k.producer = producer
+	return nil
+}
+
+// Stop will flush any buffered entries, and close the producer.
+func (k *KafkaOutput) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := k.Buffer.Flush(ctx); err != nil {
+		k.Warnw("Failed to flush", zap.Error(err))
+	}
+
+	if k.producer != nil {
+		return k.producer.Close()
+	}
+	return nil
+}
+
+// DeliveryFailures returns the number of entries that were dropped, along with the entries that the
+// buffer failed to send.
+func (k *KafkaOutput) DeliveryFailures() int64 {
+	return atomic.LoadInt64(&k.dropped) + k.Buffer.DeliveryFailures()
+}
+
+// ProcessMulti will send entries as messages to the topic. If any message is not acknowledged, only the
+// entries of the messages that were not acknowledged are retried, so delivery is at least once.
+func (k *KafkaOutput) ProcessMulti(ctx context.Context, entries []*entry.Entry) error {
+	messages := make([]*sarama.ProducerMessage, 0, len(entries))
+	for _, e := range entries {
+		message, err := k.newMessage(e)
+		if err != nil {
+			k.Errorw("Dropping entry that failed to encode", zap.Error(err), zap.Any("entry", e))
+			atomic.AddInt64(&k.dropped, 1)
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	err := k.producer.SendMessages(messages)
+	if err == nil {
+		return nil
+	}
+
+	producerErrors, ok := err.(sarama.ProducerErrors)
+	if !ok {
+		return fmt.Errorf("send messages: %s", err)
+	}
+
+	// Messages that can never be sent are dropped, so that they do not hold up the rest of the bundle
+	var retryable []*entry.Entry
+	var retryErr error
+	for _, producerErr := range producerErrors {
+		if producerErr.Err == sarama.ErrMessageSizeTooLarge || producerErr.Err == sarama.ErrInvalidMessage {
+			k.Errorw("Dropping entry that was rejected by the broker", zap.Error(producerErr.Err))
+			atomic.AddInt64(&k.dropped, 1)
+			continue
+		}
+		retryable = append(retryable, producerErr.Msg.Metadata.(*entry.Entry))
+		retryErr = producerErr.Err
+	}
+	if len(retryable) == 0 {
+		return nil
+	}
+	return buffer.NewPartialError(retryable, fmt.Errorf("send %d of %d messages: %s", len(retryable), len(messages), retryErr))
+}
+
+// newMessage will create the message that an entry is sent as
+func (k *KafkaOutput) newMessage(e *entry.Entry) (*sarama.ProducerMessage, error) {
+	value, err := k.encodeValue(e)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &sarama.ProducerMessage{
+		Topic:     k.topic,
+		Value:     sarama.ByteEncoder(value),
+		Timestamp: e.Timestamp,
+		Metadata:  e,
+	}
+
+	if k.keyField != nil {
+		var key string
+		err := e.Read(*k.keyField, &key)
+		if err != nil {
+			k.Debugw("Sending entry without a key", zap.Error(err))
+		} else {
+			message.Key = sarama.StringEncoder(key)
+		}
+	}
+
+	return message, nil
+}
+
+// encodeValue will encode an entry as the value of a message
+func (k *KafkaOutput) encodeValue(e *entry.Entry) ([]byte, error) {
+	if k.format == kafkaFormatEntry {
+		return json.Marshal(e)
+	}
+
+	switch record := e.Record.(type) {
+	case string:
+		return []byte(record), nil
+	case []byte:
+		return record, nil
+	default:
+		return json.Marshal(record)
+	}
+}
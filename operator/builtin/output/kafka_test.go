@@ -0,0 +1,188 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/kafka"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator/buffer"
+	"github.com/stretchr/testify/require"
+)
+
+// newKafkaProducerBroker will create a mock broker that leads the only partition of a topic.
+// The responses are encoded with the versions of the requests of the default kafka version.
+func newKafkaProducerBroker(t *testing.T, produceResponse *sarama.MockProduceResponse) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("logs", 0, broker.BrokerID()),
+		"ProduceRequest": produceResponse.SetVersion(3),
+	})
+	return broker
+}
+
+// producedKafkaRequests returns the number of produce requests that a broker received
+func producedKafkaRequests(broker *sarama.MockBroker) int {
+	count := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			count++
+		}
+	}
+	return count
+}
+
+func newTestKafkaOutputConfig(broker string) *KafkaOutputConfig {
+	cfg := NewKafkaOutputConfig("test_operator_id")
+	cfg.Brokers = []string{broker}
+	cfg.Topic = "logs"
+	return cfg
+}
+
+func TestKafkaOutput(t *testing.T) {
+	broker := newKafkaProducerBroker(t, sarama.NewMockProduceResponse(t))
+	defer broker.Close()
+
+	cfg := newTestKafkaOutputConfig(broker.Addr())
+	cfg.BufferConfig.DelayThreshold.Duration = 10 * time.Millisecond
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.NoError(t, op.Start())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, op.Process(context.Background(), &entry.Entry{Record: "test message"}))
+	}
+	require.NoError(t, op.Stop())
+
+	require.NotZero(t, producedKafkaRequests(broker))
+	require.Equal(t, int64(0), op.(*KafkaOutput).DeliveryFailures())
+}
+
+func TestKafkaOutputRetryable(t *testing.T) {
+	broker := newKafkaProducerBroker(t, sarama.NewMockProduceResponse(t).SetError("logs", 0, sarama.ErrNotEnoughReplicas))
+	defer broker.Close()
+
+	cfg := newTestKafkaOutputConfig(broker.Addr())
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	kafkaOutput := op.(*KafkaOutput)
+	kafkaOutput.saramaConfig.Producer.Retry.Max = 0
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	e := &entry.Entry{Record: "test message"}
+	err = kafkaOutput.ProcessMulti(context.Background(), []*entry.Entry{e})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "send 1 of 1 messages")
+
+	// Only the entries of the messages that failed are retried
+	partialErr, ok := err.(*buffer.PartialError)
+	require.True(t, ok)
+	require.Equal(t, []*entry.Entry{e}, partialErr.Entries)
+}
+
+func TestKafkaOutputDropsTooLarge(t *testing.T) {
+	broker := newKafkaProducerBroker(t, sarama.NewMockProduceResponse(t))
+	defer broker.Close()
+
+	cfg := newTestKafkaOutputConfig(broker.Addr())
+	cfg.MaxMessageBytes = 100
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	require.NoError(t, op.Start())
+	defer op.Stop()
+
+	large := make([]byte, 200)
+	err = op.(*KafkaOutput).ProcessMulti(context.Background(), []*entry.Entry{{Record: string(large)}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), op.(*KafkaOutput).DeliveryFailures())
+}
+
+func TestKafkaOutputNewMessage(t *testing.T) {
+	timestamp := time.Date(2020, time.July, 21, 10, 30, 0, 0, time.UTC)
+	e := &entry.Entry{
+		Timestamp: timestamp,
+		Severity:  entry.Error,
+		Labels:    map[string]string{"host": "web-1"},
+		Record:    map[string]interface{}{"message": "failed", "user": "alice"},
+	}
+
+	t.Run("Entry", func(t *testing.T) {
+		cfg := newTestKafkaOutputConfig("localhost:9092")
+		keyField := entry.NewRecordField("user")
+		cfg.KeyField = &keyField
+		op, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		message, err := op.(*KafkaOutput).newMessage(e)
+		require.NoError(t, err)
+		require.Equal(t, "logs", message.Topic)
+		require.Equal(t, sarama.StringEncoder("alice"), message.Key)
+		require.Equal(t, timestamp, message.Timestamp)
+
+		value, err := message.Value.Encode()
+		require.NoError(t, err)
+		var decoded entry.Entry
+		require.NoError(t, json.Unmarshal(value, &decoded))
+		require.Equal(t, entry.Error, decoded.Severity)
+		require.Equal(t, e.Labels, decoded.Labels)
+		require.Equal(t, e.Record, decoded.Record)
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		cfg := newTestKafkaOutputConfig("localhost:9092")
+		cfg.Format = kafkaFormatRecord
+		keyField := entry.NewRecordField("missing")
+		cfg.KeyField = &keyField
+		op, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		message, err := op.(*KafkaOutput).newMessage(e)
+		require.NoError(t, err)
+		require.Nil(t, message.Key)
+		value, err := message.Value.Encode()
+		require.NoError(t, err)
+		require.JSONEq(t, `{"message":"failed","user":"alice"}`, string(value))
+
+		message, err = op.(*KafkaOutput).newMessage(&entry.Entry{Record: "plain text"})
+		require.NoError(t, err)
+		value, err = message.Value.Encode()
+		require.NoError(t, err)
+		require.Equal(t, "plain text", string(value))
+	})
+}
+
+func TestKafkaOutputInvalid(t *testing.T) {
+	cases := map[string]func(*KafkaOutputConfig){
+		"MissingBrokers":     func(cfg *KafkaOutputConfig) { cfg.Brokers = nil },
+		"MissingTopic":       func(cfg *KafkaOutputConfig) { cfg.Topic = "" },
+		"InvalidFormat":      func(cfg *KafkaOutputConfig) { cfg.Format = "xml" },
+		"InvalidCompression": func(cfg *KafkaOutputConfig) { cfg.Compression = "brotli" },
+		"InvalidAcks":        func(cfg *KafkaOutputConfig) { cfg.RequiredAcks = "some" },
+		"ZstdOldVersion": func(cfg *KafkaOutputConfig) {
+			cfg.Compression = "zstd"
+			cfg.Version = "1.0.0"
+		},
+		"InvalidSASLMechanism": func(cfg *KafkaOutputConfig) {
+			cfg.SASL = &kafka.SASLConfig{Mechanism: "GSSAPI", Username: "user"}
+		},
+		"MissingSASLUsername": func(cfg *KafkaOutputConfig) { cfg.SASL = &kafka.SASLConfig{} },
+		"MissingTLSKey":       func(cfg *KafkaOutputConfig) { cfg.TLS = &kafka.TLSConfig{CertFile: "cert.pem"} },
+		"MissingTLSCA":        func(cfg *KafkaOutputConfig) { cfg.TLS = &kafka.TLSConfig{CAFile: "/does/not/exist"} },
+	}
+
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := newTestKafkaOutputConfig("localhost:9092")
+			modify(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/otlp"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/buffer"
+	"github.com/observiq/carbon/operator/helper"
+	"go.uber.org/zap"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	operator.Register("otlp_output", func() operator.Builder { return NewOTLPOutputConfig("") })
+}
+
+// Protocols that an otlp output can send requests with
+const (
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http"
+)
+
+// protobufContentType is the content type of OTLP requests and responses over http
+const protobufContentType = "application/x-protobuf"
+
+func NewOTLPOutputConfig(operatorID string) *OTLPOutputConfig {
+	return &OTLPOutputConfig{
+		OutputConfig: helper.NewOutputConfig(operatorID, "otlp_output"),
+		BufferConfig: buffer.NewConfig(),
+		Protocol:     otlpProtocolGRPC,
+		Compression:  "none",
+		Timeout:      operator.Duration{Duration: 10 * time.Second},
+	}
+}
+
+// OTLPOutputConfig is the configuration of an otlp output operator.
+type OTLPOutputConfig struct {
+	helper.OutputConfig `yaml:",inline"`
+	BufferConfig        buffer.Config `json:"buffer,omitempty" yaml:"buffer,omitempty"`
+
+	Endpoint           string            `json:"endpoint"                       yaml:"endpoint"`
+	Protocol           string            `json:"protocol,omitempty"             yaml:"protocol,omitempty"`
+	Insecure           bool              `json:"insecure,omitempty"             yaml:"insecure,omitempty"`
+	CAFile             string            `json:"ca_file,omitempty"              yaml:"ca_file,omitempty"`
+	InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"              yaml:"headers,omitempty"`
+	Compression        string            `json:"compression,omitempty"          yaml:"compression,omitempty"`
+	Timeout            operator.Duration `json:"timeout,omitempty"              yaml:"timeout,omitempty"`
+}
+
+// Build will build an otlp output operator.
+func (c OTLPOutputConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	outputOperator, err := c.OutputConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Endpoint == "" {
+		return nil, fmt.Errorf("missing required parameter 'endpoint'")
+	}
+
+	if c.Timeout.Raw() <= 0 {
+		return nil, fmt.Errorf("timeout must be greater than 0")
+	}
+
+	var compress bool
+	switch c.Compression {
+	case "", "none":
+	case "gzip":
+		compress = true
+	default:
+		return nil, fmt.Errorf("invalid compression '%s', expected 'gzip' or 'none'", c.Compression)
+	}
+
+	otlpOutput := &OTLPOutput{
+		OutputOperator: outputOperator,
+		protocol:       c.Protocol,
+		insecure:       c.Insecure,
+		headers:        c.Headers,
+		compress:       compress,
+		timeout:        c.Timeout.Raw(),
+	}
+
+	switch c.Protocol {
+	case otlpProtocolGRPC:
+		if _, _, err := net.SplitHostPort(c.Endpoint); err != nil {
+			return nil, fmt.Errorf("invalid endpoint, expected host:port for protocol 'grpc': %s", err)
+		}
+		otlpOutput.endpoint = c.Endpoint
+	case otlpProtocolHTTP:
+		otlpOutput.endpoint, err = otlpHTTPEndpoint(c.Endpoint, c.Insecure)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid protocol '%s', expected 'grpc' or 'http'", c.Protocol)
+	}
+
+	if !c.Insecure || c.Protocol == otlpProtocolHTTP {
+		otlpOutput.tlsConfig = &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+		if c.CAFile != "" {
+			ca, err := ioutil.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read ca_file: %s", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("ca_file does not contain a PEM encoded certificate")
+			}
+			otlpOutput.tlsConfig.RootCAs = pool
+		}
+	}
+
+	newBuffer, err := c.BufferConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+	otlpOutput.Buffer = newBuffer
+	newBuffer.SetHandler(otlpOutput)
+
+	return otlpOutput, nil
+}
+
+// otlpHTTPEndpoint will return the url that logs are sent to with http. Endpoints without a scheme
+// use https, or http if insecure, and endpoints without a path use the default logs path.
+func otlpHTTPEndpoint(endpoint string, insecure bool) (string, error) {
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https://"
+		if insecure {
+			scheme = "http://"
+		}
+		endpoint = scheme + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid endpoint, expected scheme 'http' or 'https' for protocol 'http'")
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid endpoint, missing host")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlp.LogsPath
+	}
+	return u.String(), nil
+}
+
+// OTLPOutput is an operator that sends entries with the OpenTelemetry protocol.
+type OTLPOutput struct {
+	helper.OutputOperator
+	buffer.Buffer
+
+	endpoint  string
+	protocol  string
+	insecure  bool
+	tlsConfig *tls.Config
+	headers   map[string]string
+	compress  bool
+	timeout   time.Duration
+
+	conn       *grpc.ClientConn
+	httpClient *http.Client
+	dropped    int64
+}
+
+// otlpDropError is an error of an export request that can not be retried, so its entries are dropped
+type otlpDropError struct {
+	err error
+}
+
+func (e *otlpDropError) Error() string {
+	return e.err.Error()
+}
+
+// Start will create the client that sends requests.
+func (o *OTLPOutput) Start() error {
+	if o.protocol == otlpProtocolHTTP {
+		o.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: o.tlsConfig,
+			},
+		}
+		return nil
+	}
+
+	options := []grpc.DialOption{grpc.WithInsecure()}
+	if !o.insecure {
+		options[0] = grpc.WithTransportCredentials(credentials.NewTLS(o.tlsConfig))
+	}
+	if o.compress {
+		options = append(options, grpc.WithDefaultCallOptions(grpc.UseCompressor("gzip")))
+	}
+
+	// The connection is established in the background, so that the endpoint does not need to be
+	// available when the output starts
+	conn, err := grpc.Dial(o.endpoint, options...)
+	if err != nil {
+		return fmt.Errorf("dial grpc endpoint: %s", err)
+	}
+	o.conn = conn
+	return nil
+}
+
+// Stop will flush any buffered entries, and close the client.
+func (o *OTLPOutput) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := o.Buffer.Flush(ctx); err != nil {
+		o.Warnw("Failed to flush", zap.Error(err))
+	}
+
+	if o.conn != nil {
+		return o.conn.Close()
+	}
+	if o.httpClient != nil {
+		o.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// DeliveryFailures returns the number of entries that were dropped or rejected by the endpoint, along
+// with the entries that the buffer failed to send.
+func (o *OTLPOutput) DeliveryFailures() int64 {
+	return atomic.LoadInt64(&o.dropped) + o.Buffer.DeliveryFailures()
+}
+
+// ProcessMulti will send entries in an export request. Errors are returned for failures that can
+// be retried, and requests that are rejected otherwise are dropped.
+func (o *OTLPOutput) ProcessMulti(ctx context.Context, entries []*entry.Entry) error {
+	req := otlp.FromEntries(entries, time.Now())
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	var resp *otlp.ExportLogsServiceResponse
+	var err error
+	if o.protocol == otlpProtocolHTTP {
+		resp, err = o.exportHTTP(ctx, req)
+	} else {
+		resp, err = o.exportGRPC(ctx, req)
+	}
+	if dropErr, ok := err.(*otlpDropError); ok {
+		o.Errorw("Dropping entries that were rejected by the endpoint", zap.Error(dropErr.err), "entries", len(entries))
+		atomic.AddInt64(&o.dropped, int64(len(entries)))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if resp != nil && (resp.RejectedLogRecords > 0 || resp.ErrorMessage != "") {
+		o.Warnw("Endpoint rejected some entries", "rejected", resp.RejectedLogRecords, "message", resp.ErrorMessage)
+		atomic.AddInt64(&o.dropped, resp.RejectedLogRecords)
+	}
+	return nil
+}
+
+func (o *OTLPOutput) exportGRPC(ctx context.Context, req *otlp.ExportLogsServiceRequest) (*otlp.ExportLogsServiceResponse, error) {
+	for key, value := range o.headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(key), value)
+	}
+
+	resp, err := otlp.Export(ctx, o.conn, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return nil, fmt.Errorf("export entries: %s", err)
+	default:
+		return nil, &otlpDropError{err: err}
+	}
+}
+
+func (o *OTLPOutput) exportHTTP(ctx context.Context, req *otlp.ExportLogsServiceRequest) (*otlp.ExportLogsServiceResponse, error) {
+	body, err := req.Marshal()
+	if err != nil {
+		return nil, &otlpDropError{err: fmt.Errorf("encode request: %s", err)}
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, o.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.compress {
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		if _, err := gzipWriter.Write(body); err != nil {
+			return nil, err
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return nil, err
+		}
+		body = compressed.Bytes()
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+
+	httpReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	httpReq.ContentLength = int64(len(body))
+	httpReq.Header.Set("Content-Type", protobufContentType)
+	for key, value := range o.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	res, err := o.httpClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("export entries: %s", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %s", err)
+	}
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		resp := &otlp.ExportLogsServiceResponse{}
+		if err := resp.Unmarshal(resBody); err != nil {
+			o.Debugw("Failed to decode response", zap.Error(err))
+			return nil, nil
+		}
+		return resp, nil
+	case res.StatusCode == http.StatusTooManyRequests, res.StatusCode == http.StatusBadGateway,
+		res.StatusCode == http.StatusServiceUnavailable, res.StatusCode == http.StatusGatewayTimeout:
+		return nil, fmt.Errorf("export entries: %s: %s", res.Status, httpErrorMessage(resBody))
+	default:
+		return nil, &otlpDropError{err: fmt.Errorf("%s: %s", res.Status, httpErrorMessage(resBody))}
+	}
+}
+
+// httpErrorMessage will return the message of the encoded status in the body of an error response
+func httpErrorMessage(body []byte) string {
+	s := &rpcstatus.Status{}
+	if err := proto.Unmarshal(body, s); err == nil && s.Message != "" {
+		return s.Message
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,235 @@
+package output
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/otlp"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/builtin/input"
+	"github.com/observiq/carbon/operator/helper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPOutput(t *testing.T) {
+	// startOTLPInput will start an otlp input that the output can export to, returning the address of
+	// the protocol and a channel of the entries it receives
+	startOTLPInput := func(t *testing.T, protocol string, tlsConfig *helper.TLSConfig) (operator.Operator, string, chan *entry.Entry) {
+		// The address is found before the input starts, since its listeners are not exported
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		cfg := input.NewOTLPInputConfig("test_input")
+		cfg.OutputIDs = []string{"test_output_id"}
+		protocolConfig := &input.OTLPProtocolConfig{ListenAddress: address, TLS: tlsConfig}
+		if protocol == otlpProtocolGRPC {
+			cfg.GRPC = protocolConfig
+		} else {
+			cfg.HTTP = protocolConfig
+		}
+		otlpInput, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+
+		mockOutput := testutil.Operator{}
+		mockOutput.On("ID").Return("test_output_id")
+		mockOutput.On("CanProcess").Return(true)
+		require.NoError(t, otlpInput.SetOutputs([]operator.Operator{&mockOutput}))
+
+		entryChan := make(chan *entry.Entry, 10)
+		mockOutput.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			entryChan <- args.Get(1).(*entry.Entry)
+		}).Return(nil)
+
+		require.NoError(t, otlpInput.Start())
+		return otlpInput, address, entryChan
+	}
+
+	basicOTLPOutputConfig := func(endpoint, protocol string) *OTLPOutputConfig {
+		cfg := NewOTLPOutputConfig("test_id")
+		cfg.Endpoint = endpoint
+		cfg.Protocol = protocol
+		cfg.Insecure = true
+		cfg.BufferConfig.DelayThreshold = operator.Duration{Duration: time.Millisecond}
+		return cfg
+	}
+
+	startOTLPOutput := func(t *testing.T, cfg *OTLPOutputConfig) *OTLPOutput {
+		otlpOutput, err := cfg.Build(testutil.NewBuildContext(t))
+		require.NoError(t, err)
+		require.NoError(t, otlpOutput.Start())
+		return otlpOutput.(*OTLPOutput)
+	}
+
+	newEntry := func() *entry.Entry {
+		e := entry.New()
+		e.Timestamp = time.Unix(1600000000, 0)
+		e.Severity = entry.Error
+		e.Record = map[string]interface{}{"message": "test message", "count": int64(1)}
+		e.Labels = map[string]string{
+			"resource.host": "test",
+			"key":           "value",
+		}
+		return e
+	}
+
+	// exportEntry will export an entry with an output, and require that the input receives it
+	exportEntry := func(t *testing.T, otlpOutput *OTLPOutput, entryChan chan *entry.Entry) {
+		e := newEntry()
+		require.NoError(t, otlpOutput.Process(context.Background(), e))
+
+		select {
+		case received := <-entryChan:
+			require.Equal(t, e.Timestamp, received.Timestamp)
+			require.Equal(t, e.Severity, received.Severity)
+			require.Equal(t, e.Record, received.Record)
+			require.Equal(t, map[string]string{
+				"resource.host": "test",
+				"key":           "value",
+				"severity_text": "ERROR",
+			}, received.Labels)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for entry")
+		}
+	}
+
+	for _, protocol := range []string{otlpProtocolGRPC, otlpProtocolHTTP} {
+		for _, compression := range []string{"none", "gzip"} {
+			t.Run(protocol+"_"+compression, func(t *testing.T) {
+				otlpInput, address, entryChan := startOTLPInput(t, protocol, nil)
+				defer otlpInput.Stop()
+
+				cfg := basicOTLPOutputConfig(address, protocol)
+				cfg.Compression = compression
+				otlpOutput := startOTLPOutput(t, cfg)
+				defer otlpOutput.Stop()
+
+				exportEntry(t, otlpOutput, entryChan)
+			})
+		}
+
+		t.Run(protocol+"_tls", func(t *testing.T) {
+			files := testutil.NewTLSFiles(t, "client")
+			otlpInput, address, entryChan := startOTLPInput(t, protocol, &helper.TLSConfig{
+				CertFile: files.ServerCertFile,
+				KeyFile:  files.ServerKeyFile,
+			})
+			defer otlpInput.Stop()
+
+			_, port, err := net.SplitHostPort(address)
+			require.NoError(t, err)
+			cfg := basicOTLPOutputConfig("localhost:"+port, protocol)
+			cfg.Insecure = false
+			cfg.CAFile = files.CAFile
+			otlpOutput := startOTLPOutput(t, cfg)
+			defer otlpOutput.Stop()
+
+			exportEntry(t, otlpOutput, entryChan)
+		})
+	}
+
+	t.Run("HTTPHeaders", func(t *testing.T) {
+		requests := make(chan *http.Request, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = ioutil.ReadAll(r.Body)
+			requests <- r
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := basicOTLPOutputConfig(server.URL, otlpProtocolHTTP)
+		cfg.Headers = map[string]string{"Authorization": "Bearer token"}
+		otlpOutput := startOTLPOutput(t, cfg)
+		defer otlpOutput.Stop()
+
+		require.NoError(t, otlpOutput.ProcessMulti(context.Background(), []*entry.Entry{newEntry()}))
+		r := <-requests
+		require.Equal(t, otlp.LogsPath, r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	})
+
+	t.Run("HTTPStatus", func(t *testing.T) {
+		statusCode := http.StatusServiceUnavailable
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+		defer server.Close()
+
+		otlpOutput := startOTLPOutput(t, basicOTLPOutputConfig(server.URL, otlpProtocolHTTP))
+		defer otlpOutput.Stop()
+
+		// Failures that can be retried are returned, and other rejected requests are dropped
+		require.Error(t, otlpOutput.ProcessMulti(context.Background(), []*entry.Entry{newEntry()}))
+		require.Equal(t, int64(0), otlpOutput.DeliveryFailures())
+		statusCode = http.StatusBadRequest
+		require.NoError(t, otlpOutput.ProcessMulti(context.Background(), []*entry.Entry{newEntry(), newEntry()}))
+		require.Equal(t, int64(2), otlpOutput.DeliveryFailures())
+	})
+
+	t.Run("HTTPRejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := (&otlp.ExportLogsServiceResponse{RejectedLogRecords: 1, ErrorMessage: "invalid record"}).Marshal()
+			require.NoError(t, err)
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			_, _ = w.Write(body)
+		}))
+		defer server.Close()
+
+		otlpOutput := startOTLPOutput(t, basicOTLPOutputConfig(server.URL, otlpProtocolHTTP))
+		defer otlpOutput.Stop()
+
+		// Records that the endpoint rejected are counted as failures
+		require.NoError(t, otlpOutput.ProcessMulti(context.Background(), []*entry.Entry{newEntry(), newEntry()}))
+		require.Equal(t, int64(1), otlpOutput.DeliveryFailures())
+	})
+
+	t.Run("HTTPEndpoint", func(t *testing.T) {
+		cases := []struct {
+			endpoint string
+			insecure bool
+			expected string
+		}{
+			{"localhost:4318", false, "https://localhost:4318/v1/logs"},
+			{"localhost:4318", true, "http://localhost:4318/v1/logs"},
+			{"http://localhost:4318/", false, "http://localhost:4318/v1/logs"},
+			{"https://example.com/custom/path", false, "https://example.com/custom/path"},
+		}
+		for _, tc := range cases {
+			endpoint, err := otlpHTTPEndpoint(tc.endpoint, tc.insecure)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, endpoint)
+		}
+
+		_, err := otlpHTTPEndpoint("ftp://localhost", false)
+		require.Error(t, err)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		cases := map[string]func(*OTLPOutputConfig){
+			"MissingEndpoint": func(cfg *OTLPOutputConfig) { cfg.Endpoint = "" },
+			"GRPCEndpoint":    func(cfg *OTLPOutputConfig) { cfg.Endpoint = "http://localhost:4317" },
+			"Protocol":        func(cfg *OTLPOutputConfig) { cfg.Protocol = "udp" },
+			"Compression":     func(cfg *OTLPOutputConfig) { cfg.Compression = "zstd" },
+			"Timeout":         func(cfg *OTLPOutputConfig) { cfg.Timeout = operator.Duration{} },
+			"CAFile":          func(cfg *OTLPOutputConfig) { cfg.Insecure = false; cfg.CAFile = "/does/not/exist" },
+		}
+		for name, modify := range cases {
+			t.Run(name, func(t *testing.T) {
+				cfg := basicOTLPOutputConfig("localhost:4317", otlpProtocolGRPC)
+				modify(cfg)
+				_, err := cfg.Build(testutil.NewBuildContext(t))
+				require.Error(t, err)
+			})
+		}
+	})
+}
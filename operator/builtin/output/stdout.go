@@ -48,6 +48,9 @@ type StdoutOperator struct {
 	helper.OutputOperator
 	encoder *json.Encoder
 	mux     sync.Mutex
+
+	// failures is the number of entries that could not be written
+	failures int64
 }
 
 // Process will log entries received.
@@ -55,6 +58,7 @@ func (o *StdoutOperator) Process(ctx context.Context, entry *entry.Entry) error
 	o.mux.Lock()
 	err := o.encoder.Encode(entry)
 	if err != nil {
+		o.failures++
 		o.mux.Unlock()
 		o.Errorf("Failed to process entry: %s, $s", err, entry.Record)
 		return err
@@ -62,3 +66,10 @@ func (o *StdoutOperator) Process(ctx context.Context, entry *entry.Entry) error
 	o.mux.Unlock()
 	return nil
 }
+
+// DeliveryFailures returns the number of entries that could not be written to stdout.
+func (o *StdoutOperator) DeliveryFailures() int64 {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return o.failures
+}
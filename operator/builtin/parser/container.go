@@ -0,0 +1,330 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/errors"
+	"github.com/observiq/carbon/operator"
+	"github.com/observiq/carbon/operator/helper"
+)
+
+const (
+	containerFormatAuto   = "auto"
+	containerFormatDocker = "docker"
+	containerFormatCRI    = "cri"
+)
+
+func init() {
+	operator.Register("container_parser", func() operator.Builder { return NewContainerParserConfig("") })
+}
+
+func NewContainerParserConfig(operatorID string) *ContainerParserConfig {
+	return &ContainerParserConfig{
+		ParserConfig:     helper.NewParserConfig(operatorID, "container_parser"),
+		Format:           containerFormatAuto,
+		SourceField:      entry.NewNilField(),
+		MaxLogSize:       1024 * 1024,
+		ForceFlushPeriod: operator.Duration{Duration: 5 * time.Second},
+	}
+}
+
+// ContainerParserConfig is the configuration of a container parser operator.
+type ContainerParserConfig struct {
+	helper.ParserConfig `yaml:",inline"`
+
+	Format           string            `json:"format,omitempty"             yaml:"format,omitempty"`
+	SourceField      entry.Field       `json:"source_field,omitempty"       yaml:"source_field,omitempty"`
+	MaxLogSize       int               `json:"max_log_size,omitempty"       yaml:"max_log_size,omitempty"`
+	ForceFlushPeriod operator.Duration `json:"force_flush_period,omitempty" yaml:"force_flush_period,omitempty"`
+}
+
+// Build will build a container parser operator.
+func (c ContainerParserConfig) Build(context operator.BuildContext) (operator.Operator, error) {
+	parserOperator, err := c.ParserConfig.Build(context)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Format {
+	case containerFormatAuto, containerFormatDocker, containerFormatCRI:
+	default:
+		return nil, fmt.Errorf("invalid format '%s'", c.Format)
+	}
+
+	if c.MaxLogSize <= 0 {
+		return nil, fmt.Errorf("max_log_size must be greater than 0")
+	}
+
+	if c.ForceFlushPeriod.Raw() < 0 {
+		return nil, fmt.Errorf("force_flush_period must not be negative")
+	}
+
+	containerParser := &ContainerParser{
+		ParserOperator:   parserOperator,
+		format:           c.Format,
+		sourceField:      c.SourceField,
+		maxLogSize:       c.MaxLogSize,
+		forceFlushPeriod: c.ForceFlushPeriod.Raw(),
+		json:             jsoniter.ConfigFastest,
+		partials:         make(map[partialKey]*partialEntry),
+	}
+
+	return containerParser, nil
+}
+
+// ContainerParser is an operator that parses the log formats written by container runtimes,
+// and reassembles entries that were split into partial lines by the runtime.
+type ContainerParser struct {
+	helper.ParserOperator
+	format           string
+	sourceField      entry.Field
+	maxLogSize       int
+	forceFlushPeriod time.Duration
+	json             jsoniter.API
+
+	partials map[partialKey]*partialEntry
+	mux      sync.Mutex
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// containerLine is a single line written by a container runtime
+type containerLine struct {
+	timestamp time.Time
+	stream    string
+	log       string
+	partial   bool
+}
+
+// partialKey identifies the lines that make up a single entry. Lines of different
+// streams and sources are split independently, so they are reassembled independently.
+type partialKey struct {
+	source string
+	stream string
+}
+
+// partialEntry holds the lines of an entry until its final line is received
+type partialEntry struct {
+	entry     *entry.Entry
+	timestamp time.Time
+	stream    string
+	log       bytes.Buffer
+	received  time.Time
+}
+
+// Start will start flushing partial entries that are never completed.
+func (c *ContainerParser) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	if c.forceFlushPeriod == 0 {
+		return nil
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.forceFlushPeriod / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.flush(ctx, time.Now().Add(-c.forceFlushPeriod))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop will stop the operator, and send any partial entries that have not been completed.
+func (c *ContainerParser) Stop() error {
+	c.cancel()
+	c.wg.Wait()
+	c.flush(context.Background(), time.Now())
+	return nil
+}
+
+// Process will parse an entry written by a container runtime.
+func (c *ContainerParser) Process(ctx context.Context, entry *entry.Entry) error {
+	value, ok := entry.Get(c.ParseFrom)
+	if !ok {
+		err := errors.NewError(
+			"Entry is missing the expected parse_from field.",
+			"Ensure that all incoming entries contain the parse_from field.",
+			"parse_from", c.ParseFrom.String(),
+		)
+		return c.HandleEntryError(ctx, entry, err)
+	}
+
+	line, err := c.parseLine(value)
+	if err != nil {
+		return c.HandleEntryError(ctx, entry, err)
+	}
+
+	key := partialKey{stream: line.stream}
+	if source, ok := c.sourceField.Get(entry); ok {
+		key.source = fmt.Sprintf("%v", source)
+	}
+
+	c.mux.Lock()
+	partial, ok := c.partials[key]
+	if !ok && !line.partial {
+		c.mux.Unlock()
+		return c.write(ctx, entry, line.timestamp, line.stream, line.log)
+	}
+
+	if !ok {
+		partial = &partialEntry{
+			entry:     entry,
+			timestamp: line.timestamp,
+			stream:    line.stream,
+		}
+		c.partials[key] = partial
+	}
+	partial.log.WriteString(line.log)
+	partial.received = time.Now()
+
+	if line.partial && partial.log.Len() < c.maxLogSize {
+		c.mux.Unlock()
+		return nil
+	}
+	delete(c.partials, key)
+	c.mux.Unlock()
+
+	if line.partial {
+		c.Warnw("Sending partial entry that exceeds max_log_size", "max_log_size", c.maxLogSize)
+	}
+	return c.write(ctx, partial.entry, partial.timestamp, partial.stream, partial.log.String())
+}
+
+// flush will send the partial entries that have not received a line since the cutoff
+func (c *ContainerParser) flush(ctx context.Context, cutoff time.Time) {
+	c.mux.Lock()
+	var flushed []*partialEntry
+	for key, partial := range c.partials {
+		if partial.received.After(cutoff) {
+			continue
+		}
+		flushed = append(flushed, partial)
+		delete(c.partials, key)
+	}
+	c.mux.Unlock()
+
+	for _, partial := range flushed {
+		_ = c.write(ctx, partial.entry, partial.timestamp, partial.stream, partial.log.String())
+	}
+}
+
+// write will replace the parsed field of an entry with the reassembled log and send it to the output
+func (c *ContainerParser) write(ctx context.Context, e *entry.Entry, timestamp time.Time, stream, log string) error {
+	e.Timestamp = timestamp
+	return c.ProcessWith(ctx, e, func(interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"log":    log,
+			"stream": stream,
+		}, nil
+	})
+}
+
+// parseLine will parse a line in the configured format
+func (c *ContainerParser) parseLine(value interface{}) (*containerLine, error) {
+	var raw string
+	switch v := value.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return nil, fmt.Errorf("type %T cannot be parsed as a container log", value)
+	}
+
+	format := c.format
+	if format == containerFormatAuto {
+		format = detectContainerFormat(raw)
+	}
+
+	if format == containerFormatDocker {
+		return c.parseDocker(raw)
+	}
+	return parseCRI(raw)
+}
+
+// detectContainerFormat will return the format of a line. The docker json-file
+// format is a JSON object, while the CRI format starts with a timestamp.
+func detectContainerFormat(raw string) string {
+	if strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		return containerFormatDocker
+	}
+	return containerFormatCRI
+}
+
+// parseDocker will parse a line written by the docker json-file logging driver.
+// Lines that do not end with a newline were split, and are continued by the next line.
+func (c *ContainerParser) parseDocker(raw string) (*containerLine, error) {
+	var parsed struct {
+		Log    string `json:"log"`
+		Stream string `json:"stream"`
+		Time   string `json:"time"`
+	}
+	if err := c.json.UnmarshalFromString(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("parse docker log: %s", err)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, parsed.Time)
+	if err != nil {
+		return nil, fmt.Errorf("parse docker log time: %s", err)
+	}
+
+	line := &containerLine{
+		timestamp: timestamp,
+		stream:    parsed.Stream,
+		log:       parsed.Log,
+		partial:   !strings.HasSuffix(parsed.Log, "\n"),
+	}
+	if !line.partial {
+		line.log = strings.TrimSuffix(line.log, "\n")
+	}
+	return line, nil
+}
+
+// parseCRI will parse a line in the CRI format, `<time> <stream> <tags> <log>`.
+// Lines tagged with `P` were split, and are continued by the next line.
+func parseCRI(raw string) (*containerLine, error) {
+	raw = strings.TrimSuffix(raw, "\n")
+	parts := strings.SplitN(raw, " ", 4)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("parse cri log: expected '<time> <stream> <tags> <log>'")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse cri log time: %s", err)
+	}
+
+	line := &containerLine{
+		timestamp: timestamp,
+		stream:    parts[1],
+	}
+	if len(parts) == 4 {
+		line.log = parts[3]
+	}
+
+	switch tag := strings.SplitN(parts[2], ":", 2)[0]; tag {
+	case "P":
+		line.partial = true
+	case "F":
+	default:
+		return nil, fmt.Errorf("parse cri log: invalid tag '%s'", tag)
+	}
+	return line, nil
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/entry"
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/observiq/carbon/operator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestContainerParser(t *testing.T, configure func(*ContainerParserConfig)) (*ContainerParser, chan *entry.Entry) {
+	cfg := NewContainerParserConfig("test")
+	cfg.OutputIDs = []string{"mock_output"}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	op, err := cfg.Build(testutil.NewBuildContext(t))
+	require.NoError(t, err)
+	parser := op.(*ContainerParser)
+
+	entryChan := make(chan *entry.Entry, 10)
+	mockOutput := &testutil.Operator{}
+	mockOutput.On("Process", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		entryChan <- args.Get(1).(*entry.Entry)
+	})
+	parser.OutputOperators = []operator.Operator{mockOutput}
+
+	require.NoError(t, parser.Start())
+	return parser, entryChan
+}
+
+func processContainerLines(t *testing.T, parser *ContainerParser, lines ...string) {
+	for _, line := range lines {
+		e := entry.New()
+		e.Record = line
+		require.NoError(t, parser.Process(context.Background(), e))
+	}
+}
+
+func expectContainerEntry(t *testing.T, entryChan chan *entry.Entry, expectedRecord interface{}, expectedTimestamp time.Time) *entry.Entry {
+	select {
+	case e := <-entryChan:
+		require.Equal(t, expectedRecord, e.Record)
+		require.True(t, expectedTimestamp.Equal(e.Timestamp), "expected %s, got %s", expectedTimestamp, e.Timestamp)
+		return e
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for entry")
+		return nil
+	}
+}
+
+func expectNoContainerEntries(t *testing.T, entryChan chan *entry.Entry) {
+	select {
+	case e := <-entryChan:
+		require.FailNow(t, "Received unexpected entry", "%v", e.Record)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestContainerParserBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		configure func(*ContainerParserConfig)
+		expectErr bool
+	}{
+		{"Default", func(c *ContainerParserConfig) {}, false},
+		{"Docker", func(c *ContainerParserConfig) { c.Format = "docker" }, false},
+		{"CRI", func(c *ContainerParserConfig) { c.Format = "cri" }, false},
+		{"InvalidFormat", func(c *ContainerParserConfig) { c.Format = "podman" }, true},
+		{"ZeroMaxLogSize", func(c *ContainerParserConfig) { c.MaxLogSize = 0 }, true},
+		{"NegativeForceFlushPeriod", func(c *ContainerParserConfig) { c.ForceFlushPeriod = operator.Duration{Duration: -time.Second} }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewContainerParserConfig("test")
+			cfg.OutputIDs = []string{"mock_output"}
+			tc.configure(cfg)
+			_, err := cfg.Build(testutil.NewBuildContext(t))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestContainerParserFormats(t *testing.T) {
+	timestamp := time.Date(2020, 7, 21, 12, 30, 15, 123456789, time.UTC)
+
+	cases := []struct {
+		name   string
+		format string
+		line   string
+	}{
+		{"DockerAuto", "auto", `{"log":"hello world\n","stream":"stderr","time":"2020-07-21T12:30:15.123456789Z"}`},
+		{"Docker", "docker", `{"log":"hello world\n","stream":"stderr","time":"2020-07-21T12:30:15.123456789Z"}`},
+		{"CRIAuto", "auto", `2020-07-21T12:30:15.123456789Z stderr F hello world`},
+		{"CRI", "cri", `2020-07-21T12:30:15.123456789Z stderr F hello world`},
+		{"CRITrailingNewline", "cri", "2020-07-21T12:30:15.123456789Z stderr F hello world\n"},
+		{"CRIOffset", "cri", `2020-07-21T14:30:15.123456789+02:00 stderr F hello world`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser, entryChan := newTestContainerParser(t, func(c *ContainerParserConfig) {
+				c.Format = tc.format
+			})
+			defer parser.Stop()
+
+			processContainerLines(t, parser, tc.line)
+			expectContainerEntry(t, entryChan, map[string]interface{}{
+				"log":    "hello world",
+				"stream": "stderr",
+			}, timestamp)
+		})
+	}
+}
+
+func TestContainerParserEmptyCRILine(t *testing.T) {
+	parser, entryChan := newTestContainerParser(t, nil)
+	defer parser.Stop()
+
+	processContainerLines(t, parser, `2020-07-21T12:30:15Z stdout F`)
+	expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "",
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC))
+}
+
+func TestContainerParserInvalidLines(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+	}{
+		{"DockerInvalidJSON", `{"log":`},
+		{"DockerInvalidTime", `{"log":"a\n","stream":"stdout","time":"yesterday"}`},
+		{"CRITooShort", `2020-07-21T12:30:15Z stdout`},
+		{"CRIInvalidTime", `yesterday stdout F a`},
+		{"CRIInvalidTag", `2020-07-21T12:30:15Z stdout X a`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser, entryChan := newTestContainerParser(t, func(c *ContainerParserConfig) {
+				c.OnError = "drop"
+			})
+			defer parser.Stop()
+
+			e := entry.New()
+			e.Record = tc.line
+			require.Error(t, parser.Process(context.Background(), e))
+			expectNoContainerEntries(t, entryChan)
+		})
+	}
+}
+
+func TestContainerParserPartials(t *testing.T) {
+	first := time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC)
+
+	cases := []struct {
+		name  string
+		lines []string
+	}{
+		{
+			"Docker",
+			[]string{
+				`{"log":"part1 ","stream":"stdout","time":"2020-07-21T12:30:15Z"}`,
+				`{"log":"part2 ","stream":"stdout","time":"2020-07-21T12:30:16Z"}`,
+				`{"log":"part3\n","stream":"stdout","time":"2020-07-21T12:30:17Z"}`,
+			},
+		},
+		{
+			"CRI",
+			[]string{
+				`2020-07-21T12:30:15Z stdout P part1 `,
+				`2020-07-21T12:30:16Z stdout P part2 `,
+				`2020-07-21T12:30:17Z stdout F part3`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser, entryChan := newTestContainerParser(t, nil)
+			defer parser.Stop()
+
+			processContainerLines(t, parser, tc.lines[:2]...)
+			expectNoContainerEntries(t, entryChan)
+
+			processContainerLines(t, parser, tc.lines[2])
+			expectContainerEntry(t, entryChan, map[string]interface{}{
+				"log":    "part1 part2 part3",
+				"stream": "stdout",
+			}, first)
+		})
+	}
+}
+
+func TestContainerParserInterleavedPartials(t *testing.T) {
+	parser, entryChan := newTestContainerParser(t, func(c *ContainerParserConfig) {
+		c.SourceField = entry.NewLabelField("file_path")
+	})
+	defer parser.Stop()
+
+	process := func(source, line string) {
+		e := entry.New()
+		e.Record = line
+		e.AddLabel("file_path", source)
+		require.NoError(t, parser.Process(context.Background(), e))
+	}
+
+	process("a.log", `2020-07-21T12:30:15Z stdout P a1 `)
+	process("a.log", `2020-07-21T12:30:15Z stderr P e1 `)
+	process("b.log", `2020-07-21T12:30:16Z stdout P b1 `)
+	process("a.log", `2020-07-21T12:30:17Z stdout F a2`)
+	process("b.log", `2020-07-21T12:30:18Z stdout F b2`)
+	process("a.log", `2020-07-21T12:30:19Z stderr F e2`)
+
+	e := expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "a1 a2",
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC))
+	require.Equal(t, "a.log", e.Labels["file_path"])
+
+	e = expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "b1 b2",
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 16, 0, time.UTC))
+	require.Equal(t, "b.log", e.Labels["file_path"])
+
+	expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "e1 e2",
+		"stream": "stderr",
+	}, time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC))
+}
+
+func TestContainerParserMaxLogSize(t *testing.T) {
+	parser, entryChan := newTestContainerParser(t, func(c *ContainerParserConfig) {
+		c.MaxLogSize = 10
+	})
+	defer parser.Stop()
+
+	processContainerLines(t, parser,
+		`2020-07-21T12:30:15Z stdout P 123456`,
+		`2020-07-21T12:30:16Z stdout P 789012`,
+		`2020-07-21T12:30:17Z stdout F 345`,
+	)
+
+	expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "123456789012",
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC))
+	expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "345",
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 17, 0, time.UTC))
+}
+
+func TestContainerParserForceFlush(t *testing.T) {
+	parser, entryChan := newTestContainerParser(t, func(c *ContainerParserConfig) {
+		c.ForceFlushPeriod = operator.Duration{Duration: 100 * time.Millisecond}
+	})
+	defer parser.Stop()
+
+	processContainerLines(t, parser, `2020-07-21T12:30:15Z stdout P never finished`)
+	expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    "never finished",
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC))
+}
+
+func TestContainerParserFlushOnStop(t *testing.T) {
+	parser, entryChan := newTestContainerParser(t, func(c *ContainerParserConfig) {
+		c.ForceFlushPeriod = operator.Duration{}
+	})
+
+	processContainerLines(t, parser, `{"log":"`+strings.Repeat("a", 100)+`","stream":"stdout","time":"2020-07-21T12:30:15Z"}`)
+	expectNoContainerEntries(t, entryChan)
+
+	require.NoError(t, parser.Stop())
+	expectContainerEntry(t, entryChan, map[string]interface{}{
+		"log":    strings.Repeat("a", 100),
+		"stream": "stdout",
+	}, time.Date(2020, 7, 21, 12, 30, 15, 0, time.UTC))
+}
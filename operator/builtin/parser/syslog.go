@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"time"
@@ -23,6 +24,13 @@ func NewSyslogParserConfig(operatorID string) *SyslogParserConfig {
 	}
 }
 
+// Protocols supported by the syslog parser
+const (
+	SyslogProtocolAuto    = "auto"
+	SyslogProtocolRFC3164 = "rfc3164"
+	SyslogProtocolRFC5424 = "rfc5424"
+)
+
 // SyslogParserConfig is the configuration of a syslog parser operator.
 type SyslogParserConfig struct {
 	helper.ParserConfig `yaml:",inline"`
@@ -33,13 +41,23 @@ type SyslogParserConfig struct {
 // Build will build a JSON parser operator.
 func (c SyslogParserConfig) Build(context operator.BuildContext) (operator.Operator, error) {
 	if c.ParserConfig.TimeParser == nil {
-		parseFromField := entry.NewRecordField("timestamp")
+		parseFromField := parsedField(c.ParseTo, "timestamp")
 		c.ParserConfig.TimeParser = &helper.TimeParser{
 			ParseFrom:  &parseFromField,
 			LayoutType: helper.NativeKey,
 		}
 	}
 
+	if c.ParserConfig.SeverityParserConfig == nil {
+		parseFromField := parsedField(c.ParseTo, "severity")
+		c.ParserConfig.SeverityParserConfig = &helper.SeverityParserConfig{
+			ParseFrom: &parseFromField,
+			Preserve:  true,
+			Preset:    "none",
+			Mapping:   syslogSeverityMapping,
+		}
+	}
+
 	parserOperator, err := c.ParserConfig.Build(context)
 	if err != nil {
 		return nil, err
@@ -49,34 +67,74 @@ func (c SyslogParserConfig) Build(context operator.BuildContext) (operator.Opera
 		return nil, fmt.Errorf("missing field 'protocol'")
 	}
 
-	machine, err := buildMachine(c.Protocol)
-	if err != nil {
-		return nil, err
+	switch c.Protocol {
+	case SyslogProtocolAuto, SyslogProtocolRFC3164, SyslogProtocolRFC5424:
+	default:
+		return nil, fmt.Errorf("invalid protocol %s", c.Protocol)
 	}
 
 	syslogParser := &SyslogParser{
 		ParserOperator: parserOperator,
-		machine:        machine,
+		protocol:       c.Protocol,
 	}
 
 	return syslogParser, nil
 }
 
-func buildMachine(protocol string) (syslog.Machine, error) {
-	switch protocol {
-	case "rfc3164":
-		return rfc3164.NewMachine(), nil
-	case "rfc5424":
-		return rfc5424.NewMachine(), nil
-	default:
-		return nil, fmt.Errorf("invalid protocol %s", protocol)
+// syslogSeverityMapping maps the severities of syslog messages to entry severities
+var syslogSeverityMapping = map[interface{}]interface{}{
+	"emergency": 0,
+	"alert":     1,
+	"critical":  2,
+	"error":     3,
+	"warning":   4,
+	"notice":    5,
+	"info":      6,
+	"debug":     7,
+}
+
+// parsedField will return the field that a key of a parsed message is written to
+func parsedField(parseTo entry.Field, key string) entry.Field {
+	if recordField, ok := parseTo.FieldInterface.(entry.RecordField); ok {
+		return entry.Field{FieldInterface: recordField.Child(key)}
 	}
+	return entry.NewRecordField(key)
+}
+
+// buildMachine will build a machine that parses a message. Machines hold the state of a parse, so they can not be shared.
+func buildMachine(protocol string, message []byte) syslog.Machine {
+	if protocol == SyslogProtocolAuto {
+		protocol = detectProtocol(message)
+	}
+
+	if protocol == SyslogProtocolRFC5424 {
+		return rfc5424.NewMachine()
+	}
+	return rfc3164.NewMachine()
+}
+
+// detectProtocol will return rfc5424 if the priority of a message is followed by a version, and rfc3164 otherwise
+func detectProtocol(message []byte) string {
+	end := bytes.IndexByte(message, '>')
+	if len(message) == 0 || message[0] != '<' || end < 0 {
+		return SyslogProtocolRFC3164
+	}
+
+	version := message[end+1:]
+	digits := 0
+	for digits < len(version) && digits < 3 && version[digits] >= '0' && version[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || version[0] == '0' || digits == len(version) || version[digits] != ' ' {
+		return SyslogProtocolRFC3164
+	}
+	return SyslogProtocolRFC5424
 }
 
 // SyslogParser is an operator that parses syslog.
 type SyslogParser struct {
 	helper.ParserOperator
-	machine syslog.Machine
+	protocol string
 }
 
 // Process will parse an entry field as syslog.
@@ -91,7 +149,7 @@ func (s *SyslogParser) parse(value interface{}) (interface{}, error) {
 		return nil, err
 	}
 
-	syslog, err := s.machine.Parse(bytes)
+	syslog, err := buildMachine(s.protocol, bytes).Parse(bytes)
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +227,7 @@ func (s *SyslogParser) toSafeMap(message map[string]interface{}) (map[string]int
 				delete(message, key)
 				continue
 			}
-			message[key] = *v
+			message[key] = structuredDataToMap(*v)
 		default:
 			return nil, fmt.Errorf("key %s has unknown field of type %T", key, v)
 		}
@@ -178,6 +236,19 @@ func (s *SyslogParser) toSafeMap(message map[string]interface{}) (map[string]int
 	return message, nil
 }
 
+// structuredDataToMap will convert structured data elements to nested maps, so that their parameters can be used as fields
+func structuredDataToMap(structuredData map[string]map[string]string) map[string]interface{} {
+	elements := make(map[string]interface{}, len(structuredData))
+	for id, params := range structuredData {
+		element := make(map[string]interface{}, len(params))
+		for name, value := range params {
+			element[name] = value
+		}
+		elements[id] = element
+	}
+	return elements
+}
+
 func toBytes(value interface{}) ([]byte, error) {
 	switch v := value.(type) {
 	case string:
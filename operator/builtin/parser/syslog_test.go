@@ -24,6 +24,7 @@ func TestSyslogParser(t *testing.T) {
 		config            *SyslogParserConfig
 		inputRecord       interface{}
 		expectedTimestamp time.Time
+		expectedSeverity  entry.Severity
 		expectedRecord    interface{}
 	}{
 		{
@@ -35,6 +36,7 @@ func TestSyslogParser(t *testing.T) {
 			}(),
 			"<34>Jan 12 06:30:00 1.2.3.4 apache_server: test message",
 			time.Date(time.Now().Year(), 1, 12, 6, 30, 0, 0, time.UTC),
+			entry.Critical,
 			map[string]interface{}{
 				"appname":  "apache_server",
 				"facility": 4,
@@ -53,6 +55,7 @@ func TestSyslogParser(t *testing.T) {
 			}(),
 			[]byte("<34>Jan 12 06:30:00 1.2.3.4 apache_server: test message"),
 			time.Date(time.Now().Year(), 1, 12, 6, 30, 0, 0, time.UTC),
+			entry.Critical,
 			map[string]interface{}{
 				"appname":  "apache_server",
 				"facility": 4,
@@ -71,6 +74,7 @@ func TestSyslogParser(t *testing.T) {
 			}(),
 			`<86>1 2015-08-05T21:58:59.693Z 192.168.2.132 SecureAuth0 23108 ID52020 [SecureAuth@27389 UserHostAddress="192.168.2.132" Realm="SecureAuth0" UserID="Tester2" PEN="27389"] Found the user for retrieving user's profile`,
 			time.Date(2015, 8, 5, 21, 58, 59, 693000000, time.UTC),
+			entry.Info,
 			map[string]interface{}{
 				"appname":  "SecureAuth0",
 				"facility": 10,
@@ -80,8 +84,8 @@ func TestSyslogParser(t *testing.T) {
 				"priority": 86,
 				"proc_id":  "23108",
 				"severity": 6,
-				"structured_data": map[string]map[string]string{
-					"SecureAuth@27389": {
+				"structured_data": map[string]interface{}{
+					"SecureAuth@27389": map[string]interface{}{
 						"PEN":             "27389",
 						"Realm":           "SecureAuth0",
 						"UserHostAddress": "192.168.2.132",
@@ -91,6 +95,47 @@ func TestSyslogParser(t *testing.T) {
 				"version": 1,
 			},
 		},
+		{
+			"AutoRFC3164",
+			func() *SyslogParserConfig {
+				cfg := basicConfig()
+				cfg.Protocol = "auto"
+				return cfg
+			}(),
+			"<36>Jan 12 06:30:00 1.2.3.4 apache_server: test message",
+			time.Date(time.Now().Year(), 1, 12, 6, 30, 0, 0, time.UTC),
+			entry.Warning,
+			map[string]interface{}{
+				"appname":  "apache_server",
+				"facility": 4,
+				"hostname": "1.2.3.4",
+				"message":  "test message",
+				"priority": 36,
+				"severity": 4,
+			},
+		},
+		{
+			"AutoRFC5424",
+			func() *SyslogParserConfig {
+				cfg := basicConfig()
+				cfg.Protocol = "auto"
+				return cfg
+			}(),
+			`<83>1 2015-08-05T21:58:59.693Z 192.168.2.132 SecureAuth0 23108 ID52020 - Failed to find the user`,
+			time.Date(2015, 8, 5, 21, 58, 59, 693000000, time.UTC),
+			entry.Error,
+			map[string]interface{}{
+				"appname":  "SecureAuth0",
+				"facility": 10,
+				"hostname": "192.168.2.132",
+				"message":  "Failed to find the user",
+				"msg_id":   "ID52020",
+				"priority": 83,
+				"proc_id":  "23108",
+				"severity": 3,
+				"version":  1,
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -118,9 +163,40 @@ func TestSyslogParser(t *testing.T) {
 			case e := <-entryChan:
 				require.Equal(t, e.Record, tc.expectedRecord)
 				require.Equal(t, tc.expectedTimestamp, e.Timestamp)
+				require.Equal(t, tc.expectedSeverity, e.Severity)
 			case <-time.After(time.Second):
 				require.FailNow(t, "Timed out waiting for entry to be processed")
 			}
 		})
 	}
 }
+
+func TestDetectSyslogProtocol(t *testing.T) {
+	cases := []struct {
+		message  string
+		expected string
+	}{
+		{"<34>1 2015-08-05T21:58:59.693Z host app - - - message", SyslogProtocolRFC5424},
+		{"<34>12 2015-08-05T21:58:59.693Z host app - - - message", SyslogProtocolRFC5424},
+		{"<34>Jan 12 06:30:00 host app: message", SyslogProtocolRFC3164},
+		{"<34>0 2015-08-05T21:58:59.693Z host app - - - message", SyslogProtocolRFC3164},
+		{"<34>1000 host app: message", SyslogProtocolRFC3164},
+		{"<34>1", SyslogProtocolRFC3164},
+		{"Jan 12 06:30:00 host app: message", SyslogProtocolRFC3164},
+		{"", SyslogProtocolRFC3164},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.message, func(t *testing.T) {
+			require.Equal(t, tc.expected, detectProtocol([]byte(tc.message)))
+		})
+	}
+}
+
+func TestSyslogParserInvalidProtocol(t *testing.T) {
+	cfg := NewSyslogParserConfig("test_operator_id")
+	cfg.OutputIDs = []string{"output1"}
+	cfg.Protocol = "rfc1234"
+	_, err := cfg.Build(testutil.NewBuildContext(t))
+	require.Error(t, err)
+}
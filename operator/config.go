@@ -26,6 +26,10 @@ type BuildContext struct {
 	PluginRegistry PluginRegistry
 	Database       Database
 	Logger         *zap.SugaredLogger
+
+	// Once indicates that input operators should read all of the currently
+	// available entries a single time, and then finish. See Finisher.
+	Once bool
 }
 
 // Database is a database used to save offsets
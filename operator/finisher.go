@@ -0,0 +1,45 @@
+package operator
+
+import (
+	"fmt"
+
+	"github.com/observiq/carbon/errors"
+)
+
+// Finisher is an operator that can finish producing entries on its own, such as an input
+// operator that has read all of its source. When the agent is run with --once, every input
+// operator must be a Finisher, and the agent exits once all of them have finished.
+type Finisher interface {
+	// Finished returns a channel that is closed once the operator will not produce any more entries.
+	Finished() <-chan struct{}
+}
+
+// InputFinishers returns the input operators of a pipeline as Finishers. An error is returned
+// if any input operator can not finish on its own, with the suggestion to resolve it.
+func InputFinishers(operators []Operator, suggestion string) ([]Finisher, error) {
+	finishers := make([]Finisher, 0)
+	for _, op := range operators {
+		if op.CanProcess() {
+			continue
+		}
+
+		finisher, ok := op.(Finisher)
+		if !ok {
+			return nil, errors.NewError(
+				fmt.Sprintf("operator '%s' does not support running once", op.ID()),
+				suggestion,
+				"operator_type", op.Type(),
+			)
+		}
+		finishers = append(finishers, finisher)
+	}
+	return finishers, nil
+}
+
+// FailureReporter is an operator that can fail to deliver entries without the failure being
+// returned to the operators writing to it, such as an output that sends entries asynchronously.
+type FailureReporter interface {
+	// DeliveryFailures returns the number of entries that could not be delivered. The
+	// number is only final once the operator has been stopped.
+	DeliveryFailures() int64
+}
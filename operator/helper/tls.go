@@ -0,0 +1,221 @@
+package helper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ClientAuthNone, ClientAuthVerify and ClientAuthRequire are the supported client_auth modes
+const (
+	ClientAuthNone    = "none"
+	ClientAuthVerify  = "verify"
+	ClientAuthRequire = "require"
+)
+
+// tlsVersions maps the supported min_version values to tls versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsReloadInterval is the minimum duration between checks for changed certificate files
+var tlsReloadInterval = 5 * time.Second
+
+// TLSConfig is the configuration of a tls server.
+type TLSConfig struct {
+	CertFile   string `json:"cert_file,omitempty"   yaml:"cert_file,omitempty"`
+	KeyFile    string `json:"key_file,omitempty"    yaml:"key_file,omitempty"`
+	CAFile     string `json:"ca_file,omitempty"     yaml:"ca_file,omitempty"`
+	ClientAuth string `json:"client_auth,omitempty" yaml:"client_auth,omitempty"`
+	MinVersion string `json:"min_version,omitempty" yaml:"min_version,omitempty"`
+
+	// ClientCertificateLabels enables labeling entries with the subject of the client certificate
+	ClientCertificateLabels bool `json:"client_certificate_labels,omitempty" yaml:"client_certificate_labels,omitempty"`
+}
+
+// Build will build a tls server config. The certificate and CA files are reloaded when they
+// change, so that renewed certificates are used for new connections without a restart.
+func (c TLSConfig) Build(logger *zap.SugaredLogger) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("tls requires both cert_file and key_file")
+	}
+
+	clientAuth := tls.NoClientCert
+	switch c.ClientAuth {
+	case ClientAuthNone, "":
+	case ClientAuthVerify:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid client_auth '%s'", c.ClientAuth)
+	}
+
+	if clientAuth != tls.NoClientCert && c.CAFile == "" {
+		return nil, fmt.Errorf("client_auth '%s' requires ca_file", c.ClientAuth)
+	}
+
+	if c.ClientCertificateLabels && clientAuth == tls.NoClientCert {
+		return nil, fmt.Errorf("client_certificate_labels requires client_auth '%s' or '%s'", ClientAuthVerify, ClientAuthRequire)
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if c.MinVersion != "" {
+		var ok bool
+		if minVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			return nil, fmt.Errorf("invalid min_version '%s'", c.MinVersion)
+		}
+	}
+
+	reloader := &tlsReloader{
+		TLSConfig:  c,
+		clientAuth: clientAuth,
+		minVersion: minVersion,
+		logger:     logger,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         minVersion,
+		GetConfigForClient: reloader.getConfigForClient,
+	}, nil
+}
+
+// tlsReloader builds the tls config used for each connection, and rebuilds it when the files it was loaded from change
+type tlsReloader struct {
+	TLSConfig
+	clientAuth tls.ClientAuthType
+	minVersion uint16
+	logger     *zap.SugaredLogger
+
+	mux         sync.Mutex
+	config      *tls.Config
+	modTimes    []time.Time
+	lastChecked time.Time
+}
+
+func (r *tlsReloader) files() []string {
+	files := []string{r.CertFile, r.KeyFile}
+	if r.CAFile != "" {
+		files = append(files, r.CAFile)
+	}
+	return files
+}
+
+// load will build the tls config from the current contents of the files
+func (r *tlsReloader) load() error {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %s", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   r.clientAuth,
+		MinVersion:   r.minVersion,
+	}
+
+	if r.CAFile != "" {
+		ca, err := ioutil.ReadFile(r.CAFile)
+		if err != nil {
+			return fmt.Errorf("read ca_file: %s", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("ca_file does not contain any PEM encoded certificates")
+		}
+	}
+
+	r.config = config
+	r.modTimes = modTimes
+	r.lastChecked = time.Now()
+	return nil
+}
+
+func (r *tlsReloader) statFiles() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("stat tls file: %s", err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+// getConfigForClient will return the config for a new connection, reloading it first if its files changed
+func (r *tlsReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if time.Since(r.lastChecked) < tlsReloadInterval {
+		return r.config, nil
+	}
+	r.lastChecked = time.Now()
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		r.logger.Warnw("Failed to check tls files for changes", zap.Error(err))
+		return r.config, nil
+	}
+	if timesEqual(modTimes, r.modTimes) {
+		return r.config, nil
+	}
+
+	// Keep using the previous files if the new files are invalid, such as when only one has been replaced so far
+	if err := r.load(); err != nil {
+		r.logger.Warnw("Failed to reload tls files. Using the previous files", zap.Error(err))
+		return r.config, nil
+	}
+	r.logger.Infow("Reloaded tls files", "cert_file", r.CertFile)
+	return r.config, nil
+}
+
+func timesEqual(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientCertificateLabels returns the labels describing the subject of the verified client certificate of a connection
+func ClientCertificateLabels(state tls.ConnectionState) map[string]string {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	subject := state.VerifiedChains[0][0].Subject
+	labels := map[string]string{
+		"tls_client_subject": subject.String(),
+	}
+	if subject.CommonName != "" {
+		labels["tls_client_common_name"] = subject.CommonName
+	}
+	if len(subject.Organization) != 0 {
+		labels["tls_client_organization"] = strings.Join(subject.Organization, ",")
+	}
+	return labels
+}
@@ -0,0 +1,216 @@
+package helper
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/observiq/carbon/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestTLSConfigBuild(t *testing.T) {
+	files := testutil.NewTLSFiles(t, "client")
+
+	cases := []struct {
+		name      string
+		config    TLSConfig
+		expectErr bool
+	}{
+		{
+			"Default",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile},
+			false,
+		},
+		{
+			"MutualTLS",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile, CAFile: files.CAFile, ClientAuth: "require", MinVersion: "1.3", ClientCertificateLabels: true},
+			false,
+		},
+		{
+			"MissingKeyFile",
+			TLSConfig{CertFile: files.ServerCertFile},
+			true,
+		},
+		{
+			"MissingCertFile",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerCertFile + ".missing"},
+			true,
+		},
+		{
+			"MismatchedKey",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ClientKeyFile},
+			true,
+		},
+		{
+			"InvalidClientAuth",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile, CAFile: files.CAFile, ClientAuth: "always"},
+			true,
+		},
+		{
+			"ClientAuthWithoutCA",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile, ClientAuth: "verify"},
+			true,
+		},
+		{
+			"InvalidCA",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile, CAFile: files.ServerKeyFile, ClientAuth: "verify"},
+			true,
+		},
+		{
+			"LabelsWithoutClientAuth",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile, ClientCertificateLabels: true},
+			true,
+		},
+		{
+			"InvalidMinVersion",
+			TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile, MinVersion: "2.0"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.config.Build(zaptest.NewLogger(t).Sugar())
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// tlsHandshake will complete a handshake between a server using the server config and a client using
+// the client config, returning the connection state of the server and the error of the client
+func tlsHandshake(t *testing.T, serverConfig, clientConfig *tls.Config) (tls.ConnectionState, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	stateChan := make(chan tls.ConnectionState, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tlsConn := conn.(*tls.Conn)
+		_ = tlsConn.SetDeadline(time.Now().Add(time.Second))
+		_ = tlsConn.Handshake()
+		stateChan <- tlsConn.ConnectionState()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+	if err == nil {
+		// With tls 1.3, a rejected client certificate is only reported once the client reads.
+		// Otherwise, the server closes the connection after the handshake.
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		if err == io.EOF {
+			err = nil
+		}
+		conn.Close()
+	}
+	return <-stateChan, err
+}
+
+func TestTLSConfigClientAuth(t *testing.T) {
+	files := testutil.NewTLSFiles(t, "client.example.com")
+
+	cases := []struct {
+		name           string
+		clientAuth     string
+		withClientCert bool
+		expectErr      bool
+		expectedLabels map[string]string
+	}{
+		{"NoneWithoutCertificate", "none", false, false, nil},
+		{"VerifyWithoutCertificate", "verify", false, false, nil},
+		{"RequireWithoutCertificate", "require", false, true, nil},
+		{
+			"RequireWithCertificate",
+			"require",
+			true,
+			false,
+			map[string]string{
+				"tls_client_subject":      "CN=client.example.com,O=carbon",
+				"tls_client_common_name":  "client.example.com",
+				"tls_client_organization": "carbon",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := TLSConfig{
+				CertFile:   files.ServerCertFile,
+				KeyFile:    files.ServerKeyFile,
+				CAFile:     files.CAFile,
+				ClientAuth: tc.clientAuth,
+			}
+			serverConfig, err := config.Build(zaptest.NewLogger(t).Sugar())
+			require.NoError(t, err)
+
+			clientConfig := files.ClientConfigWithoutCertificate()
+			if tc.withClientCert {
+				clientConfig = files.ClientConfig(t)
+			}
+
+			state, err := tlsHandshake(t, serverConfig, clientConfig)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLabels, ClientCertificateLabels(state))
+		})
+	}
+}
+
+func TestTLSConfigReload(t *testing.T) {
+	defer func(interval time.Duration) { tlsReloadInterval = interval }(tlsReloadInterval)
+	tlsReloadInterval = 0
+
+	files := testutil.NewTLSFiles(t, "client")
+	config := TLSConfig{CertFile: files.ServerCertFile, KeyFile: files.ServerKeyFile}
+	serverConfig, err := config.Build(zaptest.NewLogger(t).Sugar())
+	require.NoError(t, err)
+
+	serverName := func() string {
+		conn, err := tls.Dial("tcp", listenTLS(t, serverConfig), files.ClientConfigWithoutCertificate())
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	require.Equal(t, "localhost", serverName())
+
+	files.WriteServerCertificate(t, "renewed")
+	require.Equal(t, "renewed", serverName())
+
+	// Invalid files are not used, such as when only the certificate has been replaced so far
+	require.NoError(t, ioutil.WriteFile(files.ServerKeyFile, []byte("invalid"), 0600))
+	require.Equal(t, "renewed", serverName())
+}
+
+// listenTLS will accept and complete the handshake of a single connection, returning the address of the listener
+func listenTLS(t *testing.T, config *tls.Config) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+	return listener.Addr().String()
+}
@@ -15,22 +15,27 @@ type Config []Params
 
 // BuildPipeline will build a pipeline from the config.
 func (c Config) BuildPipeline(context operator.BuildContext) (*Pipeline, error) {
-	operatorConfigs, err := c.buildOperatorConfigs(context.PluginRegistry)
+	operators, err := c.BuildOperators(context)
 	if err != nil {
 		return nil, err
 	}
 
-	operators, err := c.buildOperators(operatorConfigs, context)
+	pipeline, err := NewPipeline(operators)
 	if err != nil {
 		return nil, err
 	}
 
-	pipeline, err := NewPipeline(operators)
+	return pipeline, nil
+}
+
+// BuildOperators will build the operators of the config, without connecting them in a pipeline.
+func (c Config) BuildOperators(context operator.BuildContext) ([]operator.Operator, error) {
+	operatorConfigs, err := c.buildOperatorConfigs(context.PluginRegistry)
 	if err != nil {
 		return nil, err
 	}
 
-	return pipeline, nil
+	return c.buildOperators(operatorConfigs, context)
 }
 
 func (c Config) buildOperators(operatorConfigs []operator.Config, context operator.BuildContext) ([]operator.Operator, error) {
@@ -54,6 +54,16 @@ func (p *Pipeline) Stop() {
 	p.running = false
 }
 
+// Operators returns the operators in a pipeline in topological order.
+func (p *Pipeline) Operators() []operator.Operator {
+	sortedNodes, _ := topo.Sort(p.Graph)
+	operators := make([]operator.Operator, 0, len(sortedNodes))
+	for _, node := range sortedNodes {
+		operators = append(operators, node.(OperatorNode).Operator())
+	}
+	return operators
+}
+
 // MarshalDot will encode the pipeline as a dot graph.
 func (p *Pipeline) MarshalDot() ([]byte, error) {
 	return dot.Marshal(p.Graph, "G", "", " ")